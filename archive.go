@@ -0,0 +1,181 @@
+package chapointdat
+
+import (
+	"archive/zip"
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// archiveName matches the product number at the start of a file name such as
+// Prod195_3578_3.dat.
+var archiveName = regexp.MustCompile(`(?i)^prod(\d+)_`)
+
+// ArchiveRun is the files of one run found by WalkArchive.
+type ArchiveRun struct {
+	Run      int
+	ProdDate time.Time
+	// Paths are the files of the run, ordered by part number.
+	Paths []string
+}
+
+// WalkArchive calls fn for each run of the snapshots in dir and its
+// subdirectories, in run order, with a new Reader for opts. fn typically calls
+// ExtractAll with the paths of the run. Zip and .dat files are grouped into
+// runs by their header, so that the parts of a split snapshot are passed
+// together. Files named for a product other than the snapshot, such as
+// Prod198 updates, are ignored, and files which cannot be opened or do not
+// start with a valid header are passed to errH and skipped. The header of a
+// zip is that of its first .dat entry chosen by any WithEntryFilter of opts.
+// An error from fn ends the walk.
+func WalkArchive(dir string, fn func(run ArchiveRun, r *Reader) error, errH func(err error), opts ...Opt) error {
+	filter := NewReader(opts...)
+	if filter.entryFilter != nil {
+		if err := filter.entryFilter.Validate(); err != nil {
+			return err
+		}
+	}
+	runs := map[int]*ArchiveRun{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if d.IsDir() || ext != ".zip" && ext != ".dat" {
+			return nil
+		}
+		if m := archiveName.FindStringSubmatch(d.Name()); m != nil {
+			if product, _ := strconv.Atoi(m[1]); Product(product) != ProductAppointmentSnapshot {
+				return nil
+			}
+		}
+		h, err := fileHeader(path, filter.readEntry)
+		if err != nil {
+			errH(fmt.Errorf("error skipping %s: %w", path, err))
+			return nil
+		}
+		run, ok := runs[h.Run]
+		if !ok {
+			run = &ArchiveRun{Run: h.Run, ProdDate: h.ProdDate}
+			runs[h.Run] = run
+		}
+		run.Paths = append(run.Paths, path)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error walking %s: %w", dir, err)
+	}
+	for _, n := range slices.Sorted(maps.Keys(runs)) {
+		run := runs[n]
+		slices.SortFunc(run.Paths, func(a, b string) int {
+			return cmp.Or(cmp.Compare(filePartNumber(a), filePartNumber(b)), cmp.Compare(a, b))
+		})
+		if err := fn(*run, NewReader(opts...)); err != nil {
+			return fmt.Errorf("error processing run %d: %w", n, err)
+		}
+	}
+	return nil
+}
+
+// walkRuns extracts every run of the snapshots in dir, as WalkArchive, passing
+// the records to write and calling endRun once each run has been read. opts
+// must not include WithRecordHandler.
+func walkRuns(dir string, write func(rec Record) error, endRun func() error, errH func(err error), opts []Opt) error {
+	return WalkArchive(dir, func(run ArchiveRun, r *Reader) error {
+		if _, err := r.ExtractAll(run.Paths, len(run.Paths), errH); err != nil {
+			return err
+		}
+		return endRun()
+	}, errH, append(slices.Clone(opts), WithRecordHandler(write))...)
+}
+
+// runPair is the run being read, from its header, and the run before it, for
+// trackers comparing consecutive runs.
+type runPair struct {
+	run              int
+	prodDate         time.Time
+	previousRun      int
+	previousProdDate time.Time
+}
+
+func (p *runPair) header(h *Header) {
+	p.run, p.prodDate = h.Run, h.ProdDate
+}
+
+// endRun makes the run read the previous run.
+func (p *runPair) endRun() {
+	p.previousRun, p.previousProdDate = p.run, p.prodDate
+}
+
+// fileHeader reads the header of a .dat file, or of the first .dat entry of a
+// zip chosen by readEntry, or else its first entry chosen.
+func fileHeader(path string, readEntry func(name string) bool) (Header, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Header{}, err
+	}
+	defer func() { _ = f.Close() }()
+	magic := make([]byte, len(zipMagic))
+	var rd io.Reader = f
+	if _, err := io.ReadFull(f, magic); err == nil && string(magic) == zipMagic {
+		fi, err := f.Stat()
+		if err != nil {
+			return Header{}, err
+		}
+		z, err := zip.NewReader(f, fi.Size())
+		if err != nil {
+			return Header{}, err
+		}
+		var entry *zip.File
+		for _, zf := range z.File {
+			if zf.FileInfo().IsDir() || !readEntry(zf.Name) {
+				continue
+			}
+			if strings.EqualFold(filepath.Ext(zf.Name), ".dat") {
+				entry = zf
+				break
+			}
+			if entry == nil {
+				entry = zf
+			}
+		}
+		if entry == nil {
+			return Header{}, errors.New("no entries to read in zip")
+		}
+		rc, err := entry.Open()
+		if err != nil {
+			return Header{}, err
+		}
+		defer func() { _ = rc.Close() }()
+		rd = rc
+	} else if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return Header{}, err
+	}
+	scan := bufio.NewScanner(rd)
+	if !scan.Scan() {
+		return Header{}, cmp.Or(scan.Err(), error(io.ErrUnexpectedEOF))
+	}
+	// a Reader without options, so that old snapshots are not stale
+	return (&Reader{}).headerRow(scan.Bytes())
+}
+
+// filePartNumber returns the part number of a file name, or 0.
+func filePartNumber(path string) int {
+	m := partNumber.FindStringSubmatch(filepath.Base(path))
+	if m == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(m[1])
+	return n
+}
@@ -0,0 +1,102 @@
+package chapointdat
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_WalkArchive(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run := func(n string) string {
+		return strings.Replace(testSnapshot(testCompanyLine), "0195", n, 1)
+	}
+	zipped, err := os.ReadFile(writeTestZip(t, "z.zip", run("0001")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	write("2020/Prod195_0001.zip", string(zipped))
+	write("2021/Prod195_0002_2.dat", run("0002"))
+	write("2021/Prod195_0002_1.dat", run("0002"))
+	write("snapshot.dat", run("0003"))
+	write("Prod198_0004.dat", run("0004"))
+	write("Prod195_0005.zip", "PK\x03\x04 truncated")
+	write("notes.txt", "not a snapshot")
+
+	var runs []ArchiveRun
+	var skipped []error
+	err = WalkArchive(dir, func(run ArchiveRun, r *Reader) error {
+		runs = append(runs, run)
+		_, err := r.ExtractAll(run.Paths, 1, func(err error) {})
+		return err
+	}, func(err error) { skipped = append(skipped, err) }, WithMaxHeaderAge(1))
+	if !errors.Is(err, ErrStaleSnapshot) {
+		t.Fatalf("expected the Reader options to be used got %v", err)
+	}
+	if len(runs) != 1 || len(skipped) != 1 || !strings.Contains(skipped[0].Error(), "Prod195_0005.zip") {
+		t.Fatalf("unexpected runs %v and skipped %v", runs, skipped)
+	}
+
+	runs = nil
+	if err := WalkArchive(dir, func(run ArchiveRun, r *Reader) error {
+		runs = append(runs, run)
+		return nil
+	}, func(err error) {}); err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 3 || runs[0].Run != 1 || runs[1].Run != 2 || runs[2].Run != 3 {
+		t.Fatalf("unexpected runs %v", runs)
+	}
+	if len(runs[1].Paths) != 2 || !strings.HasSuffix(runs[1].Paths[0], "Prod195_0002_1.dat") {
+		t.Errorf("expected parts in order got %v", runs[1].Paths)
+	}
+}
+
+func Test_WalkArchive_Entries(t *testing.T) {
+	dir := t.TempDir()
+	f, err := os.Create(filepath.Join(dir, "Prod195_0006.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, e := range [][2]string{
+		{"README.txt", "Appointments data\n"},
+		{"other/Prod195_0007_1.dat", strings.Replace(testSnapshot(testCompanyLine), "0195", "0007", 1)},
+		{"data/Prod195_0006_1.dat", strings.Replace(testSnapshot(testCompanyLine), "0195", "0006", 1)},
+	} {
+		w, err := zw.Create(e[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.WriteString(w, e[1])
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	var runs []int
+	if err := WalkArchive(dir, func(run ArchiveRun, r *Reader) error {
+		runs = append(runs, run.Run)
+		return nil
+	}, func(err error) { t.Error(err) }, WithEntryFilter(EntryFilter{Exclude: []string{"other/*"}})); err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0] != 6 {
+		t.Errorf("expected the run of the first .dat entry not excluded got %v", runs)
+	}
+}
@@ -0,0 +1,264 @@
+package arrowipc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	ch "github.com/richardjennings/chapointdat"
+)
+
+const (
+	testHeaderLine  = "DDDDSNAP019520240101"
+	testCompanyLine = "000000841D                      00010019A. WEST & PARTNERS<"
+	testPersonLine  = "004638192201024407940002        19910915        NP25 3DZ194509          0093MR<HANS<KJAERSGAARD<<<<1 AGINCOURT STREET<<MONMOUTH<<WALES<MARKETING DIRECTOR<DANISH<ENGLAND<"
+)
+
+// testOtherLine is testPersonLine of another person without an appointment
+// date.
+var testOtherLine = strings.NewReplacer("024407940002", "024407940003", "19910915", "        ", "KJAERSGAARD", "SMITHSONIAN").Replace(testPersonLine)
+
+func testSnapshot(lines ...string) string {
+	return testHeaderLine + "\n" + strings.Join(lines, "\n") + "\n" + fmt.Sprintf("99999999%08d\n", len(lines))
+}
+
+// fbTest reads a table of a FlatBuffer, checking offsets are aligned.
+type fbTest struct {
+	t   *testing.T
+	buf []byte
+	pos int
+}
+
+func fbRoot(t *testing.T, buf []byte) fbTest {
+	return fbTest{t: t, buf: buf, pos: int(binary.LittleEndian.Uint32(buf))}
+}
+
+func (f fbTest) field(slot int, align int) (int, bool) {
+	vt := f.pos - int(int32(binary.LittleEndian.Uint32(f.buf[f.pos:])))
+	if f.pos%4 != 0 || vt%2 != 0 {
+		f.t.Fatalf("unaligned table %d or vtable %d", f.pos, vt)
+	}
+	if 4+2*slot >= int(binary.LittleEndian.Uint16(f.buf[vt:])) {
+		return 0, false
+	}
+	off := int(binary.LittleEndian.Uint16(f.buf[vt+4+2*slot:]))
+	if off == 0 {
+		return 0, false
+	}
+	if (f.pos+off)%align != 0 {
+		f.t.Fatalf("unaligned field %d at %d", slot, f.pos+off)
+	}
+	return f.pos + off, true
+}
+
+func (f fbTest) int(slot, size int) int64 {
+	p, ok := f.field(slot, size)
+	if !ok {
+		return 0
+	}
+	switch size {
+	case 1:
+		return int64(int8(f.buf[p]))
+	case 2:
+		return int64(int16(binary.LittleEndian.Uint16(f.buf[p:])))
+	case 4:
+		return int64(int32(binary.LittleEndian.Uint32(f.buf[p:])))
+	}
+	return int64(binary.LittleEndian.Uint64(f.buf[p:]))
+}
+
+func (f fbTest) ref(slot int) int {
+	p, ok := f.field(slot, 4)
+	if !ok {
+		f.t.Fatalf("missing field %d", slot)
+	}
+	return p + int(binary.LittleEndian.Uint32(f.buf[p:]))
+}
+
+func (f fbTest) table(slot int) fbTest {
+	return fbTest{t: f.t, buf: f.buf, pos: f.ref(slot)}
+}
+
+func (f fbTest) string(slot int) string {
+	p := f.ref(slot)
+	n := int(binary.LittleEndian.Uint32(f.buf[p:]))
+	return string(f.buf[p+4 : p+4+n])
+}
+
+func (f fbTest) tables(slot int) []fbTest {
+	p := f.ref(slot)
+	var tables []fbTest
+	for i := range int(binary.LittleEndian.Uint32(f.buf[p:])) {
+		e := p + 4 + 4*i
+		tables = append(tables, fbTest{t: f.t, buf: f.buf, pos: e + int(binary.LittleEndian.Uint32(f.buf[e:]))})
+	}
+	return tables
+}
+
+func (f fbTest) structs(slot int) [][2]int64 {
+	p := f.ref(slot)
+	if (p+4)%8 != 0 {
+		f.t.Fatalf("unaligned structs at %d", p+4)
+	}
+	var structs [][2]int64
+	for i := range int(binary.LittleEndian.Uint32(f.buf[p:])) {
+		e := p + 4 + 16*i
+		structs = append(structs, [2]int64{int64(binary.LittleEndian.Uint64(f.buf[e:])), int64(binary.LittleEndian.Uint64(f.buf[e+8:]))})
+	}
+	return structs
+}
+
+type testBatch struct {
+	rows    int64
+	nodes   [][2]int64
+	buffers [][]byte
+}
+
+// readTestStream reads the schema fields and record batches of a stream.
+func readTestStream(t *testing.T, b []byte) (fields []fbTest, batches []testBatch) {
+	for {
+		if len(b) < 8 || !bytes.Equal(b[:4], continuation) {
+			t.Fatalf("expected a message or end of stream got %x", b)
+		}
+		n := int(binary.LittleEndian.Uint32(b[4:]))
+		if n == 0 {
+			if len(b) != 8 {
+				t.Fatalf("unexpected data after the end of stream")
+			}
+			return fields, batches
+		}
+		if (8+n)%8 != 0 {
+			t.Fatalf("unpadded metadata of %d bytes", n)
+		}
+		msg := fbRoot(t, b[8:8+n])
+		bodyLength := int(msg.int(3, 8))
+		body := b[8+n : 8+n+bodyLength]
+		b = b[8+n+bodyLength:]
+		if msg.int(0, 2) != metadataVersionV5 {
+			t.Fatalf("unexpected version %d", msg.int(0, 2))
+		}
+		switch msg.int(1, 1) {
+		case messageHeaderSchema:
+			if fields != nil || bodyLength != 0 {
+				t.Fatal("unexpected schema")
+			}
+			fields = msg.table(2).tables(1)
+		case messageHeaderRecordBatch:
+			if fields == nil {
+				t.Fatal("expected the schema first")
+			}
+			rb := msg.table(2)
+			batch := testBatch{rows: rb.int(0, 8), nodes: rb.structs(1)}
+			for _, buf := range rb.structs(2) {
+				if buf[0]%8 != 0 {
+					t.Fatalf("unaligned buffer at %d", buf[0])
+				}
+				batch.buffers = append(batch.buffers, body[buf[0]:buf[0]+buf[1]])
+			}
+			batches = append(batches, batch)
+		default:
+			t.Fatalf("unexpected message %d", msg.int(1, 1))
+		}
+	}
+}
+
+// column returns the validity, offsets, if any, and data buffers of field i.
+func (b testBatch) column(fields []fbTest, i int) [][]byte {
+	j := 0
+	for _, f := range fields[:i] {
+		j += 2
+		if f.int(2, 1) == typeUtf8 {
+			j++
+		}
+	}
+	if fields[i].int(2, 1) == typeUtf8 {
+		return b.buffers[j : j+3]
+	}
+	return b.buffers[j : j+2]
+}
+
+func (b testBatch) strings(fields []fbTest, i int) []string {
+	c := b.column(fields, i)
+	var values []string
+	for row := range int(b.rows) {
+		values = append(values, string(c[2][binary.LittleEndian.Uint32(c[1][4*row:]):binary.LittleEndian.Uint32(c[1][4*row+4:])]))
+	}
+	return values
+}
+
+func testFieldIndex(t *testing.T, fields []fbTest, name string) int {
+	for i, f := range fields {
+		if f.string(0) == name {
+			return i
+		}
+	}
+	t.Fatalf("no field %s", name)
+	return 0
+}
+
+func Test_Writer(t *testing.T) {
+	var b bytes.Buffer
+	w, err := NewWriter(&b, ch.RecordKindPerson, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := ch.NewReader(ch.WithRecordHandler(w.Write))
+	if _, err := r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine, testPersonLine, testOtherLine)), "-", func(err error) { t.Error(err) }); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fields, batches := readTestStream(t, b.Bytes())
+	if len(fields) != len(ch.PersonSchema().Fields) {
+		t.Fatalf("expected %d fields got %d", len(ch.PersonSchema().Fields), len(fields))
+	}
+	date := testFieldIndex(t, fields, "AppointmentDate")
+	if f := fields[date]; f.int(2, 1) != typeDate || f.table(3).int(0, 2) != dateUnitDay || f.int(1, 1) != 1 || len(f.tables(5)) != 0 {
+		t.Error("expected a nullable date32 appointment date")
+	}
+	if f := fields[testFieldIndex(t, fields, "Surname")]; f.int(2, 1) != typeUtf8 || f.int(1, 1) != 0 {
+		t.Error("expected a utf8 surname")
+	}
+	if len(batches) != 2 {
+		t.Fatalf("expected a batch of each person got %d", len(batches))
+	}
+	surname := testFieldIndex(t, fields, "Surname")
+	if s := batches[0].strings(fields, surname); len(s) != 1 || s[0] != "KJAERSGAARD" {
+		t.Errorf("unexpected surnames %v", s)
+	}
+	if s := batches[1].strings(fields, surname); len(s) != 1 || s[0] != "SMITHSONIAN" {
+		t.Errorf("unexpected surnames %v", s)
+	}
+	c := batches[0].column(fields, date)
+	days := int32(time.Date(1991, 9, 15, 0, 0, 0, 0, time.UTC).Unix() / 86400)
+	if batches[0].nodes[date] != [2]int64{1, 0} || len(c[0]) != 0 || int32(binary.LittleEndian.Uint32(c[1])) != days {
+		t.Errorf("unexpected appointment date %v %x", batches[0].nodes[date], c)
+	}
+	// a blank date is null
+	if c := batches[1].column(fields, date); batches[1].nodes[date] != [2]int64{1, 1} || len(c[0]) != 1 || c[0][0]&1 != 0 {
+		t.Errorf("expected a null appointment date got %v %x", batches[1].nodes[date], c)
+	}
+
+	if _, err := NewWriter(&b, ch.RecordKindHeader, 0); err == nil {
+		t.Error("expected an error for headers")
+	}
+}
+
+func Test_Writer_Empty(t *testing.T) {
+	var b bytes.Buffer
+	w, err := NewWriter(&b, ch.RecordKindCompany, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if fields, batches := readTestStream(t, b.Bytes()); len(fields) != len(ch.CompanySchema().Fields) || len(batches) != 0 {
+		t.Errorf("expected only a schema got %d fields and %d batches", len(fields), len(batches))
+	}
+}
@@ -0,0 +1,174 @@
+package arrowipc
+
+import (
+	"cmp"
+	"encoding/binary"
+	"slices"
+)
+
+// The metadata of Arrow IPC messages is encoded as FlatBuffers. Only what the
+// Message, Schema and RecordBatch tables need is implemented: tables of
+// scalars and references, strings, vectors of tables and vectors of 16 byte
+// structs. Objects are written after the object referring to them, so that
+// every offset is forward, and aligned from the start of the buffer.
+
+type (
+	// fbObject is written at the end of a fbBuilder, returning its position.
+	fbObject interface {
+		write(b *fbBuilder) int
+	}
+
+	// fbTable is a table of fields, each in the slot of its field id.
+	fbTable []fbField
+
+	fbField struct {
+		slot int
+		// scalar is the little endian value of a scalar field, or ref the
+		// object referred to.
+		scalar []byte
+		ref    fbObject
+	}
+
+	fbString string
+
+	// fbTables is a vector of tables.
+	fbTables []fbObject
+
+	// fbStructs is a vector of structs of two int64s, such as Buffer and
+	// FieldNode.
+	fbStructs [][2]int64
+
+	fbBuilder struct {
+		buf []byte
+	}
+)
+
+// finishFlatBuffer returns root encoded as a FlatBuffer.
+func finishFlatBuffer(root fbObject) []byte {
+	b := &fbBuilder{buf: make([]byte, 4, 256)}
+	pos := root.write(b)
+	binary.LittleEndian.PutUint32(b.buf[0:], uint32(pos))
+	return b.buf
+}
+
+func fbInt8(slot int, v int8) fbField {
+	return fbField{slot: slot, scalar: []byte{byte(v)}}
+}
+
+func fbBool(slot int, v bool) fbField {
+	if v {
+		return fbInt8(slot, 1)
+	}
+	return fbInt8(slot, 0)
+}
+
+func fbInt16(slot int, v int16) fbField {
+	return fbField{slot: slot, scalar: binary.LittleEndian.AppendUint16(nil, uint16(v))}
+}
+
+func fbInt32(slot int, v int32) fbField {
+	return fbField{slot: slot, scalar: binary.LittleEndian.AppendUint32(nil, uint32(v))}
+}
+
+func fbInt64(slot int, v int64) fbField {
+	return fbField{slot: slot, scalar: binary.LittleEndian.AppendUint64(nil, uint64(v))}
+}
+
+func fbRef(slot int, o fbObject) fbField {
+	return fbField{slot: slot, ref: o}
+}
+
+func (b *fbBuilder) align(n int) {
+	for len(b.buf)%n != 0 {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+func (b *fbBuilder) putOffset(at, to int) {
+	binary.LittleEndian.PutUint32(b.buf[at:], uint32(to-at))
+}
+
+func (t fbTable) write(b *fbBuilder) int {
+	// fields are laid out largest first, so each is aligned to its size
+	fields := slices.SortedStableFunc(slices.Values(t), func(a, b fbField) int {
+		return cmp.Compare(b.size(), a.size())
+	})
+	slots := 0
+	offsets := make([]int, len(fields))
+	size := 4
+	for i, f := range fields {
+		slots = max(slots, f.slot+1)
+		for size%f.size() != 0 {
+			size++
+		}
+		offsets[i] = size
+		size += f.size()
+	}
+	for size%4 != 0 {
+		size++
+	}
+
+	b.align(2)
+	vtable := len(b.buf)
+	vt := make([]uint16, 2+slots)
+	vt[0], vt[1] = uint16(4+2*slots), uint16(size)
+	for i, f := range fields {
+		vt[2+f.slot] = uint16(offsets[i])
+	}
+	for _, v := range vt {
+		b.buf = binary.LittleEndian.AppendUint16(b.buf, v)
+	}
+	b.align(8)
+	pos := len(b.buf)
+	b.buf = append(b.buf, make([]byte, size)...)
+	binary.LittleEndian.PutUint32(b.buf[pos:], uint32(int32(pos-vtable)))
+	for i, f := range fields {
+		copy(b.buf[pos+offsets[i]:], f.scalar)
+	}
+	for i, f := range fields {
+		if f.ref != nil {
+			b.putOffset(pos+offsets[i], f.ref.write(b))
+		}
+	}
+	return pos
+}
+
+func (f fbField) size() int {
+	if f.ref != nil {
+		return 4
+	}
+	return len(f.scalar)
+}
+
+func (s fbString) write(b *fbBuilder) int {
+	b.align(4)
+	pos := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(s)))
+	b.buf = append(append(b.buf, s...), 0)
+	return pos
+}
+
+func (v fbTables) write(b *fbBuilder) int {
+	b.align(4)
+	pos := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(v)))
+	b.buf = append(b.buf, make([]byte, 4*len(v))...)
+	for i, o := range v {
+		b.putOffset(pos+4+4*i, o.write(b))
+	}
+	return pos
+}
+
+func (v fbStructs) write(b *fbBuilder) int {
+	// the structs after the length are aligned to 8
+	for len(b.buf)%8 != 4 {
+		b.buf = append(b.buf, 0)
+	}
+	pos := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(v)))
+	for _, s := range v {
+		b.buf = binary.LittleEndian.AppendUint64(b.buf, uint64(s[0]))
+		b.buf = binary.LittleEndian.AppendUint64(b.buf, uint64(s[1]))
+	}
+	return pos
+}
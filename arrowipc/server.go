@@ -0,0 +1,123 @@
+package arrowipc
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+
+	ch "github.com/richardjennings/chapointdat"
+)
+
+// Server serves the records of snapshots as Arrow IPC streams, read from the
+// snapshot files for each request:
+//
+//	GET /companies
+//	GET /persons?select=person.Postcode startswith "WA"
+//	GET /search?q=bee research&limit=10
+//
+// with select an optional expression of chapointdat.ParseSelect. /search
+// answers from Names as JSON lines of chapointdat.NameMatch, and is not found
+// if Names is nil. A stream which cannot be read to the end is cut short
+// without its end of stream marker, so that clients report an error rather
+// than read part of the data.
+type Server struct {
+	// Paths are the snapshot files read, as by Reader.ExtractAll.
+	Paths []string
+	// Opts are the options of the Reader of each request, which must not
+	// include handlers of records.
+	Opts []ch.Opt
+	// BatchSize is the number of rows in each record batch, DefaultBatchSize
+	// by default.
+	BatchSize int
+	// Names is the name index queried by /search.
+	Names *ch.NameIndex
+	// ErrorHandler is passed the lines which could not be read. They are
+	// ignored by default.
+	ErrorHandler func(err error)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var kind ch.RecordKind
+	switch req.URL.Path {
+	case "/companies":
+		kind = ch.RecordKindCompany
+	case "/persons":
+		kind = ch.RecordKindPerson
+	case "/search":
+		s.search(w, req)
+		return
+	default:
+		http.NotFound(w, req)
+		return
+	}
+	aw, err := NewWriter(w, kind, s.BatchSize)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	opts := append(slices.Clone(s.Opts), ch.WithRecordHandler(aw.Write))
+	if expr := req.URL.Query().Get("select"); expr != "" {
+		f, err := ch.ParseSelect(expr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		opts = append(opts, ch.WithSelect(f))
+	}
+	errH := s.ErrorHandler
+	if errH == nil {
+		errH = func(err error) {}
+	}
+
+	r := ch.NewReader(opts...)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-req.Context().Done():
+			r.Stop()
+		case <-done:
+		}
+	}()
+	w.Header().Set("Content-Type", ContentType)
+	if _, err := r.ExtractAll(s.Paths, 1, errH); err != nil {
+		errH(err)
+		if !aw.started {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if err := aw.Close(); err != nil {
+		errH(err)
+	}
+}
+
+// search writes the matches of the name query q as JSON lines.
+func (s *Server) search(w http.ResponseWriter, req *http.Request) {
+	if s.Names == nil {
+		http.NotFound(w, req)
+		return
+	}
+	q := req.URL.Query()
+	limit := 10
+	if l := q.Get("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil || limit < 1 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	e := json.NewEncoder(w)
+	for _, m := range s.Names.Search(q.Get("q"), limit) {
+		if err := e.Encode(m); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package arrowipc
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ch "github.com/richardjennings/chapointdat"
+)
+
+func Test_Server(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Prod195.dat")
+	if err := os.WriteFile(path, []byte(testSnapshot(testCompanyLine, testPersonLine, testOtherLine)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	names := ch.NewNameIndex()
+	if _, err := ch.NewReader(ch.WithRecordHandler(names.Write)).ExtractAll([]string{path}, 1, func(err error) { t.Error(err) }); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(&Server{Paths: []string{path}, Names: names, ErrorHandler: func(err error) { t.Error(err) }})
+	defer srv.Close()
+	get := func(path string) (*http.Response, []byte) {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, b
+	}
+
+	resp, b := get("/persons?select=" + url.QueryEscape(`person.Surname == "SMITHSONIAN"`))
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != ContentType {
+		t.Fatalf("unexpected response %s %s", resp.Status, b)
+	}
+	fields, batches := readTestStream(t, b)
+	if len(batches) != 1 || batches[0].rows != 1 {
+		t.Fatalf("expected the selected person got %+v", batches)
+	}
+	if s := batches[0].strings(fields, testFieldIndex(t, fields, "PersonNumber")); s[0] != "024407940003" {
+		t.Errorf("unexpected person %v", s)
+	}
+	if _, b := get("/companies"); len(b) == 0 {
+		t.Error("expected companies")
+	} else if _, batches := readTestStream(t, b); len(batches) != 1 || batches[0].rows != 1 {
+		t.Errorf("expected a company got %+v", batches)
+	}
+
+	if resp, b := get("/search?q=" + url.QueryEscape("west partners") + "&limit=1"); resp.StatusCode != http.StatusOK || !strings.Contains(string(b), `"Name":"A. WEST \u0026 PARTNERS"`) {
+		t.Errorf("unexpected search response %s %s", resp.Status, b)
+	}
+
+	for path, status := range map[string]int{
+		"/search?q=west&limit=x":                                    http.StatusBadRequest,
+		"/persons?select=" + url.QueryEscape("person.Unknown == 1"): http.StatusBadRequest,
+		"/headers": http.StatusNotFound,
+	} {
+		if resp, _ := get(path); resp.StatusCode != status {
+			t.Errorf("%s: expected %d got %s", path, status, resp.Status)
+		}
+	}
+	missing := httptest.NewServer(&Server{Paths: []string{filepath.Join(t.TempDir(), "missing.zip")}})
+	defer missing.Close()
+	if resp, err := http.Get(missing.URL + "/companies"); err != nil || resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected an error for a missing snapshot got %v %v", resp, err)
+	} else {
+		_ = resp.Body.Close()
+	}
+}
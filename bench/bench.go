@@ -0,0 +1,237 @@
+// Package bench measures the throughput and allocations of extracting
+// snapshots generated by chapointdat.GenerateSnapshot, of several sizes and
+// with several configurations, so that performance can be checked on any
+// machine and compared against a baseline. The generated snapshots are the same
+// for every run, so results differ only by hardware and code.
+//
+//	go test -run XXX -bench Extract/20k ./bench
+//	chapointdat bench -sizes 1k,20k
+package bench
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	ch "github.com/richardjennings/chapointdat"
+)
+
+// Seed is the seed of every generated snapshot.
+const Seed = 1
+
+type (
+	// Size is a generated snapshot of a number of companies.
+	Size struct {
+		Name      string
+		Companies int
+	}
+
+	// Case is a configuration of the Reader to measure, with Opts returning
+	// the options of each extraction.
+	Case struct {
+		Name string
+		Opts func() []ch.Opt
+	}
+
+	// Result is the measurement of a Case over a Size.
+	Result struct {
+		Case      string
+		Size      string
+		Companies int
+		// Records is the number of companies and persons in the snapshot,
+		// and Bytes its size.
+		Records          int
+		Bytes            int64
+		NsPerOp          int64
+		RecordsPerSecond float64
+		MBPerSecond      float64
+		AllocsPerOp      int64
+		BytesPerOp       int64
+	}
+
+	// Baseline is a set of Results with the platform they were measured on.
+	Baseline struct {
+		GoVersion string
+		GOOS      string
+		GOARCH    string
+		CPUs      int
+		Results   []Result
+	}
+
+	// Regression is a Result worse than its baseline by more than a
+	// tolerance.
+	Regression struct {
+		Case, Size, Metric string
+		Baseline, Current  float64
+	}
+)
+
+var (
+	// Sizes are the snapshots measured by default, the largest being about 4%
+	// of a full snapshot.
+	Sizes = []Size{{"1k", 1000}, {"20k", 20000}, {"200k", 200000}}
+
+	// Cases are the configurations measured by default: parsing with no
+	// handlers, exporting JSON lines, and parsing on 4 goroutines with
+	// ordered delivery.
+	Cases = []Case{
+		{"parse", func() []ch.Opt { return nil }},
+		{"jsonl", func() []ch.Opt { return []ch.Opt{ch.WithJSONLExport(io.Discard)} }},
+		{"ordered4", func() []ch.Opt { return []ch.Opt{ch.WithOrderedDelivery(4)} }},
+	}
+
+	//go:embed baseline.json
+	baselineJSON []byte
+)
+
+// DefaultBaseline returns the baseline published with the package, measured
+// over Sizes and Cases. Its allocations are comparable on any machine, but its
+// throughput only on similar hardware.
+func DefaultBaseline() (Baseline, error) {
+	var b Baseline
+	if err := json.Unmarshal(baselineJSON, &b); err != nil {
+		return Baseline{}, fmt.Errorf("error reading baseline: %w", err)
+	}
+	return b, nil
+}
+
+// ReadBaseline reads a Baseline written by WriteBaseline.
+func ReadBaseline(rd io.Reader) (Baseline, error) {
+	var b Baseline
+	if err := json.NewDecoder(rd).Decode(&b); err != nil {
+		return Baseline{}, fmt.Errorf("error reading baseline: %w", err)
+	}
+	return b, nil
+}
+
+// WriteBaseline writes b as indented JSON.
+func WriteBaseline(w io.Writer, b Baseline) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(b)
+}
+
+// Run measures each of cases over a snapshot of each of sizes, generated in
+// dir, returning a Baseline of the results on this machine.
+func Run(dir string, sizes []Size, cases []Case) (Baseline, error) {
+	b := Baseline{GoVersion: runtime.Version(), GOOS: runtime.GOOS, GOARCH: runtime.GOARCH, CPUs: runtime.NumCPU()}
+	for _, size := range sizes {
+		path, err := Generate(dir, size)
+		if err != nil {
+			return Baseline{}, err
+		}
+		for _, c := range cases {
+			r, err := Measure(path, size, c)
+			if err != nil {
+				return Baseline{}, err
+			}
+			b.Results = append(b.Results, r)
+		}
+		if err := os.Remove(path); err != nil {
+			return Baseline{}, err
+		}
+	}
+	return b, nil
+}
+
+// Generate writes the snapshot of size to a .dat file in dir, returning its
+// path.
+func Generate(dir string, size Size) (string, error) {
+	path := filepath.Join(dir, fmt.Sprintf("Bench%s_1_1.dat", size.Name))
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	if err := ch.GenerateSnapshot(f, size.Companies, Seed); err != nil {
+		_ = f.Close()
+		return "", fmt.Errorf("error generating %s: %w", path, err)
+	}
+	return path, f.Close()
+}
+
+// measureTime is the least time Measure extracts a snapshot repeatedly for.
+var measureTime = time.Second
+
+// Measure benchmarks extracting path, the snapshot of size, with c: after one
+// extraction to warm up, it extracts path repeatedly for at least a second,
+// reading the allocations from the runtime's memory statistics as go test
+// -benchmem does.
+func Measure(path string, size Size, c Case) (Result, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return Result{}, err
+	}
+	res := Result{Case: c.Name, Size: size.Name, Companies: size.Companies, Bytes: fi.Size()}
+	extract := func() error {
+		s, err := ch.NewReader(c.Opts()...).ExtractAll([]string{path}, 1, func(err error) {})
+		if err == nil && s.Errors > 0 {
+			err = fmt.Errorf("%d lines rejected", s.Errors)
+		}
+		if err != nil {
+			return fmt.Errorf("error measuring %s over %s: %w", c.Name, size.Name, err)
+		}
+		res.Records = s.Companies + s.Persons
+		return nil
+	}
+	if err := extract(); err != nil {
+		return Result{}, err
+	}
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	n := int64(0)
+	for ; n == 0 || time.Since(start) < measureTime; n++ {
+		if err := extract(); err != nil {
+			return Result{}, err
+		}
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	res.NsPerOp = elapsed.Nanoseconds() / n
+	res.AllocsPerOp = int64(after.Mallocs-before.Mallocs) / n
+	res.BytesPerOp = int64(after.TotalAlloc-before.TotalAlloc) / n
+	if res.NsPerOp > 0 {
+		seconds := float64(res.NsPerOp) / 1e9
+		res.RecordsPerSecond = float64(res.Records) / seconds
+		res.MBPerSecond = float64(res.Bytes) / 1e6 / seconds
+	}
+	return res, nil
+}
+
+// Compare returns the results of current which are worse than those of the
+// same case and size in baseline by more than tolerance, a fraction such as
+// 0.1: fewer records per second, or more allocations or bytes allocated per
+// operation. Results missing from either are ignored.
+func Compare(baseline, current Baseline, tolerance float64) []Regression {
+	type key struct{ c, s string }
+	base := map[key]Result{}
+	for _, r := range baseline.Results {
+		base[key{r.Case, r.Size}] = r
+	}
+	var regressions []Regression
+	for _, r := range current.Results {
+		b, ok := base[key{r.Case, r.Size}]
+		if !ok {
+			continue
+		}
+		add := func(metric string, baseline, current float64, worse bool) {
+			if worse {
+				regressions = append(regressions, Regression{Case: r.Case, Size: r.Size, Metric: metric, Baseline: baseline, Current: current})
+			}
+		}
+		add("records/s", b.RecordsPerSecond, r.RecordsPerSecond, r.RecordsPerSecond < b.RecordsPerSecond*(1-tolerance))
+		add("allocs/op", float64(b.AllocsPerOp), float64(r.AllocsPerOp), float64(r.AllocsPerOp) > float64(b.AllocsPerOp)*(1+tolerance))
+		add("B/op", float64(b.BytesPerOp), float64(r.BytesPerOp), float64(r.BytesPerOp) > float64(b.BytesPerOp)*(1+tolerance))
+	}
+	return regressions
+}
+
+func (r Regression) String() string {
+	return fmt.Sprintf("%s/%s %s %.0f, baseline %.0f", r.Case, r.Size, r.Metric, r.Current, r.Baseline)
+}
@@ -0,0 +1,89 @@
+package bench
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	ch "github.com/richardjennings/chapointdat"
+)
+
+// BenchmarkExtract measures each of Cases over each of Sizes, named by size
+// and then case, as -bench Extract/20k/jsonl.
+func BenchmarkExtract(b *testing.B) {
+	for _, size := range Sizes {
+		b.Run(size.Name, func(b *testing.B) {
+			path, err := Generate(b.TempDir(), size)
+			if err != nil {
+				b.Fatal(err)
+			}
+			for _, c := range Cases {
+				b.Run(c.Name, func(b *testing.B) {
+					b.ReportAllocs()
+					var records int
+					for b.Loop() {
+						s, err := ch.NewReader(c.Opts()...).ExtractAll([]string{path}, 1, func(err error) { b.Error(err) })
+						if err != nil {
+							b.Fatal(err)
+						}
+						records += s.Companies + s.Persons
+					}
+					b.ReportMetric(float64(records)/b.Elapsed().Seconds(), "records/s")
+				})
+			}
+		})
+	}
+}
+
+func Test_Run(t *testing.T) {
+	defer func(d time.Duration) { measureTime = d }(measureTime)
+	measureTime = 10 * time.Millisecond
+	b, err := Run(t.TempDir(), []Size{{"tiny", 50}}, Cases[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Results) != 2 || b.Results[0].Records == 0 || b.Results[0].Records != b.Results[1].Records || b.Results[0].RecordsPerSecond <= 0 {
+		t.Fatalf("unexpected results %+v", b.Results)
+	}
+	var buf bytes.Buffer
+	if err := WriteBaseline(&buf, b); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadBaseline(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := Compare(b, read, 0); len(r) != 0 {
+		t.Errorf("expected no regressions against itself got %v", r)
+	}
+}
+
+func Test_Compare(t *testing.T) {
+	base := Baseline{Results: []Result{{Case: "parse", Size: "1k", RecordsPerSecond: 1000, AllocsPerOp: 100, BytesPerOp: 1000}}}
+	current := Baseline{Results: []Result{
+		{Case: "parse", Size: "1k", RecordsPerSecond: 850, AllocsPerOp: 105, BytesPerOp: 1200},
+		{Case: "jsonl", Size: "1k", RecordsPerSecond: 1},
+	}}
+	r := Compare(base, current, 0.1)
+	if len(r) != 2 || r[0].Metric != "records/s" || r[1].Metric != "B/op" {
+		t.Errorf("unexpected regressions %v", r)
+	}
+}
+
+func Test_DefaultBaseline(t *testing.T) {
+	b, err := DefaultBaseline()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Results) != len(Sizes)*len(Cases) {
+		t.Errorf("expected a result for each size and case got %d", len(b.Results))
+	}
+	// the generated snapshots, and so their sizes, are the same on any machine
+	var buf bytes.Buffer
+	if err := ch.GenerateSnapshot(&buf, Sizes[0].Companies, Seed); err != nil {
+		t.Fatal(err)
+	}
+	if b.Results[0].Size != Sizes[0].Name || b.Results[0].Bytes != int64(buf.Len()) {
+		t.Errorf("expected %d bytes for %s got %+v", buf.Len(), Sizes[0].Name, b.Results[0])
+	}
+}
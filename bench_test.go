@@ -0,0 +1,85 @@
+package chapointdat
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// A full snapshot has around 5 million companies, eg:
+//
+//	go test -run XXX -bench Snapshot -bench.companies 5000000
+var benchCompanies = flag.Int("bench.companies", 20000, "number of companies in the generated benchmark snapshot")
+
+func writeBenchSnapshot(b *testing.B) (string, int64) {
+	b.Helper()
+	path := filepath.Join(b.TempDir(), "Prod195_1_1.dat")
+	f, err := os.Create(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := GenerateSnapshot(f, *benchCompanies, 1); err != nil {
+		b.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		b.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return path, fi.Size()
+}
+
+func BenchmarkSnapshot(b *testing.B) {
+	path, size := writeBenchSnapshot(b)
+	b.SetBytes(size)
+	b.ReportAllocs()
+	var records int
+	for b.Loop() {
+		s, err := NewReader().ExtractAll([]string{path}, 1, func(err error) { b.Error(err) })
+		if err != nil {
+			b.Fatal(err)
+		}
+		records += s.Companies + s.Persons
+	}
+	b.ReportMetric(float64(records)/b.Elapsed().Seconds(), "records/s")
+}
+
+func BenchmarkPersonRow(b *testing.B) {
+	r := NewReader()
+	line := []byte(testPersonLine)
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, _, err := r.personRow(line); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCompanyRow(b *testing.B) {
+	r := NewReader()
+	line := []byte(testCompanyLine)
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := r.companyRow(line); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Test_GenerateSnapshot_Parses(t *testing.T) {
+	var b strings.Builder
+	if err := GenerateSnapshot(&b, 500, 1); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewReader().ExtractReader(strings.NewReader(b.String()), "-", func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Companies != 500 || s.RecordCount != s.Companies+s.Persons {
+		t.Errorf("unexpected summary %+v", s)
+	}
+}
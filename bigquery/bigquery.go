@@ -0,0 +1,242 @@
+// Package bigquery loads snapshots into BigQuery by staging newline delimited
+// JSON in Google Cloud Storage and running a load job for each table with the
+// schema from chapointdat.CompanySchema and PersonSchema.
+package bigquery
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	ch "github.com/richardjennings/chapointdat"
+	"github.com/richardjennings/chapointdat/objstore"
+)
+
+const defaultEndpoint = "https://bigquery.googleapis.com"
+
+type (
+	// Config configures a Loader. Zero values use the defaults.
+	Config struct {
+		// Client defaults to http.DefaultClient.
+		Client *http.Client
+		// Endpoint defaults to https://bigquery.googleapis.com.
+		Endpoint string
+		Project  string
+		Dataset  string
+		// Token returns an OAuth 2.0 access token, usually the same as that of
+		// Stage, for example objstore.MetadataToken.
+		Token func() (string, error)
+		// Stage is the bucket to stage files in, under Prefix.
+		Stage  *objstore.GCS
+		Prefix string
+		// Append adds rows to existing tables rather than replacing them.
+		Append bool
+		// Blanks sets how blank values are loaded, with columns nullable for
+		// chapointdat.BlankNull.
+		Blanks ch.Blanks
+		// PollInterval is the time between checks on whether a load job has
+		// finished, defaulting to 2 seconds.
+		PollInterval time.Duration
+	}
+
+	// Loader stages company and person records and loads them into the
+	// companies and persons tables, creating them if needed. Pass its Write
+	// method to WithRecordHandler and call End once extraction has finished.
+	Loader struct {
+		cfg    Config
+		mu     sync.Mutex
+		tables []*table
+	}
+
+	table struct {
+		schema ch.Schema
+		key    string
+		w      io.WriteCloser
+		e      *json.Encoder
+		blanks ch.Blanks
+	}
+
+	jobStatus struct {
+		JobReference struct {
+			JobID    string `json:"jobId"`
+			Location string `json:"location"`
+		} `json:"jobReference"`
+		Status struct {
+			State       string `json:"state"`
+			ErrorResult *struct {
+				Reason  string `json:"reason"`
+				Message string `json:"message"`
+			} `json:"errorResult"`
+		} `json:"status"`
+	}
+)
+
+func NewLoader(cfg Config) (*Loader, error) {
+	if cfg.Project == "" || cfg.Dataset == "" || cfg.Stage == nil {
+		return nil, errors.New("bigquery loader requires a project, dataset and staging bucket")
+	}
+	l := &Loader{cfg: cfg}
+	for _, s := range []ch.Schema{ch.CompanySchema(), ch.PersonSchema()} {
+		key := cfg.Prefix + s.Name + ".jsonl"
+		w := cfg.Stage.Create(key)
+		e := json.NewEncoder(w)
+		e.SetEscapeHTML(false)
+		l.tables = append(l.tables, &table{schema: s, key: key, w: w, e: e, blanks: cfg.Blanks})
+	}
+	return l, nil
+}
+
+// Write stages company and person records. Other records are ignored.
+func (l *Loader) Write(rec ch.Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch rec.Kind {
+	case ch.RecordKindCompany:
+		return l.tables[0].write(rec.Fields())
+	case ch.RecordKindPerson:
+		return l.tables[1].write(rec.Fields())
+	}
+	return nil
+}
+
+// End finishes staging and, if load is true, loads both tables and waits for
+// the load jobs to finish. Staged files are left in place.
+func (l *Loader) End(load bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var errs []error
+	for _, t := range l.tables {
+		if err := t.w.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("error staging %s: %w", t.key, err))
+		}
+	}
+	if len(errs) > 0 || !load {
+		return errors.Join(errs...)
+	}
+	for _, t := range l.tables {
+		if err := l.load(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write stages values, the Fields of a company or person, as a row with dates
+// formatted and blank nullable fields null, as BigQuery requires, and other
+// blank fields as Config.Blanks sets.
+func (t *table) write(values map[string]string) error {
+	row := make(map[string]any, len(t.schema.Fields))
+	for _, f := range t.schema.Fields {
+		v := t.blanks.Value(f, values[f.Name])
+		if d, ok := v.(time.Time); ok {
+			v = d.Format(time.DateOnly)
+		}
+		row[f.Column] = v
+	}
+	return t.e.Encode(row)
+}
+
+// load runs a load job for t and waits for it to finish.
+func (l *Loader) load(t *table) error {
+	type field struct {
+		Name        string `json:"name"`
+		Type        string `json:"type"`
+		Mode        string `json:"mode"`
+		Description string `json:"description,omitempty"`
+	}
+	fields := make([]field, len(t.schema.Fields))
+	for i, f := range t.schema.Fields {
+		fields[i] = field{Name: f.Column, Type: f.SQLType(ch.SQLDialectBigQuery), Mode: "REQUIRED", Description: f.Description}
+		if l.cfg.Blanks.Nullable(f) {
+			fields[i].Mode = "NULLABLE"
+		}
+		// descriptions are limited to 1024 characters
+		if len(fields[i].Description) > 1024 {
+			fields[i].Description = strings.ToValidUTF8(fields[i].Description[:1021], "") + "..."
+		}
+	}
+	disposition := "WRITE_TRUNCATE"
+	if l.cfg.Append {
+		disposition = "WRITE_APPEND"
+	}
+	job := map[string]any{
+		"configuration": map[string]any{
+			"load": map[string]any{
+				"sourceUris":   []string{"gs://" + l.cfg.Stage.Bucket + "/" + t.key},
+				"sourceFormat": "NEWLINE_DELIMITED_JSON",
+				"destinationTable": map[string]string{
+					"projectId": l.cfg.Project,
+					"datasetId": l.cfg.Dataset,
+					"tableId":   t.schema.Name,
+				},
+				"schema":            map[string]any{"fields": fields},
+				"writeDisposition":  disposition,
+				"createDisposition": "CREATE_IF_NEEDED",
+			},
+		},
+	}
+	body, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	var st jobStatus
+	if err := l.do(http.MethodPost, "/projects/"+url.PathEscape(l.cfg.Project)+"/jobs", body, &st); err != nil {
+		return fmt.Errorf("error starting load of %s: %w", t.schema.Name, err)
+	}
+	interval := l.cfg.PollInterval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	for st.Status.State != "DONE" {
+		time.Sleep(interval)
+		path := "/projects/" + url.PathEscape(l.cfg.Project) + "/jobs/" + url.PathEscape(st.JobReference.JobID) +
+			"?location=" + url.QueryEscape(st.JobReference.Location)
+		if err := l.do(http.MethodGet, path, nil, &st); err != nil {
+			return fmt.Errorf("error checking load of %s: %w", t.schema.Name, err)
+		}
+	}
+	if e := st.Status.ErrorResult; e != nil {
+		return fmt.Errorf("error loading %s: %s: %s", t.schema.Name, e.Reason, e.Message)
+	}
+	return nil
+}
+
+func (l *Loader) do(method, path string, body []byte, v any) error {
+	endpoint := l.cfg.Endpoint
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(endpoint, "/")+"/bigquery/v2"+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if l.cfg.Token != nil {
+		token, err := l.cfg.Token()
+		if err != nil {
+			return fmt.Errorf("error getting token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	c := l.cfg.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &objstore.StatusError{Method: method, Path: req.URL.Path, StatusCode: resp.StatusCode, Body: string(b)}
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,161 @@
+package bigquery
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	ch "github.com/richardjennings/chapointdat"
+	"github.com/richardjennings/chapointdat/objstore"
+)
+
+const (
+	testHeaderLine  = "DDDDSNAP019520240101"
+	testCompanyLine = "000000841D                      00010019A. WEST & PARTNERS<"
+	testPersonLine  = "004638192201024407940002        19910915        NP25 3DZ194509          0093MR<HANS<KJAERSGAARD<<<<1 AGINCOURT STREET<<MONMOUTH<<WALES<MARKETING DIRECTOR<DANISH<ENGLAND<"
+)
+
+// fakeGoogle stores media uploads and runs load jobs which finish on the
+// first check.
+type fakeGoogle struct {
+	mu      sync.Mutex
+	objects map[string]string
+	jobs    []map[string]any
+	fail    bool
+}
+
+func (g *fakeGoogle) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer token" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	switch {
+	case r.URL.Path == "/upload/storage/v1/b/stage/o":
+		g.objects[r.URL.Query().Get("name")] = string(body)
+	case r.Method == http.MethodPost && r.URL.Path == "/bigquery/v2/projects/p/jobs":
+		var job map[string]any
+		if err := json.Unmarshal(body, &job); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		g.jobs = append(g.jobs, job)
+		_, _ = io.WriteString(w, `{"jobReference":{"jobId":"j1","location":"EU"},"status":{"state":"RUNNING"}}`)
+	case r.Method == http.MethodGet && r.URL.Path == "/bigquery/v2/projects/p/jobs/j1" && r.URL.Query().Get("location") == "EU":
+		if g.fail {
+			_, _ = io.WriteString(w, `{"jobReference":{"jobId":"j1"},"status":{"state":"DONE","errorResult":{"reason":"invalid","message":"bad row"}}}`)
+			return
+		}
+		_, _ = io.WriteString(w, `{"jobReference":{"jobId":"j1"},"status":{"state":"DONE"}}`)
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+func testLoader(t *testing.T, g *fakeGoogle, blanks ch.Blanks) *Loader {
+	srv := httptest.NewServer(g)
+	t.Cleanup(srv.Close)
+	token := func() (string, error) { return "token", nil }
+	l, err := NewLoader(Config{
+		Endpoint:     srv.URL,
+		Project:      "p",
+		Dataset:      "d",
+		Token:        token,
+		Stage:        &objstore.GCS{Endpoint: srv.URL, Bucket: "stage", Token: token},
+		Prefix:       "Prod195/",
+		PollInterval: 1,
+		Blanks:       blanks,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func Test_Loader(t *testing.T) {
+	g := &fakeGoogle{objects: map[string]string{}}
+	l := testLoader(t, g, ch.Blanks{})
+	r := ch.NewReader(ch.WithRecordHandler(l.Write))
+	in := testHeaderLine + "\n" + testCompanyLine + "\n" + testPersonLine + "\n9999999900000002\n"
+	if _, err := r.ExtractReader(strings.NewReader(in), "-", func(err error) { t.Error(err) }); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.End(true); err != nil {
+		t.Fatal(err)
+	}
+	if g.objects["Prod195/companies.jsonl"] != `{"company_name":"A. WEST & PARTNERS","company_number":"00000084","company_status":"D","number_of_officers":1}`+"\n" {
+		t.Errorf("unexpected companies %s", g.objects["Prod195/companies.jsonl"])
+	}
+	if p := g.objects["Prod195/persons.jsonl"]; !strings.Contains(p, `"appointment_date":"1991-09-15"`) || !strings.Contains(p, `"resignation_date":null`) {
+		t.Errorf("unexpected persons %s", p)
+	}
+	if len(g.jobs) != 2 {
+		t.Fatalf("expected 2 load jobs got %d", len(g.jobs))
+	}
+	load := g.jobs[1]["configuration"].(map[string]any)["load"].(map[string]any)
+	fields := load["schema"].(map[string]any)["fields"].([]any)
+	if load["sourceUris"].([]any)[0] != "gs://stage/Prod195/persons.jsonl" || load["writeDisposition"] != "WRITE_TRUNCATE" ||
+		fields[5].(map[string]any)["type"] != "DATE" || fields[5].(map[string]any)["mode"] != "NULLABLE" {
+		t.Errorf("unexpected load job %v", load)
+	}
+}
+
+func Test_Loader_Failed(t *testing.T) {
+	g := &fakeGoogle{objects: map[string]string{}, fail: true}
+	l := testLoader(t, g, ch.Blanks{})
+	if err := l.End(true); err == nil || !strings.Contains(err.Error(), "bad row") {
+		t.Errorf("expected a load error got %v", err)
+	}
+
+	g = &fakeGoogle{objects: map[string]string{}}
+	l = testLoader(t, g, ch.Blanks{})
+	if err := l.End(false); err != nil || len(g.jobs) != 0 {
+		t.Errorf("expected nothing loaded got %v and %d jobs", err, len(g.jobs))
+	}
+	if _, err := NewLoader(Config{}); err == nil {
+		t.Error("expected an error for an empty config")
+	}
+}
+
+func Test_Loader_Blanks(t *testing.T) {
+	g := &fakeGoogle{objects: map[string]string{}}
+	l := testLoader(t, g, ch.Blanks{Mode: ch.BlankNull})
+	r := ch.NewReader(ch.WithRecordHandler(l.Write))
+	in := testHeaderLine + "\n" + testPersonLine + "\n9999999900000001\n"
+	if _, err := r.ExtractReader(strings.NewReader(in), "-", func(err error) { t.Error(err) }); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.End(true); err != nil {
+		t.Fatal(err)
+	}
+	if p := g.objects["Prod195/persons.jsonl"]; !strings.Contains(p, `"honours":null`) || !strings.Contains(p, `"surname":"KJAERSGAARD"`) {
+		t.Errorf("unexpected persons %s", p)
+	}
+	load := g.jobs[1]["configuration"].(map[string]any)["load"].(map[string]any)
+	for _, f := range load["schema"].(map[string]any)["fields"].([]any) {
+		if f.(map[string]any)["mode"] != "NULLABLE" {
+			t.Errorf("expected every field to be nullable got %v", f)
+		}
+	}
+}
+
+func Test_Loader_Truncated(t *testing.T) {
+	g := &fakeGoogle{objects: map[string]string{}}
+	l := testLoader(t, g, ch.Blanks{})
+	p := &ch.Person{CompanyNumber: "00000084", PersonNumber: "024407940002", Surname: "KJAERSGAARD", Truncated: true, Phonetic: &ch.PhoneticKeys{}}
+	if err := l.Write(ch.Record{Kind: ch.RecordKindPerson, Person: p}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.End(false); err != nil {
+		t.Fatal(err)
+	}
+	if p := g.objects["Prod195/persons.jsonl"]; !strings.Contains(p, `"surname":"KJAERSGAARD"`) || strings.Contains(p, "truncated") {
+		t.Errorf("unexpected persons %s", p)
+	}
+}
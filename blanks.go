@@ -0,0 +1,112 @@
+package chapointdat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	// BlankEmpty writes blank values as the empty string, which is the
+	// default. SQLScript and the BigQuery loader still write blank dates and
+	// integers as NULL.
+	BlankEmpty = BlankMode(iota)
+	// BlankNull writes blank values as NULL in SQL, null in JSON and an
+	// unquoted empty value in CSV, which loaders such as Postgres COPY read as
+	// NULL even when other values are quoted.
+	BlankNull
+	// BlankSentinel writes blank values as Blanks.Sentinel, other than blank
+	// dates and integers in SQL and BigQuery, which are NULL.
+	BlankSentinel
+)
+
+type (
+	BlankMode int
+
+	// Blanks sets how exporters and loaders write blank values, which are
+	// fixed width fields of only spaces and empty variable length fields.
+	Blanks struct {
+		Mode BlankMode
+		// Sentinel is written for blank values by BlankSentinel, such as \N.
+		Sentinel string
+	}
+)
+
+// ParseBlankMode returns the mode named empty, null or sentinel.
+func ParseBlankMode(s string) (BlankMode, error) {
+	for m, name := range blankModeNames {
+		if s == name {
+			return BlankMode(m), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown blank mode: %s", s)
+}
+
+var blankModeNames = []string{"empty", "null", "sentinel"}
+
+func (m BlankMode) String() string {
+	if int(m) < len(blankModeNames) {
+		return blankModeNames[m]
+	}
+	return "unknown"
+}
+
+// Value returns v, a value of f, as SchemaField.Value does but with blank
+// values as b sets: nil for BlankNull, and the sentinel for BlankSentinel if f
+// is not nullable.
+func (b Blanks) Value(f SchemaField, v string) any {
+	if isBlank(v) {
+		switch {
+		case b.Mode == BlankNull:
+			return nil
+		case b.Mode == BlankSentinel && !f.Nullable():
+			return b.Sentinel
+		}
+	}
+	return f.Value(v)
+}
+
+// Nullable reports whether blank values of f are loaded as NULL, which for
+// BlankNull is every field.
+func (b Blanks) Nullable(f SchemaField) bool {
+	return b.Mode == BlankNull || f.Nullable()
+}
+
+// text returns v as written to CSV, and whether it is null.
+func (b Blanks) text(v string) (string, bool) {
+	if !isBlank(v) {
+		return v, false
+	}
+	switch b.Mode {
+	case BlankNull:
+		return "", true
+	case BlankSentinel:
+		return b.Sentinel, false
+	}
+	return v, false
+}
+
+// json replaces the empty string values in line, a record encoded as JSON, as
+// b sets. An empty string value is always encoded as :"", which cannot occur
+// within a string, where quotes are escaped.
+func (b Blanks) json(line []byte) ([]byte, error) {
+	var replacement []byte
+	switch b.Mode {
+	case BlankNull:
+		replacement = []byte(":null")
+	case BlankSentinel:
+		s, err := json.Marshal(b.Sentinel)
+		if err != nil {
+			return nil, err
+		}
+		replacement = append([]byte(":"), s...)
+	default:
+		return line, nil
+	}
+	return bytes.ReplaceAll(line, []byte(`:""`), replacement), nil
+}
+
+func isBlank(v string) bool {
+	return strings.TrimSpace(v) == ""
+}
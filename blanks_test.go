@@ -0,0 +1,91 @@
+package chapointdat
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func Test_Blanks_CSVExport(t *testing.T) {
+	for _, tc := range []struct {
+		esc      ExportEscaping
+		expected string
+	}{
+		{ExportEscaping{}, `1 AGINCOURT STREET,,MONMOUTH`},
+		{ExportEscaping{Quote: QuoteAll}, `"1 AGINCOURT STREET","","MONMOUTH"`},
+		{ExportEscaping{Quote: QuoteAll, Blanks: Blanks{Mode: BlankNull}}, `"1 AGINCOURT STREET",,"MONMOUTH"`},
+		{ExportEscaping{Blanks: Blanks{Mode: BlankSentinel, Sentinel: `\N`}}, `1 AGINCOURT STREET,\N,MONMOUTH`},
+	} {
+		var persons bytes.Buffer
+		r := NewReader(WithExportEscaping(tc.esc), WithCSVExport(nil, &persons))
+		if _, err := r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine, testPersonLine)), "-", func(err error) { t.Error(err) }); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(persons.String(), tc.expected) {
+			t.Errorf("%+v: expected %s in %s", tc.esc, tc.expected, persons.String())
+		}
+	}
+}
+
+func Test_Blanks_JSONLExport(t *testing.T) {
+	for blanks, expected := range map[Blanks]string{
+		{}:                                   `"AddressLine2":"",`,
+		{Mode: BlankNull}:                    `"AddressLine2":null,`,
+		{Mode: BlankSentinel, Sentinel: "-"}: `"AddressLine2":"-",`,
+	} {
+		var b bytes.Buffer
+		r := NewReader(WithExportEscaping(ExportEscaping{Blanks: blanks}), WithJSONLExport(&b))
+		if _, err := r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine, testPersonLine)), "-", func(err error) { t.Error(err) }); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(b.String(), expected) || !strings.Contains(b.String(), `"Surname":"KJAERSGAARD"`) {
+			t.Errorf("%+v: expected %s in %s", blanks, expected, b.String())
+		}
+		if lines := strings.Count(b.String(), "\n"); lines != 4 {
+			t.Errorf("%+v: expected 4 lines got %d", blanks, lines)
+		}
+	}
+}
+
+func Test_Blanks_SQLScript(t *testing.T) {
+	for blanks, expected := range map[Blanks][]string{
+		{Mode: BlankNull}: {
+			"CREATE TABLE IF NOT EXISTS persons (company_number TEXT, ",
+			"('00463819', '2', '01', '024407940002', NULL, '1991-09-15', NULL, 'NP25 3DZ', '194509', NULL, 'MR', 'HANS', 'KJAERSGAARD', NULL, NULL,",
+		},
+		{Mode: BlankSentinel, Sentinel: "?"}: {
+			"CREATE TABLE IF NOT EXISTS persons (company_number TEXT NOT NULL, ",
+			"('00463819', '2', '01', '024407940002', '?', '1991-09-15', NULL, 'NP25 3DZ', '194509', NULL, 'MR', 'HANS', 'KJAERSGAARD', '?', '?',",
+		},
+	} {
+		var b strings.Builder
+		script, err := NewSQLScript(&b, SQLDialectSQLite, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		script.SetBlanks(blanks)
+		r := NewReader(WithRecordHandler(script.Write))
+		if _, err := r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine, testPersonLine)), "-", func(err error) { t.Error(err) }); err != nil {
+			t.Fatal(err)
+		}
+		if err := script.End(true); err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range expected {
+			if !strings.Contains(b.String(), e) {
+				t.Errorf("%+v: expected script to contain %q got %q", blanks, e, b.String())
+			}
+		}
+	}
+}
+
+func Test_ParseBlankMode(t *testing.T) {
+	for _, m := range []BlankMode{BlankEmpty, BlankNull, BlankSentinel} {
+		if got, err := ParseBlankMode(m.String()); err != nil || got != m {
+			t.Errorf("expected %s got %s %v", m, got, err)
+		}
+	}
+	if _, err := ParseBlankMode("none"); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,173 @@
+package chapointdat
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+type (
+	// CharsetViolation is a character in a line outside the Companies House
+	// permitted character set, or a byte which is not valid UTF-8.
+	CharsetViolation struct {
+		Path  string
+		Entry string
+		Line  int
+		// Position is the byte offset of the character within the line, and
+		// Field the name of the field of the record it falls in.
+		Position int
+		Field    string
+		// Rune is utf8.RuneError for an invalid byte, and Bytes the bytes of
+		// the character.
+		Rune  rune
+		Bytes []byte
+		// Suggested is a replacement made up of permitted characters, such as
+		// AE for Æ, or "" if there is none.
+		Suggested string
+	}
+
+	fieldSpan struct {
+		name       string
+		start, end int
+	}
+)
+
+// permittedRunes are the characters permitted by Companies House in addition
+// to printable ASCII.
+const permittedRunes = "£€¥«»‘’“”" +
+	"ÀÁÂÃÄÅĀĂĄÆǼÇĆĈĊČÞĎÐĐÈÉÊËĒĔĖĘĚĜĞĠĢĤĦÌÍÎÏĨĪĬĮİĴĶĹĻĽĿŁÑŃŅŇŊÒÓÔÕÖØŌŎŐǾŒŔŖŘŚŜŞŠŢŤŦÙÚÛÜŨŪŬŮŰŲŴẀẂẄỲÝŶŸȲŹŻŽ" +
+	"àáâãäåāăąæǽçćĉċčþďðđèéêëēĕėęěĝğġģĥħìíîïĩīĭįıĵķĺļľŀłñńņňŋòóôõöøōŏőǿœŕŗřśŝşšţťŧùúûüũūŭůűųŵẁẃẅỳýŷÿȳźżžß"
+
+// transliterations suggest replacements for characters by the letters they
+// are based on.
+var transliterations = map[rune]string{
+	'Æ': "AE", 'Ǽ': "AE", 'æ': "ae", 'ǽ': "ae", 'Œ': "OE", 'œ': "oe", 'Þ': "TH", 'þ': "th", 'ß': "ss",
+	'Ð': "D", 'Đ': "D", 'ð': "d", 'đ': "d", 'Ł': "L", 'ł': "l", 'Ø': "O", 'Ǿ': "O", 'ø': "o", 'ǿ': "o",
+	'Ħ': "H", 'ħ': "h", 'Ŧ': "T", 'ŧ': "t", 'Ŋ': "N", 'ŋ': "n", 'ı': "i", 'Ŀ': "L", 'ŀ': "l",
+	'‘': "'", '’': "'", '“': `"`, '”': `"`, '«': `"`, '»': `"`, '£': "GBP", '€': "EUR", '¥': "JPY",
+	'\t': " ", '\u00a0': " ",
+}
+
+// baseLetters map the accented letters in permittedRunes without a
+// transliteration to the letter they are based on.
+var baseLetters = map[rune]rune{}
+
+func init() {
+	groups := []struct {
+		base    rune
+		letters string
+	}{
+		{'A', "ÀÁÂÃÄÅĀĂĄ"}, {'C', "ÇĆĈĊČ"}, {'D', "Ď"}, {'E', "ÈÉÊËĒĔĖĘĚ"}, {'G', "ĜĞĠĢ"}, {'H', "Ĥ"},
+		{'I', "ÌÍÎÏĨĪĬĮİ"}, {'J', "Ĵ"}, {'K', "Ķ"}, {'L', "ĹĻĽ"}, {'N', "ÑŃŅŇ"}, {'O', "ÒÓÔÕÖŌŎŐ"},
+		{'R', "ŔŖŘ"}, {'S', "ŚŜŞŠ"}, {'T', "ŢŤ"}, {'U', "ÙÚÛÜŨŪŬŮŰŲ"}, {'W', "ŴẀẂẄ"}, {'Y', "ỲÝŶŸȲ"},
+		{'Z', "ŹŻŽ"},
+	}
+	for _, g := range groups {
+		for _, l := range g.letters {
+			baseLetters[l] = g.base
+			if lower := []rune(strings.ToLower(string(l))); len(lower) == 1 {
+				baseLetters[lower[0]] = g.base + 'a' - 'A'
+			}
+		}
+	}
+}
+
+var (
+	companyFieldSpans = []fieldSpan{
+		{"CompanyNumber", 0, 8}, {"RecordType", 8, 9}, {"CompanyStatus", 9, 10}, {"Filler", 10, 32},
+		{"NumberOfOfficers", 32, 36}, {"NameLength", 36, 40}, {"CompanyName", 40, -1},
+	}
+	personFieldSpans = []fieldSpan{
+		{"CompanyNumber", 0, 8}, {"RecordType", 8, 9}, {"AppDateOrigin", 9, 10}, {"AppointmentType", 10, 12},
+		{"PersonNumber", 12, 24}, {"CorporateIndicator", 24, 25}, {"Filler", 25, 32}, {"AppointmentDate", 32, 40},
+		{"ResignationDate", 40, 48}, {"Postcode", 48, 56}, {"PartialDateOfBirth", 56, 64},
+		{"FullDateOfBirth", 64, 72}, {"VariableDataLength", 72, 76}, {"VariableData", 76, -1},
+	}
+)
+
+// WithCharsetHandler calls h with each character of a line outside the
+// permitted character set, once the line has been parsed and before its
+// record is handled, so that lines parsed concurrently are still checked in
+// order. Multi byte characters in the fixed width fields otherwise surface as
+// errors in the fields after them. An error returned by h rejects the line in
+// place of any error from parsing it; otherwise the line is handled as usual.
+// Violations are counted in Summary.CharsetViolations.
+func WithCharsetHandler(h func(v CharsetViolation) error) Opt {
+	return func(r *Reader) {
+		r.charsetHandler = h
+	}
+}
+
+// PermittedRune reports whether r is in the Companies House permitted
+// character set.
+func PermittedRune(r rune) bool {
+	return r >= ' ' && r <= '~' || r != utf8.RuneError && strings.ContainsRune(permittedRunes, r)
+}
+
+// SuggestReplacement returns permitted characters to replace r with, or "".
+func SuggestReplacement(r rune) string {
+	if s, ok := transliterations[r]; ok {
+		return s
+	}
+	if b, ok := baseLetters[r]; ok {
+		return string(b)
+	}
+	return ""
+}
+
+// checkCharset passes the violations in line to the charset handler.
+func (r *Reader) checkCharset(line []byte, st *state) error {
+	for i := 0; i < len(line); {
+		if c := line[i]; c >= ' ' && c <= '~' {
+			i++
+			continue
+		}
+		c, n := utf8.DecodeRune(line[i:])
+		if PermittedRune(c) && !inFixedWidth(line, i) {
+			i += n
+			continue
+		}
+		st.summary.CharsetViolations++
+		v := CharsetViolation{
+			Path: st.path, Entry: st.entry, Line: st.line, Position: i, Field: lineField(line, i),
+			Rune: c, Bytes: append([]byte(nil), line[i:i+n]...), Suggested: SuggestReplacement(c),
+		}
+		if err := r.retry(func() error { return r.charsetHandler(v) }); err != nil {
+			return fmt.Errorf("error processing charset handler: %w", err)
+		}
+		i += n
+	}
+	return nil
+}
+
+// inFixedWidth reports whether position i of line is within the fixed width
+// fields of a company or person, where only ASCII is permitted as widths are
+// in bytes.
+func inFixedWidth(line []byte, i int) bool {
+	switch lineField(line, i) {
+	case "", "CompanyName", "VariableData":
+		return false
+	}
+	return true
+}
+
+// lineField returns the name of the field of a company or person line at
+// byte position i.
+func lineField(line []byte, i int) string {
+	if len(line) < 9 {
+		return ""
+	}
+	var spans []fieldSpan
+	switch string(line[8]) {
+	case companyRecordType:
+		spans = companyFieldSpans
+	case personRecordType:
+		spans = personFieldSpans
+	}
+	for _, s := range spans {
+		if i >= s.start && (s.end < 0 || i < s.end) {
+			return s.name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,53 @@
+package chapointdat
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func Test_CharsetHandler(t *testing.T) {
+	// an Æ in the postcode shifts the fields after it by a byte
+	postcode := strings.Replace(testPersonLine, "NP25 3DZ", "NP25 3Æ", 1)
+	name := strings.Replace(testCompanyLine, "A. WEST & PARTNERS", "Æ WEST & PARTNERS\x01", 1)
+	name = strings.Replace(name, "0019", "0021", 1)
+	var violations []CharsetViolation
+	var errs []error
+	r := NewReader(WithCharsetHandler(func(v CharsetViolation) error {
+		violations = append(violations, v)
+		if v.Rune == 0x01 {
+			return errors.New("control character")
+		}
+		return nil
+	}))
+	s, err := r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine, postcode, name)), "-", func(err error) { errs = append(errs, err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 2 || s.CharsetViolations != 2 {
+		t.Fatalf("unexpected violations %+v", violations)
+	}
+	v := violations[0]
+	if v.Line != 3 || v.Position != 54 || v.Field != "Postcode" || v.Rune != 'Æ' || string(v.Bytes) != "Æ" || v.Suggested != "AE" {
+		t.Errorf("unexpected violation %+v", v)
+	}
+	// the Æ in the company name is permitted
+	if v := violations[1]; v.Field != "CompanyName" || v.Rune != 0x01 || v.Suggested != "" {
+		t.Errorf("unexpected violation %+v", v)
+	}
+	// the rejected company is then missing from the trailer count
+	if len(errs) != 2 || !strings.Contains(errs[0].Error(), "control character") {
+		t.Errorf("expected the handler error got %v", errs)
+	}
+}
+
+func Test_SuggestReplacement(t *testing.T) {
+	for r, expected := range map[rune]string{'É': "E", 'ç': "c", 'Ø': "O", 'ß': "ss", '’': "'", '☃': ""} {
+		if s := SuggestReplacement(r); s != expected {
+			t.Errorf("expected %q for %c got %q", expected, r, s)
+		}
+	}
+	if !PermittedRune('É') || PermittedRune('☃') || PermittedRune('\t') {
+		t.Error("unexpected permitted runes")
+	}
+}
@@ -0,0 +1,86 @@
+package chapointdat
+
+import (
+	"errors"
+	"sort"
+)
+
+// ErrStopped is returned by Extract and ExtractAll when Stop was called before
+// all lines were read.
+var ErrStopped = errors.New("extraction stopped")
+
+type (
+	// Checkpoint records how far extraction got through each file, so that a
+	// later run can continue from where a stopped run left off.
+	Checkpoint struct {
+		Files []FileCheckpoint `json:"files"`
+	}
+	FileCheckpoint struct {
+		Path  string `json:"path"`
+		Entry string `json:"entry"`
+		// Line is the number of lines of the entry that have been processed.
+		Line     int  `json:"line"`
+		Complete bool `json:"complete"`
+		// Summary is the progress made within the entry up to Line.
+		Summary Summary `json:"summary"`
+	}
+)
+
+// WithCheckpointHandler calls c with a Checkpoint once extraction finishes or
+// is stopped, after sinks and flush handlers have been flushed.
+func WithCheckpointHandler(c func(checkpoint Checkpoint) error) Opt {
+	return func(r *Reader) {
+		r.checkpointHandler = c
+	}
+}
+
+// WithResume continues extraction from c. Complete entries are skipped and
+// partially processed entries continue from the line after the last one that
+// was processed. Returned summaries include the progress recorded in c.
+func WithResume(c Checkpoint) Opt {
+	return func(r *Reader) {
+		r.resume = map[string]FileCheckpoint{}
+		for _, f := range c.Files {
+			r.resume[checkpointKey(f.Path, f.Entry)] = f
+		}
+	}
+}
+
+// WithFlush registers f to be called once extraction finishes or is stopped,
+// for handlers that batch records and need to write out the remainder.
+func WithFlush(f func() error) Opt {
+	return func(r *Reader) {
+		r.flushers = append(r.flushers, f)
+	}
+}
+
+// Stop asks a running extraction to stop. The line currently being handled in
+// each file is completed, no further lines are read, and Extract or ExtractAll
+// returns ErrStopped once sinks and flush handlers have been flushed and a
+// checkpoint written. A stopped Reader cannot be used again.
+func (r *Reader) Stop() {
+	r.stopped.Store(true)
+}
+
+func (r *Reader) recordProgress(f FileCheckpoint) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.progress[checkpointKey(f.Path, f.Entry)] = f
+}
+
+func (r *Reader) checkpoint() Checkpoint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var c Checkpoint
+	for _, f := range r.progress {
+		c.Files = append(c.Files, f)
+	}
+	sort.Slice(c.Files, func(i, j int) bool {
+		return checkpointKey(c.Files[i].Path, c.Files[i].Entry) < checkpointKey(c.Files[j].Path, c.Files[j].Entry)
+	})
+	return c
+}
+
+func checkpointKey(path, entry string) string {
+	return path + "!" + entry
+}
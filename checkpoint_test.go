@@ -0,0 +1,63 @@
+package chapointdat
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_Stop_Checkpoint_Resume(t *testing.T) {
+	path := writeTestZip(t, "a.zip", testSnapshot(testCompanyLine, testPersonLine, testPersonLine))
+	var r *Reader
+	var checkpoint Checkpoint
+	var flushed bool
+	r = NewReader(
+		WithCompanyHandler(func(c Company) error {
+			r.Stop()
+			return nil
+		}),
+		WithFlush(func() error {
+			flushed = true
+			return nil
+		}),
+		WithCheckpointHandler(func(c Checkpoint) error {
+			checkpoint = c
+			return nil
+		}),
+	)
+	s, err := r.ExtractAll([]string{path}, 1, func(err error) { t.Error(err) })
+	if !errors.Is(err, ErrStopped) {
+		t.Fatalf("expected ErrStopped got %v", err)
+	}
+	if !flushed {
+		t.Error("expected flush")
+	}
+	if s.Companies != 1 || s.Persons != 0 {
+		t.Errorf("unexpected partial summary %+v", s)
+	}
+	if len(checkpoint.Files) != 1 || checkpoint.Files[0].Line != 2 || checkpoint.Files[0].Complete {
+		t.Fatalf("unexpected checkpoint %+v", checkpoint)
+	}
+
+	var persons int
+	r = NewReader(
+		WithResume(checkpoint),
+		WithPersonHandler(func(p Person) error {
+			persons++
+			return nil
+		}),
+		WithCheckpointHandler(func(c Checkpoint) error {
+			checkpoint = c
+			return nil
+		}),
+	)
+	s, err = r.ExtractAll([]string{path}, 1, func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if persons != 2 || s.Companies != 1 || s.Persons != 2 || s.Lines != 5 {
+		t.Errorf("unexpected resumed summary %+v with %d persons handled", s, persons)
+	}
+	if !checkpoint.Files[0].Complete {
+		t.Errorf("expected complete checkpoint %+v", checkpoint)
+	}
+}
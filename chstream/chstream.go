@@ -0,0 +1,154 @@
+// Package chstream consumes the Companies House officers streaming API and
+// reconciles its events with a chapointdat.Dataset of the last snapshot
+// ingested, reporting where the two diverge, so that consumers keeping a store
+// up to date between bulk refreshes know when it can no longer be trusted.
+package chstream
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const defaultURL = "https://stream.companieshouse.gov.uk/officers"
+
+type (
+	// Config configures Stream. Zero values use the defaults.
+	Config struct {
+		// URL defaults to https://stream.companieshouse.gov.uk/officers.
+		URL string
+		// Key is the stream key, sent as the user name of basic
+		// authentication.
+		Key string
+		// Client defaults to http.DefaultClient. It must not have a timeout,
+		// as the stream is read for as long as it stays open.
+		Client *http.Client
+		// Timepoint, if not zero, starts the stream from the event at that
+		// timepoint, so that a stream can be resumed from the event after the
+		// last one handled.
+		Timepoint int64
+	}
+
+	// Event is an event of the officers stream.
+	Event struct {
+		ResourceKind string `json:"resource_kind"`
+		// ResourceURI is the path of the appointment, as
+		// /company/<company number>/appointments/<appointment id>.
+		ResourceURI string  `json:"resource_uri"`
+		ResourceID  string  `json:"resource_id"`
+		Data        Officer `json:"data"`
+		Event       struct {
+			Timepoint   int64  `json:"timepoint"`
+			PublishedAt string `json:"published_at"`
+			// Type is changed or deleted.
+			Type string `json:"type"`
+		} `json:"event"`
+	}
+
+	// Officer is the appointment of an event, with the fields compared with
+	// the snapshot.
+	Officer struct {
+		// Name is as SURNAME, Forenames, or the name of a corporate officer.
+		Name string `json:"name"`
+		// OfficerRole is such as director, secretary or llp-member.
+		OfficerRole string `json:"officer_role"`
+		// AppointedOn and ResignedOn are ISO 8601 dates, or empty.
+		AppointedOn string `json:"appointed_on,omitempty"`
+		ResignedOn  string `json:"resigned_on,omitempty"`
+		Address     struct {
+			PostalCode string `json:"postal_code,omitempty"`
+		} `json:"address"`
+		DateOfBirth *struct {
+			Month int `json:"month"`
+			Year  int `json:"year"`
+		} `json:"date_of_birth,omitempty"`
+		Nationality string `json:"nationality,omitempty"`
+		Occupation  string `json:"occupation,omitempty"`
+	}
+
+	// StatusError is returned when the stream cannot be opened.
+	StatusError struct {
+		StatusCode int
+		Body       string
+	}
+)
+
+// Stream opens the officers stream and calls h with each event, in timepoint
+// order, until ctx is done, the stream is closed by Companies House or h
+// returns an error. Blank heartbeat lines are skipped. It returns nil once ctx
+// is done.
+func Stream(ctx context.Context, cfg Config, h func(e Event) error) error {
+	if cfg.URL == "" {
+		cfg.URL = defaultURL
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return fmt.Errorf("error parsing stream url: %w", err)
+	}
+	if cfg.Timepoint != 0 {
+		q := u.Query()
+		q.Set("timepoint", strconv.FormatInt(cfg.Timepoint, 10))
+		u.RawQuery = q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(cfg.Key, "")
+	resp, err := cfg.Client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("error opening stream: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &StatusError{StatusCode: resp.StatusCode, Body: string(b)}
+	}
+	scan := bufio.NewScanner(resp.Body)
+	scan.Buffer(nil, 1<<20)
+	for scan.Scan() {
+		line := scan.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(line, &e); err != nil {
+			return fmt.Errorf("error reading event: %w", err)
+		}
+		if err := h(e); err != nil {
+			return fmt.Errorf("error processing event handler: %w", err)
+		}
+	}
+	if err := scan.Err(); err != nil && !errors.Is(err, context.Canceled) && ctx.Err() == nil {
+		return fmt.Errorf("error reading stream: %w", err)
+	}
+	return nil
+}
+
+// CompanyNumber returns the company number of the resource URI of e, or "" if
+// it has none.
+func (e Event) CompanyNumber() string {
+	rest, ok := strings.CutPrefix(e.ResourceURI, "/company/")
+	if !ok {
+		return ""
+	}
+	number, _, _ := strings.Cut(rest, "/")
+	return number
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("stream returned %d: %s", e.StatusCode, e.Body)
+}
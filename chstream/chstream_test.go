@@ -0,0 +1,53 @@
+package chstream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testEvent = `{"resource_kind":"company-officers","resource_uri":"/company/00463819/appointments/abc","resource_id":"abc",` +
+	`"data":{"name":"KJAERSGAARD, Hans","officer_role":"director","appointed_on":"1991-09-15","address":{"postal_code":"NP25 3DZ"},` +
+	`"date_of_birth":{"month":9,"year":1945},"nationality":"Danish","occupation":"Marketing Director"},` +
+	`"event":{"timepoint":42,"published_at":"2024-01-02T10:00:00","type":"changed"}}`
+
+func Test_Stream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, _, ok := r.BasicAuth(); !ok || user != "key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("timepoint") != "42" {
+			http.Error(w, "unexpected timepoint", http.StatusBadRequest)
+			return
+		}
+		_, _ = io.WriteString(w, testEvent+"\n\n"+testEvent+"\n")
+	}))
+	defer srv.Close()
+
+	var events []Event
+	err := Stream(context.Background(), Config{URL: srv.URL, Key: "key", Timepoint: 42}, func(e Event) error {
+		events = append(events, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events got %d", len(events))
+	}
+	if e := events[0]; e.CompanyNumber() != "00463819" || e.Event.Timepoint != 42 || e.Data.Address.PostalCode != "NP25 3DZ" || e.Data.DateOfBirth.Year != 1945 {
+		t.Errorf("unexpected event %+v", e)
+	}
+
+	var se *StatusError
+	if err := Stream(context.Background(), Config{URL: srv.URL, Key: "other"}, func(e Event) error { return nil }); !errors.As(err, &se) || se.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected a StatusError got %v", err)
+	}
+	if (Event{ResourceURI: "/officers/abc"}).CompanyNumber() != "" {
+		t.Error("expected no company number")
+	}
+}
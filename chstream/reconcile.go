@@ -0,0 +1,183 @@
+package chstream
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	ch "github.com/richardjennings/chapointdat"
+)
+
+const (
+	// DivergenceAppointed is a current appointment of an event which is not
+	// in the Dataset.
+	DivergenceAppointed = DivergenceKind("appointed")
+	// DivergenceResigned is an appointment which is current in the Dataset
+	// but resigned in an event.
+	DivergenceResigned = DivergenceKind("resigned")
+	// DivergenceDeleted is an appointment of the Dataset deleted by an event.
+	DivergenceDeleted = DivergenceKind("deleted")
+	// DivergenceChanged is an appointment whose fields differ between the
+	// Dataset and an event.
+	DivergenceChanged = DivergenceKind("changed")
+)
+
+type (
+	DivergenceKind string
+
+	// Divergence is an event which does not agree with the Dataset.
+	Divergence struct {
+		Kind  DivergenceKind
+		Event Event
+		// Appointment is the appointment of the Dataset matching the event,
+		// if any.
+		Appointment *ch.Person `json:",omitempty"`
+		// Fields are the names of the fields of the appointment which differ,
+		// for DivergenceChanged.
+		Fields []string `json:",omitempty"`
+	}
+
+	// Reconciler compares the events of the officers stream with a Dataset,
+	// calling a handler with each Divergence. Pass its Write method to Stream.
+	//
+	// The stream identifies appointments by an id which is not in the
+	// snapshot, so an event is matched to the appointment of its company with
+	// the same name and role. Events of companies not in the Dataset are
+	// ignored, as a Dataset may hold only the companies of interest.
+	Reconciler struct {
+		mu        sync.Mutex
+		handler   func(d Divergence) error
+		companies map[string][]ch.Person
+		timepoint int64
+	}
+)
+
+// officerRoles are the officer_role of the stream of each current and resigned
+// AppointmentType.
+var officerRoles = map[string]string{
+	"00": "secretary", "02": "secretary",
+	"01": "director", "03": "director",
+	"04": "llp-member", "06": "llp-member",
+	"05": "llp-designated-member", "07": "llp-designated-member",
+	"11": "judicial-factor", "14": "judicial-factor",
+	"12": "receiver-and-manager", "15": "receiver-and-manager",
+	"13": "cic-manager", "16": "cic-manager",
+	"17": "member-of-an-administrative-organ", "20": "member-of-an-administrative-organ",
+	"18": "member-of-a-supervisory-organ", "21": "member-of-a-supervisory-organ",
+	"19": "member-of-a-management-organ", "22": "member-of-a-management-organ",
+}
+
+// NewReconciler returns a Reconciler comparing events with d, which is not
+// read again, calling h with each Divergence.
+func NewReconciler(d *ch.Dataset, h func(d Divergence) error) *Reconciler {
+	r := &Reconciler{handler: h, companies: map[string][]ch.Person{}}
+	for n := range d.Companies {
+		r.companies[n] = nil
+	}
+	for _, k := range d.Keys() {
+		r.companies[k.CompanyNumber] = append(r.companies[k.CompanyNumber], d.Appointments[k])
+	}
+	return r
+}
+
+// Write compares the appointment of e with the Dataset, calling the handler
+// if they diverge. Events of other kinds of resource are ignored.
+func (r *Reconciler) Write(e Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.timepoint = e.Event.Timepoint
+	if e.ResourceKind != "company-officers" {
+		return nil
+	}
+	appointments, ok := r.companies[e.CompanyNumber()]
+	if !ok {
+		return nil
+	}
+	p := match(appointments, e.Data)
+	d := Divergence{Event: e, Appointment: p}
+	switch {
+	case e.Event.Type == "deleted":
+		if p == nil {
+			return nil
+		}
+		d.Kind = DivergenceDeleted
+	case p == nil:
+		if e.Data.ResignedOn != "" {
+			return nil
+		}
+		d.Kind = DivergenceAppointed
+	case e.Data.ResignedOn != "" && p.ResignationDate == "" && !resignedType(p.AppointmentType):
+		d.Kind = DivergenceResigned
+	default:
+		if d.Fields = changedFields(*p, e.Data); len(d.Fields) == 0 {
+			return nil
+		}
+		d.Kind = DivergenceChanged
+	}
+	if err := r.handler(d); err != nil {
+		return fmt.Errorf("error processing divergence handler: %w", err)
+	}
+	return nil
+}
+
+// Timepoint returns the timepoint of the last event written, from which a
+// stream can be resumed.
+func (r *Reconciler) Timepoint() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.timepoint
+}
+
+// match returns the first of appointments with the name and role of o.
+func match(appointments []ch.Person, o Officer) *ch.Person {
+	name, role := normalise(o.Name), officerRole(o.OfficerRole)
+	for i, p := range appointments {
+		full := p.Surname
+		if strings.TrimSpace(p.Forenames) != "" {
+			full += ", " + p.Forenames
+		}
+		if normalise(full) == name && officerRoles[p.AppointmentType] == role {
+			return &appointments[i]
+		}
+	}
+	return nil
+}
+
+// changedFields returns the names of the fields of p which differ from those
+// given by o.
+func changedFields(p ch.Person, o Officer) []string {
+	var fields []string
+	compare := func(field, snapshot, event string) {
+		if event != "" && normalise(snapshot) != normalise(event) {
+			fields = append(fields, field)
+		}
+	}
+	compare("AppointmentDate", p.AppointmentDate, strings.ReplaceAll(o.AppointedOn, "-", ""))
+	compare("Postcode", strings.ReplaceAll(p.Postcode, " ", ""), strings.ReplaceAll(o.Address.PostalCode, " ", ""))
+	if o.DateOfBirth != nil {
+		compare("PartialDateOfBirth", p.PartialDateOfBirth, fmt.Sprintf("%04d%02d", o.DateOfBirth.Year, o.DateOfBirth.Month))
+	}
+	compare("Nationality", p.Nationality, o.Nationality)
+	compare("Occupation", p.Occupation, o.Occupation)
+	return fields
+}
+
+// officerRole returns role without the corporate and nominee qualifiers, which
+// the snapshot records separately or not at all.
+func officerRole(role string) string {
+	role = strings.TrimPrefix(role, "corporate-")
+	return strings.TrimPrefix(role, "nominee-")
+}
+
+func resignedType(t string) bool {
+	switch t {
+	case "02", "03", "06", "07", "14", "15", "16", "20", "21", "22":
+		return true
+	}
+	return false
+}
+
+// normalise returns s in upper case with runs of spaces collapsed.
+func normalise(s string) string {
+	return strings.Join(strings.Fields(strings.ToUpper(s)), " ")
+}
@@ -0,0 +1,67 @@
+package chstream
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	ch "github.com/richardjennings/chapointdat"
+)
+
+const testPersonLine = "004638192201024407940002        19910915        NP25 3DZ194509          0093MR<HANS<KJAERSGAARD<<<<1 AGINCOURT STREET<<MONMOUTH<<WALES<MARKETING DIRECTOR<DANISH<ENGLAND<"
+
+func testReconcileEvent(t *testing.T, edit func(e *Event)) Event {
+	var e Event
+	if err := json.Unmarshal([]byte(testEvent), &e); err != nil {
+		t.Fatal(err)
+	}
+	edit(&e)
+	return e
+}
+
+func Test_Reconciler(t *testing.T) {
+	d := ch.NewDataset()
+	r := ch.NewReader(ch.WithRecordHandler(d.Write))
+	snapshot := "DDDDSNAP019520240101\n004638191D                      00010019A. WEST & PARTNERS<\n" + testPersonLine + "\n9999999900000002\n"
+	if _, err := r.ExtractReader(strings.NewReader(snapshot), "-", func(err error) { t.Error(err) }); err != nil {
+		t.Fatal(err)
+	}
+
+	var divergences []Divergence
+	rc := NewReconciler(d, func(d Divergence) error {
+		divergences = append(divergences, d)
+		return nil
+	})
+	for _, e := range []Event{
+		// agrees with the snapshot
+		testReconcileEvent(t, func(e *Event) {}),
+		testReconcileEvent(t, func(e *Event) { e.Data.Name = "SMITH, Anna" }),
+		testReconcileEvent(t, func(e *Event) { e.Data.Address.PostalCode = "CF14 3UZ"; e.Data.Occupation = "" }),
+		testReconcileEvent(t, func(e *Event) { e.Data.ResignedOn = "2024-01-10" }),
+		testReconcileEvent(t, func(e *Event) { e.Event.Type = "deleted" }),
+		// a secretary and another company are not in the snapshot
+		testReconcileEvent(t, func(e *Event) { e.Data.OfficerRole = "secretary"; e.Data.ResignedOn = "2024-01-10" }),
+		testReconcileEvent(t, func(e *Event) { e.ResourceURI = "/company/00000099/appointments/abc"; e.Event.Timepoint = 50 }),
+	} {
+		if err := rc.Write(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var kinds []DivergenceKind
+	for _, d := range divergences {
+		kinds = append(kinds, d.Kind)
+	}
+	if expected := []DivergenceKind{DivergenceAppointed, DivergenceChanged, DivergenceResigned, DivergenceDeleted}; !slices.Equal(kinds, expected) {
+		t.Fatalf("expected %v got %v", expected, kinds)
+	}
+	if d := divergences[1]; !slices.Equal(d.Fields, []string{"Postcode"}) || d.Appointment == nil || d.Appointment.PersonNumber != "024407940002" {
+		t.Errorf("unexpected divergence %+v", d)
+	}
+	if divergences[0].Appointment != nil {
+		t.Error("expected no appointment for a new appointment")
+	}
+	if rc.Timepoint() != 50 {
+		t.Errorf("expected timepoint 50 got %d", rc.Timepoint())
+	}
+}
@@ -0,0 +1,174 @@
+package chapointdat
+
+import (
+	"cmp"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type (
+	// CompanyChurn is the appointments of a company added and removed between
+	// runs. A rise in churn, such as directors resigning together, is an early
+	// sign of distress.
+	CompanyChurn struct {
+		CompanyNumber string
+		// Officers and PreviousOfficers are the appointments of the company in
+		// the run and the run before.
+		Officers, PreviousOfficers int
+		Added, Removed             int
+		Run                        int
+		ProdDate                   time.Time
+		PreviousRun                int
+		PreviousProdDate           time.Time
+	}
+
+	// ChurnTracker follows the appointments of each company across runs, read
+	// in run order, calling a handler with the CompanyChurn of each company
+	// whose appointments changed. Pass its Write method to WithRecordHandler
+	// and call EndRun once each run has been read.
+	ChurnTracker struct {
+		mu       sync.Mutex
+		handler  func(c CompanyChurn) error
+		previous map[string]map[churnKey]bool
+		current  map[string]map[churnKey]bool
+		runPair
+	}
+	// churnKey identifies an appointment within a company.
+	churnKey struct {
+		personNumber, appointmentType string
+	}
+
+	// ChurnCSV writes CompanyChurn as CSV, starting with a row of field names.
+	// Pass its Write method to NewChurnTracker and call Flush once done.
+	ChurnCSV struct {
+		w      *csv.Writer
+		header bool
+	}
+)
+
+// NewChurnTracker returns a ChurnTracker calling h with the churn of each
+// company.
+func NewChurnTracker(h func(c CompanyChurn) error) *ChurnTracker {
+	return &ChurnTracker{handler: h, current: map[string]map[churnKey]bool{}}
+}
+
+// TrackChurn reads every run of the snapshots in dir, as WalkArchive, calling
+// h with the churn of companies between them. The first run read is the
+// baseline and has no churn. opts must not include WithRecordHandler.
+func TrackChurn(dir string, h func(c CompanyChurn) error, errH func(err error), opts ...Opt) error {
+	t := NewChurnTracker(h)
+	return walkRuns(dir, t.Write, t.EndRun, errH, opts)
+}
+
+// Write records the run of headers, the companies and the appointments of
+// persons. Other records are ignored.
+func (t *ChurnTracker) Write(rec Record) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch rec.Kind {
+	case RecordKindHeader:
+		t.header(rec.Header)
+	case RecordKindCompany:
+		if t.current[rec.Company.CompanyNumber] == nil {
+			t.current[rec.Company.CompanyNumber] = map[churnKey]bool{}
+		}
+	case RecordKindPerson:
+		p := rec.Person
+		if t.current[p.CompanyNumber] == nil {
+			t.current[p.CompanyNumber] = map[churnKey]bool{}
+		}
+		t.current[p.CompanyNumber][churnKey{p.PersonNumber, p.AppointmentType}] = true
+	}
+	return nil
+}
+
+// EndRun calls the handler, in company number order, with the churn from the
+// previous run to the run written since, which becomes the previous run.
+// Companies in only one of the runs are not reported, as their appointments
+// were not added or removed one by one.
+func (t *ChurnTracker) EndRun() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.previous != nil {
+		var churn []CompanyChurn
+		for n, before := range t.previous {
+			after, ok := t.current[n]
+			if !ok {
+				continue
+			}
+			c := CompanyChurn{
+				CompanyNumber: n, Officers: len(after), PreviousOfficers: len(before),
+				Run: t.run, ProdDate: t.prodDate, PreviousRun: t.previousRun, PreviousProdDate: t.previousProdDate,
+			}
+			for k := range after {
+				if !before[k] {
+					c.Added++
+				}
+			}
+			for k := range before {
+				if !after[k] {
+					c.Removed++
+				}
+			}
+			if c.Added > 0 || c.Removed > 0 {
+				churn = append(churn, c)
+			}
+		}
+		slices.SortFunc(churn, func(a, b CompanyChurn) int {
+			return cmp.Compare(a.CompanyNumber, b.CompanyNumber)
+		})
+		for _, c := range churn {
+			if err := t.handler(c); err != nil {
+				return fmt.Errorf("error processing churn handler: %w", err)
+			}
+		}
+	}
+	t.previous, t.current = t.current, map[string]map[churnKey]bool{}
+	t.endRun()
+	return nil
+}
+
+// Rate returns the appointments added and removed as a fraction of the
+// appointments in the previous run, or 0 if there were none.
+func (c CompanyChurn) Rate() float64 {
+	if c.PreviousOfficers == 0 {
+		return 0
+	}
+	return float64(c.Added+c.Removed) / float64(c.PreviousOfficers)
+}
+
+var churnCSVHeader = []string{"CompanyNumber", "Run", "ProdDate", "PreviousRun", "PreviousProdDate", "PreviousOfficers", "Officers", "Added", "Removed", "Rate"}
+
+// NewChurnCSV returns a ChurnCSV writing to w.
+func NewChurnCSV(w io.Writer) *ChurnCSV {
+	return &ChurnCSV{w: csv.NewWriter(w)}
+}
+
+// Write writes c as a row, with dates in ISO 8601 format.
+func (w *ChurnCSV) Write(c CompanyChurn) error {
+	if !w.header {
+		w.header = true
+		if err := w.w.Write(churnCSVHeader); err != nil {
+			return err
+		}
+	}
+	return w.w.Write([]string{
+		c.CompanyNumber,
+		strconv.Itoa(c.Run), c.ProdDate.Format(time.DateOnly),
+		strconv.Itoa(c.PreviousRun), c.PreviousProdDate.Format(time.DateOnly),
+		strconv.Itoa(c.PreviousOfficers), strconv.Itoa(c.Officers),
+		strconv.Itoa(c.Added), strconv.Itoa(c.Removed),
+		strconv.FormatFloat(c.Rate(), 'f', 4, 64),
+	})
+}
+
+// Flush writes any buffered rows.
+func (w *ChurnCSV) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
@@ -0,0 +1,56 @@
+package chapointdat
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_TrackChurn(t *testing.T) {
+	dir := t.TempDir()
+	company := strings.Replace(testCompanyLine, "00000084", "00463819", 1)
+	other := strings.Replace(testCompanyLine, "00000084", "00000085", 1)
+	// the same person resigns one appointment and takes up another
+	secretary := strings.Replace(testPersonLine, "2201024407940002", "2200024407940002", 1)
+	newcomer := strings.Replace(testPersonLine, "024407940002", "024407940003", 1)
+	for name, lines := range map[string][]string{
+		"Prod195_0001.dat": {company, testPersonLine, other},
+		"Prod195_0002.dat": {company, secretary, newcomer, other},
+		"Prod195_0003.dat": {company, secretary, newcomer},
+	} {
+		run := strings.TrimSuffix(strings.TrimPrefix(name, "Prod195_"), ".dat")
+		content := strings.Replace(testSnapshot(lines...), "0195", run, 1)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var churn []CompanyChurn
+	var b strings.Builder
+	w := NewChurnCSV(&b)
+	err := TrackChurn(dir, func(c CompanyChurn) error {
+		churn = append(churn, c)
+		return w.Write(c)
+	}, func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// the removal of 00000085 in run 3 is not churn
+	if len(churn) != 1 {
+		t.Fatalf("unexpected churn %+v", churn)
+	}
+	if c := churn[0]; c.CompanyNumber != "00463819" || c.Added != 2 || c.Removed != 1 || c.PreviousOfficers != 1 || c.Officers != 2 || c.Run != 2 || c.PreviousRun != 1 || c.Rate() != 3 {
+		t.Errorf("unexpected churn %+v", c)
+	}
+	expected := "CompanyNumber,Run,ProdDate,PreviousRun,PreviousProdDate,PreviousOfficers,Officers,Added,Removed,Rate\n" +
+		"00463819,2,2024-01-01,1,2024-01-01,1,2,2,1,3.0000\n"
+	if b.String() != expected {
+		t.Errorf("expected %q got %q", expected, b.String())
+	}
+	if (CompanyChurn{Added: 1}).Rate() != 0 {
+		t.Error("expected no rate without previous officers")
+	}
+}
@@ -0,0 +1,42 @@
+package chapointdat
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// WithClock sets the function returning the current time, time.Now by default.
+// It is used for the age checked by WithMaxHeaderAge, the Time of dead letters
+// and the timestamps of WithWebhook without a WebhookConfig.Now, so that with a
+// fixed clock, such as from FixedClock, two runs over the same input with the
+// same options give byte-identical output. The system clock is always used
+// for the pacing of WithRateLimit and, in the objstore package, for signing S3
+// requests and the expiry of MetadataToken tokens, which must agree with real
+// time and the clocks of servers.
+func WithClock(now func() time.Time) Opt {
+	return func(r *Reader) {
+		r.now = now
+	}
+}
+
+// FixedClock returns a clock for WithClock which always returns t.
+func FixedClock(t time.Time) func() time.Time {
+	return func() time.Time { return t }
+}
+
+// SourceDateEpochClock returns a FixedClock at the Unix time in the
+// SOURCE_DATE_EPOCH environment variable, the convention of reproducible
+// builds, or time.Now if it is not set.
+func SourceDateEpochClock() (func() time.Time, error) {
+	v, ok := os.LookupEnv("SOURCE_DATE_EPOCH")
+	if !ok || v == "" {
+		return time.Now, nil
+	}
+	secs, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("error reading SOURCE_DATE_EPOCH: %w", err)
+	}
+	return FixedClock(time.Unix(secs, 0).UTC()), nil
+}
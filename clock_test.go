@@ -0,0 +1,52 @@
+package chapointdat
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_WithClock(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	run := func() []byte {
+		var b bytes.Buffer
+		r := NewReader(WithClock(FixedClock(at)), WithDeadLetterSink(NewFileDeadLetterSink(&b)), WithRelaxedFraming())
+		if _, err := r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine, "short")), "-", func(err error) {}); err != nil {
+			t.Fatal(err)
+		}
+		return b.Bytes()
+	}
+	first := run()
+	if !bytes.Contains(first, []byte("2024-03-01T12:00:00Z")) {
+		t.Errorf("expected the time of the clock in %s", first)
+	}
+	if second := run(); !bytes.Equal(first, second) {
+		t.Errorf("expected identical output got %s and %s", first, second)
+	}
+
+	r := NewReader(WithClock(FixedClock(at)), WithMaxHeaderAge(30*24*time.Hour))
+	if _, err := r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine)), "-", func(err error) {}); !errors.Is(err, ErrStaleSnapshot) {
+		t.Errorf("expected ErrStaleSnapshot got %v", err)
+	}
+	r = NewReader(WithClock(FixedClock(at)), WithMaxHeaderAge(90*24*time.Hour))
+	if _, err := r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine)), "-", func(err error) { t.Error(err) }); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_SourceDateEpochClock(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1709294400")
+	now, err := SourceDateEpochClock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !now().Equal(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected time %s", now())
+	}
+	t.Setenv("SOURCE_DATE_EPOCH", "yesterday")
+	if _, err := SourceDateEpochClock(); err == nil {
+		t.Error("expected an error")
+	}
+}
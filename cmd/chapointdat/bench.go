@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/richardjennings/chapointdat/bench"
+	"log"
+	"os"
+	"slices"
+	"strings"
+)
+
+func benchmark(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	baselinePath := fs.String("baseline", "", "baseline to compare with, instead of the one published with the bench package")
+	out := fs.String("o", "", "write the results as a baseline to this file, - for stdout")
+	sizes := fs.String("sizes", "", "comma separated sizes to measure, of 1k, 20k and 200k, all by default")
+	tolerance := fs.Float64("tolerance", 0.1, "fraction by which a result may be worse than the baseline")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: chapointdat bench [options]")
+		fmt.Fprintln(fs.Output(), "Measures extracting generated snapshots and reports regressions from a baseline.")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	measured := bench.Sizes
+	if *sizes != "" {
+		measured = nil
+		for _, name := range strings.Split(*sizes, ",") {
+			i := slices.IndexFunc(bench.Sizes, func(s bench.Size) bool { return s.Name == name })
+			if i < 0 {
+				return fmt.Errorf("unknown size: %s", name)
+			}
+			measured = append(measured, bench.Sizes[i])
+		}
+	}
+	base, err := bench.DefaultBaseline()
+	if *baselinePath != "" {
+		f, ferr := os.Open(*baselinePath)
+		if ferr != nil {
+			return ferr
+		}
+		base, err = bench.ReadBaseline(f)
+		_ = f.Close()
+	}
+	if err != nil {
+		return err
+	}
+	dir, err := os.MkdirTemp("", "chapointdat-bench-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	current, err := bench.Run(dir, measured, bench.Cases)
+	if err != nil {
+		return err
+	}
+	for _, r := range current.Results {
+		log.Printf("%s/%s: %.0f records/s, %.1f MB/s, %d allocs/op, %d B/op", r.Case, r.Size, r.RecordsPerSecond, r.MBPerSecond, r.AllocsPerOp, r.BytesPerOp)
+	}
+	if *out != "" {
+		w, err := create(*out)
+		if err != nil {
+			return err
+		}
+		if err := errors.Join(bench.WriteBaseline(w, current), w.Close()); err != nil {
+			return err
+		}
+	}
+	if base.GOARCH != current.GOARCH || base.CPUs != current.CPUs {
+		log.Printf("baseline measured on %s with %d CPUs, so compare records/s with care", base.GOARCH, base.CPUs)
+	}
+	if regressions := bench.Compare(base, current, *tolerance); len(regressions) > 0 {
+		return fmt.Errorf("regressions from baseline: %v", regressions)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	ch "github.com/richardjennings/chapointdat"
+	"log"
+	"os"
+)
+
+func churn(args []string) error {
+	fs := flag.NewFlagSet("churn", flag.ExitOnError)
+	format := fs.String("format", "csv", "output format: csv or jsonl")
+	out := fs.String("o", "-", "output file, - for stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: chapointdat churn [options] <archive directory>")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	w, err := create(*out)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	var write func(c ch.CompanyChurn) error
+	flush := bw.Flush
+	switch *format {
+	case "csv":
+		cw := ch.NewChurnCSV(bw)
+		write = cw.Write
+		flush = func() error { return errors.Join(cw.Flush(), bw.Flush()) }
+	case "jsonl":
+		e := json.NewEncoder(bw)
+		write = func(c ch.CompanyChurn) error { return e.Encode(c) }
+	default:
+		return fmt.Errorf("unknown format: %s", *format)
+	}
+	err = ch.TrackChurn(fs.Arg(0), write, func(err error) { log.Print(err) })
+	return errors.Join(err, flush(), w.Close())
+}
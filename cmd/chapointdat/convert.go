@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	ch "github.com/richardjennings/chapointdat"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+func convert(args []string) error {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	format := fs.String("format", "jsonl", "output format: jsonl or csv")
+	out := fs.String("o", "-", "output file for jsonl, - for stdout")
+	companies := fs.String("companies", "", "output file for company csv")
+	persons := fs.String("persons", "", "output file for person csv")
+	delimiter := fs.String("delimiter", ",", "csv field delimiter")
+	quote := fs.String("quote", "minimal", "csv quoting: minimal, all or none")
+	sortLocale := fs.String("sort", "", "sort csv output by name with the collation of this locale, such as en or da, holding it in memory")
+	sel := fs.String("select", "", `export only the records matching an expression such as 'person.Postcode startswith "WA"'`)
+	numbers := fs.String("company-numbers", "preserve", "company number format: preserve, pad or strip")
+	blanks := fs.String("blanks", "empty", "how to write blank values: empty, null or sentinel")
+	sentinel := fs.String("blank-sentinel", `\N`, "value written for blank values with -blanks sentinel")
+	only := fs.String("select-companies", "", "file of company numbers, one per line, to convert only those companies and their officers")
+	cp1252 := fs.Bool("cp1252-fallback", false, "decode files re-saved by Windows tools from Windows-1252")
+	include := fs.String("include", "", "comma separated glob patterns of the zip entries to read, such as *.dat")
+	exclude := fs.String("exclude", "", "comma separated glob patterns of zip entries not to read, such as README*")
+	gunzip := fs.Bool("gunzip", false, "decompress gzipped input")
+	memory := fs.Int64("memory-budget", 0, "bound memory use to about this many MiB, reading more slowly")
+	corrections := fs.String("corrections", "", "comma separated correction files for the run, applied in order over the snapshot")
+	shard := fs.String("shard", "", "read only the shard of a JSON descriptor written by the shards command, instead of a file")
+	var esc ch.ExportEscaping
+	fs.Func("replace-terminator", "replace any < in values with this string", func(s string) error {
+		esc.ReplaceTerminator, esc.TerminatorReplacement = true, s
+		return nil
+	})
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: chapointdat convert [options] <file.zip|file.dat|->")
+		fmt.Fprintln(fs.Output(), "       chapointdat convert [options] -shard <descriptor>")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 && *shard == "" || fs.NArg() != 0 && *shard != "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	if n := utf8.RuneCountInString(*delimiter); n != 1 {
+		return fmt.Errorf("invalid delimiter: %q", *delimiter)
+	}
+	esc.Delimiter, _ = utf8.DecodeRuneInString(*delimiter)
+	switch *quote {
+	case "minimal":
+		esc.Quote = ch.QuoteMinimal
+	case "all":
+		esc.Quote = ch.QuoteAll
+	case "none":
+		esc.Quote = ch.QuoteNone
+	default:
+		return fmt.Errorf("unknown quoting: %s", *quote)
+	}
+
+	numberFormat, err := ch.ParseCompanyNumberFormat(*numbers)
+	if err != nil {
+		return err
+	}
+	esc.Blanks.Mode, err = ch.ParseBlankMode(*blanks)
+	if err != nil {
+		return err
+	}
+	esc.Blanks.Sentinel = *sentinel
+	opts := []ch.Opt{ch.WithExportEscaping(esc), ch.WithCompanyNumberFormat(numberFormat), ch.WithMemoryBudget(*memory << 20)}
+	if *cp1252 {
+		opts = append(opts, ch.WithCP1252Fallback())
+	}
+	if *gunzip {
+		opts = append(opts, ch.WithInputHook(ch.GunzipInput))
+	}
+	if *include != "" || *exclude != "" {
+		opts = append(opts, ch.WithEntryFilter(ch.EntryFilter{Include: ch.ParseEntryPatterns(*include), Exclude: ch.ParseEntryPatterns(*exclude)}))
+	}
+	if *only != "" {
+		f, err := os.Open(*only)
+		if err != nil {
+			return err
+		}
+		set, err := ch.ReadCompanySet(f)
+		_ = f.Close()
+		if err != nil {
+			return err
+		}
+		opts = append(opts, ch.WithCompanyNumbers(set))
+	}
+	if *corrections != "" {
+		c, err := ch.ReadCorrections(strings.Split(*corrections, ",")...)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, ch.WithCorrections(c))
+	}
+	if *sel != "" {
+		f, err := ch.ParseSelect(*sel)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, ch.WithSelect(f))
+	}
+	// closeAll closes the output files once, so that errors writing them on
+	// close are returned after extraction
+	var closers []io.Closer
+	closeAll := func() error {
+		var errs []error
+		for _, c := range closers {
+			errs = append(errs, c.Close())
+		}
+		closers = nil
+		return errors.Join(errs...)
+	}
+	defer func() { _ = closeAll() }()
+	switch *format {
+	case "jsonl":
+		w, err := create(*out)
+		if err != nil {
+			return err
+		}
+		closers = append(closers, w)
+		opts = append(opts, ch.WithJSONLExport(w))
+	case "csv":
+		if *companies == "" && *persons == "" {
+			return errors.New("csv output requires -companies and/or -persons")
+		}
+		var cw, pw io.WriteCloser
+		var err error
+		if *companies != "" {
+			if cw, err = create(*companies); err != nil {
+				return err
+			}
+			closers = append(closers, cw)
+		}
+		if *persons != "" {
+			if pw, err = create(*persons); err != nil {
+				return err
+			}
+			closers = append(closers, pw)
+		}
+		if *sortLocale != "" {
+			c, err := ch.NewCollator(*sortLocale)
+			if err != nil {
+				return err
+			}
+			opts = append(opts, ch.WithSortedCSVExport(writer(cw), writer(pw), c))
+		} else {
+			opts = append(opts, ch.WithCSVExport(writer(cw), writer(pw)))
+		}
+	default:
+		return fmt.Errorf("unknown format: %s", *format)
+	}
+
+	if *shard != "" {
+		var sh ch.Shard
+		if err := json.Unmarshal([]byte(*shard), &sh); err != nil {
+			return fmt.Errorf("error parsing shard: %w", err)
+		}
+		s, err := extractShard(sh, opts)
+		return errors.Join(err, closeAll(), report(s))
+	}
+	s, err := extract(fs.Args(), opts)
+	return errors.Join(err, closeAll(), report(s))
+}
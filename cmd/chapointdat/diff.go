@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	ch "github.com/richardjennings/chapointdat"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+func diff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	format := fs.String("format", "table", "output format: table, json or csv")
+	out := fs.String("o", "-", "output file, - for stdout")
+	companies := fs.String("companies", "", "comma separated company numbers to compare, or @file with one per line")
+	areas := fs.String("postcode-area", "", "comma separated postcode areas, such as NP,CF, of appointments to report")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: chapointdat diff [options] <old.zip[,part2.zip...]> <new.zip[,part2.zip...]>")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	switch *format {
+	case "table", "json", "csv":
+	default:
+		return fmt.Errorf("unknown format: %s", *format)
+	}
+	include, err := companyFilter(*companies)
+	if err != nil {
+		return err
+	}
+
+	var datasets [2]*ch.Dataset
+	for i, arg := range fs.Args() {
+		d := ch.NewDataset()
+		handler := func(rec ch.Record) error {
+			if include != nil && !include[recordCompanyNumber(rec)] {
+				return nil
+			}
+			return d.Write(rec)
+		}
+		if _, err := extract(strings.Split(arg, ","), []ch.Opt{ch.WithRecordHandler(handler)}); err != nil {
+			return fmt.Errorf("error reading %s: %w", arg, err)
+		}
+		datasets[i] = d
+	}
+
+	var changes []ch.AppointmentChange
+	wanted := splitList(strings.ToUpper(*areas))
+	for _, c := range ch.DiffDatasets(datasets[0], datasets[1]) {
+		// an appointment is reported if it was in an area before or after
+		if len(wanted) == 0 || c.Old != nil && wanted[postcodeArea(c.Old.Postcode)] || c.New != nil && wanted[postcodeArea(c.New.Postcode)] {
+			changes = append(changes, c)
+		}
+	}
+
+	w, err := create(*out)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	switch *format {
+	case "table":
+		err = writeDiffTable(bw, changes)
+	case "json":
+		err = writeDiffJSON(bw, changes)
+	case "csv":
+		err = writeDiffCSV(bw, changes)
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func writeDiffTable(w io.Writer, changes []ch.AppointmentChange) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHANGE\tCOMPANY\tPERSON\tTYPE\tNAME\tPOSTCODE\tFIELDS")
+	for _, c := range changes {
+		p := c.Person()
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", c.Kind, c.Key.CompanyNumber, c.Key.PersonNumber, c.Key.AppointmentType,
+			strings.TrimSpace(p.Forenames+" "+p.Surname), p.Postcode, strings.Join(c.Fields, ","))
+	}
+	return tw.Flush()
+}
+
+func writeDiffJSON(w io.Writer, changes []ch.AppointmentChange) error {
+	e := json.NewEncoder(w)
+	e.SetEscapeHTML(false)
+	for _, c := range changes {
+		if err := e.Encode(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeDiffCSV(w io.Writer, changes []ch.AppointmentChange) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"change", "company_number", "person_number", "appointment_type", "forenames", "surname", "postcode", "fields"})
+	for _, c := range changes {
+		p := c.Person()
+		_ = cw.Write([]string{string(c.Kind), c.Key.CompanyNumber, c.Key.PersonNumber, c.Key.AppointmentType,
+			p.Forenames, p.Surname, p.Postcode, strings.Join(c.Fields, ",")})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// companyFilter returns the set of company numbers in list, or in the file
+// named after an @, or nil for no filter.
+func companyFilter(list string) (map[string]bool, error) {
+	if list == "" {
+		return nil, nil
+	}
+	if path, ok := strings.CutPrefix(list, "@"); ok {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		list = strings.Join(strings.Fields(string(b)), ",")
+	}
+	return splitList(list), nil
+}
+
+func splitList(list string) map[string]bool {
+	set := map[string]bool{}
+	for _, v := range strings.Split(list, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			set[v] = true
+		}
+	}
+	return set
+}
+
+func recordCompanyNumber(rec ch.Record) string {
+	switch rec.Kind {
+	case ch.RecordKindCompany:
+		return rec.Company.CompanyNumber
+	case ch.RecordKindPerson:
+		return rec.Person.CompanyNumber
+	}
+	return ""
+}
+
+// postcodeArea returns the letters a postcode starts with, such as NP for
+// NP25 3DZ.
+func postcodeArea(postcode string) string {
+	postcode = strings.ToUpper(strings.TrimSpace(postcode))
+	i := strings.IndexFunc(postcode, func(r rune) bool { return r < 'A' || r > 'Z' })
+	if i < 0 {
+		return postcode
+	}
+	return postcode[:i]
+}
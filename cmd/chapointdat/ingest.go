@@ -0,0 +1,379 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	ch "github.com/richardjennings/chapointdat"
+	"github.com/richardjennings/chapointdat/bigquery"
+	"github.com/richardjennings/chapointdat/objstore"
+	"github.com/richardjennings/chapointdat/parquet"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ingestReport is written once ingest finishes, successfully or not.
+type ingestReport struct {
+	Status   string     `json:"status"`
+	Error    string     `json:"error,omitempty"`
+	Sources  []string   `json:"sources"`
+	Sink     string     `json:"sink"`
+	Started  time.Time  `json:"started"`
+	Finished time.Time  `json:"finished"`
+	Summary  ch.Summary `json:"summary"`
+	// Coverage is of the companies given by -expect-companies.
+	Coverage *ch.CoverageReport `json:"coverage,omitempty"`
+	// Drift is from the summary of the report given by -baseline.
+	Drift []ch.Drift `json:"drift,omitempty"`
+}
+
+func ingest(args []string) error {
+	fs := flag.NewFlagSet("ingest", flag.ExitOnError)
+	url := fs.String("url", "", "download the snapshot from url instead of reading files")
+	sink := fs.String("sink", "", "sink to load into: postgres, mysql, sqlite, bigquery, jsonl, csv or parquet")
+	dsn := fs.String("dsn", "", "connection string for postgres, database name for mysql, database file for sqlite, or project.dataset for bigquery")
+	script := fs.String("script", "", "write the SQL load script for postgres, mysql or sqlite to this file instead of running psql, mysql or sqlite3")
+	out := fs.String("o", "", "output file for jsonl, directory for csv or parquet, or gs://bucket/prefix/ to stage bigquery loads in")
+	replace := fs.Bool("replace", true, "replace existing rows in the same transaction")
+	parts := fs.Int("parts", 0, "number of parts expected in a split snapshot")
+	maxAge := fs.Duration("max-age", 0, "reject snapshots produced longer ago than this")
+	rate := fs.Float64("rate", 0, "load at most this many records per second, 0 for no limit")
+	maxErrors := fs.Int("max-errors", -1, "fail if more lines than this are rejected, -1 for no limit")
+	invalidBytes := fs.String("invalid-bytes", "keep", "policy for bytes which are not valid UTF-8: keep, reject, replace, question-mark or drop")
+	numbers := fs.String("company-numbers", "preserve", "company number format: preserve, pad or strip")
+	blankMode := fs.String("blanks", "empty", "how to load blank values: empty, null or sentinel")
+	sentinel := fs.String("blank-sentinel", `\N`, "value loaded for blank values with -blanks sentinel")
+	cp1252 := fs.Bool("cp1252-fallback", false, "decode files re-saved by Windows tools from Windows-1252")
+	include := fs.String("include", "", "comma separated glob patterns of the zip entries to read, such as *.dat")
+	exclude := fs.String("exclude", "", "comma separated glob patterns of zip entries not to read, such as README*")
+	gunzip := fs.Bool("gunzip", false, "decompress gzipped input")
+	memory := fs.Int64("memory-budget", 0, "bound memory use to about this many MiB, reading more slowly")
+	corrections := fs.String("corrections", "", "comma separated correction files for the run, applied in order over the snapshot")
+	expect := fs.String("expect-companies", "", "file of company numbers, one per line, to report the coverage of")
+	baseline := fs.String("baseline", "", "completion report of the last good run, failing if the summary drifts too far from it")
+	reportPath := fs.String("report", "-", "file to write the JSON completion report to, - for stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: chapointdat ingest [options] (-url <url> | <file.zip|file.dat>...)")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if (*url == "") == (fs.NArg() == 0) {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	rep := ingestReport{Sink: *sink, Started: now().UTC(), Sources: fs.Args()}
+	err := func() error {
+		paths := fs.Args()
+		if *url != "" {
+			rep.Sources = []string{*url}
+			path, err := download(*url)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = os.Remove(path) }()
+			paths = []string{path}
+		}
+		policy, err := ch.ParseInvalidBytesPolicy(*invalidBytes)
+		if err != nil {
+			return err
+		}
+		numberFormat, err := ch.ParseCompanyNumberFormat(*numbers)
+		if err != nil {
+			return err
+		}
+		opts := []ch.Opt{ch.WithExpectedParts(*parts), ch.WithMaxHeaderAge(*maxAge), ch.WithRateLimit(*rate), ch.WithInvalidBytes(policy), ch.WithCompanyNumberFormat(numberFormat), ch.WithMemoryBudget(*memory << 20)}
+		mode, err := ch.ParseBlankMode(*blankMode)
+		if err != nil {
+			return err
+		}
+		if *cp1252 {
+			opts = append(opts, ch.WithCP1252Fallback())
+		}
+		if *gunzip {
+			opts = append(opts, ch.WithInputHook(ch.GunzipInput))
+		}
+		if *include != "" || *exclude != "" {
+			opts = append(opts, ch.WithEntryFilter(ch.EntryFilter{Include: ch.ParseEntryPatterns(*include), Exclude: ch.ParseEntryPatterns(*exclude)}))
+		}
+		if *corrections != "" {
+			c, err := ch.ReadCorrections(strings.Split(*corrections, ",")...)
+			if err != nil {
+				return err
+			}
+			opts = append(opts, ch.WithCorrections(c))
+		}
+		if *expect != "" {
+			f, err := os.Open(*expect)
+			if err != nil {
+				return err
+			}
+			expected, err := ch.ReadCompanySet(f)
+			_ = f.Close()
+			if err != nil {
+				return fmt.Errorf("error reading %s: %w", *expect, err)
+			}
+			opts = append(opts, ch.WithCoverageReport(expected, func(c ch.CoverageReport) error {
+				rep.Coverage = &c
+				return nil
+			}))
+		}
+		var base *ingestReport
+		if *baseline != "" {
+			if base, err = readReport(*baseline); err != nil {
+				return err
+			}
+		}
+		load, err := newLoader(*sink, *dsn, *script, *out, *replace, ch.Blanks{Mode: mode, Sentinel: *sentinel})
+		if err != nil {
+			return err
+		}
+		s, err := extract(paths, append(opts, load.opts...))
+		rep.Summary = s
+		if err == nil && *maxErrors >= 0 && s.Errors > *maxErrors {
+			err = fmt.Errorf("%d lines rejected, more than the %d allowed", s.Errors, *maxErrors)
+		}
+		if err == nil && base != nil {
+			if rep.Drift = ch.CompareSummaries(base.Summary, s, ch.DefaultDriftTolerance); len(rep.Drift) > 0 {
+				err = fmt.Errorf("summary drifted from %s: %v", *baseline, rep.Drift)
+			}
+		}
+		return errors.Join(err, load.end(err == nil))
+	}()
+	rep.Finished = now().UTC()
+	rep.Status = "ok"
+	if err != nil {
+		rep.Status = "failed"
+		if errors.Is(err, ch.ErrStopped) {
+			rep.Status = "stopped"
+		}
+		rep.Error = err.Error()
+	}
+	return errors.Join(err, writeReport(*reportPath, rep))
+}
+
+// loader is a configured sink, ended with whether extraction succeeded.
+type loader struct {
+	opts []ch.Opt
+	end  func(ok bool) error
+}
+
+func newLoader(sink, dsn, script, out string, replace bool, blanks ch.Blanks) (loader, error) {
+	switch sink {
+	case "postgres", "sqlite", "mysql":
+		return newSQLLoader(ch.SQLDialect(sink), dsn, script, replace, blanks)
+	case "bigquery":
+		return newBigQueryLoader(dsn, out, replace, blanks)
+	case "jsonl":
+		w, err := create(out)
+		if err != nil {
+			return loader{}, err
+		}
+		return loader{opts: []ch.Opt{ch.WithExportEscaping(ch.ExportEscaping{Blanks: blanks}), ch.WithJSONLExport(w)}, end: func(bool) error { return w.Close() }}, nil
+	case "csv":
+		if out == "" {
+			return loader{}, errors.New("csv sink requires -o directory")
+		}
+		if err := os.MkdirAll(out, 0o755); err != nil {
+			return loader{}, err
+		}
+		cw, err := os.Create(filepath.Join(out, "companies.csv"))
+		if err != nil {
+			return loader{}, err
+		}
+		pw, err := os.Create(filepath.Join(out, "persons.csv"))
+		if err != nil {
+			_ = cw.Close()
+			return loader{}, err
+		}
+		return loader{opts: []ch.Opt{ch.WithExportEscaping(ch.ExportEscaping{Blanks: blanks}), ch.WithCSVExport(cw, pw)}, end: func(bool) error { return errors.Join(cw.Close(), pw.Close()) }}, nil
+	case "parquet":
+		return newParquetLoader(out, blanks)
+	case "":
+		return loader{}, errors.New("-sink is required")
+	default:
+		return loader{}, fmt.Errorf("unsupported sink: %s", sink)
+	}
+}
+
+// newParquetLoader writes companies.parquet and persons.parquet to the
+// directory out.
+func newParquetLoader(out string, blanks ch.Blanks) (loader, error) {
+	if out == "" {
+		return loader{}, errors.New("parquet sink requires -o directory")
+	}
+	if err := os.MkdirAll(out, 0o755); err != nil {
+		return loader{}, err
+	}
+	var l loader
+	var files []*os.File
+	var writers []*parquet.Writer
+	closeAll := func() error {
+		var errs []error
+		for _, f := range files {
+			errs = append(errs, f.Close())
+		}
+		return errors.Join(errs...)
+	}
+	for _, kind := range []ch.RecordKind{ch.RecordKindCompany, ch.RecordKindPerson} {
+		f, err := os.Create(filepath.Join(out, map[ch.RecordKind]string{ch.RecordKindCompany: "companies.parquet", ch.RecordKindPerson: "persons.parquet"}[kind]))
+		if err != nil {
+			return loader{}, errors.Join(err, closeAll())
+		}
+		files = append(files, f)
+		w, err := parquet.NewWriter(f, kind, 0)
+		if err != nil {
+			return loader{}, errors.Join(err, closeAll())
+		}
+		w.SetBlanks(blanks)
+		writers = append(writers, w)
+		l.opts = append(l.opts, ch.WithRecordHandler(w.Write))
+	}
+	l.end = func(bool) error {
+		var errs []error
+		for _, w := range writers {
+			errs = append(errs, w.Close())
+		}
+		return errors.Join(append(errs, closeAll())...)
+	}
+	return l, nil
+}
+
+// newSQLLoader writes a load script to a file, or pipes it to psql or sqlite3.
+func newSQLLoader(dialect ch.SQLDialect, dsn, script string, replace bool, blanks ch.Blanks) (loader, error) {
+	var w io.WriteCloser
+	var cmd *exec.Cmd
+	if script != "" {
+		f, err := os.Create(script)
+		if err != nil {
+			return loader{}, err
+		}
+		w = f
+	} else {
+		if dsn == "" {
+			return loader{}, fmt.Errorf("%s sink requires -dsn or -script", dialect)
+		}
+		switch dialect {
+		case ch.SQLDialectPostgres:
+			cmd = exec.Command("psql", "-X", "-q", "-v", "ON_ERROR_STOP=1", dsn)
+		case ch.SQLDialectMySQL:
+			// connection options come from option files or MYSQL_ environment
+			// variables, with the database named by dsn
+			cmd = exec.Command("mysql", "--batch", dsn)
+		default:
+			cmd = exec.Command("sqlite3", "-bail", dsn)
+		}
+		cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return loader{}, err
+		}
+		if err := cmd.Start(); err != nil {
+			return loader{}, fmt.Errorf("error starting %s: %w", cmd.Path, err)
+		}
+		w = stdin
+	}
+	s, err := ch.NewSQLScript(w, dialect, replace)
+	if err != nil {
+		return loader{}, err
+	}
+	s.SetBlanks(blanks)
+	return loader{
+		opts: []ch.Opt{ch.WithRecordHandler(s.Write)},
+		end: func(ok bool) error {
+			err := errors.Join(s.End(ok), w.Close())
+			if cmd != nil {
+				if werr := cmd.Wait(); werr != nil {
+					err = errors.Join(err, fmt.Errorf("error loading with %s: %w", cmd.Path, werr))
+				}
+			}
+			return err
+		},
+	}, nil
+}
+
+// newBigQueryLoader stages in out, a gs:// URL, and loads into dsn, a project
+// and dataset such as my-project.companies_house. The access token is read from
+// GOOGLE_OAUTH_ACCESS_TOKEN, or else from the metadata server.
+func newBigQueryLoader(dsn, out string, replace bool, blanks ch.Blanks) (loader, error) {
+	project, dataset, ok := strings.Cut(dsn, ".")
+	if !ok {
+		return loader{}, errors.New("bigquery sink requires -dsn project.dataset")
+	}
+	bucket, prefix, ok := strings.Cut(strings.TrimPrefix(out, "gs://"), "/")
+	if !strings.HasPrefix(out, "gs://") || bucket == "" {
+		return loader{}, errors.New("bigquery sink requires -o gs://bucket/prefix/")
+	}
+	if ok && prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	token := objstore.MetadataToken(nil)
+	if t := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); t != "" {
+		token = func() (string, error) { return t, nil }
+	}
+	l, err := bigquery.NewLoader(bigquery.Config{
+		Project: project,
+		Dataset: dataset,
+		Token:   token,
+		Stage:   &objstore.GCS{Bucket: bucket, Token: token},
+		Prefix:  prefix,
+		Append:  !replace,
+		Blanks:  blanks,
+	})
+	if err != nil {
+		return loader{}, err
+	}
+	return loader{opts: []ch.Opt{ch.WithRecordHandler(l.Write)}, end: l.End}, nil
+}
+
+// download saves url to a temporary file.
+func download(url string) (string, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error downloading %s: %s", url, resp.Status)
+	}
+	f, err := os.CreateTemp("", "chapointdat-*"+filepath.Ext(url))
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return "", fmt.Errorf("error downloading %s: %w", url, err)
+	}
+	log.Printf("downloaded %s", url)
+	return f.Name(), f.Close()
+}
+
+func readReport(path string) (*ingestReport, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rep ingestReport
+	if err := json.Unmarshal(b, &rep); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return &rep, nil
+}
+
+func writeReport(path string, rep ingestReport) error {
+	w, err := create(path)
+	if err != nil {
+		return err
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return errors.Join(e.Encode(rep), w.Close())
+}
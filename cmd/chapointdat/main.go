@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	ch "github.com/richardjennings/chapointdat"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const usage = `Usage: chapointdat <command> [options]
+
+Commands:
+  bench     measure extraction of generated snapshots and compare with a baseline
+  churn     print the appointments added and removed per company between the runs of an archive
+  convert   convert a snapshot (.zip or .dat, - for stdin) to JSON lines or CSV
+  diff      print the appointments added, removed and changed between two snapshots
+  ingest    download or read a snapshot, validate it and load it into a sink
+  reconcile reconcile the officers streaming API with a snapshot, printing where they diverge
+  sample    write an anonymized sample of a snapshot for bug reports and tests
+  search    search the names of companies and officers, building or reading a name index
+  schema    print the JSON Schema or SQL table definition of companies or persons
+  serve     serve the companies and persons of snapshots as Arrow IPC streams over HTTP
+  shards    split snapshots into shard descriptors for convert -shard on separate machines
+
+Setting SOURCE_DATE_EPOCH fixes the time used, for reproducible output.
+`
+
+// now is the clock of every command, fixed by SOURCE_DATE_EPOCH.
+var now = time.Now
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var err error
+	if now, err = ch.SourceDateEpochClock(); err != nil {
+		log.Fatal(err)
+	}
+	switch os.Args[1] {
+	case "bench":
+		err = benchmark(os.Args[2:])
+	case "churn":
+		err = churn(os.Args[2:])
+	case "convert":
+		err = convert(os.Args[2:])
+	case "diff":
+		err = diff(os.Args[2:])
+	case "ingest":
+		err = ingest(os.Args[2:])
+	case "reconcile":
+		err = reconcile(os.Args[2:])
+	case "sample":
+		err = sample(os.Args[2:])
+	case "search":
+		err = search(os.Args[2:])
+	case "schema":
+		err = schema(os.Args[2:])
+	case "serve":
+		err = serve(os.Args[2:])
+	case "shards":
+		err = shards(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// extract reads paths, or stdin when the only path is -, stopping cleanly on
+// SIGINT or SIGTERM.
+func extract(paths []string, opts []ch.Opt) (ch.Summary, error) {
+	r, errH, stop := reader(opts)
+	defer stop()
+	if len(paths) == 1 && paths[0] == "-" {
+		return r.ExtractReader(os.Stdin, "-", errH)
+	}
+	return r.ExtractAll(paths, 1, errH)
+}
+
+// extractShard reads the shard s in the same way as extract.
+func extractShard(s ch.Shard, opts []ch.Opt) (ch.Summary, error) {
+	r, errH, stop := reader(opts)
+	defer stop()
+	return r.ExtractShard(s, errH)
+}
+
+// reader returns a Reader of opts which is stopped on SIGINT or SIGTERM until
+// stop is called, and an error handler logging errors.
+func reader(opts []ch.Opt) (r *ch.Reader, errH func(err error), stop func()) {
+	r = ch.NewReader(append([]ch.Opt{ch.WithClock(now)}, opts...)...)
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		if _, ok := <-sig; ok {
+			r.Stop()
+		}
+	}()
+	errH = func(err error) {
+		log.Println(err)
+	}
+	return r, errH, func() { signal.Stop(sig) }
+}
+
+// report writes s to stderr as JSON.
+func report(s ch.Summary) error {
+	e := json.NewEncoder(os.Stderr)
+	e.SetIndent("", "  ")
+	return e.Encode(s)
+}
+
+func create(path string) (io.WriteCloser, error) {
+	if path == "-" {
+		return nopCloser{os.Stdout}, nil
+	}
+	return os.Create(path)
+}
+
+// writer avoids passing a typed nil to the exporter.
+func writer(w io.WriteCloser) io.Writer {
+	if w == nil {
+		return nil
+	}
+	return w
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
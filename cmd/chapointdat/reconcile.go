@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	ch "github.com/richardjennings/chapointdat"
+	"github.com/richardjennings/chapointdat/chstream"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func reconcile(args []string) error {
+	fs := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	datasetPath := fs.String("dataset", "", "file written by Dataset.WriteTo to reconcile with, instead of reading snapshots")
+	key := fs.String("key", os.Getenv("CH_STREAM_KEY"), "stream key, CH_STREAM_KEY by default")
+	timepoint := fs.Int64("timepoint", 0, "timepoint to resume the stream from")
+	out := fs.String("o", "-", "output file for divergences as JSON lines, - for stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: chapointdat reconcile [options] (-dataset <file> | <file.zip|file.dat>...)")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if (*datasetPath == "") == (fs.NArg() == 0) {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	d := ch.NewDataset()
+	if *datasetPath != "" {
+		f, err := os.Open(*datasetPath)
+		if err != nil {
+			return err
+		}
+		d, err = ch.ReadDataset(bufio.NewReader(f))
+		_ = f.Close()
+		if err != nil {
+			return err
+		}
+	} else if _, err := extract(fs.Args(), []ch.Opt{ch.WithRecordHandler(d.Write)}); err != nil {
+		return err
+	}
+
+	w, err := create(*out)
+	if err != nil {
+		return err
+	}
+	e := json.NewEncoder(w)
+	r := chstream.NewReconciler(d, func(d chstream.Divergence) error {
+		return e.Encode(d)
+	})
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err = chstream.Stream(ctx, chstream.Config{Key: *key, Timepoint: *timepoint}, r.Write)
+	if tp := r.Timepoint(); tp != 0 {
+		log.Printf("resume from -timepoint %d", tp+1)
+	}
+	return errors.Join(err, w.Close())
+}
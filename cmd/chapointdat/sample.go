@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	ch "github.com/richardjennings/chapointdat"
+	"os"
+)
+
+func sample(args []string) error {
+	fs := flag.NewFlagSet("sample", flag.ExitOnError)
+	rate := fs.Float64("rate", 0.001, "fraction of companies to sample")
+	seed := fs.Uint64("seed", uint64(now().UnixNano()), "seed choosing companies and replacement values")
+	out := fs.String("o", "-", "output .dat file, - for stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: chapointdat sample [options] <file.zip|file.dat|->...")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	w, err := create(*out)
+	if err != nil {
+		return err
+	}
+	s := ch.NewSampler(w, *rate, *seed)
+	sum, err := extract(fs.Args(), []ch.Opt{ch.WithRecordHandler(s.Write)})
+	return errors.Join(err, s.Close(), w.Close(), report(sum))
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	ch "github.com/richardjennings/chapointdat"
+	"os"
+)
+
+func schema(args []string) error {
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+	format := fs.String("format", "json", "output format: json for JSON Schema, or sql for CREATE TABLE statements")
+	dialect := fs.String("dialect", "postgres", "SQL dialect for sql: postgres, mysql, sqlite or bigquery")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: chapointdat schema [options] <companies|persons>")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	var s ch.Schema
+	switch fs.Arg(0) {
+	case "companies":
+		s = ch.CompanySchema()
+	case "persons":
+		s = ch.PersonSchema()
+	default:
+		return fmt.Errorf("unknown table: %s", fs.Arg(0))
+	}
+	switch *format {
+	case "json":
+		b, err := s.JSONSchema()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Println(string(b))
+		return err
+	case "sql":
+		ddl, err := s.DDL(ch.SQLDialect(*dialect))
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Print(ddl)
+		return err
+	}
+	return fmt.Errorf("unknown format: %s", *format)
+}
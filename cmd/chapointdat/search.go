@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	ch "github.com/richardjennings/chapointdat"
+	"os"
+)
+
+func search(args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	indexPath := fs.String("index", "", "name index written by search -o to query, instead of reading snapshots")
+	out := fs.String("o", "", "write the name index built from the snapshots to this file")
+	query := fs.String("q", "", "name to search for")
+	limit := fs.Int("limit", 10, "maximum number of names to print")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: chapointdat search [options] (-index <file> | <file.zip|file.dat>...)")
+		fmt.Fprintln(fs.Output(), "Prints the names matching -q as JSON lines, best first.")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if (*indexPath == "") == (fs.NArg() == 0) || *query == "" && *out == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	x := ch.NewNameIndex()
+	if *indexPath != "" {
+		f, err := os.Open(*indexPath)
+		if err != nil {
+			return err
+		}
+		x, err = ch.ReadNameIndex(f)
+		_ = f.Close()
+		if err != nil {
+			return err
+		}
+	} else if _, err := extract(fs.Args(), []ch.Opt{ch.WithRecordHandler(x.Write)}); err != nil {
+		return err
+	}
+	if *out != "" {
+		w, err := create(*out)
+		if err != nil {
+			return err
+		}
+		_, err = x.WriteTo(w)
+		if err = errors.Join(err, w.Close()); err != nil {
+			return err
+		}
+	}
+	if *query == "" {
+		return nil
+	}
+	w := bufio.NewWriter(os.Stdout)
+	e := json.NewEncoder(w)
+	for _, m := range x.Search(*query, *limit) {
+		if err := e.Encode(m); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
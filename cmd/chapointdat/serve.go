@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	ch "github.com/richardjennings/chapointdat"
+	"github.com/richardjennings/chapointdat/arrowipc"
+	"log"
+	"net/http"
+	"os"
+)
+
+func serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8815", "address to listen on")
+	batch := fs.Int("batch", arrowipc.DefaultBatchSize, "rows per record batch")
+	names := fs.String("name-index", "", "name index written by search -o to serve on /search")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: chapointdat serve [options] <file.zip|file.dat>...")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	s := &arrowipc.Server{Paths: fs.Args(), Opts: []ch.Opt{ch.WithClock(now)}, BatchSize: *batch, ErrorHandler: func(err error) {
+		log.Println(err)
+	}}
+	if *names != "" {
+		f, err := os.Open(*names)
+		if err != nil {
+			return err
+		}
+		s.Names, err = ch.ReadNameIndex(f)
+		_ = f.Close()
+		if err != nil {
+			return err
+		}
+		log.Printf("serving /search on %s", *addr)
+	}
+	log.Printf("serving /companies and /persons on %s", *addr)
+	return http.ListenAndServe(*addr, s)
+}
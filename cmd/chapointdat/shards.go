@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	ch "github.com/richardjennings/chapointdat"
+	"os"
+)
+
+func shards(args []string) error {
+	fs := flag.NewFlagSet("shards", flag.ExitOnError)
+	n := fs.Int("n", 2, "number of shards to split the snapshot into")
+	include := fs.String("include", "", "comma separated glob patterns of the zip entries to split, such as *.dat")
+	exclude := fs.String("exclude", "", "comma separated glob patterns of zip entries not to split, such as README*")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: chapointdat shards [options] <file.zip|file.dat>...")
+		fmt.Fprintln(fs.Output(), "Writes a JSON shard descriptor per line, each to pass to convert -shard.")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	filter := ch.EntryFilter{Include: ch.ParseEntryPatterns(*include), Exclude: ch.ParseEntryPatterns(*exclude)}
+	shards, err := ch.SplitEntryShards(fs.Args(), *n, filter)
+	if err != nil {
+		return err
+	}
+	e := json.NewEncoder(os.Stdout)
+	for _, s := range shards {
+		if err := e.Encode(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
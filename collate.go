@@ -0,0 +1,199 @@
+package chapointdat
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+type (
+	// Collator orders names as people expect rather than by byte value:
+	// accents and case only break ties between names which are otherwise
+	// equal, and spaces and punctuation are ignored until then, so that
+	// Ó BRIEN sorts with OBRIEN and before OCONNOR. Letters outside the Latin
+	// alphabet sort after it in code point order.
+	Collator struct {
+		// after are letters tailored by the locale to sort after Z, in order.
+		after []rune
+	}
+
+	// collationElement is the weights of one letter or digit of a string at
+	// the primary (letter), secondary (accent) and tertiary (case) levels.
+	collationElement struct {
+		primary, secondary rune
+		tertiary           bool
+	}
+
+	sortedCSVExporter struct {
+		mu        sync.Mutex
+		csv       *csvExporter
+		collator  *Collator
+		companies []sortedRecord[Company]
+		persons   []sortedRecord[Person]
+	}
+	sortedRecord[T any] struct {
+		v    T
+		keys [2][]collationElement
+	}
+)
+
+// localeTailorings are the letters sorted after Z by locales which differ
+// from the default order of Latin letters.
+var localeTailorings = map[string][]rune{
+	"da": []rune("ÆØÅ"), "nb": []rune("ÆØÅ"), "nn": []rune("ÆØÅ"), "no": []rune("ÆØÅ"),
+	"sv": []rune("ÅÄÖ"), "fi": []rune("ÅÄÖ"),
+}
+
+// NewCollator returns a Collator for locale, such as "en", "de", "da" or "sv",
+// or "" for the default order of Latin letters, in which Æ is AE and Å is A.
+// Only the Danish, Norwegian, Swedish and Finnish letters sorted after Z differ
+// from the default order.
+func NewCollator(locale string) (*Collator, error) {
+	lang, _, _ := strings.Cut(strings.ToLower(locale), "-")
+	lang, _, _ = strings.Cut(lang, "_")
+	switch lang {
+	case "", "en", "cy", "de", "fr", "es", "it", "nl", "pt", "ga", "gd":
+		return &Collator{}, nil
+	}
+	after, ok := localeTailorings[lang]
+	if !ok {
+		return nil, fmt.Errorf("unsupported locale: %s", locale)
+	}
+	return &Collator{after: after}, nil
+}
+
+// Compare returns -1, 0 or 1 as a sorts before, with or after b.
+func (c *Collator) Compare(a, b string) int {
+	return cmp.Or(compareCollationKeys(c.key(a), c.key(b)), cmp.Compare(a, b))
+}
+
+// key returns the collation elements of s, ignoring spaces and punctuation.
+func (c *Collator) key(s string) []collationElement {
+	var elements []collationElement
+	for _, r := range s {
+		upper := unicode.IsUpper(r)
+		u := unicode.ToUpper(r)
+		if i := slices.Index(c.after, u); i >= 0 {
+			elements = append(elements, collationElement{primary: 'Z' + 1 + rune(i), tertiary: upper})
+			continue
+		}
+		if unicode.IsDigit(r) || u >= 'A' && u <= 'Z' {
+			elements = append(elements, collationElement{primary: u, tertiary: upper})
+			continue
+		}
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		base := strings.ToUpper(SuggestReplacement(r))
+		if base == "" || strings.IndexFunc(base, func(b rune) bool { return b < 'A' || b > 'Z' }) >= 0 {
+			// a letter outside the Latin alphabet
+			elements = append(elements, collationElement{primary: 0x10000 + u, tertiary: upper})
+			continue
+		}
+		for _, b := range base {
+			// the secondary weight distinguishes the accented letter from its
+			// base
+			elements = append(elements, collationElement{primary: b, secondary: u, tertiary: upper})
+		}
+	}
+	return elements
+}
+
+// compareCollationKeys compares a and b by all primary weights, then all
+// secondary weights and then all tertiary weights.
+func compareCollationKeys(a, b []collationElement) int {
+	if c := slices.CompareFunc(a, b, func(x, y collationElement) int { return cmp.Compare(x.primary, y.primary) }); c != 0 {
+		return c
+	}
+	if c := slices.CompareFunc(a, b, func(x, y collationElement) int { return cmp.Compare(x.secondary, y.secondary) }); c != 0 {
+		return c
+	}
+	// lower case sorts before upper case
+	return slices.CompareFunc(a, b, func(x, y collationElement) int {
+		return cmp.Compare(boolWeight(x.tertiary), boolWeight(y.tertiary))
+	})
+}
+
+func boolWeight(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// WithSortedCSVExport writes companies and persons as WithCSVExport, but
+// sorted by c: companies by name and then company number, and persons by
+// surname, forenames and then person number, company number and appointment
+// type, so that published extracts are the same whatever the order of the
+// snapshot. Records are held in memory until extraction finishes.
+func WithSortedCSVExport(companies, persons io.Writer, c *Collator) Opt {
+	return func(r *Reader) {
+		e := &sortedCSVExporter{csv: &csvExporter{esc: &r.escaping}, collator: c}
+		if companies != nil {
+			e.csv.companies = bufio.NewWriter(companies)
+		}
+		if persons != nil {
+			e.csv.persons = bufio.NewWriter(persons)
+		}
+		r.sinks = append(r.sinks, e.write)
+		r.flushers = append(r.flushers, e.flush)
+		r.holdsRecords = "WithSortedCSVExport"
+	}
+}
+
+func (e *sortedCSVExporter) write(rec Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch {
+	case rec.Kind == RecordKindCompany && e.csv.companies != nil:
+		e.companies = append(e.companies, sortedRecord[Company]{
+			v: *rec.Company, keys: [2][]collationElement{e.collator.key(rec.Company.CompanyName)},
+		})
+	case rec.Kind == RecordKindPerson && e.csv.persons != nil:
+		e.persons = append(e.persons, sortedRecord[Person]{
+			v: *rec.Person, keys: [2][]collationElement{e.collator.key(rec.Person.Surname), e.collator.key(rec.Person.Forenames)},
+		})
+	}
+	return nil
+}
+
+// flush writes the records in order.
+func (e *sortedCSVExporter) flush() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	slices.SortStableFunc(e.companies, func(a, b sortedRecord[Company]) int {
+		return cmp.Or(
+			compareCollationKeys(a.keys[0], b.keys[0]),
+			cmp.Compare(a.v.CompanyName, b.v.CompanyName),
+			cmp.Compare(a.v.CompanyNumber, b.v.CompanyNumber),
+		)
+	})
+	slices.SortStableFunc(e.persons, func(a, b sortedRecord[Person]) int {
+		return cmp.Or(
+			compareCollationKeys(a.keys[0], b.keys[0]),
+			compareCollationKeys(a.keys[1], b.keys[1]),
+			cmp.Compare(a.v.Surname, b.v.Surname),
+			cmp.Compare(a.v.Forenames, b.v.Forenames),
+			cmp.Compare(a.v.PersonNumber, b.v.PersonNumber),
+			cmp.Compare(a.v.CompanyNumber, b.v.CompanyNumber),
+			cmp.Compare(a.v.AppointmentType, b.v.AppointmentType),
+		)
+	})
+	for _, c := range e.companies {
+		if err := e.csv.writeRow(e.csv.companies, &e.csv.companyHdr, c.v); err != nil {
+			return err
+		}
+	}
+	for _, p := range e.persons {
+		if err := e.csv.writeRow(e.csv.persons, &e.csv.personHdr, p.v); err != nil {
+			return err
+		}
+	}
+	e.companies, e.persons = nil, nil
+	return e.csv.flush()
+}
@@ -0,0 +1,55 @@
+package chapointdat
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func Test_Collator(t *testing.T) {
+	c, err := NewCollator("en-GB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"Zebra", "ÉCLAIR", "ECLAIR", "eclair", "O'BRIEN", "OCONNOR", "Ó BRIEN", "ÆTHER", "ADAMS", "Straße", "STRASSER", "10 DOWNING", "2 DOWNING", "Ωmega"}
+	slices.SortFunc(names, c.Compare)
+	expected := []string{"10 DOWNING", "2 DOWNING", "ADAMS", "ÆTHER", "eclair", "ECLAIR", "ÉCLAIR", "O'BRIEN", "Ó BRIEN", "OCONNOR", "Straße", "STRASSER", "Zebra", "Ωmega"}
+	if !slices.Equal(names, expected) {
+		t.Errorf("expected %q got %q", expected, names)
+	}
+
+	da, err := NewCollator("da")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if da.Compare("ÅRHUS", "ZEALAND") <= 0 || c.Compare("ÅRHUS", "ZEALAND") >= 0 {
+		t.Error("expected Å after Z only in Danish")
+	}
+	if _, err := NewCollator("xx"); err == nil {
+		t.Error("expected an error for an unsupported locale")
+	}
+}
+
+func Test_SortedCSVExport(t *testing.T) {
+	second := strings.Replace(strings.Replace(testCompanyLine, "00000084", "00000085", 1), "A. WEST", "A  WEST", 1)
+	first := strings.Replace(strings.Replace(testCompanyLine, "00000084", "00000086", 1), "0019A. WEST", "0020Á WEST.", 1)
+	var companies, persons strings.Builder
+	c, _ := NewCollator("")
+	r := NewReader(WithSortedCSVExport(&companies, &persons, c))
+	if _, err := r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine, first, second, testPersonLine)), "-", func(err error) { t.Error(err) }); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(companies.String()), "\n")
+	var numbers []string
+	for _, l := range lines[1:] {
+		numbers = append(numbers, l[:8])
+	}
+	// A WEST and A. WEST differ only in punctuation, so are ordered by byte
+	// value, and then Á WEST. by its accent
+	if !slices.Equal(numbers, []string{"00000085", "00000084", "00000086"}) {
+		t.Errorf("unexpected order %v", numbers)
+	}
+	if !strings.Contains(persons.String(), "KJAERSGAARD") {
+		t.Errorf("expected the person got %q", persons.String())
+	}
+}
@@ -0,0 +1,77 @@
+package chapointdat
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+)
+
+// companyFilter tracks the company numbers of WithCompanyNumbers through a
+// file.
+type companyFilter struct {
+	numbers []string
+	// next is the index of the first number not before the last line's.
+	next int
+	last string
+}
+
+// WithCompanyNumbers reads only the companies in set, and the persons
+// following them, as for extracting a few thousand companies from a full
+// snapshot. The lines of other companies are skipped without being parsed,
+// walking set in company number order alongside the snapshot; a file out of
+// order is still read correctly, only more slowly. Company numbers in set are
+// padded as by CompanyNumberPad to match the snapshot. Skipped lines are
+// counted in Summary.Skipped rather than Companies and Persons, and are
+// included in the checks of trailer counts.
+func WithCompanyNumbers(set CompanySet) Opt {
+	return func(r *Reader) {
+		padded := CompanySet{}
+		for n := range set {
+			padded.Add(CompanyNumberPad.Format(strings.TrimSpace(n)))
+		}
+		r.companyFilter = padded.Sorted()
+	}
+}
+
+// skipLine reports whether line is of a company or person not selected by
+// WithCompanyNumbers, counting it if so. Headers and trailers are never
+// skipped, though the run number or record count may put a record type at the
+// ninth byte.
+func (r *Reader) skipLine(line []byte, st *state) bool {
+	if r.companyFilter == nil || st.first(st.i) || bytes.HasPrefix(line, []byte(trailerRecordIdentifier)) || !st.skips(r.companyFilter, line) {
+		return false
+	}
+	st.summary.Skipped++
+	return true
+}
+
+// skips reports whether line is of a company or person not in numbers.
+func (st *state) skips(numbers []string, line []byte) bool {
+	var n string
+	switch {
+	case len(line) > 8 && (string(line[8]) == companyRecordType || string(line[8]) == personRecordType):
+		n = string(line[:8])
+	case isRecordType(line, companyRecordType) || isRecordType(line, personRecordType):
+		// the missing leading 0 that line repairs
+		n = "0" + string(line[:7])
+	default:
+		return false
+	}
+	if st.filter == nil {
+		st.filter = &companyFilter{numbers: numbers}
+	}
+	return !st.filter.wants(CompanyNumberPad.Format(strings.TrimSpace(n)))
+}
+
+// wants reports whether n is one of the numbers of f, moving forward through
+// them as the numbers of a sorted file increase.
+func (f *companyFilter) wants(n string) bool {
+	if n < f.last {
+		f.next = sort.SearchStrings(f.numbers, n)
+	}
+	for f.next < len(f.numbers) && f.numbers[f.next] < n {
+		f.next++
+	}
+	f.last = n
+	return f.next < len(f.numbers) && f.numbers[f.next] == n
+}
@@ -0,0 +1,63 @@
+package chapointdat
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func Test_WithCompanyNumbers(t *testing.T) {
+	var lines []string
+	for _, n := range []int{1, 2, 3, 4, 6, 5} {
+		number := fmt.Sprintf("%08d", n)
+		lines = append(lines, strings.Replace(testCompanyLine, "00000084", number, 1), strings.Replace(testPersonLine, "00463819", number, 1))
+	}
+	for _, opts := range [][]Opt{nil, {WithOrderedDelivery(2)}} {
+		var companies, persons []string
+		r := NewReader(append(opts, WithCompanyNumbers(CompanySet{"2": {}, "00000005": {}, "00000006": {}, "00000099": {}}),
+			WithCompanyHandler(func(c Company) error {
+				companies = append(companies, c.CompanyNumber)
+				return nil
+			}), WithPersonHandler(func(p Person) error {
+				persons = append(persons, p.CompanyNumber)
+				return nil
+			}))...)
+		s, err := r.ExtractReader(strings.NewReader(testSnapshot(lines...)), "-", func(err error) { t.Error(err) })
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 6 is out of order, and 5 read by searching again
+		if want := []string{"00000002", "00000006", "00000005"}; !slices.Equal(companies, want) || !slices.Equal(persons, want) {
+			t.Errorf("expected %v got companies %v and persons %v", want, companies, persons)
+		}
+		if s.Companies != 3 || s.Persons != 3 || s.Skipped != 6 {
+			t.Errorf("unexpected summary %+v", s)
+		}
+		if err := s.Validate(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func Test_WithCompanyNumbers_ShortLine(t *testing.T) {
+	var errs []error
+	r := NewReader(WithCompanyNumbers(CompanySet{"00000084": {}}))
+	s, err := r.ExtractReader(strings.NewReader("DDDDSNAP195020240101\n04638191\n9999999900000000\n"), "-", func(err error) { errs = append(errs, err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Errors != 1 || len(errs) != 1 || !strings.Contains(errs[0].Error(), "too short") {
+		t.Errorf("expected the short line to be rejected got %+v and %v", s, errs)
+	}
+
+	// a run number and record count starting with a record type
+	errs = nil
+	s, err = r.ExtractReader(strings.NewReader("DDDDSNAP195020240101\n"+testCompanyLine+"\n9999999910000000\n"), "-", func(err error) { errs = append(errs, err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Headers) != 1 || s.Trailers != 1 || s.Companies != 1 {
+		t.Errorf("expected the header and trailer to be read got %+v", s)
+	}
+}
@@ -0,0 +1,54 @@
+package chapointdat
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// legalSuffixes are the tokens of endings of company names which describe the
+// legal form of the company rather than identify it, in English and Welsh.
+var legalSuffixes = [][]string{
+	{"LIMITED"}, {"LTD"}, {"PLC"}, {"P", "L", "C"}, {"PUBLIC", "LIMITED", "COMPANY"},
+	{"LLP"}, {"L", "L", "P"}, {"LIMITED", "LIABILITY", "PARTNERSHIP"}, {"LP"}, {"LIMITED", "PARTNERSHIP"},
+	{"CIC"}, {"COMMUNITY", "INTEREST", "COMPANY"}, {"UNLIMITED"}, {"CYF"}, {"CYFYNGEDIG"}, {"CCC"},
+	{"CWMNI", "CYFYNGEDIG", "CYHOEDDUS"}, {"AND", "PARTNERS"}, {"AND", "CO"}, {"AND", "COMPANY"},
+}
+
+// WithNormalizedNames sets Company.NormalizedName to the NormalizeCompanyName
+// of each company name read.
+func WithNormalizedNames() Opt {
+	return func(r *Reader) {
+		r.normalizeNames = true
+	}
+}
+
+// CompanyNameTokens splits name into upper case words of letters and digits,
+// with & as AND and apostrophes removed, so that SMITH'S & CO. LTD is SMITHS,
+// AND, CO and LTD.
+func CompanyNameTokens(name string) []string {
+	name = strings.NewReplacer("&", " AND ", "'", "", "’", "").Replace(strings.ToUpper(name))
+	return strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// NormalizeCompanyName returns the CompanyNameTokens of name, without a leading
+// THE and any legal suffixes such as LIMITED, PLC and & PARTNERS, joined by
+// spaces, for matching names which differ only in punctuation or legal form.
+// A name made up only of suffixes is kept.
+func NormalizeCompanyName(name string) string {
+	tokens := CompanyNameTokens(name)
+	if len(tokens) > 1 && tokens[0] == "THE" {
+		tokens = tokens[1:]
+	}
+	for trimmed := true; trimmed; {
+		trimmed = false
+		for _, suffix := range legalSuffixes {
+			if len(tokens) > len(suffix) && slices.Equal(tokens[len(tokens)-len(suffix):], suffix) {
+				tokens, trimmed = tokens[:len(tokens)-len(suffix)], true
+			}
+		}
+	}
+	return strings.Join(tokens, " ")
+}
@@ -0,0 +1,44 @@
+package chapointdat
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func Test_NormalizeCompanyName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"A. WEST & PARTNERS":                    "A WEST",
+		"The Smith's Bakery Ltd.":               "SMITHS BAKERY",
+		"ACME HOLDINGS P.L.C.":                  "ACME HOLDINGS",
+		"JONES & CO LIMITED":                    "JONES",
+		"GREEN ENERGY CYFYNGEDIG":               "GREEN ENERGY",
+		"BROWN LLP":                             "BROWN",
+		"NORTH WEST COMMUNITY INTEREST COMPANY": "NORTH WEST",
+		"LIMITED":                               "LIMITED",
+		"THE":                                   "THE",
+	} {
+		if s := NormalizeCompanyName(name); s != expected {
+			t.Errorf("expected %q for %q got %q", expected, name, s)
+		}
+	}
+	if tokens := CompanyNameTokens("O'NEILL & SONS (UK) LTD"); !slices.Equal(tokens, []string{"ONEILL", "AND", "SONS", "UK", "LTD"}) {
+		t.Errorf("unexpected tokens %v", tokens)
+	}
+}
+
+func Test_WithNormalizedNames(t *testing.T) {
+	var names []string
+	for _, opts := range [][]Opt{nil, {WithNormalizedNames()}} {
+		r := NewReader(append(opts, WithCompanyHandler(func(c Company) error {
+			names = append(names, c.NormalizedName)
+			return nil
+		}))...)
+		if _, err := r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine)), "-", func(err error) { t.Error(err) }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !slices.Equal(names, []string{"", "A WEST"}) {
+		t.Errorf("unexpected normalized names %q", names)
+	}
+}
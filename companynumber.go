@@ -0,0 +1,96 @@
+package chapointdat
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// CompanyNumberPreserve passes company numbers through as read, which is
+	// the default.
+	CompanyNumberPreserve = CompanyNumberFormat(iota)
+	// CompanyNumberPad pads the digits of company numbers with leading zeros
+	// to the full 8 characters, so that 84 is 00000084 and SC1234 is
+	// SC001234.
+	CompanyNumberPad
+	// CompanyNumberStrip removes the leading zeros of company numbers which
+	// are only digits, so that 00000084 is 84. Numbers with a prefix, such as
+	// SC123456, are not changed.
+	CompanyNumberStrip
+)
+
+// companyNumberLength is the length of a company number in full.
+const companyNumberLength = 8
+
+type CompanyNumberFormat int
+
+// WithCompanyNumberFormat formats the company numbers of companies and persons
+// passed to handlers, sinks, exporters and loaders as f, so that they join
+// with other datasets which write them differently. Company numbers are
+// formatted after they are read, so checks of the snapshot such as the order
+// of companies are unaffected.
+func WithCompanyNumberFormat(f CompanyNumberFormat) Opt {
+	return func(r *Reader) {
+		r.companyNumbers = f
+	}
+}
+
+// ParseCompanyNumberFormat returns the format named preserve, pad or strip.
+func ParseCompanyNumberFormat(s string) (CompanyNumberFormat, error) {
+	for f, name := range companyNumberFormatNames {
+		if s == name {
+			return CompanyNumberFormat(f), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown company number format: %s", s)
+}
+
+var companyNumberFormatNames = []string{"preserve", "pad", "strip"}
+
+func (f CompanyNumberFormat) String() string {
+	if int(f) < len(companyNumberFormatNames) {
+		return companyNumberFormatNames[f]
+	}
+	return "unknown"
+}
+
+// Format returns n in format f. A number which is not a prefix of capital
+// letters followed by digits is returned unchanged.
+func (f CompanyNumberFormat) Format(n string) string {
+	digits := strings.TrimLeft(n, "ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	prefix := n[:len(n)-len(digits)]
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return n
+	}
+	switch f {
+	case CompanyNumberPad:
+		if pad := companyNumberLength - len(n); pad > 0 {
+			return prefix + strings.Repeat("0", pad) + digits
+		}
+	case CompanyNumberStrip:
+		if prefix == "" {
+			if stripped := strings.TrimLeft(digits, "0"); stripped != "" {
+				return stripped
+			}
+			return "0"
+		}
+	}
+	return n
+}
+
+// formatCompanyNumbers returns rec with the company number of its company or
+// person in the company number format. The company or person is copied, so
+// that the record as read is unchanged.
+func (r *Reader) formatCompanyNumbers(rec Record) Record {
+	switch rec.Kind {
+	case RecordKindCompany:
+		c := *rec.Company
+		c.CompanyNumber = r.companyNumbers.Format(c.CompanyNumber)
+		rec.Company = &c
+	case RecordKindPerson:
+		p := *rec.Person
+		p.CompanyNumber = r.companyNumbers.Format(p.CompanyNumber)
+		rec.Person = &p
+	}
+	return rec
+}
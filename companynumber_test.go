@@ -0,0 +1,72 @@
+package chapointdat
+
+import (
+	"bytes"
+	"iter"
+	"strings"
+	"testing"
+)
+
+func Test_CompanyNumberFormat_Format(t *testing.T) {
+	for _, tc := range []struct {
+		format           CompanyNumberFormat
+		number, expected string
+	}{
+		{CompanyNumberPreserve, "84", "84"},
+		{CompanyNumberPad, "84", "00000084"},
+		{CompanyNumberPad, "00000084", "00000084"},
+		{CompanyNumberPad, "SC1234", "SC001234"},
+		{CompanyNumberPad, "SC123456", "SC123456"},
+		{CompanyNumberPad, "", ""},
+		{CompanyNumberPad, "OC 1234", "OC 1234"},
+		{CompanyNumberStrip, "00000084", "84"},
+		{CompanyNumberStrip, "00000000", "0"},
+		{CompanyNumberStrip, "SC001234", "SC001234"},
+		{CompanyNumberStrip, "R0000012", "R0000012"},
+	} {
+		if got := tc.format.Format(tc.number); got != tc.expected {
+			t.Errorf("%s %q: expected %q got %q", tc.format, tc.number, tc.expected, got)
+		}
+	}
+}
+
+func Test_WithCompanyNumberFormat(t *testing.T) {
+	person := strings.Replace(testPersonLine, "00463819", "00000084", 1)
+	var companies bytes.Buffer
+	var handled, streamed []string
+	r := NewReader(
+		WithCompanyNumberFormat(CompanyNumberStrip),
+		WithCSVExport(&companies, nil),
+		WithPersonHandler(func(p Person) error {
+			handled = append(handled, p.CompanyNumber)
+			return nil
+		}),
+		WithCompanyStreamHandler(func(c Company, officers iter.Seq[Person]) error {
+			streamed = append(streamed, c.CompanyNumber)
+			for p := range officers {
+				streamed = append(streamed, p.CompanyNumber)
+			}
+			return nil
+		}),
+	)
+	if _, err := r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine, person)), "-", func(err error) { t.Error(err) }); err != nil {
+		t.Fatal(err)
+	}
+	if rows := strings.Split(companies.String(), "\n"); len(rows) < 2 || !strings.HasPrefix(rows[1], "84,") {
+		t.Errorf("expected the stripped company number in %q", companies.String())
+	}
+	if strings.Join(handled, ",") != "84" || strings.Join(streamed, ",") != "84,84" {
+		t.Errorf("unexpected company numbers %v %v", handled, streamed)
+	}
+}
+
+func Test_ParseCompanyNumberFormat(t *testing.T) {
+	for _, f := range []CompanyNumberFormat{CompanyNumberPreserve, CompanyNumberPad, CompanyNumberStrip} {
+		if got, err := ParseCompanyNumberFormat(f.String()); err != nil || got != f {
+			t.Errorf("expected %s got %s %v", f, got, err)
+		}
+	}
+	if _, err := ParseCompanyNumberFormat("trim"); err == nil {
+		t.Error("expected an error")
+	}
+}
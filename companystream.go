@@ -0,0 +1,85 @@
+package chapointdat
+
+import (
+	"fmt"
+	"iter"
+)
+
+// companyStream runs the company stream handler for one company of a file as
+// a coroutine, resuming it with each officer read.
+type companyStream struct {
+	next    func() (struct{}, bool)
+	stop    func()
+	company string
+	officer Person
+	// finished is set once the handler has returned, and err is its result.
+	finished bool
+	err      error
+	at       DeadLetter
+	line     []byte
+}
+
+// WithCompanyStreamHandler calls h with each company and an iterator over
+// its officers, read lazily as the lines following the company are parsed,
+// so that a company with thousands of officers is never held in memory. h is
+// called before the company's officers are read and ranging over officers
+// may only be done once; any officers h does not range over are skipped. An
+// error returned by h is passed to the error handler with the company line
+// once the officers have been read. Officers are grouped within each file, so
+// WithWorkers must not be used for persons.
+func WithCompanyStreamHandler(h func(c Company, officers iter.Seq[Person]) error) Opt {
+	return func(r *Reader) {
+		r.companyStream = h
+	}
+}
+
+// stream passes rec, which has been recorded, to the company stream handler.
+func (r *Reader) stream(rec Record, line []byte, st *state, errH func(err error)) {
+	if rec.Kind == RecordKindPerson {
+		s := st.stream
+		if s != nil && !s.finished && rec.Person.CompanyNumber == s.company {
+			s.officer = *r.output(rec).Person
+			if _, ok := s.next(); !ok {
+				s.finished = true
+			}
+		}
+		return
+	}
+	r.endStream(st, errH)
+	if rec.Kind != RecordKindCompany {
+		return
+	}
+	s := &companyStream{company: rec.Company.CompanyNumber, at: st.deadLetter(), line: append([]byte(nil), line...)}
+	c := *r.output(rec).Company
+	s.next, s.stop = iter.Pull(func(resume func(struct{}) bool) {
+		s.err = r.companyStream(c, func(yield func(Person) bool) {
+			// each resume waits for the next officer, returning false once the
+			// company's officers have all been read
+			for resume(struct{}{}) {
+				if !yield(s.officer) {
+					return
+				}
+			}
+		})
+	})
+	st.stream = s
+	// run h up to the point that it waits for the first officer
+	if _, ok := s.next(); !ok {
+		s.finished = true
+	}
+}
+
+// endStream finishes the handler for the current company of st, reporting any
+// error it returns.
+func (r *Reader) endStream(st *state, errH func(err error)) {
+	s := st.stream
+	if s == nil {
+		return
+	}
+	st.stream = nil
+	s.stop()
+	if s.err != nil {
+		st.summary.Errors++
+		r.reportError(s.at, s.line, fmt.Errorf("error processing company stream handler: %w", s.err), errH)
+	}
+}
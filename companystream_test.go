@@ -0,0 +1,42 @@
+package chapointdat
+
+import (
+	"errors"
+	"iter"
+	"strings"
+	"testing"
+)
+
+func Test_CompanyStreamHandler(t *testing.T) {
+	second := strings.Replace(testCompanyLine, "00000084", "00000085", 1)
+	other := strings.Replace(testPersonLine, "024407940002", "024407940003", 1)
+	var got []string
+	h := func(c Company, officers iter.Seq[Person]) error {
+		got = append(got, "company "+c.CompanyNumber)
+		for p := range officers {
+			got = append(got, "officer "+p.PersonNumber)
+			if c.CompanyNumber == "00000085" {
+				return errors.New("stopped early")
+			}
+		}
+		got = append(got, "end "+c.CompanyNumber)
+		return nil
+	}
+	for _, opts := range [][]Opt{nil, {WithOrderedDelivery(2)}} {
+		got = nil
+		var errs []error
+		r := NewReader(append(opts, WithCompanyStreamHandler(h))...)
+		company := strings.Replace(testCompanyLine, "00000084", "00463819", 1)
+		s, err := r.ExtractReader(strings.NewReader(testSnapshot(company, testPersonLine, other, second, strings.Replace(testPersonLine, "00463819", "00000085", 1), testPersonLine)), "-", func(err error) { errs = append(errs, err) })
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := "company 00463819,officer 024407940002,officer 024407940003,end 00463819,company 00000085,officer 024407940002"
+		if strings.Join(got, ",") != expected {
+			t.Errorf("expected %s got %s", expected, strings.Join(got, ","))
+		}
+		if len(errs) != 1 || !strings.Contains(errs[0].Error(), "stopped early") || !strings.Contains(errs[0].Error(), second) || s.Errors != 1 {
+			t.Errorf("expected the handler error for the second company got %v with %d errors", errs, s.Errors)
+		}
+	}
+}
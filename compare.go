@@ -0,0 +1,57 @@
+package chapointdat
+
+import "reflect"
+
+// FieldChange is a field whose value differs between two records.
+type FieldChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// Equal reports whether p and o have the same values for every field of the
+// PersonSchema, ignoring values derived from them such as Truncated and
+// Phonetic.
+func (p Person) Equal(o Person) bool {
+	return len(fieldDiff(p, o, true)) == 0
+}
+
+// FieldDiff returns the fields of the PersonSchema which differ from p to o,
+// in schema order, for change data capture against a store of persons.
+func (p Person) FieldDiff(o Person) []FieldChange {
+	return fieldDiff(p, o, false)
+}
+
+// Equal reports whether c and o have the same values for every field of the
+// CompanySchema, ignoring values derived from them such as NormalizedName and
+// Phonetic.
+func (c Company) Equal(o Company) bool {
+	return len(fieldDiff(c, o, true)) == 0
+}
+
+// FieldDiff returns the fields of the CompanySchema which differ from c to o,
+// in schema order.
+func (c Company) FieldDiff(o Company) []FieldChange {
+	return fieldDiff(c, o, false)
+}
+
+// fieldDiff returns the changes to the string fields of the schema from a to
+// b, which are of the same struct type, stopping at the first if first is
+// set.
+func fieldDiff(a, b any, first bool) []FieldChange {
+	av, bv := reflect.ValueOf(a), reflect.ValueOf(b)
+	var changes []FieldChange
+	for i := range av.NumField() {
+		f := av.Type().Field(i)
+		if f.Type.Kind() != reflect.String || f.Tag.Get("schema") == "-" {
+			continue
+		}
+		if o, n := av.Field(i).String(), bv.Field(i).String(); o != n {
+			changes = append(changes, FieldChange{Field: f.Name, Old: o, New: n})
+			if first {
+				break
+			}
+		}
+	}
+	return changes
+}
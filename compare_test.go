@@ -0,0 +1,37 @@
+package chapointdat
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_Person_FieldDiff(t *testing.T) {
+	a := Person{CompanyNumber: "00000084", PersonNumber: "024407940002", Surname: "SMITH", Postcode: "NP25 3DZ"}
+	b := a
+	b.Truncated = true
+	b.Phonetic = &PhoneticKeys{Soundex: "S530"}
+	if !a.Equal(b) || len(a.FieldDiff(b)) != 0 {
+		t.Errorf("expected derived fields to be ignored, got %v", a.FieldDiff(b))
+	}
+	b.Surname, b.Postcode = "SMYTH", "NP25 3DY"
+	want := []FieldChange{{Field: "Postcode", Old: "NP25 3DZ", New: "NP25 3DY"}, {Field: "Surname", Old: "SMITH", New: "SMYTH"}}
+	if got := a.FieldDiff(b); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v got %v", want, got)
+	}
+	if a.Equal(b) {
+		t.Error("expected persons to differ")
+	}
+}
+
+func Test_Company_FieldDiff(t *testing.T) {
+	a := Company{CompanyNumber: "00000084", CompanyName: "A. WEST & PARTNERS", NormalizedName: "A WEST"}
+	b := a
+	b.NormalizedName = ""
+	if !a.Equal(b) {
+		t.Error("expected NormalizedName to be ignored")
+	}
+	b.CompanyStatus = "D"
+	if got := a.FieldDiff(b); len(got) != 1 || got[0] != (FieldChange{Field: "CompanyStatus", New: "D"}) || a.Equal(b) {
+		t.Errorf("unexpected diff %v", got)
+	}
+}
@@ -0,0 +1,320 @@
+package chapointdat
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+)
+
+type (
+	// Corrections are the records of correction files issued for a run,
+	// which replace the records of the snapshot with the same company number
+	// or AppointmentKey when read by a Reader with WithCorrections.
+	Corrections struct {
+		// Run is the run of the correction files.
+		Run int
+		// lines are the lines of the corrections by correctionKey, with later
+		// files winning.
+		lines map[string][]byte
+		// companies are the company numbers of the corrections in the order
+		// first read, and persons the keys of the person lines of each.
+		companies []string
+		persons   map[string][]string
+	}
+
+	// correcting applies Corrections for a Reader, recording which have been
+	// used.
+	correcting struct {
+		mu   sync.Mutex
+		c    *Corrections
+		used map[string]bool
+	}
+
+	// correctingScanner applies corrections to the lines of a file: replacing
+	// lines, adding the persons of a company at the end of its lines and
+	// adjusting the trailer count for them.
+	correctingScanner struct {
+		lineScanner
+		c  *correcting
+		st *state
+		// line is the line last scanned, pending lines to return before
+		// held, a line read from the file.
+		line    []byte
+		pending [][]byte
+		held    []byte
+		company string
+		added   int
+		err     error
+	}
+)
+
+// ReadCorrections reads correction files, each a zip or .dat file in the
+// snapshot format, in order, so that a record in a later file replaces one in
+// an earlier file. The files must all be of the same run.
+func ReadCorrections(paths ...string) (*Corrections, error) {
+	c := &Corrections{lines: map[string][]byte{}, persons: map[string][]string{}}
+	r := NewReader(WithRelaxedFraming())
+	for _, path := range paths {
+		err := readSnapshotLines(path, func(line []byte) error {
+			if bytes.HasPrefix(line, []byte(headerIdentifierPrefix)) {
+				h, err := r.headerRow(line)
+				if err != nil {
+					return err
+				}
+				if c.Run != 0 && h.Run != c.Run {
+					return fmt.Errorf("run %d does not match run %d", h.Run, c.Run)
+				}
+				c.Run = h.Run
+				return nil
+			}
+			key, ok := correctionKey(line)
+			if !ok {
+				return nil
+			}
+			number := string(line[0:8])
+			if _, seen := c.persons[number]; !seen {
+				c.companies = append(c.companies, number)
+				c.persons[number] = nil
+			}
+			if _, seen := c.lines[key]; !seen && line[8] == personRecordType[0] {
+				c.persons[number] = append(c.persons[number], key)
+			}
+			c.lines[key] = bytes.Clone(line)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error reading corrections %s: %w", path, err)
+		}
+	}
+	return c, nil
+}
+
+// WithCorrections applies c over the snapshot as it is read, so that handlers
+// see the corrected view: a company or person in c replaces the one of the
+// snapshot with the same company number or AppointmentKey, persons of a
+// company in c which are not in the snapshot are read after its other
+// persons, and companies in c which are in none of the files read are
+// delivered, with their persons, once every file has been read. Corrections
+// cannot remove records. Files of a run other than that of c are rejected.
+// Summary.Corrections counts the records replaced or added.
+func WithCorrections(c *Corrections) Opt {
+	return func(r *Reader) {
+		r.corrections = &correcting{c: c, used: map[string]bool{}}
+	}
+}
+
+// correctionKey returns the key of a company or person line, of its record
+// type, company number and, for a person, appointment type and person number.
+func correctionKey(line []byte) (string, bool) {
+	switch {
+	case len(line) >= 9 && line[8] == companyRecordType[0]:
+		return string(line[0:9]), true
+	case len(line) >= 24 && line[8] == personRecordType[0]:
+		return string(line[0:9]) + string(line[10:24]), true
+	}
+	return "", false
+}
+
+// readSnapshotLines calls fn with each line of path, a zip of .dat files or a
+// .dat file.
+func readSnapshotLines(path string, fn func(line []byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	var readers []func() (io.ReadCloser, error)
+	magic := make([]byte, len(zipMagic))
+	if _, err := io.ReadFull(f, magic); err == nil && string(magic) == zipMagic {
+		fi, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		z, err := zip.NewReader(f, fi.Size())
+		if err != nil {
+			return err
+		}
+		for _, zf := range z.File {
+			readers = append(readers, zf.Open)
+		}
+	} else if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	} else {
+		readers = append(readers, func() (io.ReadCloser, error) { return io.NopCloser(f), nil })
+	}
+	for _, open := range readers {
+		rc, err := open()
+		if err != nil {
+			return err
+		}
+		scan := newScanner(rc, 0)
+		for scan.Scan() {
+			if err := fn(scan.Bytes()); err != nil {
+				_ = rc.Close()
+				return err
+			}
+		}
+		if err := errors.Join(scan.Err(), rc.Close()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanner returns scan with the corrections applied to its lines.
+func (c *correcting) scanner(scan lineScanner, st *state) lineScanner {
+	return &correctingScanner{lineScanner: scan, c: c, st: st}
+}
+
+// line returns the correction replacing line, if any.
+func (c *correcting) line(line []byte) ([]byte, bool) {
+	key, ok := correctionKey(line)
+	if !ok {
+		return line, false
+	}
+	corrected, ok := c.c.lines[key]
+	if !ok {
+		return line, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.used[key] = true
+	return corrected, true
+}
+
+// endCompany returns the persons of company in the corrections which have not
+// been read.
+func (c *correcting) endCompany(company string) [][]byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var lines [][]byte
+	for _, key := range c.c.persons[company] {
+		if !c.used[key] {
+			c.used[key] = true
+			lines = append(lines, c.c.lines[key])
+		}
+	}
+	return lines
+}
+
+// remaining returns the lines of the companies in the corrections which have
+// not been read, each followed by its persons.
+func (c *correcting) remaining() [][]byte {
+	var lines [][]byte
+	for _, n := range c.c.companies {
+		c.mu.Lock()
+		key := n + companyRecordType
+		company, ok := c.c.lines[key]
+		if !ok || c.used[key] {
+			c.mu.Unlock()
+			continue
+		}
+		c.used[key] = true
+		c.mu.Unlock()
+		lines = append(append(lines, company), c.endCompany(n)...)
+	}
+	return lines
+}
+
+// deliverCorrections delivers the companies in the corrections which were in
+// none of the files read, adding them to s.
+func (r *Reader) deliverCorrections(s *Summary) error {
+	for _, line := range r.corrections.remaining() {
+		rec, err := r.parse(line, false)
+		if err != nil {
+			return fmt.Errorf("error processing correction: %w", err)
+		}
+		if r.selected != nil && !r.selected(rec) {
+			continue
+		}
+		if rec.Kind == RecordKindCompany && r.disappeared != nil {
+			r.disappeared.add(rec.Company.CompanyNumber)
+		}
+		if s != nil {
+			s.Corrections++
+		}
+		if err := r.deliver(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *correctingScanner) Scan() bool {
+	for {
+		if len(s.pending) > 0 {
+			s.line, s.pending = s.pending[0], s.pending[1:]
+			s.added++
+			s.st.summary.Corrections++
+			return true
+		}
+		if s.held != nil {
+			s.line, s.held = s.correct(s.held), nil
+			return true
+		}
+		if s.err != nil || !s.lineScanner.Scan() {
+			// a file without a trailer ends with the persons of its last
+			// company
+			if s.pending = s.c.endCompany(s.company); len(s.pending) > 0 {
+				s.company = ""
+				continue
+			}
+			return false
+		}
+		line := s.lineScanner.Bytes()
+		switch {
+		case bytes.HasPrefix(line, []byte(headerIdentifierPrefix)):
+			if run, err := strconv.Atoi(string(line[min(8, len(line)):min(12, len(line))])); err == nil && s.c.c.Run != 0 && run != s.c.c.Run {
+				s.err = fmt.Errorf("error applying corrections: run %d does not match the run %d of the corrections", run, s.c.c.Run)
+				return false
+			}
+			s.line = line
+			return true
+		case bytes.HasPrefix(line, []byte(trailerRecordIdentifier)):
+			s.pending, s.held = s.c.endCompany(s.company), line
+			s.company = ""
+		case len(line) >= 9:
+			if number := string(line[0:8]); number != s.company {
+				s.pending = s.c.endCompany(s.company)
+				s.company = number
+			}
+			s.held = line
+		default:
+			s.line = line
+			return true
+		}
+	}
+}
+
+// correct returns the correction of line, or for a trailer the trailer with
+// its count including the persons added.
+func (s *correctingScanner) correct(line []byte) []byte {
+	if bytes.HasPrefix(line, []byte(trailerRecordIdentifier)) {
+		if len(line) < 16 || s.added == 0 {
+			return line
+		}
+		count, err := strconv.Atoi(string(bytes.TrimSpace(line[8:16])))
+		if err != nil {
+			return line
+		}
+		return append(fmt.Appendf(nil, "%s%08d", trailerRecordIdentifier, count+s.added), line[16:]...)
+	}
+	corrected, ok := s.c.line(line)
+	if ok {
+		s.st.summary.Corrections++
+	}
+	return corrected
+}
+
+func (s *correctingScanner) Bytes() []byte {
+	return s.line
+}
+
+func (s *correctingScanner) Err() error {
+	return errors.Join(s.err, s.lineScanner.Err())
+}
@@ -0,0 +1,85 @@
+package chapointdat
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_WithCorrections(t *testing.T) {
+	dir := t.TempDir()
+	company := strings.Replace(testCompanyLine, "00000084", "00463819", 1)
+	renamed := strings.Replace(company, "A. WEST", "A. EAST", 1)
+	moved := strings.Replace(testPersonLine, "NP25 3DZ", "CF14 3UZ", 1)
+	appointed := strings.Replace(testPersonLine, "024407940002", "024407940003", 1)
+	added := strings.Replace(testCompanyLine, "00000084", "00000099", 1)
+	addedPerson := strings.Replace(testPersonLine, "00463819", "00000099", 1)
+	// the second file replaces the correction of the first
+	first := filepath.Join(dir, "Prod195_corrections_1.dat")
+	second := writeTestZip(t, "Prod195_corrections_2.zip", testSnapshot(moved, appointed, added, addedPerson))
+	if err := os.WriteFile(first, []byte(testSnapshot(strings.Replace(company, "A. WEST", "A. NORTH", 1), testPersonLine)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "c.dat"), []byte(testSnapshot(renamed)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := ReadCorrections(first, second, filepath.Join(dir, "c.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var companies []string
+	var persons []Person
+	r := NewReader(WithCorrections(c), WithCompanyHandler(func(c Company) error {
+		companies = append(companies, c.CompanyNumber+" "+c.CompanyName)
+		return nil
+	}), WithPersonHandler(func(p Person) error {
+		persons = append(persons, p)
+		return nil
+	}))
+	s, err := r.ExtractReader(strings.NewReader(testSnapshot(company, testPersonLine, testCompanyLine)), "-", func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"00463819 A. EAST & PARTNERS", "00000084 A. WEST & PARTNERS", "00000099 A. WEST & PARTNERS"}
+	if strings.Join(companies, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v got %v", expected, companies)
+	}
+	if len(persons) != 3 {
+		t.Fatalf("expected 3 persons got %d", len(persons))
+	}
+	if p := persons[0]; p.Postcode != "CF14 3UZ" || p.PersonNumber != "024407940002" {
+		t.Errorf("expected the corrected person got %+v", p)
+	}
+	if p := persons[1]; p.CompanyNumber != "00463819" || p.PersonNumber != "024407940003" {
+		t.Errorf("expected the added person got %+v", p)
+	}
+	if p := persons[2]; p.CompanyNumber != "00000099" {
+		t.Errorf("expected the person of the added company got %+v", p)
+	}
+	if s.Corrections != 5 {
+		t.Errorf("expected 5 corrections got %d", s.Corrections)
+	}
+	if err := s.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_WithCorrections_Run(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "c.dat")
+	if err := os.WriteFile(path, []byte(strings.Replace(testSnapshot(testCompanyLine), "0195", "0196", 1)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := ReadCorrections(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadCorrections(path, writeTestZip(t, "c.zip", testSnapshot(testCompanyLine))); err == nil {
+		t.Error("expected an error for corrections of different runs")
+	}
+	r := NewReader(WithCorrections(c))
+	if _, err := r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine)), "-", func(err error) {}); err == nil || !strings.Contains(err.Error(), "run 195") {
+		t.Errorf("expected a run error got %v", err)
+	}
+}
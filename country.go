@@ -0,0 +1,76 @@
+package chapointdat
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// CountryDiscrepancy is an officer whose country of residence differs from
+// the country of their service address.
+type CountryDiscrepancy struct {
+	Person Person
+	// Country and ResCountry are the normalized countries compared.
+	Country, ResCountry string
+}
+
+// countryAliases map normalized names to the country they are compared as.
+// The countries of the United Kingdom are the United Kingdom, as a service
+// address in England for a resident of the United Kingdom is not a
+// discrepancy.
+var countryAliases = map[string]string{
+	"UK": "UNITED KINGDOM", "GB": "UNITED KINGDOM", "GREAT BRITAIN": "UNITED KINGDOM", "BRITAIN": "UNITED KINGDOM",
+	"ENGLAND": "UNITED KINGDOM", "SCOTLAND": "UNITED KINGDOM", "WALES": "UNITED KINGDOM",
+	"NORTHERN IRELAND": "UNITED KINGDOM", "ENGLAND AND WALES": "UNITED KINGDOM",
+	"US": "UNITED STATES", "USA": "UNITED STATES", "UNITED STATES OF AMERICA": "UNITED STATES",
+	"IRELAND": "REPUBLIC OF IRELAND", "EIRE": "REPUBLIC OF IRELAND",
+}
+
+// WithCountryDiscrepancyHandler calls h, after the person handler, with each
+// officer whose ResCountry differs from their service address Country once
+// both are normalized with NormalizeCountry. Officers without both, such as
+// corporate officers, are skipped. An error returned by h is handled as for a
+// sink.
+func WithCountryDiscrepancyHandler(h func(d CountryDiscrepancy) error) Opt {
+	return func(r *Reader) {
+		r.sinks = append(r.sinks, func(rec Record) error {
+			if rec.Kind != RecordKindPerson {
+				return nil
+			}
+			d, ok := CheckCountries(*rec.Person)
+			if !ok {
+				return nil
+			}
+			if err := h(d); err != nil {
+				return fmt.Errorf("error processing country discrepancy handler: %w", err)
+			}
+			return nil
+		})
+	}
+}
+
+// CheckCountries returns the CountryDiscrepancy of p, and false if p has no
+// discrepancy.
+func CheckCountries(p Person) (CountryDiscrepancy, bool) {
+	country, res := NormalizeCountry(p.Country), NormalizeCountry(p.ResCountry)
+	if country == "" || res == "" || country == res {
+		return CountryDiscrepancy{}, false
+	}
+	return CountryDiscrepancy{Person: p, Country: country, ResCountry: res}, true
+}
+
+// NormalizeCountry returns country in upper case without punctuation, with
+// common alternative names, such as UK and England, replaced.
+func NormalizeCountry(country string) string {
+	s := strings.Map(func(r rune) rune {
+		if unicode.IsPunct(r) {
+			return -1
+		}
+		return unicode.ToUpper(r)
+	}, country)
+	s = strings.Join(strings.Fields(s), " ")
+	if alias, ok := countryAliases[s]; ok {
+		return alias
+	}
+	return s
+}
@@ -0,0 +1,39 @@
+package chapointdat
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_CountryDiscrepancyHandler(t *testing.T) {
+	// WALES and ENGLAND are both the United Kingdom
+	france := strings.Replace(strings.Replace(testPersonLine, "<ENGLAND<", "<FRANCE<", 1), "0093MR", "0092MR", 1)
+	var discrepancies []CountryDiscrepancy
+	r := NewReader(WithCountryDiscrepancyHandler(func(d CountryDiscrepancy) error {
+		discrepancies = append(discrepancies, d)
+		return nil
+	}))
+	if _, err := r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine, testPersonLine, france)), "-", func(err error) { t.Error(err) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(discrepancies) != 1 {
+		t.Fatalf("unexpected discrepancies %+v", discrepancies)
+	}
+	if d := discrepancies[0]; d.Country != "UNITED KINGDOM" || d.ResCountry != "FRANCE" || d.Person.Surname != "KJAERSGAARD" {
+		t.Errorf("unexpected discrepancy %+v", d)
+	}
+}
+
+func Test_NormalizeCountry(t *testing.T) {
+	for country, expected := range map[string]string{
+		"U.K.": "UNITED KINGDOM", " england ": "UNITED KINGDOM", "United  States of America": "UNITED STATES",
+		"France": "FRANCE", "": "",
+	} {
+		if s := NormalizeCountry(country); s != expected {
+			t.Errorf("expected %q for %q got %q", expected, country, s)
+		}
+	}
+	if _, ok := CheckCountries(Person{Country: "FRANCE"}); ok {
+		t.Error("expected no discrepancy without a ResCountry")
+	}
+}
@@ -0,0 +1,88 @@
+package chapointdat
+
+import (
+	"fmt"
+	"sync"
+)
+
+type (
+	// CoverageReport reconciles a list of expected companies, such as a
+	// client portfolio, with a snapshot.
+	CoverageReport struct {
+		Expected int
+		// Found is the number of expected companies read.
+		Found int
+		// Missing are the expected companies not read, and NoOfficers those
+		// read without any appointments, in company number order.
+		Missing    []string
+		NoOfficers []string
+	}
+
+	coverage struct {
+		mu       sync.Mutex
+		expected CompanySet
+		found    CompanySet
+		// officers counts the appointments of each expected company.
+		officers map[string]int
+		handler  func(c CoverageReport) error
+	}
+)
+
+// WithCoverageReport calls h, once extraction has finished without being
+// stopped, with a CoverageReport of the companies in expected, whose numbers
+// must be in the format set by WithCompanyNumberFormat, if any. Companies and
+// persons not selected by WithSelect are not counted.
+func WithCoverageReport(expected CompanySet, h func(c CoverageReport) error) Opt {
+	return func(r *Reader) {
+		c := &coverage{expected: expected, found: CompanySet{}, officers: map[string]int{}, handler: h}
+		r.sinks = append(r.sinks, c.write)
+		r.flushers = append(r.flushers, func() error {
+			if r.stopped.Load() {
+				return nil
+			}
+			return c.flush()
+		})
+	}
+}
+
+func (c *coverage) write(rec Record) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case rec.Kind == RecordKindCompany && c.expected.Contains(rec.Company.CompanyNumber):
+		c.found.Add(rec.Company.CompanyNumber)
+	case rec.Kind == RecordKindPerson && c.expected.Contains(rec.Person.CompanyNumber):
+		c.officers[rec.Person.CompanyNumber]++
+	}
+	return nil
+}
+
+func (c *coverage) flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	report := CoverageReport{Expected: len(c.expected)}
+	for _, n := range c.expected.Sorted() {
+		switch {
+		case !c.found.Contains(n):
+			report.Missing = append(report.Missing, n)
+		case c.officers[n] == 0:
+			report.Found++
+			report.NoOfficers = append(report.NoOfficers, n)
+		default:
+			report.Found++
+		}
+	}
+	if err := c.handler(report); err != nil {
+		return fmt.Errorf("error processing coverage report handler: %w", err)
+	}
+	return nil
+}
+
+// Coverage returns the fraction of expected companies found, or 1 if none
+// were expected.
+func (c CoverageReport) Coverage() float64 {
+	if c.Expected == 0 {
+		return 1
+	}
+	return float64(c.Found) / float64(c.Expected)
+}
@@ -0,0 +1,36 @@
+package chapointdat
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_WithCoverageReport(t *testing.T) {
+	company := strings.Replace(testCompanyLine, "00000084", "00463819", 1)
+	expected := CompanySet{}
+	for _, n := range []string{"00463819", "00000084", "00000085", "00000086"} {
+		expected.Add(n)
+	}
+	var reports []CoverageReport
+	r := NewReader(WithCoverageReport(expected, func(c CoverageReport) error {
+		reports = append(reports, c)
+		return nil
+	}))
+	if _, err := r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine, company, testPersonLine)), "-", func(err error) { t.Error(err) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 {
+		t.Fatalf("expected 1 report got %d", len(reports))
+	}
+	want := CoverageReport{Expected: 4, Found: 2, Missing: []string{"00000085", "00000086"}, NoOfficers: []string{"00000084"}}
+	if !reflect.DeepEqual(reports[0], want) {
+		t.Errorf("expected %+v got %+v", want, reports[0])
+	}
+	if c := reports[0].Coverage(); c != 0.5 {
+		t.Errorf("expected coverage of 0.5 got %v", c)
+	}
+	if c := (CoverageReport{}).Coverage(); c != 1 {
+		t.Errorf("expected full coverage of nothing got %v", c)
+	}
+}
@@ -0,0 +1,105 @@
+package chapointdat
+
+import "unicode/utf8"
+
+const (
+	encodingUnknown = lineEncoding(iota)
+	encodingUTF8
+	encodingCP1252
+)
+
+type lineEncoding int
+
+// cp1252 are the characters of the bytes 0x80 to 0x9f in Windows-1252, the
+// bytes it leaves undefined being read as the C1 control characters as Windows
+// does. Bytes from 0xa0 are the same as in Latin-1.
+var cp1252 = [32]rune{
+	'€', '\u0081', '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', '\u008d', 'Ž', '\u008f',
+	'\u0090', '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', '\u009d', 'ž', 'Ÿ',
+}
+
+// WithCP1252Fallback reads files which have been re-saved by Windows tools in
+// Windows-1252 rather than UTF-8, which otherwise give errors or mojibake as
+// the byte lengths of names and variable data no longer match. The encoding
+// of each file is detected from its first line which is not ASCII: if that
+// line is not valid UTF-8, every line of the file is decoded from
+// Windows-1252 before it is parsed. Decoded lines are counted in
+// Summary.CP1252Lines, and offsets in checkpoints, indexes and dead letters
+// remain those of the file as read.
+func WithCP1252Fallback() Opt {
+	return func(r *Reader) {
+		r.cp1252Fallback = true
+	}
+}
+
+// decodingScanner decodes the lines of a file detected as Windows-1252.
+type decodingScanner struct {
+	lineScanner
+	st   *state
+	line []byte
+}
+
+func (s *decodingScanner) Scan() bool {
+	if !s.lineScanner.Scan() {
+		return false
+	}
+	s.line = s.st.decode(s.lineScanner.Bytes())
+	return true
+}
+
+func (s *decodingScanner) Bytes() []byte {
+	return s.line
+}
+
+// decode returns line as UTF-8, detecting the encoding of the file from its
+// first line which is not ASCII.
+func (st *state) decode(line []byte) []byte {
+	if st.encoding == encodingUnknown {
+		ascii := true
+		for _, c := range line {
+			if c >= utf8.RuneSelf {
+				ascii = false
+				break
+			}
+		}
+		switch {
+		case ascii:
+			return line
+		case utf8.Valid(line):
+			st.encoding = encodingUTF8
+		default:
+			st.encoding = encodingCP1252
+		}
+	}
+	if st.encoding == encodingUTF8 {
+		return line
+	}
+	return st.decodeCP1252(line)
+}
+
+// decodeCP1252 returns line decoded from Windows-1252, counting it if it is
+// not ASCII.
+func (st *state) decodeCP1252(line []byte) []byte {
+	var b []byte
+	for i, c := range line {
+		if c < utf8.RuneSelf {
+			if b != nil {
+				b = append(b, c)
+			}
+			continue
+		}
+		if b == nil {
+			b = append(make([]byte, 0, len(line)+len(line)/4), line[:i]...)
+		}
+		if c < 0xa0 {
+			b = utf8.AppendRune(b, cp1252[c-0x80])
+		} else {
+			b = utf8.AppendRune(b, rune(c))
+		}
+	}
+	if b == nil {
+		return line
+	}
+	st.summary.CP1252Lines++
+	return b
+}
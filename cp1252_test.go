@@ -0,0 +1,65 @@
+package chapointdat
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_WithCP1252Fallback(t *testing.T) {
+	// the name length counts the bytes of the name in UTF-8
+	company := "000000841D                      00010010CAFÉ LTD<"
+	person := strings.Replace(testPersonLine, "<HANS<", "<SEÁN<", 1)
+	person = strings.Replace(person, "0093", "0094", 1)
+	resaved := strings.NewReplacer("É", "\xc9", "Á", "\xc1").Replace(testSnapshot(company, person))
+
+	var companies []Company
+	var persons []Person
+	r := NewReader(WithCP1252Fallback(), WithCompanyHandler(func(c Company) error {
+		companies = append(companies, c)
+		return nil
+	}), WithPersonHandler(func(p Person) error {
+		persons = append(persons, p)
+		return nil
+	}))
+	s, err := r.ExtractReader(strings.NewReader(resaved), "-", func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(companies) != 1 || companies[0].CompanyName != "CAFÉ LTD" {
+		t.Errorf("unexpected companies %+v", companies)
+	}
+	if len(persons) != 1 || persons[0].Forenames != "SEÁN" {
+		t.Errorf("unexpected persons %+v", persons)
+	}
+	if s.CP1252Lines != 2 {
+		t.Errorf("expected 2 lines decoded got %d", s.CP1252Lines)
+	}
+
+	errs := 0
+	if _, err := NewReader().ExtractReader(strings.NewReader(resaved), "-", func(err error) { errs++ }); err != nil {
+		t.Fatal(err)
+	}
+	if errs == 0 {
+		t.Error("expected errors without the fallback")
+	}
+
+	// a file of valid UTF-8 is not decoded
+	companies = nil
+	s, err = r.ExtractReader(strings.NewReader(testSnapshot(company, strings.Replace(company, "00000084", "00000085", 1)+"\xc9")), "-", func(err error) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.CP1252Lines != 0 || len(companies) != 2 || companies[0].CompanyName != "CAFÉ LTD" {
+		t.Errorf("unexpected decoding of UTF-8 %d %+v", s.CP1252Lines, companies)
+	}
+}
+
+func Test_decodeCP1252(t *testing.T) {
+	st := &state{}
+	if s := string(st.decodeCP1252([]byte("O\x92BRIEN \x80 \xe6"))); s != "O’BRIEN € æ" {
+		t.Errorf("unexpected decoding %q", s)
+	}
+	if st.summary.CP1252Lines != 1 {
+		t.Errorf("expected 1 line decoded got %d", st.summary.CP1252Lines)
+	}
+}
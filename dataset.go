@@ -0,0 +1,180 @@
+package chapointdat
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// datasetMagic starts a file written by Dataset.WriteTo, followed by its
+// format version.
+const (
+	datasetMagic   = "CHAPDATA"
+	datasetVersion = 1
+)
+
+type (
+	// AppointmentKey identifies an appointment across runs. The same person
+	// may hold more than one type of appointment in a company.
+	AppointmentKey struct {
+		CompanyNumber   string
+		PersonNumber    string
+		AppointmentType string
+	}
+
+	// datasetHeader is the start of a file written by Dataset.WriteTo, which
+	// is followed by each company and then each appointment.
+	datasetHeader struct {
+		Version      int
+		Headers      []Header
+		Companies    int
+		Appointments int
+	}
+
+	// Dataset holds the companies and appointments of a snapshot in memory,
+	// for comparison with another run. Pass its Write method to
+	// WithRecordHandler. A full snapshot needs several GB; filter records
+	// before Write to hold only those of interest.
+	Dataset struct {
+		mu           sync.Mutex
+		Headers      []Header
+		Companies    map[string]Company
+		Appointments map[AppointmentKey]Person
+	}
+)
+
+func NewDataset() *Dataset {
+	return &Dataset{Companies: map[string]Company{}, Appointments: map[AppointmentKey]Person{}}
+}
+
+// Key returns the AppointmentKey of p.
+func (p Person) Key() AppointmentKey {
+	return AppointmentKey{CompanyNumber: p.CompanyNumber, PersonNumber: p.PersonNumber, AppointmentType: p.AppointmentType}
+}
+
+// Write adds headers, companies and persons to d. Other records are ignored.
+func (d *Dataset) Write(rec Record) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch rec.Kind {
+	case RecordKindHeader:
+		d.Headers = append(d.Headers, *rec.Header)
+	case RecordKindCompany:
+		d.Companies[rec.Company.CompanyNumber] = *rec.Company
+	case RecordKindPerson:
+		d.Appointments[rec.Person.Key()] = *rec.Person
+	}
+	return nil
+}
+
+// Keys returns the keys of the appointments in d in order.
+func (d *Dataset) Keys() []AppointmentKey {
+	keys := make([]AppointmentKey, 0, len(d.Appointments))
+	for k := range d.Appointments {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, AppointmentKey.Compare)
+	return keys
+}
+
+// Compare orders keys by company number, person number and then appointment
+// type.
+func (k AppointmentKey) Compare(o AppointmentKey) int {
+	return cmp.Or(
+		cmp.Compare(k.CompanyNumber, o.CompanyNumber),
+		cmp.Compare(k.PersonNumber, o.PersonNumber),
+		cmp.Compare(k.AppointmentType, o.AppointmentType),
+	)
+}
+
+// WriteTo writes d to w in a binary format read by ReadDataset, so that a
+// service restarting can reload a snapshot in seconds rather than parsing it
+// again. Companies and appointments are written in order, so the same dataset
+// is always written the same way.
+func (d *Dataset) WriteTo(w io.Writer) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	bw := bufio.NewWriter(w)
+	cw := &countingWriter{w: bw}
+	if _, err := io.WriteString(cw, datasetMagic); err != nil {
+		return cw.n, err
+	}
+	if err := d.encode(gob.NewEncoder(cw)); err != nil {
+		return cw.n, fmt.Errorf("error writing dataset: %w", err)
+	}
+	return cw.n, bw.Flush()
+}
+
+// encode writes the header of d and then each company and appointment in
+// order.
+func (d *Dataset) encode(enc *gob.Encoder) error {
+	h := datasetHeader{Version: datasetVersion, Headers: d.Headers, Companies: len(d.Companies), Appointments: len(d.Appointments)}
+	if err := enc.Encode(h); err != nil {
+		return err
+	}
+	for _, n := range slices.Sorted(maps.Keys(d.Companies)) {
+		if err := enc.Encode(d.Companies[n]); err != nil {
+			return err
+		}
+	}
+	for _, k := range d.Keys() {
+		if err := enc.Encode(d.Appointments[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadDataset reads a Dataset written by Dataset.WriteTo.
+func ReadDataset(rd io.Reader) (*Dataset, error) {
+	br := bufio.NewReader(rd)
+	magic := make([]byte, len(datasetMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != datasetMagic {
+		return nil, errors.New("error reading dataset: not a dataset file")
+	}
+	return decodeDataset(gob.NewDecoder(br))
+}
+
+// decodeDataset reads a Dataset written by Dataset.encode.
+func decodeDataset(dec *gob.Decoder) (*Dataset, error) {
+	var h datasetHeader
+	if err := dec.Decode(&h); err != nil {
+		return nil, fmt.Errorf("error reading dataset: %w", err)
+	}
+	if h.Version != datasetVersion {
+		return nil, fmt.Errorf("error reading dataset: unsupported version %d", h.Version)
+	}
+	d := &Dataset{Headers: h.Headers, Companies: make(map[string]Company, h.Companies), Appointments: make(map[AppointmentKey]Person, h.Appointments)}
+	for range h.Companies {
+		var c Company
+		if err := dec.Decode(&c); err != nil {
+			return nil, fmt.Errorf("error reading dataset company: %w", err)
+		}
+		d.Companies[c.CompanyNumber] = c
+	}
+	for range h.Appointments {
+		var p Person
+		if err := dec.Decode(&p); err != nil {
+			return nil, fmt.Errorf("error reading dataset appointment: %w", err)
+		}
+		d.Appointments[p.Key()] = p
+	}
+	return d, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
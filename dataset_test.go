@@ -0,0 +1,45 @@
+package chapointdat
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_Dataset_WriteTo(t *testing.T) {
+	d := NewDataset()
+	r := NewReader(WithRecordHandler(d.Write), WithPhoneticKeys())
+	if _, err := r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine, testPersonLine)), "-", func(err error) { t.Error(err) }); err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	n, err := d.WriteTo(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(b.Len()) {
+		t.Errorf("expected %d bytes written got %d", b.Len(), n)
+	}
+	got, err := ReadDataset(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Companies, d.Companies) || !reflect.DeepEqual(got.Appointments, d.Appointments) || len(got.Headers) != 1 || !got.Headers[0].ProdDate.Equal(d.Headers[0].ProdDate) {
+		t.Errorf("expected %+v got %+v", d, got)
+	}
+	if len(DiffDatasets(d, got)) != 0 {
+		t.Error("expected no changes in the reloaded dataset")
+	}
+
+	var again bytes.Buffer
+	if _, err := got.WriteTo(&again); err != nil || !bytes.Equal(again.Bytes(), b.Bytes()) {
+		t.Errorf("expected the dataset to be written the same way %v", err)
+	}
+	if _, err := ReadDataset(strings.NewReader("not a dataset")); err == nil {
+		t.Error("expected an error")
+	}
+	if _, err := ReadDataset(bytes.NewReader(b.Bytes()[:b.Len()-10])); err == nil {
+		t.Error("expected an error for a truncated dataset")
+	}
+}
@@ -0,0 +1,166 @@
+package chapointdat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type (
+	// DeadLetter is a line that was rejected, with where it was read from and
+	// why.
+	DeadLetter struct {
+		Path  string
+		Entry string
+		// Line is the 1 based line number within Entry, or 0 for lines read by
+		// ExtractCompanies.
+		Line int
+		// Offset is the byte offset of the start of the line within Entry.
+		Offset int64
+		// Raw is the original bytes of the line, base64 encoded in JSON.
+		Raw  []byte
+		Err  error `json:"-"`
+		Time time.Time
+	}
+
+	// DeadLetterSink receives every line passed to the error handler. An error
+	// returned from WriteDeadLetter is joined to the error passed to the error
+	// handler. A sink which also has a Flush() error method is flushed when
+	// extraction finishes, as with WithFlush.
+	DeadLetterSink interface {
+		WriteDeadLetter(d DeadLetter) error
+	}
+
+	// ObjectPutter stores data under key, for example in an S3 bucket, so that
+	// an existing client can be used with NewObjectDeadLetterSink.
+	ObjectPutter interface {
+		PutObject(key string, data []byte) error
+	}
+
+	// MessageProducer publishes a message to a topic, for example with an
+	// existing Kafka producer, for use with NewTopicDeadLetterSink.
+	MessageProducer interface {
+		Produce(topic string, key, value []byte) error
+	}
+
+	fileDeadLetterSink struct {
+		mu sync.Mutex
+		e  *json.Encoder
+	}
+
+	objectDeadLetterSink struct {
+		mu        sync.Mutex
+		p         ObjectPutter
+		prefix    string
+		batchSize int
+		buf       bytes.Buffer
+		count     int
+		objects   int
+	}
+
+	topicDeadLetterSink struct {
+		p     MessageProducer
+		topic string
+	}
+)
+
+// WithDeadLetterSink writes every rejected line to s along with its error and
+// position. It may be given more than once.
+func WithDeadLetterSink(s DeadLetterSink) Opt {
+	return func(r *Reader) {
+		r.deadLetters = append(r.deadLetters, s)
+		if f, ok := s.(interface{ Flush() error }); ok {
+			r.flushers = append(r.flushers, f.Flush)
+		}
+	}
+}
+
+// MarshalJSON encodes d with Err as its message in an Error field.
+func (d DeadLetter) MarshalJSON() ([]byte, error) {
+	type deadLetter DeadLetter
+	v := struct {
+		deadLetter
+		Error string `json:",omitempty"`
+	}{deadLetter: deadLetter(d)}
+	if d.Err != nil {
+		v.Error = d.Err.Error()
+	}
+	return json.Marshal(v)
+}
+
+// NewFileDeadLetterSink returns a DeadLetterSink writing each DeadLetter to w
+// as a line of JSON.
+func NewFileDeadLetterSink(w io.Writer) DeadLetterSink {
+	e := json.NewEncoder(w)
+	e.SetEscapeHTML(false)
+	return &fileDeadLetterSink{e: e}
+}
+
+func (s *fileDeadLetterSink) WriteDeadLetter(d DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.e.Encode(d)
+}
+
+// NewObjectDeadLetterSink returns a DeadLetterSink which collects dead letters
+// as JSON lines and stores every batchSize of them, and any remaining when
+// extraction finishes, as an object named prefix followed by a sequence number
+// and .jsonl, such as rejected/000001.jsonl.
+func NewObjectDeadLetterSink(p ObjectPutter, prefix string, batchSize int) DeadLetterSink {
+	return &objectDeadLetterSink{p: p, prefix: prefix, batchSize: max(batchSize, 1)}
+}
+
+func (s *objectDeadLetterSink) WriteDeadLetter(d DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	s.buf.Write(b)
+	s.buf.WriteByte('\n')
+	s.count++
+	if s.count >= s.batchSize {
+		return s.flush()
+	}
+	return nil
+}
+
+func (s *objectDeadLetterSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush()
+}
+
+func (s *objectDeadLetterSink) flush() error {
+	if s.count == 0 {
+		return nil
+	}
+	s.objects++
+	key := fmt.Sprintf("%s%06d.jsonl", s.prefix, s.objects)
+	if err := s.p.PutObject(key, bytes.Clone(s.buf.Bytes())); err != nil {
+		return fmt.Errorf("error putting %s: %w", key, err)
+	}
+	s.buf.Reset()
+	s.count = 0
+	return nil
+}
+
+// NewTopicDeadLetterSink returns a DeadLetterSink publishing each DeadLetter
+// to topic as JSON, keyed by entry and line number so that redeliveries of the
+// same line share a key.
+func NewTopicDeadLetterSink(p MessageProducer, topic string) DeadLetterSink {
+	return &topicDeadLetterSink{p: p, topic: topic}
+}
+
+func (s *topicDeadLetterSink) WriteDeadLetter(d DeadLetter) error {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	return s.p.Produce(s.topic, []byte(d.Entry+":"+strconv.Itoa(d.Line)), b)
+}
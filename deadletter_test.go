@@ -0,0 +1,68 @@
+package chapointdat
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testObjects map[string][]byte
+
+func (o testObjects) PutObject(key string, data []byte) error {
+	o[key] = data
+	return nil
+}
+
+type testProducer struct {
+	topics, keys []string
+}
+
+func (p *testProducer) Produce(topic string, key, value []byte) error {
+	p.topics = append(p.topics, topic)
+	p.keys = append(p.keys, string(key))
+	return nil
+}
+
+func Test_DeadLetter_File(t *testing.T) {
+	path := writeTestZip(t, "a.zip", testSnapshot(testCompanyLine, testPersonLine))
+	var b bytes.Buffer
+	r := NewReader(WithDeadLetterSink(NewFileDeadLetterSink(&b)), WithPersonHandler(func(p Person) error {
+		return errors.New("not yet")
+	}))
+	if err := r.Extract(path, 1, func(err error) {}); err != nil {
+		t.Fatal(err)
+	}
+	var d struct {
+		DeadLetter
+		Error string
+	}
+	if err := json.Unmarshal(b.Bytes(), &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Path != path || d.Entry != "part1.dat" || d.Line != 3 || string(d.Raw) != testPersonLine || !strings.Contains(d.Error, "not yet") {
+		t.Errorf("unexpected dead letter %+v", d)
+	}
+}
+
+func Test_DeadLetter_Object_Topic(t *testing.T) {
+	bad := "00000000X unknown"
+	path := writeTestZip(t, "a.zip", testSnapshot(bad, bad, bad))
+	objects := testObjects{}
+	p := &testProducer{}
+	r := NewReader(
+		WithDeadLetterSink(NewObjectDeadLetterSink(objects, "rejected/", 2)),
+		WithDeadLetterSink(NewTopicDeadLetterSink(p, "dead")),
+	)
+	if err := r.Extract(path, 1, func(err error) {}); err != nil {
+		t.Fatal(err)
+	}
+	// three bad lines and the trailer
+	if len(objects) != 2 || bytes.Count(objects["rejected/000001.jsonl"], []byte("\n")) != 2 || bytes.Count(objects["rejected/000002.jsonl"], []byte("\n")) != 2 {
+		t.Errorf("unexpected objects %q", objects)
+	}
+	if strings.Join(p.keys, " ") != "part1.dat:2 part1.dat:3 part1.dat:4 part1.dat:5" || p.topics[0] != "dead" {
+		t.Errorf("unexpected messages %v %v", p.topics, p.keys)
+	}
+}
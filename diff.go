@@ -0,0 +1,61 @@
+package chapointdat
+
+import "slices"
+
+const (
+	ChangeAdded   = ChangeKind("added")
+	ChangeRemoved = ChangeKind("removed")
+	ChangeChanged = ChangeKind("changed")
+)
+
+type (
+	ChangeKind string
+
+	// AppointmentChange is an appointment added, removed or changed between
+	// two runs. Old is nil for an added appointment and New for a removed one.
+	AppointmentChange struct {
+		Kind ChangeKind
+		Key  AppointmentKey
+		Old  *Person `json:",omitempty"`
+		New  *Person `json:",omitempty"`
+		// Fields are the names of the fields which changed.
+		Fields []string `json:",omitempty"`
+	}
+)
+
+// DiffDatasets returns the appointments added, removed and changed from old to
+// new, in AppointmentKey order.
+func DiffDatasets(old, new *Dataset) []AppointmentChange {
+	var changes []AppointmentChange
+	for k, o := range old.Appointments {
+		n, ok := new.Appointments[k]
+		if !ok {
+			changes = append(changes, AppointmentChange{Kind: ChangeRemoved, Key: k, Old: &o})
+			continue
+		}
+		if diff := o.FieldDiff(n); len(diff) > 0 {
+			fields := make([]string, len(diff))
+			for i, f := range diff {
+				fields[i] = f.Field
+			}
+			changes = append(changes, AppointmentChange{Kind: ChangeChanged, Key: k, Old: &o, New: &n, Fields: fields})
+		}
+	}
+	for k, n := range new.Appointments {
+		if _, ok := old.Appointments[k]; !ok {
+			changes = append(changes, AppointmentChange{Kind: ChangeAdded, Key: k, New: &n})
+		}
+	}
+	slices.SortFunc(changes, func(a, b AppointmentChange) int {
+		return a.Key.Compare(b.Key)
+	})
+	return changes
+}
+
+// Person returns New, or Old for a removed appointment.
+func (c AppointmentChange) Person() Person {
+	if c.New != nil {
+		return *c.New
+	}
+	return *c.Old
+}
@@ -0,0 +1,45 @@
+package chapointdat
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func Test_DiffDatasets(t *testing.T) {
+	moved := strings.Replace(testPersonLine, "NP25 3DZ", "CF10 1AA", 1)
+	added := strings.Replace(testPersonLine, "024407940002", "024407940003", 1)
+	resigned := strings.Replace(testPersonLine, "004638192201", "004638192202", 1)
+	var datasets []*Dataset
+	for _, lines := range [][]string{
+		{testCompanyLine, testPersonLine, resigned},
+		{testCompanyLine, moved, added},
+	} {
+		d := NewDataset()
+		r := NewReader(WithRecordHandler(d.Write))
+		if _, err := r.ExtractReader(strings.NewReader(testSnapshot(lines...)), "-", func(err error) { t.Error(err) }); err != nil {
+			t.Fatal(err)
+		}
+		if len(d.Headers) != 1 || len(d.Companies) != 1 {
+			t.Fatalf("unexpected dataset %+v", d)
+		}
+		datasets = append(datasets, d)
+	}
+	changes := DiffDatasets(datasets[0], datasets[1])
+	var kinds []ChangeKind
+	for _, c := range changes {
+		kinds = append(kinds, c.Kind)
+	}
+	if !slices.Equal(kinds, []ChangeKind{ChangeChanged, ChangeRemoved, ChangeAdded}) {
+		t.Fatalf("unexpected changes %+v", changes)
+	}
+	if !slices.Equal(changes[0].Fields, []string{"Postcode"}) || changes[0].Old.Postcode != "NP25 3DZ" || changes[0].New.Postcode != "CF10 1AA" {
+		t.Errorf("unexpected change %+v", changes[0])
+	}
+	if changes[1].New != nil || changes[1].Key.AppointmentType != "02" {
+		t.Errorf("unexpected removal %+v", changes[1])
+	}
+	if changes[2].Old != nil || changes[2].Person().PersonNumber != "024407940003" {
+		t.Errorf("unexpected addition %+v", changes[2])
+	}
+}
@@ -0,0 +1,165 @@
+package chapointdat
+
+import (
+	"bufio"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type (
+	// CompanyNumbers is satisfied by CompanySet and BloomFilter.
+	CompanyNumbers interface {
+		Add(companyNumber string)
+		Contains(companyNumber string) bool
+	}
+	CompanySet  map[string]struct{}
+	BloomFilter struct {
+		bits []uint64
+		k    int
+	}
+
+	disappeared struct {
+		mu       sync.Mutex
+		previous CompanySet
+		seen     CompanyNumbers
+		handler  func(companyNumber string) error
+	}
+)
+
+// WithDisappearedCompanyHandler calls h, once extraction has finished without
+// being stopped, with each company number in previous, such as the companies
+// of the previous run, that was not read. Companies disappear from snapshots
+// when they are dissolved or removed. Company numbers read are added to seen,
+// which may be nil; a CompanySet can be saved for comparison with the next
+// run, while a BloomFilter sized for a whole snapshot uses far less memory at
+// the cost of missing a small proportion of disappearances.
+func WithDisappearedCompanyHandler(previous CompanySet, seen CompanyNumbers, h func(companyNumber string) error) Opt {
+	return func(r *Reader) {
+		if seen == nil {
+			seen = CompanySet{}
+		}
+		r.disappeared = &disappeared{previous: previous, seen: seen, handler: h}
+	}
+}
+
+func (d *disappeared) add(companyNumber string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.seen.Add(companyNumber)
+}
+
+// flush calls the handler for disappeared companies in company number order.
+func (d *disappeared) flush() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, n := range d.previous.Sorted() {
+		if d.seen.Contains(n) {
+			continue
+		}
+		if err := d.handler(n); err != nil {
+			return fmt.Errorf("error processing disappeared company handler: %w", err)
+		}
+	}
+	return nil
+}
+
+// ReadCompanySet reads company numbers one per line, as written by
+// CompanySet.WriteTo.
+func ReadCompanySet(rd io.Reader) (CompanySet, error) {
+	s := CompanySet{}
+	scan := bufio.NewScanner(rd)
+	for scan.Scan() {
+		if n := strings.TrimSpace(scan.Text()); n != "" {
+			s.Add(n)
+		}
+	}
+	return s, scan.Err()
+}
+
+func (s CompanySet) Add(companyNumber string) {
+	s[companyNumber] = struct{}{}
+}
+
+func (s CompanySet) Contains(companyNumber string) bool {
+	_, ok := s[companyNumber]
+	return ok
+}
+
+func (s CompanySet) Sorted() []string {
+	numbers := make([]string, 0, len(s))
+	for n := range s {
+		numbers = append(numbers, n)
+	}
+	slices.Sort(numbers)
+	return numbers
+}
+
+// WriteTo writes the company numbers in s one per line in order.
+func (s CompanySet) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	var written int64
+	for _, n := range s.Sorted() {
+		c, err := bw.WriteString(n + "\n")
+		written += int64(c)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, bw.Flush()
+}
+
+// minBloomRate and maxBloomRate bound the false positive rate of
+// NewBloomFilter.
+const (
+	minBloomRate = 1e-9
+	maxBloomRate = 0.5
+)
+
+// NewBloomFilter returns a BloomFilter sized for n company numbers with a false
+// positive rate of about p, which is between 0 and 1: a rate of 0 or less is
+// taken as one in a billion, and of 1 or more as a half.
+func NewBloomFilter(n int, p float64) *BloomFilter {
+	switch {
+	case !(p > 0):
+		p = minBloomRate
+	case p >= 1:
+		p = maxBloomRate
+	}
+	m := math.Ceil(-float64(max(n, 1)) * math.Log(p) / (math.Ln2 * math.Ln2))
+	k := max(int(math.Round(m/float64(max(n, 1))*math.Ln2)), 1)
+	return &BloomFilter{bits: make([]uint64, int(m)/64+1), k: k}
+}
+
+func (b *BloomFilter) Add(companyNumber string) {
+	h1, h2 := bloomHashes(companyNumber)
+	m := uint64(len(b.bits) * 64)
+	for i := range b.k {
+		bit := (h1 + uint64(i)*h2) % m
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+func (b *BloomFilter) Contains(companyNumber string) bool {
+	h1, h2 := bloomHashes(companyNumber)
+	m := uint64(len(b.bits) * 64)
+	for i := range b.k {
+		bit := (h1 + uint64(i)*h2) % m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bloomHashes returns the two hashes combined for each of the k bits.
+func bloomHashes(s string) (uint64, uint64) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	h1 := h.Sum64()
+	return h1, h1>>33 | h1<<31 | 1
+}
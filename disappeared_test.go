@@ -0,0 +1,76 @@
+package chapointdat
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func Test_DisappearedCompanies(t *testing.T) {
+	previous, err := ReadCompanySet(strings.NewReader("00000083\n00000084\n00000085\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, seen := range []CompanyNumbers{nil, CompanySet{}, NewBloomFilter(1000, 0.001)} {
+		var gone []string
+		r := NewReader(WithDisappearedCompanyHandler(previous, seen, func(companyNumber string) error {
+			gone = append(gone, companyNumber)
+			return nil
+		}))
+		if _, err := r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine)), "-", func(err error) { t.Error(err) }); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(gone, ",") != "00000083,00000085" {
+			t.Errorf("unexpected disappeared companies %v", gone)
+		}
+	}
+}
+
+func Test_CompanySet_WriteTo(t *testing.T) {
+	s := CompanySet{}
+	s.Add("SC000002")
+	s.Add("00000001")
+	var b bytes.Buffer
+	if _, err := s.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "00000001\nSC000002\n" {
+		t.Errorf("unexpected set %q", b.String())
+	}
+}
+
+func Test_BloomFilter_FalsePositives(t *testing.T) {
+	b := NewBloomFilter(10000, 0.01)
+	for i := range 10000 {
+		b.Add(fmt.Sprintf("%08d", i))
+	}
+	for i := range 10000 {
+		if !b.Contains(fmt.Sprintf("%08d", i)) {
+			t.Fatalf("missing %08d", i)
+		}
+	}
+	var fp int
+	for i := range 10000 {
+		if b.Contains(fmt.Sprintf("SC%06d", i)) {
+			fp++
+		}
+	}
+	if fp > 300 {
+		t.Errorf("too many false positives %d", fp)
+	}
+}
+
+func Test_NewBloomFilter_Rate(t *testing.T) {
+	for _, p := range []float64{0, -1, 1, 2, math.NaN()} {
+		b := NewBloomFilter(1000, p)
+		b.Add("00000084")
+		if !b.Contains("00000084") {
+			t.Errorf("expected a usable filter for rate %v", p)
+		}
+	}
+	if lo, hi := NewBloomFilter(1000, 0), NewBloomFilter(1000, 1); len(lo.bits) <= len(hi.bits) || len(hi.bits) < 2 {
+		t.Errorf("expected rates to be clamped got %d and %d words", len(lo.bits), len(hi.bits))
+	}
+}
@@ -0,0 +1,134 @@
+package chapointdat
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// DefaultDriftTolerance flags a change of more than 10% in the number of
+// files, lines, companies or persons, and a change of more than 1 percentage
+// point in a rate which is significant at z 3.29, a p value of 0.001.
+var DefaultDriftTolerance = DriftTolerance{Count: 0.1, Rate: 0.01, Z: 3.29}
+
+type (
+	// DriftTolerance sets how far a Summary may drift from the one before
+	// without being flagged by CompareSummaries.
+	DriftTolerance struct {
+		// Count is the largest relative change allowed in the number of
+		// files, lines, companies, persons and custom records.
+		Count float64
+		// Rate is the largest change allowed in the rate of errors,
+		// warnings, repairs and truncated persons per line, and in the share
+		// of companies of each status. A larger change is flagged only if it
+		// is also significant, having a two proportion z statistic of at
+		// least Z, so that small snapshots do not raise false alarms.
+		Rate float64
+		Z    float64
+	}
+
+	// Drift is a metric which changed by more than a DriftTolerance between
+	// two runs. Old and New are the counts of a count and the fractions of a
+	// rate.
+	Drift struct {
+		Metric string
+		Old    float64
+		New    float64
+		// Z is the z statistic of the change in a rate.
+		Z float64 `json:",omitempty"`
+	}
+)
+
+// CompareSummaries returns the metrics which drifted beyond t from old, the
+// Summary of the last good run, to new, so that a snapshot unexpectedly small,
+// or with unusual numbers of errors or companies of a status, can be held back
+// before it replaces a good dataset downstream. Rates are of errors, warnings,
+// truncated persons and each kind of repair per line, and of each status per
+// company.
+func CompareSummaries(old, new Summary, t DriftTolerance) []Drift {
+	type count struct {
+		metric   string
+		old, new int
+	}
+	var drifts []Drift
+	for _, c := range []count{
+		{"files", old.Files, new.Files},
+		{"lines", old.Lines, new.Lines},
+		{"companies", old.Companies, new.Companies},
+		{"persons", old.Persons, new.Persons},
+		{"custom", old.Custom, new.Custom},
+	} {
+		if d, ok := countDrift(c.metric, c.old, c.new, t); ok {
+			drifts = append(drifts, d)
+		}
+	}
+	rates := []count{
+		{"errors", old.Errors, new.Errors},
+		{"warnings", old.Warnings, new.Warnings},
+		{"truncated", old.Truncated, new.Truncated},
+	}
+	for _, k := range unionKeys(old.Repaired, new.Repaired) {
+		rates = append(rates, count{"repaired " + string(k), old.Repaired[k], new.Repaired[k]})
+	}
+	for _, r := range rates {
+		if d, ok := rateDrift(r.metric, r.old, old.Lines, r.new, new.Lines, t); ok {
+			drifts = append(drifts, d)
+		}
+	}
+	for _, k := range unionKeys(old.Statuses, new.Statuses) {
+		if d, ok := rateDrift("status "+string(k), old.Statuses[k], old.Companies, new.Statuses[k], new.Companies, t); ok {
+			drifts = append(drifts, d)
+		}
+	}
+	return drifts
+}
+
+// countDrift reports whether a count changed by more than t.Count.
+func countDrift(metric string, old, new int, t DriftTolerance) (Drift, bool) {
+	d := Drift{Metric: metric, Old: float64(old), New: float64(new)}
+	if old == 0 {
+		return d, new != 0
+	}
+	return d, math.Abs(d.New-d.Old)/d.Old > t.Count
+}
+
+// rateDrift reports whether the rate of x in n changed by more than t.Rate
+// with a z statistic of at least t.Z.
+func rateDrift(metric string, oldX, oldN, newX, newN int, t DriftTolerance) (Drift, bool) {
+	if oldN == 0 || newN == 0 {
+		return Drift{}, false
+	}
+	d := Drift{Metric: metric, Old: float64(oldX) / float64(oldN), New: float64(newX) / float64(newN)}
+	if math.Abs(d.New-d.Old) <= t.Rate {
+		return d, false
+	}
+	p := float64(oldX+newX) / float64(oldN+newN)
+	se := math.Sqrt(p * (1 - p) * (1/float64(oldN) + 1/float64(newN)))
+	if se == 0 {
+		return d, false
+	}
+	d.Z = (d.New - d.Old) / se
+	return d, math.Abs(d.Z) >= t.Z
+}
+
+// unionKeys returns the keys of a and b in order.
+func unionKeys[K ~string](a, b map[K]int) []K {
+	var keys []K
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func (d Drift) String() string {
+	if d.Z != 0 {
+		return fmt.Sprintf("%s %.2f%% to %.2f%% (z %.1f)", d.Metric, d.Old*100, d.New*100, d.Z)
+	}
+	return fmt.Sprintf("%s %.0f to %.0f", d.Metric, d.Old, d.New)
+}
@@ -0,0 +1,46 @@
+package chapointdat
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_CompareSummaries(t *testing.T) {
+	old := Summary{Files: 1, Lines: 100000, Companies: 20000, Persons: 79998, Errors: 10,
+		Statuses: map[Status]int{"": 19000, StatusD: 1000}}
+	if d := CompareSummaries(old, old, DefaultDriftTolerance); len(d) != 0 {
+		t.Errorf("expected no drift got %v", d)
+	}
+	new := old
+	new.Persons = 60000
+	new.Errors = 5000
+	new.Statuses = map[Status]int{"": 19000, StatusD: 700, StatusL: 300}
+	d := CompareSummaries(old, new, DefaultDriftTolerance)
+	var metrics []string
+	for _, m := range d {
+		metrics = append(metrics, m.Metric)
+	}
+	// the shares of D and L companies each moved by 1.5 points
+	if got := strings.Join(metrics, ","); got != "persons,errors,status D,status L" {
+		t.Errorf("unexpected drift %v", d)
+	}
+	if d[0].String() != "persons 79998 to 60000" || d[1].Z <= 0 || d[2].Z >= 0 {
+		t.Errorf("unexpected drift %v", d)
+	}
+	// the same rates over a small snapshot are not significant
+	small, smallNew := Summary{Lines: 100, Errors: 0}, Summary{Lines: 100, Errors: 2}
+	if d := CompareSummaries(small, smallNew, DefaultDriftTolerance); len(d) != 0 {
+		t.Errorf("expected no drift got %v", d)
+	}
+}
+
+func Test_Summary_Statuses(t *testing.T) {
+	r := NewReader()
+	s, err := r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine, testPersonLine)), "-", func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Statuses) != 1 || s.Statuses[StatusD] != 1 {
+		t.Errorf("unexpected statuses %v", s.Statuses)
+	}
+}
@@ -0,0 +1,94 @@
+package chapointdat
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Writer encodes records in the fixed width snapshot format read by Reader.
+type Writer struct {
+	w       *bufio.Writer
+	records int
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+// WriteHeader writes a header line. An empty Identifier is written as
+// DDDDSNAP.
+func (w *Writer) WriteHeader(h Header) error {
+	id := h.Identifier
+	if id == "" {
+		id = snapshotHeaderIdentifier
+	}
+	_, err := fmt.Fprintf(w.w, "%s%04d%s%s\n", field(id, 8), h.Run, h.ProdDate.Format("20060102"), h.Filler)
+	return err
+}
+
+func (w *Writer) WriteCompany(c Company) error {
+	var b strings.Builder
+	b.WriteString(field(c.CompanyNumber, 8))
+	b.WriteString(companyRecordType)
+	b.WriteString(field(c.CompanyStatus, 1))
+	b.WriteString(field("", 22))
+	b.WriteString(field(c.NumberOfOfficers, 4))
+	// the name length includes the "<" terminator
+	fmt.Fprintf(&b, "%04d", len(c.CompanyName)+1)
+	b.WriteString(c.CompanyName)
+	b.WriteString("<\n")
+	w.records++
+	_, err := w.w.WriteString(b.String())
+	return err
+}
+
+func (w *Writer) WritePerson(p Person) error {
+	var b strings.Builder
+	b.WriteString(field(p.CompanyNumber, 8))
+	b.WriteString(personRecordType)
+	b.WriteString(field(p.AppDateOrigin, 1))
+	b.WriteString(field(p.AppointmentType, 2))
+	b.WriteString(field(p.PersonNumber, 12))
+	b.WriteString(field(p.CorporateIndicator, 1))
+	b.WriteString(field("", 7))
+	b.WriteString(field(p.AppointmentDate, 8))
+	b.WriteString(field(p.ResignationDate, 8))
+	b.WriteString(field(p.Postcode, 8))
+	b.WriteString(field(p.PartialDateOfBirth, 8))
+	b.WriteString(field(p.FullDateOfBirth, 8))
+	var v strings.Builder
+	for _, f := range []string{
+		p.Title, p.Forenames, p.Surname, p.Honours, p.CareOf, p.PoBox, p.AddressLine1, p.AddressLine2,
+		p.PostTown, p.County, p.Country, p.Occupation, p.Nationality, p.ResCountry,
+	} {
+		v.WriteString(f)
+		v.WriteString("<")
+	}
+	fmt.Fprintf(&b, "%04d", v.Len())
+	b.WriteString(v.String())
+	b.WriteString("\n")
+	w.records++
+	_, err := w.w.WriteString(b.String())
+	return err
+}
+
+// WriteFooter writes a trailer with the number of company and person records
+// written.
+func (w *Writer) WriteFooter() error {
+	_, err := fmt.Fprintf(w.w, "%s%08d\n", trailerRecordIdentifier, w.records)
+	return err
+}
+
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+// field pads s with spaces, or truncates it, to n bytes.
+func field(s string, n int) string {
+	if len(s) >= n {
+		return s[:n]
+	}
+	return s + strings.Repeat(" ", n-len(s))
+}
@@ -0,0 +1,77 @@
+package chapointdat
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// EntryFilter chooses the zip entries to read by name with glob patterns, as
+// matched by path.Match against either the full name of an entry or its base
+// name, so that *.dat matches data/Prod195_1.dat.
+type EntryFilter struct {
+	// Include are the patterns of the entries to read, or nil to read every
+	// entry not excluded.
+	Include []string
+	// Exclude are the patterns of the entries not to read, such as
+	// README* or *.txt.
+	Exclude []string
+}
+
+// WithEntryFilter reads only the entries of zips chosen by f, so that README,
+// manifest and other products' files alongside the .dat parts are skipped
+// rather than parsed and rejected. It applies to the entries of zips only, and
+// to the parts checked for WithExpectedParts, not to .dat files given
+// directly. ExtractAll returns an error if a pattern is malformed.
+func WithEntryFilter(f EntryFilter) Opt {
+	return func(r *Reader) {
+		r.entryFilter = &f
+	}
+}
+
+// ParseEntryPatterns splits a comma separated list of glob patterns, as taken
+// by the -include and -exclude flags of the command line.
+func ParseEntryPatterns(s string) []string {
+	var patterns []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// Match reports whether the entry name is chosen by f.
+func (f EntryFilter) Match(name string) bool {
+	if len(f.Include) > 0 && !matchEntry(f.Include, name) {
+		return false
+	}
+	return !matchEntry(f.Exclude, name)
+}
+
+// Validate returns an error for the first malformed pattern of f.
+func (f EntryFilter) Validate() error {
+	for _, p := range append(append([]string(nil), f.Include...), f.Exclude...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid entry pattern %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+func matchEntry(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+		if ok, _ := path.Match(p, path.Base(name)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// readEntry reports whether the zip entry name is to be read.
+func (r *Reader) readEntry(name string) bool {
+	return r.entryFilter == nil || r.entryFilter.Match(name)
+}
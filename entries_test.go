@@ -0,0 +1,75 @@
+package chapointdat
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"testing"
+)
+
+func Test_WithEntryFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Prod195.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, content := range map[string]string{
+		"README.txt":          "Appointments data\n",
+		"data/Prod195_1.dat":  testSnapshot(testCompanyLine),
+		"data/manifest.json":  "{}\n",
+		"other/Prod217_1.dat": "DDDDSNAP\n",
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.WriteString(w, content)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	r := NewReader(WithEntryFilter(EntryFilter{Include: []string{"*.dat"}, Exclude: []string{"other/*"}}), WithExpectedParts(1))
+	s, err := r.ExtractAll([]string{path}, 1, func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Files != 1 || s.Companies != 1 {
+		t.Errorf("unexpected summary %+v", s)
+	}
+
+	r = NewReader(WithEntryFilter(EntryFilter{Exclude: []string{"["}}))
+	if _, err := r.ExtractAll([]string{path}, 1, func(err error) { t.Error(err) }); err == nil {
+		t.Error("expected error for malformed pattern")
+	}
+	// the pattern is reported rather than the parts it would fail to match
+	r = NewReader(WithEntryFilter(EntryFilter{Include: []string{"["}}), WithExpectedParts(1))
+	if _, err := r.ExtractAll([]string{path}, 1, func(err error) { t.Error(err) }); !errors.Is(err, pathpkg.ErrBadPattern) || errors.Is(err, ErrMissingPart) {
+		t.Errorf("expected a malformed pattern error got %v", err)
+	}
+	if err := WalkArchive(filepath.Dir(path), func(ArchiveRun, *Reader) error { return nil }, func(err error) { t.Error(err) }, WithEntryFilter(EntryFilter{Include: []string{"["}})); !errors.Is(err, pathpkg.ErrBadPattern) {
+		t.Errorf("expected WalkArchive to report the malformed pattern got %v", err)
+	}
+}
+
+func Test_EntryFilter_Match(t *testing.T) {
+	f := EntryFilter{Include: ParseEntryPatterns("*.dat, *.DAT"), Exclude: ParseEntryPatterns("README*")}
+	for name, want := range map[string]bool{
+		"Prod195_1.dat":      true,
+		"data/Prod195_1.DAT": true,
+		"README.dat":         false,
+		"manifest.json":      false,
+	} {
+		if got := f.Match(name); got != want {
+			t.Errorf("expected %v for %s got %v", want, name, got)
+		}
+	}
+	if !(EntryFilter{}).Match("anything") {
+		t.Error("expected an empty filter to match")
+	}
+}
@@ -0,0 +1,91 @@
+package chapointdat
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// ProductAppointmentSnapshot is the officer appointments snapshot,
+	// supplied as Prod195 files.
+	ProductAppointmentSnapshot = Product(195)
+	// ProductAppointmentUpdate is the officer appointments update file,
+	// supplied as Prod198 files.
+	ProductAppointmentUpdate = Product(198)
+)
+
+type (
+	// Product is the number of a Companies House bulk data product.
+	Product int
+
+	// Envelope is a record of any bulk data product with the product and run
+	// it was read from, so that one sink can take every product. Payload is
+	// the *Header, *Company, *Person or *Footer of the record, or the value of
+	// a custom record.
+	Envelope struct {
+		Product Product
+		Run     int
+		RunDate time.Time
+		Kind    RecordKind
+		Payload any
+	}
+)
+
+// WithEnvelopeSink calls h, after the handler for its type, with the Envelope
+// of every record.
+func WithEnvelopeSink(h func(e Envelope) error) Opt {
+	return func(r *Reader) {
+		r.sinks = append(r.sinks, func(rec Record) error {
+			if err := h(rec.Envelope()); err != nil {
+				return fmt.Errorf("error processing envelope sink: %w", err)
+			}
+			return nil
+		})
+	}
+}
+
+// Envelope returns rec with the product and run of the header of the file it
+// was read from. Product, Run and RunDate are zero for a record read without
+// a header, such as by ExtractCompanies.
+func (rec Record) Envelope() Envelope {
+	e := Envelope{Kind: rec.Kind}
+	if h := rec.header; h != nil {
+		e.Product, e.Run, e.RunDate = h.Product(), h.Run, h.ProdDate
+	}
+	switch rec.Kind {
+	case RecordKindHeader:
+		e.Payload = rec.Header
+	case RecordKindCompany:
+		e.Payload = rec.Company
+	case RecordKindPerson:
+		e.Payload = rec.Person
+	case RecordKindFooter:
+		e.Payload = rec.Footer
+	case RecordKindCustom:
+		e.Payload = rec.Custom
+	}
+	return e
+}
+
+// Product returns the product of the file h is the header of, from its run
+// type, or 0 if the run type is unknown.
+func (h Header) Product() Product {
+	switch h.RunType {
+	case "SNAP":
+		return ProductAppointmentSnapshot
+	case "UPDT":
+		return ProductAppointmentUpdate
+	}
+	return 0
+}
+
+func (p Product) String() string {
+	switch p {
+	case ProductAppointmentSnapshot:
+		return "Officer appointments snapshot"
+	case ProductAppointmentUpdate:
+		return "Officer appointments update"
+	default:
+		return fmt.Sprintf("Product %d", int(p))
+	}
+}
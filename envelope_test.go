@@ -0,0 +1,32 @@
+package chapointdat
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_EnvelopeSink(t *testing.T) {
+	var envelopes []Envelope
+	r := NewReader(WithEnvelopeSink(func(e Envelope) error {
+		envelopes = append(envelopes, e)
+		return nil
+	}))
+	if _, err := r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine, testPersonLine)), "-", func(err error) { t.Error(err) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(envelopes) != 4 {
+		t.Fatalf("expected an envelope for each record got %v", envelopes)
+	}
+	for _, e := range envelopes {
+		if e.Product != ProductAppointmentSnapshot || e.Run != 195 || !e.RunDate.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("unexpected envelope %+v", e)
+		}
+	}
+	if p, ok := envelopes[2].Payload.(*Person); !ok || envelopes[2].Kind != RecordKindPerson || p.Surname != "KJAERSGAARD" {
+		t.Errorf("unexpected person envelope %+v", envelopes[2])
+	}
+	if e := (Record{Kind: RecordKindCompany, Company: &Company{}}).Envelope(); e.Product != 0 || e.Payload == nil {
+		t.Errorf("unexpected envelope without a header %+v", e)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	ch "github.com/richardjennings/chapointdat"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
@@ -39,6 +41,12 @@ func main() {
 		),
 	}
 	r := ch.NewReader(opts...)
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		r.Stop()
+	}()
 	errH := func(err error) {
 		log.Println(err)
 	}
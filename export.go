@@ -0,0 +1,278 @@
+package chapointdat
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// QuoteMinimal quotes CSV values only when they contain the delimiter, a
+	// quote or a line break, or start with a space, as encoding/csv does.
+	QuoteMinimal = QuoteMode(iota)
+	// QuoteAll quotes every CSV value.
+	QuoteAll
+	// QuoteNone never quotes CSV values, instead replacing any delimiter, quote
+	// or line break in a value with a space, for loaders which do not
+	// understand quoting.
+	QuoteNone
+)
+
+type (
+	QuoteMode int
+
+	// ExportEscaping controls how values are written by WithCSVExport and
+	// WithJSONLExport.
+	ExportEscaping struct {
+		// Delimiter separates CSV values, ',' by default.
+		Delimiter rune
+		Quote     QuoteMode
+		// ReplaceTerminator replaces any "<" in a company or person value with
+		// TerminatorReplacement, in both CSV and JSON lines. "<" terminates
+		// variable length fields in a snapshot, so one in a parsed value means
+		// the line it came from was not well formed.
+		ReplaceTerminator     bool
+		TerminatorReplacement string
+		// Blanks sets how blank values are written.
+		Blanks Blanks
+	}
+
+	jsonlExporter struct {
+		mu  sync.Mutex
+		w   *bufio.Writer
+		e   *json.Encoder
+		esc *ExportEscaping
+		buf bytes.Buffer
+		// blanks encodes records to buf, for replacing blank values.
+		blanks *json.Encoder
+	}
+	csvExporter struct {
+		mu                    sync.Mutex
+		companies, persons    *bufio.Writer
+		companyHdr, personHdr bool
+		esc                   *ExportEscaping
+		row                   []byte
+	}
+)
+
+// WithExportEscaping sets the delimiter, quoting and replacement of "<" used
+// by WithCSVExport and WithJSONLExport.
+func WithExportEscaping(e ExportEscaping) Opt {
+	return func(r *Reader) {
+		r.escaping = e
+	}
+}
+
+// WithJSONLExport writes every record to w as a line of JSON.
+func WithJSONLExport(w io.Writer) Opt {
+	return func(r *Reader) {
+		bw := bufio.NewWriter(w)
+		e := &jsonlExporter{w: bw, e: json.NewEncoder(bw), esc: &r.escaping}
+		e.e.SetEscapeHTML(false)
+		e.blanks = json.NewEncoder(&e.buf)
+		e.blanks.SetEscapeHTML(false)
+		r.sinks = append(r.sinks, e.write)
+		r.flushers = append(r.flushers, e.flush)
+	}
+}
+
+// WithCSVExport writes companies and persons as CSV to separate writers, each
+// starting with a row of field names. Either writer may be nil to skip that
+// record type. Headers and footers are not written.
+func WithCSVExport(companies, persons io.Writer) Opt {
+	return func(r *Reader) {
+		e := &csvExporter{esc: &r.escaping}
+		if companies != nil {
+			e.companies = bufio.NewWriter(companies)
+		}
+		if persons != nil {
+			e.persons = bufio.NewWriter(persons)
+		}
+		r.sinks = append(r.sinks, e.write)
+		r.flushers = append(r.flushers, e.flush)
+	}
+}
+
+func (e *jsonlExporter) write(rec Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.esc.ReplaceTerminator {
+		switch rec.Kind {
+		case RecordKindCompany:
+			c := *rec.Company
+			replaceTerminators(&c, e.esc.TerminatorReplacement)
+			rec.Company = &c
+		case RecordKindPerson:
+			p := *rec.Person
+			replaceTerminators(&p, e.esc.TerminatorReplacement)
+			rec.Person = &p
+		}
+	}
+	if e.esc.Blanks.Mode == BlankEmpty {
+		return e.e.Encode(rec)
+	}
+	e.buf.Reset()
+	if err := e.blanks.Encode(rec); err != nil {
+		return err
+	}
+	line, err := e.esc.Blanks.json(e.buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(line)
+	return err
+}
+
+func (e *jsonlExporter) flush() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.w.Flush()
+}
+
+func (e *csvExporter) write(rec Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch {
+	case rec.Kind == RecordKindCompany && e.companies != nil:
+		return e.writeRow(e.companies, &e.companyHdr, *rec.Company)
+	case rec.Kind == RecordKindPerson && e.persons != nil:
+		return e.writeRow(e.persons, &e.personHdr, *rec.Person)
+	}
+	return nil
+}
+
+func (e *csvExporter) flush() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, w := range []*bufio.Writer{e.companies, e.persons} {
+		if w == nil {
+			continue
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *csvExporter) writeRow(w *bufio.Writer, hdr *bool, v any) error {
+	delim := e.esc.Delimiter
+	if delim == 0 {
+		delim = ','
+	}
+	if delim == '"' || delim == '\r' || delim == '\n' || !utf8.ValidRune(delim) || delim == utf8.RuneError {
+		return fmt.Errorf("invalid CSV delimiter %q", delim)
+	}
+	if !*hdr {
+		*hdr = true
+		e.row = appendCSVRow(e.row[:0], fieldNames(v), nil, delim, e.esc.Quote)
+		if _, err := w.Write(e.row); err != nil {
+			return err
+		}
+	}
+	values := fieldValues(v)
+	if e.esc.ReplaceTerminator {
+		for i := range values {
+			values[i] = strings.ReplaceAll(values[i], "<", e.esc.TerminatorReplacement)
+		}
+	}
+	var nulls []bool
+	if e.esc.Blanks.Mode != BlankEmpty {
+		nulls = make([]bool, len(values))
+		for i := range values {
+			values[i], nulls[i] = e.esc.Blanks.text(values[i])
+		}
+	}
+	e.row = appendCSVRow(e.row[:0], values, nulls, delim, e.esc.Quote)
+	_, err := w.Write(e.row)
+	return err
+}
+
+// appendCSVRow appends values to b as a line of CSV. Values which are null,
+// if nulls is not nil, are written unquoted and empty.
+func appendCSVRow(b []byte, values []string, nulls []bool, delim rune, quote QuoteMode) []byte {
+	for i, v := range values {
+		if i > 0 {
+			b = utf8.AppendRune(b, delim)
+		}
+		switch {
+		case nulls != nil && nulls[i]:
+		case quote == QuoteNone:
+			b = append(b, strings.Map(func(r rune) rune {
+				if r == delim || r == '"' || r == '\r' || r == '\n' {
+					return ' '
+				}
+				return r
+			}, v)...)
+		case quote == QuoteAll || csvNeedsQuotes(v, delim):
+			b = append(b, '"')
+			b = append(b, strings.ReplaceAll(v, `"`, `""`)...)
+			b = append(b, '"')
+		default:
+			b = append(b, v...)
+		}
+	}
+	return append(b, '\n')
+}
+
+// csvNeedsQuotes matches the rules of encoding/csv.
+func csvNeedsQuotes(v string, delim rune) bool {
+	if v == "" {
+		return false
+	}
+	if v == `\.` || strings.ContainsRune(v, delim) || strings.ContainsAny(v, "\"\r\n") {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(v)
+	return unicode.IsSpace(r)
+}
+
+// replaceTerminators replaces "<" in the string fields of the Company or
+// Person v points to.
+func replaceTerminators(v any, replacement string) {
+	rv := reflect.ValueOf(v).Elem()
+	for i := range rv.NumField() {
+		if f := rv.Field(i); f.Kind() == reflect.String {
+			f.SetString(strings.ReplaceAll(f.String(), "<", replacement))
+		}
+	}
+}
+
+// fieldNames returns the names of the Schema fields of a Company or Person, in
+// declaration order.
+func fieldNames(v any) []string {
+	t := reflect.TypeOf(v)
+	var names []string
+	for i := range t.NumField() {
+		if schemaField(t.Field(i)) {
+			names = append(names, t.Field(i).Name)
+		}
+	}
+	return names
+}
+
+// fieldValues returns the values of the Schema fields of a Company or Person,
+// in the same order as fieldNames.
+func fieldValues(v any) []string {
+	rv := reflect.ValueOf(v)
+	var values []string
+	for i := range rv.NumField() {
+		if schemaField(rv.Type().Field(i)) {
+			values = append(values, rv.Field(i).String())
+		}
+	}
+	return values
+}
+
+// schemaField reports whether f is a field of the Schema, which are the string
+// fields read from the snapshot.
+func schemaField(f reflect.StructField) bool {
+	return f.Type.Kind() == reflect.String && f.Tag.Get("schema") != "-"
+}
@@ -0,0 +1,93 @@
+package chapointdat
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func Test_ExtractReader_JSONLExport(t *testing.T) {
+	var out bytes.Buffer
+	r := NewReader(WithJSONLExport(&out))
+	s, err := r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine)), "-", func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Companies != 1 {
+		t.Errorf("unexpected summary %+v", s)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines got %q", out.String())
+	}
+	expected := `{"Seq":2,"Kind":"company","Company":{"CompanyNumber":"00000084","CompanyStatus":"D","NumberOfOfficers":"0000","CompanyName":"A. WEST & PARTNERS"}}`
+	if lines[1] != expected {
+		t.Errorf("expected %s got %s", expected, lines[1])
+	}
+}
+
+func Test_CSVExport(t *testing.T) {
+	var companies, persons bytes.Buffer
+	r := NewReader(WithCSVExport(&companies, &persons))
+	if _, err := r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine, testPersonLine)), "-", func(err error) { t.Error(err) }); err != nil {
+		t.Fatal(err)
+	}
+	expected := "CompanyNumber,CompanyStatus,NumberOfOfficers,CompanyName\n00000084,D,0000,A. WEST & PARTNERS\n"
+	if companies.String() != expected {
+		t.Errorf("expected %q got %q", expected, companies.String())
+	}
+	rows := strings.Split(strings.TrimSpace(persons.String()), "\n")
+	if len(rows) != 2 || !strings.HasPrefix(rows[0], "CompanyNumber,AppDateOrigin,") || !strings.Contains(rows[1], "KJAERSGAARD") {
+		t.Errorf("unexpected persons csv %q", persons.String())
+	}
+}
+
+func Test_CSVExport_Escaping(t *testing.T) {
+	c := Company{CompanyNumber: "00000084", CompanyStatus: "D", NumberOfOfficers: "0000", CompanyName: `SMITH, "JONES" & CO<LTD`}
+	for _, tc := range []struct {
+		esc      ExportEscaping
+		expected string
+	}{
+		{ExportEscaping{}, `00000084,D,0000,"SMITH, ""JONES"" & CO<LTD"`},
+		{ExportEscaping{Delimiter: ';'}, `00000084;D;0000;"SMITH, ""JONES"" & CO<LTD"`},
+		{ExportEscaping{Delimiter: '\t', Quote: QuoteAll}, "\"00000084\"\t\"D\"\t\"0000\"\t\"SMITH, \"\"JONES\"\" & CO<LTD\""},
+		{ExportEscaping{Quote: QuoteNone, ReplaceTerminator: true, TerminatorReplacement: " "}, `00000084,D,0000,SMITH   JONES  & CO LTD`},
+	} {
+		var out bytes.Buffer
+		e := &csvExporter{companies: bufio.NewWriter(&out), esc: &tc.esc}
+		if err := e.write(Record{Kind: RecordKindCompany, Company: &c}); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.flush(); err != nil {
+			t.Fatal(err)
+		}
+		rows := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		if len(rows) != 2 || rows[1] != tc.expected {
+			t.Errorf("expected %s got %q", tc.expected, out.String())
+		}
+	}
+	e := &csvExporter{companies: bufio.NewWriter(io.Discard), esc: &ExportEscaping{Delimiter: '"'}}
+	if err := e.write(Record{Kind: RecordKindCompany, Company: &c}); err == nil {
+		t.Error("expected an error for a quote delimiter")
+	}
+}
+
+func Test_JSONLExport_ReplaceTerminator(t *testing.T) {
+	var out bytes.Buffer
+	c := Company{CompanyNumber: "00000084", CompanyName: "A<B"}
+	r := NewReader(WithJSONLExport(&out), WithExportEscaping(ExportEscaping{ReplaceTerminator: true}))
+	if err := r.sinks[0](Record{Kind: RecordKindCompany, Company: &c}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.flushers[0](); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `"CompanyName":"AB"`) {
+		t.Errorf("expected the terminator to be removed got %s", out.String())
+	}
+	if c.CompanyName != "A<B" {
+		t.Error("expected the record not to be modified")
+	}
+}
@@ -0,0 +1,135 @@
+package chapointdat
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// WithMapHandler calls h, after the handler for its type, with the Fields of
+// every header, company, person and footer, for consumers which cannot use
+// the structs, such as generic ETL and templating.
+func WithMapHandler(h func(kind RecordKind, fields map[string]string) error) Opt {
+	return func(r *Reader) {
+		r.sinks = append(r.sinks, func(rec Record) error {
+			return mapHandler(rec, rec.Fields(), h)
+		})
+	}
+}
+
+// WithTypedMapHandler calls h as WithMapHandler, but with the TypedFields of
+// each record.
+func WithTypedMapHandler(h func(kind RecordKind, fields map[string]any) error) Opt {
+	return func(r *Reader) {
+		r.sinks = append(r.sinks, func(rec Record) error {
+			return mapHandler(rec, rec.TypedFields(), h)
+		})
+	}
+}
+
+func mapHandler[V any](rec Record, fields map[string]V, h func(kind RecordKind, fields map[string]V) error) error {
+	if fields == nil {
+		return nil
+	}
+	if err := h(rec.Kind, fields); err != nil {
+		return fmt.Errorf("error processing map handler: %w", err)
+	}
+	return nil
+}
+
+// Fields returns the fields of rec keyed by their Go field names, which are
+// also the CSV headers and JSON keys of exports, with values as in the
+// snapshot: integers in decimal and dates as CCYYMMDD. Derived keys such as
+// Phonetic are left out. It returns nil for a custom record.
+func (rec Record) Fields() map[string]string {
+	v := rec.value()
+	if !v.IsValid() {
+		return nil
+	}
+	fields := make(map[string]string, v.NumField())
+	for i := range v.NumField() {
+		if v.Field(i).Kind() != reflect.Pointer {
+			fields[v.Type().Field(i).Name] = fieldString(v.Field(i))
+		}
+	}
+	return fields
+}
+
+// fieldString formats a field of a record as in the snapshot, with a flag as
+// true or blank.
+func fieldString(f reflect.Value) string {
+	switch f := f.Interface().(type) {
+	case string:
+		return f
+	case bool:
+		if f {
+			return "true"
+		}
+	case int:
+		return strconv.Itoa(f)
+	case time.Time:
+		if !f.IsZero() {
+			return f.Format("20060102")
+		}
+	}
+	return ""
+}
+
+// TypedFields returns the fields of rec as Fields, but with integers as int
+// and dates as time.Time, as converted by SchemaField.Value, and blank dates
+// and integers as nil.
+func (rec Record) TypedFields() map[string]any {
+	var schema map[string]SchemaField
+	switch rec.Kind {
+	case RecordKindCompany:
+		schema = companySchemaFields
+	case RecordKindPerson:
+		schema = personSchemaFields
+	}
+	v := rec.value()
+	if !v.IsValid() {
+		return nil
+	}
+	fields := make(map[string]any, v.NumField())
+	for i := range v.NumField() {
+		name := v.Type().Field(i).Name
+		if v.Field(i).Kind() == reflect.Pointer {
+			continue
+		}
+		if f, ok := schema[name]; ok {
+			fields[name] = f.Value(v.Field(i).String())
+		} else {
+			fields[name] = v.Field(i).Interface()
+		}
+	}
+	return fields
+}
+
+// value returns the struct rec holds, or the zero Value for a custom record.
+func (rec Record) value() reflect.Value {
+	var p any
+	switch rec.Kind {
+	case RecordKindHeader:
+		p = rec.Header
+	case RecordKindCompany:
+		p = rec.Company
+	case RecordKindPerson:
+		p = rec.Person
+	case RecordKindFooter:
+		p = rec.Footer
+	default:
+		return reflect.Value{}
+	}
+	return reflect.ValueOf(p).Elem()
+}
+
+var companySchemaFields, personSchemaFields = schemaFields(companySchema), schemaFields(personSchema)
+
+func schemaFields(s Schema) map[string]SchemaField {
+	fields := map[string]SchemaField{}
+	for _, f := range s.Fields {
+		fields[f.Name] = f
+	}
+	return fields
+}
@@ -0,0 +1,43 @@
+package chapointdat
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_MapHandler(t *testing.T) {
+	var maps []map[string]string
+	var typed []map[string]any
+	r := NewReader(WithMapHandler(func(kind RecordKind, fields map[string]string) error {
+		maps = append(maps, fields)
+		return nil
+	}), WithTypedMapHandler(func(kind RecordKind, fields map[string]any) error {
+		if kind == RecordKindPerson {
+			typed = append(typed, fields)
+		}
+		return nil
+	}))
+	if _, err := r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine, testPersonLine)), "-", func(err error) { t.Error(err) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(maps) != 4 {
+		t.Fatalf("expected a map for each record got %v", maps)
+	}
+	if h := maps[0]; h["Run"] != "195" || h["ProdDate"] != "20240101" {
+		t.Errorf("unexpected header %v", h)
+	}
+	if c := maps[1]; c["CompanyName"] != "A. WEST & PARTNERS" || c["NumberOfOfficers"] != "0000" {
+		t.Errorf("unexpected company %v", c)
+	}
+	if f := maps[3]; f["RecordCount"] != "2" {
+		t.Errorf("unexpected footer %v", f)
+	}
+	if len(typed) != 1 {
+		t.Fatalf("unexpected typed maps %v", typed)
+	}
+	p := typed[0]
+	if p["AppointmentDate"] != time.Date(1991, 9, 15, 0, 0, 0, 0, time.UTC) || p["ResignationDate"] != nil || p["Surname"] != "KJAERSGAARD" {
+		t.Errorf("unexpected person %v", p)
+	}
+}
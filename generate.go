@@ -0,0 +1,74 @@
+package chapointdat
+
+import (
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// generatedWords and the like are the values GenerateSnapshot draws names and
+// towns from.
+var (
+	generatedWords = []string{"ACME", "HOLDINGS", "NORTHERN", "BRIDGE", "TRADING", "GREEN", "CONSULTING",
+		"SERVICES", "PROPERTY", "DEVELOPMENTS", "ASSOCIATES", "WEST", "PARTNERS", "TECHNOLOGY", "GROUP"}
+	generatedForenames = []string{"JOHN", "MARY", "DAVID", "SARAH", "JAMES ROBERT", "ELIZABETH", "HANS", "PRIYA"}
+	generatedSurnames  = []string{"SMITH", "JONES", "WILLIAMS", "TAYLOR", "BROWN", "KJAERSGAARD", "PATEL", "O'NEILL"}
+	generatedTowns     = []string{"LONDON", "MANCHESTER", "CARDIFF", "EDINBURGH", "BELFAST", "MONMOUTH", "LEEDS"}
+)
+
+// GenerateSnapshot writes a snapshot of the given number of companies with
+// between 0 and 6 officers each, with names, dates and appointments drawn at
+// random but always the same for the same seed, for benchmarks and tests
+// which need realistic input of any size.
+func GenerateSnapshot(w io.Writer, companies int, seed uint64) error {
+	rnd := rand.New(rand.NewPCG(seed, seed))
+	pick := func(s []string) string { return s[rnd.IntN(len(s))] }
+	enc := NewWriter(w)
+	if err := enc.WriteHeader(Header{Run: 195, ProdDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}); err != nil {
+		return err
+	}
+	for i := range companies {
+		number := fmt.Sprintf("%08d", i+1)
+		officers := rnd.IntN(7)
+		words := make([]string, 1+rnd.IntN(4))
+		for j := range words {
+			words[j] = pick(generatedWords)
+		}
+		if err := enc.WriteCompany(Company{
+			CompanyNumber:    number,
+			CompanyStatus:    []string{"", "", "", "L", "R"}[rnd.IntN(5)],
+			NumberOfOfficers: fmt.Sprintf("%04d", officers),
+			CompanyName:      strings.Join(words, " ") + " LIMITED",
+		}); err != nil {
+			return err
+		}
+		for range officers {
+			if err := enc.WritePerson(Person{
+				CompanyNumber:      number,
+				AppDateOrigin:      "1",
+				AppointmentType:    []string{"00", "01", "01", "04"}[rnd.IntN(4)],
+				PersonNumber:       fmt.Sprintf("%012d", rnd.IntN(999999999999)),
+				AppointmentDate:    fmt.Sprintf("%04d%02d%02d", 1990+rnd.IntN(34), 1+rnd.IntN(12), 1+rnd.IntN(28)),
+				Postcode:           "NP25 3DZ",
+				PartialDateOfBirth: fmt.Sprintf("%04d%02d", 1940+rnd.IntN(60), 1+rnd.IntN(12)),
+				Title:              "MR",
+				Forenames:          pick(generatedForenames),
+				Surname:            pick(generatedSurnames),
+				AddressLine1:       fmt.Sprintf("%d HIGH STREET", 1+rnd.IntN(200)),
+				PostTown:           pick(generatedTowns),
+				Country:            "ENGLAND",
+				Occupation:         "DIRECTOR",
+				Nationality:        "BRITISH",
+				ResCountry:         "ENGLAND",
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	if err := enc.WriteFooter(); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
@@ -0,0 +1,243 @@
+package chapointdat
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"reflect"
+	"slices"
+	"sync"
+)
+
+// historyMagic starts a file written by History.WriteTo.
+const (
+	historyMagic   = "CHAPHIST"
+	historyVersion = 1
+)
+
+type (
+	// DatasetDelta is the companies and appointments which changed from one
+	// run to the next: those added or changed in full, and the keys of those
+	// removed.
+	DatasetDelta struct {
+		Headers             []Header
+		Companies           []Company
+		RemovedCompanies    []string
+		Appointments        []Person
+		RemovedAppointments []AppointmentKey
+	}
+
+	// History stores successive runs as the Dataset of the first run and the
+	// DatasetDelta of each run after, so that years of snapshots take little
+	// more space than one, and reconstructs the Dataset of any run.
+	History struct {
+		mu     sync.Mutex
+		runs   []int
+		base   *Dataset
+		deltas []DatasetDelta
+		// latest is the Dataset of the last run, which the next is compared
+		// with.
+		latest *Dataset
+	}
+
+	// historyHeader is the start of a file written by History.WriteTo, which
+	// is followed by the Dataset of the first run and then each delta.
+	historyHeader struct {
+		Version int
+		Runs    []int
+	}
+)
+
+// Delta returns the changes from old to new, in company number and
+// AppointmentKey order.
+func Delta(old, new *Dataset) DatasetDelta {
+	dd := DatasetDelta{Headers: slices.Clone(new.Headers)}
+	for _, n := range slices.Sorted(maps.Keys(new.Companies)) {
+		if c, ok := old.Companies[n]; !ok || !reflect.DeepEqual(c, new.Companies[n]) {
+			dd.Companies = append(dd.Companies, new.Companies[n])
+		}
+	}
+	for _, n := range slices.Sorted(maps.Keys(old.Companies)) {
+		if _, ok := new.Companies[n]; !ok {
+			dd.RemovedCompanies = append(dd.RemovedCompanies, n)
+		}
+	}
+	for _, k := range new.Keys() {
+		if p, ok := old.Appointments[k]; !ok || !reflect.DeepEqual(p, new.Appointments[k]) {
+			dd.Appointments = append(dd.Appointments, new.Appointments[k])
+		}
+	}
+	for _, k := range old.Keys() {
+		if _, ok := new.Appointments[k]; !ok {
+			dd.RemovedAppointments = append(dd.RemovedAppointments, k)
+		}
+	}
+	return dd
+}
+
+// Apply changes d from the run dd was taken from to the run after.
+func (dd DatasetDelta) Apply(d *Dataset) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.Headers = slices.Clone(dd.Headers)
+	for _, n := range dd.RemovedCompanies {
+		delete(d.Companies, n)
+	}
+	for _, c := range dd.Companies {
+		d.Companies[c.CompanyNumber] = c
+	}
+	for _, k := range dd.RemovedAppointments {
+		delete(d.Appointments, k)
+	}
+	for _, p := range dd.Appointments {
+		d.Appointments[p.Key()] = p
+	}
+}
+
+func NewHistory() *History {
+	return &History{}
+}
+
+// Add stores d as the run of its first header, which must be later than the
+// runs already added. d must not be changed afterwards.
+func (h *History) Add(d *Dataset) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(d.Headers) == 0 {
+		return errors.New("error adding dataset: no header")
+	}
+	run := d.Headers[0].Run
+	if len(h.runs) > 0 && run <= h.runs[len(h.runs)-1] {
+		return fmt.Errorf("error adding dataset: run %d is not after run %d", run, h.runs[len(h.runs)-1])
+	}
+	if h.base == nil {
+		h.base = d.clone()
+	} else {
+		h.deltas = append(h.deltas, Delta(h.latest, d))
+	}
+	h.runs = append(h.runs, run)
+	h.latest = d
+	return nil
+}
+
+// Runs returns the runs stored, in order.
+func (h *History) Runs() []int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return slices.Clone(h.runs)
+}
+
+// Dataset returns a new Dataset of run, from the first run and the deltas up
+// to run.
+func (h *History) Dataset(run int) (*Dataset, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	i, ok := slices.BinarySearch(h.runs, run)
+	if !ok {
+		return nil, fmt.Errorf("error reconstructing run %d: not in history", run)
+	}
+	d := h.base.clone()
+	for _, dd := range h.deltas[:i] {
+		dd.Apply(d)
+	}
+	return d, nil
+}
+
+// Delta returns the changes from the run before run to run.
+func (h *History) Delta(run int) (DatasetDelta, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	i, ok := slices.BinarySearch(h.runs, run)
+	if !ok || i == 0 {
+		return DatasetDelta{}, fmt.Errorf("error reading delta of run %d: not in history", run)
+	}
+	return h.deltas[i-1], nil
+}
+
+// BuildHistory returns the History of the runs of the snapshots in dir, found
+// as WalkArchive, holding the Dataset of only one run at a time.
+func BuildHistory(dir string, errH func(err error), opts ...Opt) (*History, error) {
+	h := NewHistory()
+	d := NewDataset()
+	err := walkRuns(dir, func(rec Record) error { return d.Write(rec) }, func() error {
+		err := h.Add(d)
+		d = NewDataset()
+		return err
+	}, errH, opts)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// WriteTo writes h to w in a binary format read by ReadHistory.
+func (h *History) WriteTo(w io.Writer) (int64, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	bw := bufio.NewWriter(w)
+	cw := &countingWriter{w: bw}
+	if _, err := io.WriteString(cw, historyMagic); err != nil {
+		return cw.n, err
+	}
+	enc := gob.NewEncoder(cw)
+	if err := enc.Encode(historyHeader{Version: historyVersion, Runs: h.runs}); err != nil {
+		return cw.n, fmt.Errorf("error writing history: %w", err)
+	}
+	if h.base != nil {
+		if err := h.base.encode(enc); err != nil {
+			return cw.n, fmt.Errorf("error writing history: %w", err)
+		}
+	}
+	for _, dd := range h.deltas {
+		if err := enc.Encode(dd); err != nil {
+			return cw.n, fmt.Errorf("error writing history: %w", err)
+		}
+	}
+	return cw.n, bw.Flush()
+}
+
+// ReadHistory reads a History written by History.WriteTo. Further runs may be
+// added to it.
+func ReadHistory(rd io.Reader) (*History, error) {
+	br := bufio.NewReader(rd)
+	magic := make([]byte, len(historyMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != historyMagic {
+		return nil, errors.New("error reading history: not a history file")
+	}
+	dec := gob.NewDecoder(br)
+	var hh historyHeader
+	if err := dec.Decode(&hh); err != nil {
+		return nil, fmt.Errorf("error reading history: %w", err)
+	}
+	if hh.Version != historyVersion {
+		return nil, fmt.Errorf("error reading history: unsupported version %d", hh.Version)
+	}
+	h := &History{runs: hh.Runs}
+	if len(h.runs) == 0 {
+		return h, nil
+	}
+	base, err := decodeDataset(dec)
+	if err != nil {
+		return nil, fmt.Errorf("error reading history: %w", err)
+	}
+	h.base, h.latest = base, base.clone()
+	for range h.runs[1:] {
+		var dd DatasetDelta
+		if err := dec.Decode(&dd); err != nil {
+			return nil, fmt.Errorf("error reading history delta: %w", err)
+		}
+		dd.Apply(h.latest)
+		h.deltas = append(h.deltas, dd)
+	}
+	return h, nil
+}
+
+// clone returns a copy of d which may be changed without changing d.
+func (d *Dataset) clone() *Dataset {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return &Dataset{Headers: slices.Clone(d.Headers), Companies: maps.Clone(d.Companies), Appointments: maps.Clone(d.Appointments)}
+}
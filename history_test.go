@@ -0,0 +1,98 @@
+package chapointdat
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_History(t *testing.T) {
+	dir := t.TempDir()
+	other := strings.Replace(testCompanyLine, "00000084", "00000085", 1)
+	moved := strings.Replace(testPersonLine, "NP25 3DZ", "CF14 3UZ", 1)
+	runs := map[string][]string{
+		"Prod195_0001.dat": {testCompanyLine, testPersonLine, other},
+		"Prod195_0002.dat": {testCompanyLine, moved, other},
+		"Prod195_0003.dat": {testCompanyLine, moved},
+	}
+	want := map[int]*Dataset{}
+	for name, lines := range runs {
+		run := strings.TrimSuffix(strings.TrimPrefix(name, "Prod195_"), ".dat")
+		content := strings.Replace(testSnapshot(lines...), "0195", run, 1)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		d := NewDataset()
+		if _, err := NewReader(WithRecordHandler(d.Write)).ExtractReader(strings.NewReader(content), name, func(err error) { t.Error(err) }); err != nil {
+			t.Fatal(err)
+		}
+		want[d.Headers[0].Run] = d
+	}
+
+	h, err := BuildHistory(dir, func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := h.Runs(); !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Fatalf("unexpected runs %v", got)
+	}
+	dd, err := h.Delta(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dd.Companies) != 0 || len(dd.RemovedCompanies) != 0 || len(dd.Appointments) != 1 || len(dd.RemovedAppointments) != 0 || dd.Appointments[0].Postcode != "CF14 3UZ" {
+		t.Errorf("unexpected delta %+v", dd)
+	}
+	if dd, _ := h.Delta(3); !reflect.DeepEqual(dd.RemovedCompanies, []string{"00000085"}) || len(dd.Appointments) != 0 {
+		t.Errorf("unexpected delta %+v", dd)
+	}
+	if _, err := h.Delta(1); err == nil {
+		t.Error("expected an error for the delta of the first run")
+	}
+
+	var b bytes.Buffer
+	if _, err := h.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := ReadHistory(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range []*History{h, reloaded} {
+		for run, w := range want {
+			d, err := h.Dataset(run)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(d.Companies, w.Companies) || !reflect.DeepEqual(d.Appointments, w.Appointments) || d.Headers[0].Run != run {
+				t.Errorf("run %d: expected %+v got %+v", run, w, d)
+			}
+		}
+	}
+	if _, err := h.Dataset(4); err == nil {
+		t.Error("expected an error for a run not in the history")
+	}
+
+	// runs may be added to a reloaded history
+	if err := reloaded.Add(want[2]); err == nil {
+		t.Error("expected an error adding an earlier run")
+	}
+	next := want[3].clone()
+	next.Headers[0].Run = 4
+	delete(next.Companies, "00000084")
+	if err := reloaded.Add(next); err != nil {
+		t.Fatal(err)
+	}
+	if dd, _ := reloaded.Delta(4); !reflect.DeepEqual(dd.RemovedCompanies, []string{"00000084"}) || len(dd.Appointments) != 0 {
+		t.Errorf("unexpected delta %+v", dd)
+	}
+	if err := NewHistory().Add(NewDataset()); err == nil {
+		t.Error("expected an error adding a dataset without a header")
+	}
+	if _, err := ReadHistory(bytes.NewReader(b.Bytes()[:b.Len()-10])); err == nil {
+		t.Error("expected an error for a truncated history")
+	}
+}
@@ -0,0 +1,286 @@
+package chapointdat
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrNotIndexed is wrapped by the error passed to the error handler by
+// ExtractCompanies for a company number which is not in the index.
+var ErrNotIndexed = errors.New("company not in index")
+
+type (
+	// IndexEntry locates the company record for a company number within the
+	// decompressed bytes of a zip entry or .dat file.
+	IndexEntry struct {
+		Entry, CompanyNumber string
+		Offset               int64
+	}
+	// Index maps company numbers to the location of their company record.
+	Index map[string]IndexEntry
+
+	indexWriter struct {
+		mu    sync.Mutex
+		w     *bufio.Writer
+		entry string
+	}
+)
+
+// WithIndex writes an index of company number to byte offset to w while
+// extracting, for later use with ReadIndex and ExtractCompanies. The index is
+// a line "@<entry>" whenever the entry changes followed by a line
+// "<company number> <offset>" for each company.
+func WithIndex(w io.Writer) Opt {
+	return func(r *Reader) {
+		r.index = &indexWriter{w: bufio.NewWriter(w)}
+		r.flushers = append(r.flushers, r.index.flush)
+	}
+}
+
+func (w *indexWriter) write(entry, companyNumber string, offset int64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if entry != w.entry {
+		w.entry = entry
+		if _, err := fmt.Fprintf(w.w, "@%s\n", entry); err != nil {
+			return fmt.Errorf("error writing index: %w", err)
+		}
+	}
+	if _, err := fmt.Fprintf(w.w, "%s %d\n", companyNumber, offset); err != nil {
+		return fmt.Errorf("error writing index: %w", err)
+	}
+	return nil
+}
+
+func (w *indexWriter) flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Flush()
+}
+
+// ReadIndex reads an index written by WithIndex.
+func ReadIndex(rd io.Reader) (Index, error) {
+	index := Index{}
+	var entry string
+	scan := bufio.NewScanner(rd)
+	for n := 1; scan.Scan(); n++ {
+		line := scan.Text()
+		if strings.HasPrefix(line, "@") {
+			entry = line[1:]
+			continue
+		}
+		number, offset, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("error reading index line %d: %s", n, line)
+		}
+		o, err := strconv.ParseInt(offset, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error reading index line %d: %w", n, err)
+		}
+		index[number] = IndexEntry{Entry: entry, CompanyNumber: number, Offset: o}
+	}
+	return index, scan.Err()
+}
+
+// ExtractCompanies uses index to process only the company records, and the
+// person records following them, for companyNumbers from path. Uncompressed
+// .dat files and stored zip entries are read from each offset directly;
+// compressed zip entries are decompressed but not parsed up to each offset.
+// Company numbers missing from index are passed to errH, wrapped in
+// ErrNotIndexed, and counted in Summary.NotIndexed.
+func (r *Reader) ExtractCompanies(path string, index Index, companyNumbers []string, errH func(err error)) (Summary, error) {
+	if err := r.start(errH); err != nil {
+		return Summary{}, err
+	}
+	s, err := r.extractCompanies(path, index, companyNumbers, errH)
+	return s, errors.Join(err, r.finish(&s))
+}
+
+func (r *Reader) extractCompanies(path string, index Index, companyNumbers []string, errH func(err error)) (Summary, error) {
+	var summary Summary
+	if err := r.rawInput("ExtractCompanies"); err != nil {
+		return summary, err
+	}
+	offsets := map[string][]int64{}
+	for _, n := range companyNumbers {
+		e, ok := index[n]
+		if !ok {
+			summary.NotIndexed++
+			errH(fmt.Errorf("%w: %s", ErrNotIndexed, n))
+			continue
+		}
+		offsets[e.Entry] = append(offsets[e.Entry], e.Offset)
+	}
+	for _, o := range offsets {
+		sort.Slice(o, func(i, j int) bool { return o[i] < o[j] })
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return summary, err
+	}
+	defer func() { _ = f.Close() }()
+	fi, err := f.Stat()
+	if err != nil {
+		return summary, err
+	}
+	z, err := zip.NewReader(f, fi.Size())
+	if err != nil {
+		// not a zip, so a single .dat file
+		entry := filepath.Base(path)
+		lr := &lineReader{reset: func(offset int64) (io.Reader, error) {
+			return io.NewSectionReader(f, offset, fi.Size()-offset), nil
+		}}
+		s, err := r.extractOffsets(lr, path, entry, offsets[entry], errH)
+		summary.Merge(s)
+		return summary, err
+	}
+	for _, zf := range z.File {
+		o := offsets[zf.Name]
+		if len(o) == 0 {
+			continue
+		}
+		lr := &lineReader{}
+		if dataOffset, err := zf.DataOffset(); err == nil && zf.Method == zip.Store {
+			size := int64(zf.UncompressedSize64)
+			lr.reset = func(offset int64) (io.Reader, error) {
+				return io.NewSectionReader(f, dataOffset+offset, size-offset), nil
+			}
+		} else {
+			rc, err := zf.Open()
+			if err != nil {
+				return summary, err
+			}
+			lr.br = bufio.NewReader(rc)
+			s, err := r.extractOffsets(lr, path, zf.Name, o, errH)
+			_ = rc.Close()
+			summary.Merge(s)
+			if err != nil {
+				return summary, err
+			}
+			continue
+		}
+		s, err := r.extractOffsets(lr, path, zf.Name, o, errH)
+		summary.Merge(s)
+		if err != nil {
+			return summary, err
+		}
+	}
+	return summary, nil
+}
+
+// lineReader reads lines from a known byte offset, either by resetting to any
+// offset or, when reset is nil, by discarding bytes from br up to a later one.
+type lineReader struct {
+	reset func(offset int64) (io.Reader, error)
+	br    *bufio.Reader
+	// pos is the offset of the next line; peeked holds that line once read.
+	pos    int64
+	peeked []byte
+	err    error
+}
+
+func (l *lineReader) seek(offset int64) error {
+	if l.reset != nil {
+		rd, err := l.reset(offset)
+		if err != nil {
+			return err
+		}
+		l.br, l.pos, l.peeked, l.err = bufio.NewReader(rd), offset, nil, nil
+		return nil
+	}
+	if l.peeked != nil && offset >= l.pos+int64(len(l.peeked)) {
+		l.next()
+	}
+	if l.peeked != nil {
+		if offset != l.pos {
+			return fmt.Errorf("cannot seek to offset %d within line at %d", offset, l.pos)
+		}
+		return nil
+	}
+	if offset < l.pos {
+		return fmt.Errorf("cannot seek back to offset %d from %d", offset, l.pos)
+	}
+	n, err := l.br.Discard(int(offset - l.pos))
+	l.pos += int64(n)
+	return err
+}
+
+// peek returns the line at pos without consuming it.
+func (l *lineReader) peek() ([]byte, error) {
+	if l.peeked == nil && l.err == nil {
+		l.peeked, l.err = l.br.ReadBytes('\n')
+		if len(l.peeked) == 0 {
+			l.peeked = nil
+		}
+	}
+	return l.peeked, l.err
+}
+
+func (l *lineReader) next() {
+	l.pos += int64(len(l.peeked))
+	l.peeked = nil
+}
+
+// extractOffsets processes the block of lines for a company at each of
+// offsets, which must be in ascending order.
+func (r *Reader) extractOffsets(lr *lineReader, path, entry string, offsets []int64, errH func(err error)) (s Summary, err error) {
+	st := &state{i: 1, path: path, entry: entry, summary: Summary{Files: 1}}
+	defer func() {
+		// the summary includes any error from the last company stream
+		r.endStream(st, errH)
+		s = st.summary
+	}()
+	for _, offset := range offsets {
+		if r.stopped.Load() {
+			return st.summary, ErrStopped
+		}
+		if err := lr.seek(offset); err != nil {
+			return st.summary, err
+		}
+		for first := true; ; first = false {
+			line, err := lr.peek()
+			if line == nil || !first && (isRecordType(line, companyRecordType) || bytes.HasPrefix(line, []byte(trailerRecordIdentifier))) {
+				if err != nil && err != io.EOF {
+					return st.summary, err
+				}
+				break
+			}
+			st.offset = lr.pos
+			st.seq = r.seq.Add(1)
+			lr.next()
+			st.summary.Lines++
+			line = bytes.TrimRight(line, "\r\n")
+			if r.cp1252Fallback {
+				line = st.decode(line)
+			}
+			if err := r.handleLine(line, st, errH); err != nil {
+				return st.summary, err
+			}
+		}
+	}
+	return st.summary, nil
+}
+
+// isRecordType reports whether line is of record type t, allowing for the
+// missing leading 0 that line repairs. A line of 8 bytes or fewer is of no
+// type.
+func isRecordType(line []byte, t string) bool {
+	if len(line) <= 8 {
+		return false
+	}
+	if string(line[8]) == t {
+		return true
+	}
+	return line[0] == '0' && line[1] != '0' && string(line[7]) == t &&
+		string(line[8]) != companyRecordType && string(line[8]) != personRecordType
+}
@@ -0,0 +1,112 @@
+package chapointdat
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testIndexedSnapshot() string {
+	var lines []string
+	for _, n := range []string{"00000084", "00000085", "00000086"} {
+		lines = append(lines,
+			strings.Replace(testCompanyLine, "00000084", n, 1),
+			strings.Replace(testPersonLine, "00463819", n, 1),
+			strings.Replace(testPersonLine, "00463819", n, 1),
+		)
+	}
+	return testSnapshot(lines...)
+}
+
+func Test_Index_ExtractCompanies(t *testing.T) {
+	dir := t.TempDir()
+	dat := filepath.Join(dir, "Prod195_1_1.dat")
+	if err := os.WriteFile(dat, []byte(testIndexedSnapshot()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	deflated := writeTestZip(t, "deflated.zip", testIndexedSnapshot())
+	stored := filepath.Join(dir, "stored.zip")
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "part1.dat", Method: zip.Store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = w.Write([]byte(testIndexedSnapshot()))
+	_ = zw.Close()
+	if err := os.WriteFile(stored, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{dat, deflated, stored} {
+		var idx bytes.Buffer
+		if err := NewReader(WithIndex(&idx)).Extract(path, 1, func(err error) { t.Error(err) }); err != nil {
+			t.Fatal(err)
+		}
+		index, err := ReadIndex(&idx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(index) != 3 {
+			t.Fatalf("expected 3 index entries got %v", index)
+		}
+		var persons []string
+		r := NewReader(WithPersonHandler(func(p Person) error {
+			persons = append(persons, p.CompanyNumber)
+			return nil
+		}))
+		s, err := r.ExtractCompanies(path, index, []string{"00000086", "00000084", "00000085"}, func(err error) { t.Error(err) })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Companies != 3 || s.Persons != 6 {
+			t.Errorf("%s: unexpected summary %+v", path, s)
+		}
+		if strings.Join(persons, ",") != "00000084,00000084,00000085,00000085,00000086,00000086" {
+			t.Errorf("%s: unexpected persons %v", path, persons)
+		}
+
+		var errs []error
+		s, err = NewReader().ExtractCompanies(path, index, []string{"00000084", "00000099"}, func(err error) { errs = append(errs, err) })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Companies != 1 || s.NotIndexed != 1 || len(errs) != 1 || !errors.Is(errs[0], ErrNotIndexed) || !strings.Contains(errs[0].Error(), "00000099") {
+			t.Errorf("%s: expected the missing company to be reported got %+v and %v", path, s, errs)
+		}
+	}
+}
+
+func Test_Index_ShortLine(t *testing.T) {
+	if isRecordType([]byte("04638191"), companyRecordType) {
+		t.Error("expected a line of 8 bytes to be of no record type")
+	}
+	path := filepath.Join(t.TempDir(), "Prod195_1_1.dat")
+	// a truncated file ending in a short line with no newline
+	if err := os.WriteFile(path, []byte(testHeaderLine+"\n"+testCompanyLine+"\n04638191"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var idx bytes.Buffer
+	if err := NewReader(WithIndex(&idx)).Extract(path, 1, func(err error) {}); err != nil {
+		t.Fatal(err)
+	}
+	index, err := ReadIndex(&idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errs []error
+	s, err := NewReader().ExtractCompanies(path, index, []string{"00000084"}, func(err error) { errs = append(errs, err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Companies != 1 || len(errs) != 1 {
+		t.Errorf("expected the short line to be rejected got %+v and %v", s, errs)
+	}
+	if _, err := SplitShards([]string{path}, 4); err != nil {
+		t.Error(err)
+	}
+}
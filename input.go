@@ -0,0 +1,75 @@
+package chapointdat
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const gzipMagic = "\x1f\x8b"
+
+// InputHook unwraps the raw bytes of an input, such as by decrypting or
+// decompressing them, returning a reader of the zip or .dat file inside. If
+// the reader returned is an io.Closer it is closed once the input has been
+// read.
+type InputHook func(rd io.Reader) (io.Reader, error)
+
+// WithInputHook applies h to every file and stream read by Extract,
+// ExtractAll, ExtractReader and ExtractReaderAt before its format is
+// detected, so that a snapshot delivered PGP encrypted or in a bespoke
+// container can be read without being unwrapped to disk first. Hooks are
+// applied in the order given. A zip produced by a hook is copied to a
+// temporary file, as for ExtractReader, and offsets in checkpoints, indexes
+// and dead letters are those of the unwrapped input. WithMmap is ignored, and
+// ExtractCompanies, ExtractShard and WithExpectedParts, which read files as
+// they are on disk, return an error.
+func WithInputHook(h InputHook) Opt {
+	return func(r *Reader) {
+		r.inputHooks = append(r.inputHooks, h)
+	}
+}
+
+// GunzipInput is an InputHook which decompresses gzip input, passing any
+// other input through unchanged.
+func GunzipInput(rd io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(rd)
+	if magic, _ := br.Peek(len(gzipMagic)); string(magic) != gzipMagic {
+		return br, nil
+	}
+	return gzip.NewReader(br)
+}
+
+// rawInput returns an error for op, which reads files as they are on disk, if
+// there are input hooks.
+func (r *Reader) rawInput(op string) error {
+	if len(r.inputHooks) > 0 {
+		return fmt.Errorf("%s cannot be used WithInputHook", op)
+	}
+	return nil
+}
+
+// unwrap applies the input hooks to rd, returning the unwrapped reader and a
+// function closing any closers the hooks returned.
+func (r *Reader) unwrap(rd io.Reader, name string) (io.Reader, func() error, error) {
+	var closers []io.Closer
+	closeAll := func() error {
+		var errs []error
+		for i := len(closers) - 1; i >= 0; i-- {
+			errs = append(errs, closers[i].Close())
+		}
+		return errors.Join(errs...)
+	}
+	for _, h := range r.inputHooks {
+		next, err := h(rd)
+		if err != nil {
+			return nil, nil, errors.Join(fmt.Errorf("error unwrapping %s: %w", name, err), closeAll())
+		}
+		if c, ok := next.(io.Closer); ok && next != rd {
+			closers = append(closers, c)
+		}
+		rd = next
+	}
+	return rd, closeAll, nil
+}
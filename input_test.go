@@ -0,0 +1,83 @@
+package chapointdat
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// xorReader is a stand in for decryption.
+type xorReader struct {
+	rd     io.Reader
+	closed bool
+}
+
+func (x *xorReader) Read(p []byte) (int, error) {
+	n, err := x.rd.Read(p)
+	for i := range p[:n] {
+		p[i] ^= 0x55
+	}
+	return n, err
+}
+
+func (x *xorReader) Close() error {
+	x.closed = true
+	return nil
+}
+
+func Test_WithInputHook(t *testing.T) {
+	zipped, err := os.ReadFile(writeTestZip(t, "a.zip", testSnapshot(testCompanyLine, testPersonLine)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = zw.Write(zipped)
+	_ = zw.Close()
+	encrypted := bytes.Clone(gz.Bytes())
+	for i := range encrypted {
+		encrypted[i] ^= 0x55
+	}
+	path := filepath.Join(t.TempDir(), "a.zip.gz.enc")
+	if err := os.WriteFile(path, encrypted, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var x *xorReader
+	var companies int
+	r := NewReader(WithInputHook(func(rd io.Reader) (io.Reader, error) {
+		x = &xorReader{rd: rd}
+		return x, nil
+	}), WithInputHook(GunzipInput), WithCompanyHandler(func(c Company) error {
+		companies++
+		return nil
+	}))
+	s, err := r.ExtractAll([]string{path}, 1, func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Companies != 1 || s.Persons != 1 || companies != 1 || !x.closed {
+		t.Errorf("unexpected summary %+v", s)
+	}
+	if _, err := r.ExtractShard(Shard{Path: path}, func(err error) { t.Error(err) }); err == nil {
+		t.Error("expected error from ExtractShard")
+	}
+
+	// uncompressed input passes through GunzipInput
+	r = NewReader(WithInputHook(GunzipInput))
+	s, err = r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine)), "-", func(err error) { t.Error(err) })
+	if err != nil || s.Companies != 1 {
+		t.Errorf("unexpected summary %+v and error %v", s, err)
+	}
+
+	errHook := errors.New("bad key")
+	r = NewReader(WithInputHook(func(rd io.Reader) (io.Reader, error) { return nil, errHook }))
+	if _, err := r.ExtractReader(strings.NewReader(""), "-", func(err error) { t.Error(err) }); !errors.Is(err, errHook) {
+		t.Errorf("expected %v got %v", errHook, err)
+	}
+}
@@ -0,0 +1,228 @@
+// Command schemagen generates schema_gen.go, describing the fields of Company
+// and Person, from their declarations and spec comments in reader.go and the
+// fixed widths sliced by companyRow and personRow. It is run by go generate.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type field struct {
+	name, description, format string
+	width                     int
+}
+
+var tables = []struct {
+	typ, table, parser string
+}{
+	{"Company", "companies", "companyRow"},
+	{"Person", "persons", "personRow"},
+}
+
+func main() {
+	log.SetFlags(0)
+	out := flag.String("o", "schema_gen.go", "output file")
+	flag.Parse()
+	src := flag.Arg(0)
+	if src == "" {
+		src = "reader.go"
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, src, nil, parser.ParseComments)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by schemagen from %s; DO NOT EDIT.\n\npackage %s\n\n", src, f.Name.Name)
+	for _, t := range tables {
+		fields, err := structFields(f, t.typ)
+		if err != nil {
+			log.Fatal(err)
+		}
+		widths := fieldWidths(f, t.parser)
+		fmt.Fprintf(&b, "var %sSchema = Schema{\n\tName: %q,\n\tFields: []SchemaField{\n", strings.ToLower(t.typ), t.table)
+		for _, fd := range fields {
+			fmt.Fprintf(&b, "\t\t{Name: %q, Column: %q", fd.name, column(fd.name))
+			if w := widths[fd.name]; w > 0 {
+				fmt.Fprintf(&b, ", Width: %d", w)
+			}
+			if fd.format != "" {
+				fmt.Fprintf(&b, ", Format: %s", fd.format)
+			}
+			if fd.description != "" {
+				fmt.Fprintf(&b, ", Description: %q", fd.description)
+			}
+			b.WriteString("},\n")
+		}
+		b.WriteString("\t},\n}\n\n")
+	}
+	src2, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, src2, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// structFields returns the fields of struct typ with the comment preceding
+// each. Fields in reader.go share one declaration, separated by block
+// comments, so comments are matched by position rather than from Doc.
+func structFields(f *ast.File, typ string) ([]field, error) {
+	var st *ast.StructType
+	ast.Inspect(f, func(n ast.Node) bool {
+		if ts, ok := n.(*ast.TypeSpec); ok && ts.Name.Name == typ {
+			st, _ = ts.Type.(*ast.StructType)
+		}
+		return st == nil
+	})
+	if st == nil {
+		return nil, fmt.Errorf("struct %s not found", typ)
+	}
+	var fields []field
+	prev := st.Fields.Opening
+	for _, fl := range st.Fields.List {
+		// fields derived while reading, rather than read from the snapshot
+		if fl.Tag != nil && strings.Contains(fl.Tag.Value, `schema:"-"`) {
+			continue
+		}
+		for _, name := range fl.Names {
+			var comment string
+			for _, cg := range f.Comments {
+				if cg.Pos() > prev && cg.End() < name.Pos() {
+					comment = cg.Text()
+				}
+			}
+			prev = name.End()
+			fields = append(fields, field{
+				name:        name.Name,
+				description: description(comment),
+				format:      fieldFormat(spaces.ReplaceAllString(comment, " ")),
+			})
+		}
+	}
+	return fields, nil
+}
+
+var (
+	spaces = regexp.MustCompile(`\s+`)
+	// listItem matches the start of an item in a list of values, such as
+	// "2  Appointment date taken from..." or "current director   (01)".
+	listItem = regexp.MustCompile(`^(\d|“|\*\*|Space\b)|\(\d\d\)$`)
+)
+
+// description joins the wrapped lines of a spec comment, keeping each item of
+// a list of values on a line of its own.
+func description(comment string) string {
+	var lines []string
+	indent := -1
+	for _, l := range strings.Split(comment, "\n") {
+		trimmed := strings.TrimSpace(l)
+		if trimmed == "" {
+			continue
+		}
+		n := len(l) - len(strings.TrimLeft(l, " \t"))
+		if indent < 0 {
+			indent = n
+		}
+		trimmed = spaces.ReplaceAllString(trimmed, " ")
+		last := len(lines) - 1
+		continuation := n > indent || !listItem.MatchString(trimmed) && last >= 0 && !listItem.MatchString(lines[last])
+		if last >= 0 && continuation {
+			lines[last] += " " + trimmed
+			continue
+		}
+		lines = append(lines, trimmed)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// fieldFormat returns the FieldFormat constant for the format named in a spec
+// comment, if any.
+func fieldFormat(comment string) string {
+	switch {
+	case strings.Contains(comment, "CCYYMMDD"):
+		return "FieldFormatDate"
+	case strings.Contains(comment, "CCYYMM"):
+		return "FieldFormatYearMonth"
+	case strings.Contains(comment, "digit integer"):
+		return "FieldFormatInteger"
+	}
+	return ""
+}
+
+// fieldWidths returns the widths of fields assigned from fixed slices of the
+// line, such as p.CompanyNumber = strings.TrimSpace(l[0:8]), in function fn.
+func fieldWidths(f *ast.File, fn string) map[string]int {
+	widths := map[string]int{}
+	for _, d := range f.Decls {
+		fd, ok := d.(*ast.FuncDecl)
+		if !ok || fd.Name.Name != fn {
+			continue
+		}
+		ast.Inspect(fd.Body, func(n ast.Node) bool {
+			as, ok := n.(*ast.AssignStmt)
+			if !ok || len(as.Lhs) != 1 || len(as.Rhs) != 1 {
+				return true
+			}
+			sel, ok := as.Lhs[0].(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			call, ok := as.Rhs[0].(*ast.CallExpr)
+			if !ok || len(call.Args) != 1 {
+				return true
+			}
+			slice, ok := call.Args[0].(*ast.SliceExpr)
+			if !ok {
+				return true
+			}
+			lo, hi := intLit(slice.Low), intLit(slice.High)
+			if lo >= 0 && hi > lo {
+				widths[sel.Sel.Name] = hi - lo
+			}
+			return true
+		})
+	}
+	return widths
+}
+
+func intLit(e ast.Expr) int {
+	lit, ok := e.(*ast.BasicLit)
+	if !ok || lit.Kind != token.INT {
+		return -1
+	}
+	i, err := strconv.Atoi(lit.Value)
+	if err != nil {
+		return -1
+	}
+	return i
+}
+
+// column converts a field name such as CompanyNumber to company_number.
+func column(name string) string {
+	var b strings.Builder
+	for i, c := range name {
+		if unicode.IsUpper(c) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			c = unicode.ToLower(c)
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
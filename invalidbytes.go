@@ -0,0 +1,129 @@
+package chapointdat
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// InvalidBytesKeep passes bytes which are not valid UTF-8 through
+	// unchanged, which is the default.
+	InvalidBytesKeep = InvalidBytesPolicy(iota)
+	// InvalidBytesReject rejects a record with an *InvalidUTF8Error.
+	InvalidBytesReject
+	// InvalidBytesReplacementChar replaces each invalid byte with U+FFFD.
+	InvalidBytesReplacementChar
+	// InvalidBytesQuestionMark replaces each invalid byte with '?'.
+	InvalidBytesQuestionMark
+	// InvalidBytesDrop removes invalid bytes.
+	InvalidBytesDrop
+)
+
+// ErrInvalidUTF8 matches, with errors.Is, an InvalidUTF8Error.
+var ErrInvalidUTF8 = errors.New("invalid UTF-8")
+
+type (
+	InvalidBytesPolicy int
+
+	// InvalidUTF8Error is returned for a record rejected by
+	// InvalidBytesReject.
+	InvalidUTF8Error struct {
+		Field string
+		// Position is the byte offset of the first invalid byte in the value
+		// of Field.
+		Position int
+		Value    string
+	}
+)
+
+// WithInvalidBytes sets the policy for bytes in the values of headers,
+// companies and persons which are not valid UTF-8, so that systems requiring
+// valid UTF-8, such as Postgres text columns, never receive them. Values are
+// parsed before bytes are replaced, so fixed width fields are unaffected by
+// the change of length. Replaced and dropped bytes are counted in
+// Summary.InvalidBytes.
+func WithInvalidBytes(p InvalidBytesPolicy) Opt {
+	return func(r *Reader) {
+		r.invalidBytes = p
+	}
+}
+
+// ParseInvalidBytesPolicy returns the policy named keep, reject, replace,
+// question-mark or drop.
+func ParseInvalidBytesPolicy(s string) (InvalidBytesPolicy, error) {
+	for p, name := range invalidBytesPolicyNames {
+		if s == name {
+			return InvalidBytesPolicy(p), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown invalid bytes policy: %s", s)
+}
+
+var invalidBytesPolicyNames = []string{"keep", "reject", "replace", "question-mark", "drop"}
+
+func (p InvalidBytesPolicy) String() string {
+	if int(p) < len(invalidBytesPolicyNames) {
+		return invalidBytesPolicyNames[p]
+	}
+	return "unknown"
+}
+
+func (e *InvalidUTF8Error) Error() string {
+	return fmt.Sprintf("invalid UTF-8 at byte %d of %s: %q", e.Position, e.Field, e.Value)
+}
+
+func (e *InvalidUTF8Error) Is(target error) bool {
+	return target == ErrInvalidUTF8
+}
+
+// fixInvalidBytes applies the invalid bytes policy to the values of rec,
+// returning the number of bytes replaced or dropped.
+func (r *Reader) fixInvalidBytes(rec Record) (int, error) {
+	var v reflect.Value
+	switch rec.Kind {
+	case RecordKindHeader:
+		v = reflect.ValueOf(rec.Header).Elem()
+	case RecordKindCompany:
+		v = reflect.ValueOf(rec.Company).Elem()
+	case RecordKindPerson:
+		v = reflect.ValueOf(rec.Person).Elem()
+	default:
+		return 0, nil
+	}
+	replacement := map[InvalidBytesPolicy]string{InvalidBytesReplacementChar: "�", InvalidBytesQuestionMark: "?"}[r.invalidBytes]
+	fixed := 0
+	for i := range v.NumField() {
+		f := v.Field(i)
+		if f.Kind() != reflect.String || utf8.ValidString(f.String()) {
+			continue
+		}
+		s := f.String()
+		if r.invalidBytes == InvalidBytesReject {
+			pos := 0
+			for pos < len(s) {
+				c, n := utf8.DecodeRuneInString(s[pos:])
+				if c == utf8.RuneError && n == 1 {
+					break
+				}
+				pos += n
+			}
+			return 0, &InvalidUTF8Error{Field: v.Type().Field(i).Name, Position: pos, Value: s}
+		}
+		var b strings.Builder
+		for pos := 0; pos < len(s); {
+			c, n := utf8.DecodeRuneInString(s[pos:])
+			if c == utf8.RuneError && n == 1 {
+				b.WriteString(replacement)
+				fixed++
+			} else {
+				b.WriteString(s[pos : pos+n])
+			}
+			pos += n
+		}
+		f.SetString(b.String())
+	}
+	return fixed, nil
+}
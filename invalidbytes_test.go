@@ -0,0 +1,64 @@
+package chapointdat
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func Test_InvalidBytes(t *testing.T) {
+	// a Latin-1 é in the name, which is not valid UTF-8
+	name := strings.Replace(testCompanyLine, "A. WEST & PARTNERS", "A. W\xe9ST & PARTNERS", 1)
+	for policy, expected := range map[InvalidBytesPolicy]string{
+		InvalidBytesKeep:            "A. W\xe9ST & PARTNERS",
+		InvalidBytesReplacementChar: "A. W�ST & PARTNERS",
+		InvalidBytesQuestionMark:    "A. W?ST & PARTNERS",
+		InvalidBytesDrop:            "A. WST & PARTNERS",
+	} {
+		var got string
+		r := NewReader(WithInvalidBytes(policy), WithCompanyHandler(func(c Company) error {
+			got = c.CompanyName
+			return nil
+		}))
+		s, err := r.ExtractReader(strings.NewReader(testSnapshot(name)), "-", func(err error) { t.Error(err) })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != expected {
+			t.Errorf("%s: expected %q got %q", policy, expected, got)
+		}
+		if n := map[bool]int{true: 0, false: 1}[policy == InvalidBytesKeep]; s.InvalidBytes != n {
+			t.Errorf("%s: expected %d invalid bytes got %d", policy, n, s.InvalidBytes)
+		}
+	}
+}
+
+func Test_InvalidBytesReject(t *testing.T) {
+	name := strings.Replace(testCompanyLine, "A. WEST & PARTNERS", "A. W\xe9ST & PARTNERS", 1)
+	var errs []error
+	r := NewReader(WithInvalidBytes(InvalidBytesReject), WithCompanyHandler(func(c Company) error {
+		t.Errorf("unexpected company %+v", c)
+		return nil
+	}))
+	if _, err := r.ExtractReader(strings.NewReader(testSnapshot(name)), "-", func(err error) { errs = append(errs, err) }); err != nil {
+		t.Fatal(err)
+	}
+	var e *InvalidUTF8Error
+	if len(errs) == 0 || !errors.As(errs[0], &e) || !errors.Is(errs[0], ErrInvalidUTF8) {
+		t.Fatalf("expected an InvalidUTF8Error got %v", errs)
+	}
+	if e.Field != "CompanyName" || e.Position != 4 {
+		t.Errorf("unexpected error %+v", e)
+	}
+}
+
+func Test_ParseInvalidBytesPolicy(t *testing.T) {
+	for _, p := range []InvalidBytesPolicy{InvalidBytesKeep, InvalidBytesReject, InvalidBytesReplacementChar, InvalidBytesQuestionMark, InvalidBytesDrop} {
+		if got, err := ParseInvalidBytesPolicy(p.String()); err != nil || got != p {
+			t.Errorf("expected %s got %s %v", p, got, err)
+		}
+	}
+	if _, err := ParseInvalidBytesPolicy("ignore"); err == nil {
+		t.Error("expected an error")
+	}
+}
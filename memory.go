@@ -0,0 +1,110 @@
+package chapointdat
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"runtime/debug"
+	"sync"
+)
+
+const (
+	// recordMemory is the memory allowed for each record held in a queue.
+	recordMemory = 2 << 10
+	// fileMemory is the memory allowed for each file or zip entry read at
+	// once, for its decompressor, line buffer and the queues of
+	// WithOrderedDelivery.
+	fileMemory = 4 << 20
+)
+
+// ErrMemoryBudget is returned when an option holds every record in memory, so
+// cannot be used WithMemoryBudget.
+var ErrMemoryBudget = errors.New("option holds every record in memory")
+
+// memoryLimits tracks the budgets of the Readers extracting at once, the
+// runtime memory limit being the lowest of them.
+var memoryLimits struct {
+	sync.Mutex
+	budgets  map[*Reader]int64
+	previous int64
+}
+
+// WithMemoryBudget bounds the memory used by the Reader to about bytes, such as
+// 200 << 20 in a 256MB container, so that extraction slows down rather than
+// running out of memory. While extracting, the soft memory limit of the Go
+// runtime is lowered to bytes, so that garbage is collected more often as it
+// is approached, and restored afterwards. Each internal queue, including that
+// of WithChannelSink, holds at most a sixteenth of the budget of records, and
+// ExtractAll reads no more files and zip entries at once than a quarter of the
+// budget allows. Extraction fails with ErrMemoryBudget WithSortedCSVExport.
+// Memory held by handlers and sinks, and by the sets passed to
+// WithDisappearedCompanyHandler, is not counted.
+func WithMemoryBudget(bytes int64) Opt {
+	return func(r *Reader) {
+		r.memoryBudget = max(bytes, 0)
+	}
+}
+
+// startBudget checks the options against the memory budget and lowers the
+// runtime memory limit to it.
+func (r *Reader) startBudget() error {
+	if r.memoryBudget == 0 {
+		return nil
+	}
+	if r.holdsRecords != "" {
+		return fmt.Errorf("%w: %s", ErrMemoryBudget, r.holdsRecords)
+	}
+	memoryLimits.Lock()
+	defer memoryLimits.Unlock()
+	if memoryLimits.budgets == nil {
+		memoryLimits.budgets = map[*Reader]int64{}
+	}
+	if len(memoryLimits.budgets) == 0 {
+		memoryLimits.previous = debug.SetMemoryLimit(-1)
+	}
+	memoryLimits.budgets[r] = r.memoryBudget
+	setMemoryLimit()
+	return nil
+}
+
+// finishBudget restores the runtime memory limit once no Reader with a budget
+// is extracting.
+func (r *Reader) finishBudget() {
+	if r.memoryBudget == 0 {
+		return
+	}
+	memoryLimits.Lock()
+	defer memoryLimits.Unlock()
+	delete(memoryLimits.budgets, r)
+	if len(memoryLimits.budgets) == 0 {
+		debug.SetMemoryLimit(memoryLimits.previous)
+		return
+	}
+	setMemoryLimit()
+}
+
+func setMemoryLimit() {
+	limit := int64(math.MaxInt64)
+	for _, b := range memoryLimits.budgets {
+		limit = min(limit, b)
+	}
+	debug.SetMemoryLimit(min(limit, memoryLimits.previous))
+}
+
+// budgetQueue returns capacity, reduced to fit the memory budget.
+func (r *Reader) budgetQueue(capacity int) int {
+	if r.memoryBudget == 0 {
+		return capacity
+	}
+	return min(capacity, max(int(r.memoryBudget/16/recordMemory), 1))
+}
+
+// budgetConcurrency returns the number of files or zip entries to read at
+// once, up to concurrency.
+func (r *Reader) budgetConcurrency(concurrency int) int {
+	concurrency = max(concurrency, 1)
+	if r.memoryBudget == 0 {
+		return concurrency
+	}
+	return min(concurrency, max(int(r.memoryBudget/4/fileMemory), 1))
+}
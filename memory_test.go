@@ -0,0 +1,46 @@
+package chapointdat
+
+import (
+	"errors"
+	"io"
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+func Test_WithMemoryBudget(t *testing.T) {
+	previous := debug.SetMemoryLimit(-1)
+	var limit int64
+	r := NewReader(WithMemoryBudget(64<<20), WithQueueSize(100000), WithCompanyHandler(func(c Company) error {
+		limit = debug.SetMemoryLimit(-1)
+		return nil
+	}))
+	if _, err := r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine)), "-", func(err error) { t.Error(err) }); err != nil {
+		t.Fatal(err)
+	}
+	if limit != 64<<20 {
+		t.Errorf("expected a memory limit of the budget while extracting got %d", limit)
+	}
+	if l := debug.SetMemoryLimit(-1); l != previous {
+		t.Errorf("expected the memory limit %d to be restored got %d", previous, l)
+	}
+	if c := r.queueCapacity(1); c != 64<<20/16/recordMemory {
+		t.Errorf("expected the queue size to fit the budget got %d", c)
+	}
+	if c := r.budgetConcurrency(8); c != 4 {
+		t.Errorf("expected 4 files at once got %d", c)
+	}
+	if c := NewReader(WithMemoryBudget(1)).budgetConcurrency(8); c != 1 {
+		t.Errorf("expected 1 file at once got %d", c)
+	}
+	if c := NewReader().budgetConcurrency(8); c != 8 {
+		t.Errorf("expected 8 files at once without a budget got %d", c)
+	}
+}
+
+func Test_WithMemoryBudget_Sorted(t *testing.T) {
+	r := NewReader(WithMemoryBudget(64<<20), WithSortedCSVExport(io.Discard, io.Discard, nil))
+	if _, err := r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine)), "-", func(err error) {}); !errors.Is(err, ErrMemoryBudget) {
+		t.Errorf("expected ErrMemoryBudget got %v", err)
+	}
+}
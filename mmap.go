@@ -0,0 +1,74 @@
+package chapointdat
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// WithMmap reads uncompressed .dat files by memory mapping them rather than
+// copying them through a buffer, leaving the OS page cache to drive
+// throughput. Zip files, stdin and platforms without mmap are read as usual.
+func WithMmap() Opt {
+	return func(r *Reader) {
+		r.mmap = true
+	}
+}
+
+func (r *Reader) extractMmap(f *os.File, path string, errH func(err error)) (Summary, error) {
+	data, unmap, err := mmapFile(f)
+	if errors.Is(err, errors.ErrUnsupported) {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return Summary{}, err
+		}
+		return r.extractEntry(f, path, filepath.Base(path), errH)
+	}
+	if err != nil {
+		return Summary{}, err
+	}
+	defer func() { _ = unmap() }()
+	st, skip, done := r.entryState(path, filepath.Base(path))
+	if done {
+		return st.summary, nil
+	}
+	return r.extractLines(&bytesScanner{data: data}, st, path, skip, errH)
+}
+
+// bytesScanner scans lines from data in place, in the same way as newScanner.
+type bytesScanner struct {
+	data   []byte
+	pos    int
+	offset int64
+	line   []byte
+}
+
+func (s *bytesScanner) Scan() bool {
+	if s.pos >= len(s.data) {
+		return false
+	}
+	s.offset = int64(s.pos)
+	end := bytes.IndexByte(s.data[s.pos:], '\n')
+	if end < 0 {
+		s.line = s.data[s.pos:]
+		s.pos = len(s.data)
+	} else {
+		s.line = s.data[s.pos : s.pos+end]
+		s.pos += end + 1
+	}
+	s.line = bytes.TrimSuffix(s.line, []byte("\r"))
+	return true
+}
+
+func (s *bytesScanner) Bytes() []byte {
+	return s.line
+}
+
+func (s *bytesScanner) Offset() int64 {
+	return s.offset
+}
+
+func (s *bytesScanner) Err() error {
+	return nil
+}
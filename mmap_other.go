@@ -0,0 +1,12 @@
+//go:build !unix
+
+package chapointdat
+
+import (
+	"errors"
+	"os"
+)
+
+func mmapFile(f *os.File) ([]byte, func() error, error) {
+	return nil, nil, errors.ErrUnsupported
+}
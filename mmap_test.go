@@ -0,0 +1,70 @@
+package chapointdat
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestDat writes a snapshot of n companies each with two officers.
+func writeTestDat(tb testing.TB, n int) string {
+	tb.Helper()
+	lines := make([]string, 0, n*3)
+	for i := range n {
+		number := fmt.Sprintf("%08d", i+1)
+		lines = append(lines,
+			strings.Replace(testCompanyLine, "00000084", number, 1),
+			strings.Replace(testPersonLine, "00463819", number, 1),
+			strings.Replace(testPersonLine, "00463819", number, 1),
+		)
+	}
+	path := filepath.Join(tb.TempDir(), "Prod195_1_1.dat")
+	if err := os.WriteFile(path, []byte(testSnapshot(lines...)), 0o644); err != nil {
+		tb.Fatal(err)
+	}
+	return path
+}
+
+func Test_Mmap_MatchesScanner(t *testing.T) {
+	path := writeTestDat(t, 100)
+	var idx1, idx2 strings.Builder
+	s1, err := NewReader(WithIndex(&idx1)).ExtractAll([]string{path}, 1, func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, err := NewReader(WithMmap(), WithIndex(&idx2)).ExtractAll([]string{path}, 1, func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s1.Companies != 100 || s1.Persons != 200 || s1.Lines != s2.Lines || s1.Companies != s2.Companies || s1.Persons != s2.Persons {
+		t.Errorf("summaries differ %+v %+v", s1, s2)
+	}
+	if idx1.String() != idx2.String() {
+		t.Error("indexes differ")
+	}
+}
+
+func benchmarkExtract(b *testing.B, opts ...Opt) {
+	path := writeTestDat(b, 10000)
+	fi, err := os.Stat(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(fi.Size())
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := NewReader(opts...).Extract(path, 1, func(err error) { b.Error(err) }); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkExtract_Scanner(b *testing.B) {
+	benchmarkExtract(b)
+}
+
+func BenchmarkExtract_Mmap(b *testing.B) {
+	benchmarkExtract(b, WithMmap())
+}
@@ -0,0 +1,299 @@
+package chapointdat
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// nameIndexMagic starts a file written by NameIndex.WriteTo, followed by its
+// format version.
+const (
+	nameIndexMagic   = "CHAPNAME"
+	nameIndexVersion = 1
+)
+
+type (
+	// NameRef is a company, or an appointment of an officer, with a name in a
+	// NameIndex. PersonNumber is blank for a company.
+	NameRef struct {
+		Kind          RecordKind
+		CompanyNumber string
+		PersonNumber  string `json:",omitempty"`
+	}
+
+	// NameMatch is a name found by NameIndex.Search with the companies and
+	// appointments which have it. Score is between 0 and 1, where 1 is a
+	// name with the same trigrams as the query.
+	NameMatch struct {
+		Name  string
+		Score float64
+		Refs  []NameRef
+	}
+
+	// NameIndex is a trigram index of the names of companies and officers,
+	// for answering name queries without a search engine. Pass its Write
+	// method to WithRecordHandler to build it while extracting, save it with
+	// WriteTo and load it with ReadNameIndex. Each distinct name is held once
+	// with the trigrams of its CompanyNameTokens, so that punctuation and case
+	// are ignored and a name matches despite a few letters differing.
+	NameIndex struct {
+		mu    sync.Mutex
+		names []string
+		refs  [][]NameRef
+		ids   map[string]uint32
+		// postings are the ids of the names with each trigram, in order.
+		postings map[string][]uint32
+	}
+)
+
+func NewNameIndex() *NameIndex {
+	return &NameIndex{ids: map[string]uint32{}, postings: map[string][]uint32{}}
+}
+
+// Write adds the names of companies and persons to x. Other records are
+// ignored.
+func (x *NameIndex) Write(rec Record) error {
+	switch rec.Kind {
+	case RecordKindCompany:
+		x.add(rec.Company.CompanyName, NameRef{Kind: RecordKindCompany, CompanyNumber: rec.Company.CompanyNumber})
+	case RecordKindPerson:
+		x.add(strings.TrimSpace(rec.Person.Forenames+" "+rec.Person.Surname),
+			NameRef{Kind: RecordKindPerson, CompanyNumber: rec.Person.CompanyNumber, PersonNumber: rec.Person.PersonNumber})
+	}
+	return nil
+}
+
+func (x *NameIndex) add(name string, ref NameRef) {
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" {
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	id, ok := x.ids[name]
+	if !ok {
+		id = uint32(len(x.names))
+		x.ids[name] = id
+		x.names = append(x.names, name)
+		x.refs = append(x.refs, nil)
+		for _, t := range nameTrigrams(name) {
+			x.postings[t] = append(x.postings[t], id)
+		}
+	}
+	x.refs[id] = append(x.refs[id], ref)
+}
+
+// Len returns the number of distinct names in x.
+func (x *NameIndex) Len() int {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return len(x.names)
+}
+
+// Search returns up to limit names sharing at least half of the trigrams of
+// query, best first, scored by the Dice coefficient of their trigrams. Names
+// with the same score are in order.
+func (x *NameIndex) Search(query string, limit int) []NameMatch {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	qt := nameTrigrams(query)
+	if len(qt) == 0 || limit <= 0 {
+		return nil
+	}
+	shared := map[uint32]int{}
+	for _, t := range qt {
+		for _, id := range x.postings[t] {
+			shared[id]++
+		}
+	}
+	var matches []NameMatch
+	for id, n := range shared {
+		if 2*n < len(qt) {
+			continue
+		}
+		name := x.names[id]
+		score := 2 * float64(n) / float64(len(qt)+len(nameTrigrams(name)))
+		matches = append(matches, NameMatch{Name: name, Score: score, Refs: x.refs[id]})
+	}
+	slices.SortFunc(matches, func(a, b NameMatch) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), cmp.Compare(a.Name, b.Name))
+	})
+	return matches[:min(limit, len(matches))]
+}
+
+// nameTrigrams returns the distinct trigrams of the CompanyNameTokens of name,
+// each token padded with a space either side so that short words and the
+// starts and ends of words count.
+func nameTrigrams(name string) []string {
+	seen := map[string]struct{}{}
+	var trigrams []string
+	for _, tok := range CompanyNameTokens(name) {
+		r := []rune(" " + tok + " ")
+		for i := 0; i+3 <= len(r); i++ {
+			t := string(r[i : i+3])
+			if _, ok := seen[t]; !ok {
+				seen[t] = struct{}{}
+				trigrams = append(trigrams, t)
+			}
+		}
+	}
+	return trigrams
+}
+
+// WriteTo writes x to w in a compact binary format read by ReadNameIndex: the
+// names with their refs, and then each trigram with the delta encoded ids of
+// its names, in order, so the same index is always written the same way.
+func (x *NameIndex) WriteTo(w io.Writer) (int64, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	bw := bufio.NewWriter(w)
+	cw := &countingWriter{w: bw}
+	e := &nameIndexEncoder{w: cw}
+	_, _ = io.WriteString(cw, nameIndexMagic)
+	e.uvarint(nameIndexVersion)
+	e.uvarint(uint64(len(x.names)))
+	for id, name := range x.names {
+		e.string(name)
+		e.uvarint(uint64(len(x.refs[id])))
+		for _, ref := range x.refs[id] {
+			e.uvarint(uint64(ref.Kind))
+			e.string(ref.CompanyNumber)
+			e.string(ref.PersonNumber)
+		}
+	}
+	e.uvarint(uint64(len(x.postings)))
+	for _, t := range slices.Sorted(maps.Keys(x.postings)) {
+		e.string(t)
+		ids := x.postings[t]
+		e.uvarint(uint64(len(ids)))
+		prev := uint32(0)
+		for _, id := range ids {
+			e.uvarint(uint64(id - prev))
+			prev = id
+		}
+	}
+	if e.err != nil {
+		return cw.n, fmt.Errorf("error writing name index: %w", e.err)
+	}
+	return cw.n, bw.Flush()
+}
+
+// ReadNameIndex reads a NameIndex written by NameIndex.WriteTo.
+func ReadNameIndex(rd io.Reader) (*NameIndex, error) {
+	br := bufio.NewReader(rd)
+	magic := make([]byte, len(nameIndexMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != nameIndexMagic {
+		return nil, errors.New("error reading name index: not a name index file")
+	}
+	d := &nameIndexDecoder{r: br}
+	if v := d.uvarint(); d.err == nil && v != nameIndexVersion {
+		return nil, fmt.Errorf("error reading name index: unsupported version %d", v)
+	}
+	x := NewNameIndex()
+	n := d.uvarint()
+	for id := uint64(0); id < n && d.err == nil; id++ {
+		name := d.string()
+		// refs are appended rather than allocated up front, as a name may
+		// have any number of them
+		var refs []NameRef
+		for i, nrefs := uint64(0), d.uvarint(); i < nrefs && d.err == nil; i++ {
+			refs = append(refs, NameRef{Kind: RecordKind(d.uvarint()), CompanyNumber: d.string(), PersonNumber: d.string()})
+		}
+		x.ids[name] = uint32(id)
+		x.names = append(x.names, name)
+		x.refs = append(x.refs, refs)
+	}
+	n = d.uvarint()
+	for range n {
+		if d.err != nil {
+			break
+		}
+		t := d.string()
+		ids := make([]uint32, d.count(len(x.names)))
+		prev := uint64(0)
+		for i := range ids {
+			delta := d.uvarint()
+			if i > 0 && delta == 0 {
+				d.err = fmt.Errorf("repeated name id %d for %q", prev, t)
+			}
+			if prev += delta; prev >= uint64(len(x.names)) && d.err == nil {
+				d.err = fmt.Errorf("name id %d for %q is not less than the %d names", prev, t, len(x.names))
+			}
+			ids[i] = uint32(prev)
+		}
+		x.postings[t] = ids
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("error reading name index: %w", d.err)
+	}
+	return x, nil
+}
+
+// nameIndexEncoder writes varints and length prefixed strings, keeping the
+// first error.
+type nameIndexEncoder struct {
+	w   io.Writer
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func (e *nameIndexEncoder) uvarint(v uint64) {
+	if e.err == nil {
+		_, e.err = e.w.Write(binary.AppendUvarint(e.buf[:0], v))
+	}
+}
+
+func (e *nameIndexEncoder) string(s string) {
+	e.uvarint(uint64(len(s)))
+	if e.err == nil {
+		_, e.err = io.WriteString(e.w, s)
+	}
+}
+
+// nameIndexDecoder reads what nameIndexEncoder writes, keeping the first
+// error and returning zero values after it.
+type nameIndexDecoder struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (d *nameIndexDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	var v uint64
+	v, d.err = binary.ReadUvarint(d.r)
+	return v
+}
+
+// count reads the length of a list of name ids, which is at most names.
+func (d *nameIndexDecoder) count(names int) uint64 {
+	n := d.uvarint()
+	if n > uint64(names) {
+		d.err = fmt.Errorf("list of %d name ids is longer than the %d names", n, names)
+		return 0
+	}
+	return n
+}
+
+func (d *nameIndexDecoder) string() string {
+	n := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	if n > 1<<20 {
+		d.err = fmt.Errorf("string of %d bytes is too long", n)
+		return ""
+	}
+	b := make([]byte, n)
+	_, d.err = io.ReadFull(d.r, b)
+	return string(b)
+}
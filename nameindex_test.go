@@ -0,0 +1,70 @@
+package chapointdat
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_NameIndex(t *testing.T) {
+	x := NewNameIndex()
+	second := strings.Replace(testCompanyLine, "00000084", "00000085", 1)
+	r := NewReader(WithRecordHandler(x.Write))
+	if _, err := r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine, testPersonLine, second)), "-", func(err error) { t.Error(err) }); err != nil {
+		t.Fatal(err)
+	}
+	_ = x.Write(Record{Kind: RecordKindCompany, Company: &Company{CompanyNumber: "00000086", CompanyName: "WESTERN PARTS LIMITED"}})
+	if x.Len() != 3 {
+		t.Fatalf("expected 3 names got %d", x.Len())
+	}
+
+	var buf bytes.Buffer
+	if _, err := x.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadNameIndex(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range []*NameIndex{x, read} {
+		// misspelt and without punctuation
+		m := x.Search("west and partnrs", 10)
+		if len(m) == 0 || m[0].Name != "A. WEST & PARTNERS" || m[0].Score >= 1 {
+			t.Fatalf("unexpected matches %+v", m)
+		}
+		want := []NameRef{{Kind: RecordKindCompany, CompanyNumber: "00000084"}, {Kind: RecordKindCompany, CompanyNumber: "00000085"}}
+		if !reflect.DeepEqual(m[0].Refs, want) {
+			t.Errorf("expected %v got %v", want, m[0].Refs)
+		}
+		if m := x.Search("zzzz", 10); len(m) != 0 {
+			t.Errorf("expected no matches got %+v", m)
+		}
+	}
+	if m := x.Search("a west & partners", 1); len(m) != 1 || m[0].Score != 1 {
+		t.Errorf("expected an exact match got %+v", m)
+	}
+	if _, err := ReadNameIndex(strings.NewReader("CHAPNAME\x01\x05")); err == nil {
+		t.Error("expected error for truncated index")
+	}
+}
+
+func Test_ReadNameIndex_Corrupt(t *testing.T) {
+	x := NewNameIndex()
+	_ = x.Write(Record{Kind: RecordKindCompany, Company: &Company{CompanyNumber: "00000084", CompanyName: "WEST"}})
+	var buf bytes.Buffer
+	if _, err := x.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	// the last byte is the id of the name with the last trigram
+	for name, corrupt := range map[string][]byte{
+		"id out of range": append(bytes.Clone(b[:len(b)-1]), 5),
+		"too many ids":    append(bytes.Clone(b[:len(b)-2]), 0xff, 0xff, 0xff, 0xff, 0x0f, 0),
+		"truncated":       b[:len(b)-3],
+	} {
+		if _, err := ReadNameIndex(bytes.NewReader(corrupt)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package objstore
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	azureVersion = "2021-08-06"
+	// azureMaxBlockSize is the largest block accepted by Put Block.
+	azureMaxBlockSize = 4000 << 20
+)
+
+// Azure is an Azure Blob Storage container authorized with a shared access
+// signature.
+type Azure struct {
+	Options
+	// ContainerURL is the URL of the container including a SAS token, such
+	// as https://account.blob.core.windows.net/snapshots?sv=...&sig=...
+	ContainerURL string
+}
+
+// Open returns the blob at key, reading its size with a HEAD request.
+func (a *Azure) Open(key string) (*Object, error) {
+	u, err := a.blobURL(key, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := a.do(http.MethodHead, u, nil, nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	discard(resp)
+	return a.object(key, resp.ContentLength, func(off, n int64) (io.ReadCloser, error) {
+		h := http.Header{"Range": {fmt.Sprintf("bytes=%d-%d", off, off+n-1)}}
+		resp, err := a.do(http.MethodGet, u, h, nil, http.StatusPartialContent)
+		if err != nil {
+			return nil, err
+		}
+		return resp.Body, nil
+	}), nil
+}
+
+// Create returns a writer uploading to key as blocks committed by Close, or a
+// single Put Blob when less than one part is written. Uncommitted blocks left
+// by a failed upload are removed by Azure after a week.
+func (a *Azure) Create(key string) io.WriteCloser {
+	w := a.partWriter(0)
+	w.partSize = min(w.partSize, azureMaxBlockSize)
+	var ids []string
+	w.upload = func(n int, part []byte, _ bool) error {
+		// block IDs must all be the same length
+		id := base64.StdEncoding.EncodeToString(fmt.Appendf(nil, "block-%08d", n))
+		u, err := a.blobURL(key, url.Values{"comp": {"block"}, "blockid": {id}})
+		if err != nil {
+			return err
+		}
+		resp, err := a.do(http.MethodPut, u, nil, part, http.StatusCreated)
+		if err != nil {
+			return err
+		}
+		discard(resp)
+		ids = append(ids, id)
+		return nil
+	}
+	w.put = func(data []byte) error {
+		return a.PutObject(key, data)
+	}
+	w.complete = func() error {
+		var b bytes.Buffer
+		b.WriteString(`<?xml version="1.0" encoding="utf-8"?><BlockList>`)
+		for _, id := range ids {
+			b.WriteString("<Latest>" + id + "</Latest>")
+		}
+		b.WriteString("</BlockList>")
+		u, err := a.blobURL(key, url.Values{"comp": {"blocklist"}})
+		if err != nil {
+			return err
+		}
+		resp, err := a.do(http.MethodPut, u, nil, b.Bytes(), http.StatusCreated)
+		if err != nil {
+			return err
+		}
+		discard(resp)
+		return nil
+	}
+	return w
+}
+
+// PutObject stores data at key as a block blob with a single request, so that
+// an Azure can be passed to chapointdat.NewObjectDeadLetterSink.
+func (a *Azure) PutObject(key string, data []byte) error {
+	u, err := a.blobURL(key, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := a.do(http.MethodPut, u, http.Header{"X-Ms-Blob-Type": {"BlockBlob"}}, data, http.StatusCreated)
+	if err != nil {
+		return err
+	}
+	discard(resp)
+	return nil
+}
+
+// blobURL returns the URL of key in the container with the SAS token and q.
+func (a *Azure) blobURL(key string, q url.Values) (string, error) {
+	u, err := url.Parse(a.ContainerURL)
+	if err != nil {
+		return "", err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	u.RawPath = ""
+	if len(q) > 0 {
+		if u.RawQuery != "" {
+			u.RawQuery += "&"
+		}
+		u.RawQuery += q.Encode()
+	}
+	return u.String(), nil
+}
+
+func (a *Azure) do(method, u string, h http.Header, body []byte, want ...int) (*http.Response, error) {
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range h {
+		req.Header[k] = v
+	}
+	req.Header.Set("X-Ms-Version", azureVersion)
+	return do(a.client(), req, want...)
+}
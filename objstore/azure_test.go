@@ -0,0 +1,78 @@
+package objstore
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// azureUploads handles Put Blob, Put Block and Put Block List for a memStore.
+func azureUploads(blocks map[string][]byte) func(s *memStore, w http.ResponseWriter, r *http.Request) {
+	return func(s *memStore, w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("sig") != "secret" || r.Header.Get("X-Ms-Version") == "" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case q.Get("comp") == "block":
+			blocks[q.Get("blockid")] = body
+		case q.Get("comp") == "blocklist":
+			var v struct {
+				Latest []string
+			}
+			if err := xml.Unmarshal(body, &v); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			var data []byte
+			for _, id := range v.Latest {
+				data = append(data, blocks[id]...)
+			}
+			s.set(r.URL.Path, data)
+		case r.Header.Get("X-Ms-Blob-Type") == "BlockBlob":
+			s.set(r.URL.Path, body)
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func Test_Azure(t *testing.T) {
+	blocks := map[string][]byte{}
+	store := &memStore{objects: map[string][]byte{}, put: azureUploads(blocks)}
+	srv := httptest.NewServer(store)
+	defer srv.Close()
+	a := &Azure{ContainerURL: srv.URL + "/c?sv=2021-08-06&sig=secret"}
+	a.PartSize = 1000
+	a.BlockSize = 512
+
+	data := bytes.Repeat([]byte("0123456789"), 250)
+	w := a.Create("exports/records.jsonl")
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 3 {
+		t.Errorf("expected 3 blocks got %d", len(blocks))
+	}
+	o, err := a.Open("exports/records.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(io.NewSectionReader(o, 0, o.Size()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("expected %d bytes got %d", len(data), len(got))
+	}
+}
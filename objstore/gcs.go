@@ -0,0 +1,197 @@
+package objstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// gcsChunkSize is the multiple of which every resumable upload chunk but
+	// the last must be.
+	gcsChunkSize = 256 << 10
+	gcsEndpoint  = "https://storage.googleapis.com"
+	metadataURL  = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// GCS is a Google Cloud Storage bucket accessed with the JSON API.
+type GCS struct {
+	Options
+	// Endpoint defaults to https://storage.googleapis.com.
+	Endpoint string
+	Bucket   string
+	// Token returns an OAuth 2.0 access token, for example MetadataToken on
+	// Google Cloud, or nil for public objects.
+	Token func() (string, error)
+}
+
+// Open returns the object at key, reading its size from its metadata.
+func (g *GCS) Open(key string) (*Object, error) {
+	resp, err := g.do(http.MethodGet, g.objectURL(key, nil), nil, nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var meta struct {
+		Size string `json:"size"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&meta); err != nil {
+		return nil, fmt.Errorf("error reading metadata of %s: %w", key, err)
+	}
+	size, err := strconv.ParseInt(meta.Size, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("error reading size of %s: %w", key, err)
+	}
+	media := g.objectURL(key, url.Values{"alt": {"media"}})
+	return g.object(key, size, func(off, n int64) (io.ReadCloser, error) {
+		h := http.Header{"Range": {fmt.Sprintf("bytes=%d-%d", off, off+n-1)}}
+		resp, err := g.do(http.MethodGet, media, h, nil, http.StatusPartialContent)
+		if err != nil {
+			return nil, err
+		}
+		return resp.Body, nil
+	}), nil
+}
+
+// Create returns a writer uploading to key with a resumable upload, or a
+// single request when less than one part is written.
+func (g *GCS) Create(key string) io.WriteCloser {
+	w := g.partWriter(gcsChunkSize)
+	// resumable chunks must be a multiple of 256 KiB
+	w.partSize = (w.partSize + gcsChunkSize - 1) / gcsChunkSize * gcsChunkSize
+	var session string
+	w.upload = func(n int, part []byte, last bool) error {
+		if session == "" {
+			u := g.uploadURL(key, "resumable")
+			resp, err := g.do(http.MethodPost, u, nil, nil, http.StatusOK)
+			if err != nil {
+				return err
+			}
+			discard(resp)
+			if session = resp.Header.Get("Location"); session == "" {
+				return fmt.Errorf("error starting upload of %s: no session URI", key)
+			}
+		}
+		total := "*"
+		want := []int{http.StatusPermanentRedirect}
+		if last {
+			total = strconv.FormatInt(w.size+int64(len(part)), 10)
+			want = []int{http.StatusOK, http.StatusCreated}
+		}
+		h := http.Header{"Content-Range": {fmt.Sprintf("bytes %d-%d/%s", w.size, w.size+int64(len(part))-1, total)}}
+		resp, err := g.do(http.MethodPut, session, h, part, want...)
+		if err != nil {
+			return err
+		}
+		discard(resp)
+		return nil
+	}
+	w.put = func(data []byte) error {
+		return g.PutObject(key, data)
+	}
+	w.complete = func() error { return nil }
+	w.abort = func() {
+		if session == "" {
+			return
+		}
+		if resp, err := g.do(http.MethodDelete, session, nil, nil, 499); err == nil {
+			discard(resp)
+		}
+	}
+	return w
+}
+
+// PutObject stores data at key with a single request, so that a GCS can be
+// passed to chapointdat.NewObjectDeadLetterSink.
+func (g *GCS) PutObject(key string, data []byte) error {
+	resp, err := g.do(http.MethodPost, g.uploadURL(key, "media"), nil, data, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	discard(resp)
+	return nil
+}
+
+func (g *GCS) endpoint() string {
+	if g.Endpoint == "" {
+		return gcsEndpoint
+	}
+	return strings.TrimSuffix(g.Endpoint, "/")
+}
+
+func (g *GCS) objectURL(key string, q url.Values) string {
+	u := g.endpoint() + "/storage/v1/b/" + url.PathEscape(g.Bucket) + "/o/" + url.PathEscape(key)
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	return u
+}
+
+func (g *GCS) uploadURL(key, uploadType string) string {
+	q := url.Values{"uploadType": {uploadType}, "name": {key}}
+	return g.endpoint() + "/upload/storage/v1/b/" + url.PathEscape(g.Bucket) + "/o?" + q.Encode()
+}
+
+func (g *GCS) do(method, u string, h http.Header, body []byte, want ...int) (*http.Response, error) {
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range h {
+		req.Header[k] = v
+	}
+	if g.Token != nil {
+		token, err := g.Token()
+		if err != nil {
+			return nil, fmt.Errorf("error getting token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return do(g.client(), req, want...)
+}
+
+// MetadataToken returns a Token function for GCS which gets access tokens for
+// the default service account from the metadata server of a Google Cloud VM,
+// Cloud Run service or GKE pod, refreshing them shortly before they expire.
+func MetadataToken(c *http.Client) func() (string, error) {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	var mu sync.Mutex
+	var token string
+	var expires time.Time
+	return func() (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if token != "" && time.Now().Before(expires) {
+			return token, nil
+		}
+		req, err := http.NewRequest(http.MethodGet, metadataURL, nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Metadata-Flavor", "Google")
+		resp, err := do(c, req, http.StatusOK)
+		if err != nil {
+			return "", err
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var v struct {
+			AccessToken string `json:"access_token"`
+			ExpiresIn   int    `json:"expires_in"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+			return "", fmt.Errorf("error reading metadata token: %w", err)
+		}
+		token = v.AccessToken
+		expires = time.Now().Add(time.Duration(v.ExpiresIn)*time.Second - time.Minute)
+		return token, nil
+	}
+}
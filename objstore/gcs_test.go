@@ -0,0 +1,97 @@
+package objstore
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// gcsUploads handles media and resumable uploads, and metadata requests, for
+// a memStore serving objects at /b/<bucket>/o/<name>.
+func gcsUploads(s *memStore, w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer token" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	q := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && q.Get("uploadType") == "media":
+		s.set("/b/b/o/"+q.Get("name"), body)
+	case r.Method == http.MethodPost && q.Get("uploadType") == "resumable":
+		w.Header().Set("Location", "http://"+r.Host+"/session/"+q.Get("name"))
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/session/"):
+		name := strings.TrimPrefix(r.URL.Path, "/session/")
+		var start, end int
+		var total string
+		if _, err := fmt.Sscanf(r.Header.Get("Content-Range"), "bytes %d-%d/%s", &start, &end, &total); err != nil || end-start+1 != len(body) {
+			http.Error(w, "bad range", http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		data := append(s.objects["/partial/"+name], body...)
+		s.objects["/partial/"+name] = data
+		s.mu.Unlock()
+		if total == "*" {
+			w.WriteHeader(http.StatusPermanentRedirect)
+			return
+		}
+		s.set("/b/b/o/"+name, data)
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+func Test_GCS(t *testing.T) {
+	store := &memStore{objects: map[string][]byte{}, put: gcsUploads}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// metadata requests are answered with the size of the object
+		if r.URL.Query().Get("alt") != "media" && strings.HasPrefix(r.URL.Path, "/storage/v1/") {
+			store.mu.Lock()
+			data, ok := store.objects[strings.TrimPrefix(r.URL.Path, "/storage/v1")]
+			store.mu.Unlock()
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprintf(w, `{"size":"%d"}`, len(data))
+			return
+		}
+		r.URL.Path = strings.TrimPrefix(r.URL.Path, "/storage/v1")
+		store.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	g := &GCS{Endpoint: srv.URL, Bucket: "b", Token: func() (string, error) { return "token", nil }}
+	g.PartSize = 1
+
+	data := bytes.Repeat([]byte("0123456789abcdef"), 40<<10)
+	w := g.Create("exports/persons.csv")
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	o, err := g.Open("exports/persons.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(io.NewSectionReader(o, 0, o.Size()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("expected %d bytes got %d", len(data), len(got))
+	}
+
+	if err := g.PutObject("small", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if string(store.objects["/b/b/o/small"]) != "x" {
+		t.Errorf("unexpected objects %v", store.objects)
+	}
+}
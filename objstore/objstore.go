@@ -0,0 +1,287 @@
+// Package objstore reads snapshots from and writes exports to S3, Google Cloud
+// Storage and Azure Blob Storage using only net/http, so that cloud pipelines
+// need not stage multi-GB files on local disk.
+//
+// An Object opened from a store is an io.ReaderAt fetching blocks with ranged
+// GET requests, for ExtractReaderAt:
+//
+//	o, err := s3.Open("snapshots/Prod195.zip")
+//	...
+//	s, err := r.ExtractReaderAt(o, o.Size(), o.Key(), 4, errH)
+//
+// Create returns an io.WriteCloser which uploads in parts of PartSize bytes
+// as they are written, for WithJSONLExport or WithCSVExport. The object only
+// appears once Close returns without error.
+package objstore
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+const (
+	defaultBlockSize   = 8 << 20
+	defaultCacheBlocks = 8
+	defaultPartSize    = 16 << 20
+)
+
+type (
+	// Options are common to all stores. Zero values use the defaults.
+	Options struct {
+		// Client defaults to http.DefaultClient.
+		Client *http.Client
+		// BlockSize is the size of the ranged reads made by an Object,
+		// defaulting to 8 MiB.
+		BlockSize int64
+		// CacheBlocks is the number of blocks an Object keeps, defaulting to 8.
+		// Sequential reads of a zip entry need one block each, so more than the
+		// number of entries read concurrently avoids refetching.
+		CacheBlocks int
+		// PartSize is the size of each part uploaded by a writer, defaulting to
+		// 16 MiB. It is rounded up to the minimum a store allows.
+		PartSize int64
+	}
+
+	// Object is a stored object read with ranged requests. It is safe for
+	// concurrent use.
+	Object struct {
+		key         string
+		size        int64
+		blockSize   int64
+		cacheBlocks int
+		get         func(off, n int64) (io.ReadCloser, error)
+
+		mu     sync.Mutex
+		blocks map[int64][]byte
+		// recent lists cached block numbers, most recently used last
+		recent []int64
+	}
+
+	// partWriter buffers writes into parts and uploads each once full.
+	partWriter struct {
+		buf      []byte
+		partSize int
+		parts    int
+		// size is the number of bytes uploaded before the current part.
+		size   int64
+		err    error
+		closed bool
+		// upload is called with each part numbered from 1, and put instead
+		// with everything written if that is no more than one part. complete
+		// is called after the last part, or abort if uploading failed.
+		upload   func(n int, part []byte, last bool) error
+		put      func(data []byte) error
+		complete func() error
+		abort    func()
+	}
+
+	// StatusError is returned for an unexpected HTTP response.
+	StatusError struct {
+		Method     string
+		Path       string
+		StatusCode int
+		Body       string
+	}
+)
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("error %s %s: %d %s: %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode), e.Body)
+}
+
+func (o Options) client() *http.Client {
+	if o.Client == nil {
+		return http.DefaultClient
+	}
+	return o.Client
+}
+
+func (o Options) object(key string, size int64, get func(off, n int64) (io.ReadCloser, error)) *Object {
+	bs := o.BlockSize
+	if bs <= 0 {
+		bs = defaultBlockSize
+	}
+	cb := o.CacheBlocks
+	if cb <= 0 {
+		cb = defaultCacheBlocks
+	}
+	return &Object{key: key, size: size, blockSize: bs, cacheBlocks: cb, get: get, blocks: map[int64][]byte{}}
+}
+
+func (o Options) partWriter(minPartSize int64) *partWriter {
+	ps := o.PartSize
+	if ps <= 0 {
+		ps = defaultPartSize
+	}
+	ps = max(ps, minPartSize)
+	return &partWriter{partSize: int(ps)}
+}
+
+func (o *Object) Key() string {
+	return o.key
+}
+
+func (o *Object) Size() int64 {
+	return o.size
+}
+
+func (o *Object) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	var n int
+	for n < len(p) {
+		if off >= o.size {
+			return n, io.EOF
+		}
+		b := off / o.blockSize
+		data, err := o.block(b)
+		if err != nil {
+			return n, err
+		}
+		c := copy(p[n:], data[off-b*o.blockSize:])
+		n += c
+		off += int64(c)
+	}
+	return n, nil
+}
+
+// block returns block b, fetching it if it is not cached. Blocks are fetched
+// without holding the lock, so concurrent readers of different blocks do not
+// wait for each other.
+func (o *Object) block(b int64) ([]byte, error) {
+	o.mu.Lock()
+	if data, ok := o.blocks[b]; ok {
+		o.use(b)
+		o.mu.Unlock()
+		return data, nil
+	}
+	o.mu.Unlock()
+
+	off := b * o.blockSize
+	n := min(o.blockSize, o.size-off)
+	rc, err := o.get(off, n)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rc.Close() }()
+	data := make([]byte, n)
+	if _, err := io.ReadFull(rc, data); err != nil {
+		return nil, fmt.Errorf("error reading %s at %d: %w", o.key, off, err)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, ok := o.blocks[b]; !ok {
+		o.blocks[b] = data
+		if len(o.recent) >= o.cacheBlocks {
+			delete(o.blocks, o.recent[0])
+			o.recent = o.recent[1:]
+		}
+	}
+	o.use(b)
+	return o.blocks[b], nil
+}
+
+// use moves b to the end of recent.
+func (o *Object) use(b int64) {
+	for i, r := range o.recent {
+		if r == b {
+			o.recent = append(o.recent[:i], o.recent[i+1:]...)
+			break
+		}
+	}
+	o.recent = append(o.recent, b)
+}
+
+func (w *partWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.closed {
+		return 0, errors.New("write to closed writer")
+	}
+	written := 0
+	for len(p) > 0 {
+		// a full part is only uploaded once more is written, so that Close
+		// always has a last part to upload
+		if len(w.buf) == w.partSize {
+			if err := w.flush(false); err != nil {
+				return written, err
+			}
+		}
+		c := min(len(p), w.partSize-len(w.buf))
+		w.buf = append(w.buf, p[:c]...)
+		p = p[c:]
+		written += c
+	}
+	return written, nil
+}
+
+func (w *partWriter) flush(last bool) error {
+	w.parts++
+	if err := w.upload(w.parts, w.buf, last); err != nil {
+		w.fail(err)
+		return err
+	}
+	w.size += int64(len(w.buf))
+	w.buf = w.buf[:0]
+	return nil
+}
+
+func (w *partWriter) fail(err error) {
+	w.err = err
+	if w.abort != nil && w.parts > 0 {
+		w.abort()
+	}
+}
+
+// Close uploads what remains and completes the upload.
+func (w *partWriter) Close() error {
+	if w.closed {
+		return w.err
+	}
+	w.closed = true
+	if w.err != nil {
+		return w.err
+	}
+	if w.parts == 0 {
+		if err := w.put(w.buf); err != nil {
+			w.err = err
+		}
+		return w.err
+	}
+	if err := w.flush(true); err != nil {
+		return err
+	}
+	if err := w.complete(); err != nil {
+		w.fail(err)
+	}
+	return w.err
+}
+
+// do sends req, returning a StatusError unless the response status is one of
+// want.
+func do(c *http.Client, req *http.Request, want ...int) (*http.Response, error) {
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range want {
+		if resp.StatusCode == s {
+			return resp, nil
+		}
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	_ = resp.Body.Close()
+	// the path only, as the query may hold a SAS token
+	return nil, &StatusError{Method: req.Method, Path: req.URL.Path, StatusCode: resp.StatusCode, Body: string(body)}
+}
+
+// discard reads and closes the body of resp so the connection can be reused.
+func discard(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+}
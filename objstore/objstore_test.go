@@ -0,0 +1,122 @@
+package objstore
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memStore serves objects from memory for HEAD and ranged GET requests, with
+// uploads handled by put.
+type memStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	put     func(s *memStore, w http.ResponseWriter, r *http.Request)
+}
+
+func (s *memStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead || r.URL.Query().Has("uploads") {
+		s.put(s, w, r)
+		return
+	}
+	s.mu.Lock()
+	data, ok := s.objects[r.URL.Path]
+	s.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeContent(w, r, "", testModTime, bytes.NewReader(data))
+}
+
+func (s *memStore) set(path string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[path] = data
+}
+
+func testZip(t *testing.T, entries ...string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for i, e := range entries {
+		w, err := zw.Create(fmt.Sprintf("part%d.dat", i+1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func Test_Object_ReadAt(t *testing.T) {
+	data := []byte(strings.Repeat("0123456789", 10))
+	var gets int
+	o := Options{BlockSize: 16, CacheBlocks: 2}.object("k", int64(len(data)), func(off, n int64) (io.ReadCloser, error) {
+		gets++
+		return io.NopCloser(bytes.NewReader(data[off : off+n])), nil
+	})
+	p := make([]byte, 40)
+	if n, err := o.ReadAt(p, 10); n != 40 || err != nil || !bytes.Equal(p, data[10:50]) {
+		t.Errorf("unexpected read %d %v %q", n, err, p[:n])
+	}
+	// blocks 0 to 3 were read and 2 and 3 are still cached
+	if n, err := o.ReadAt(p[:8], 40); n != 8 || err != nil || gets != 4 {
+		t.Errorf("expected a cached read got %d %v after %d gets", n, err, gets)
+	}
+	if n, err := o.ReadAt(p, 90); n != 10 || err != io.EOF || !bytes.Equal(p[:n], data[90:]) {
+		t.Errorf("expected a short read at the end got %d %v", n, err)
+	}
+}
+
+func Test_PartWriter(t *testing.T) {
+	var parts []string
+	var put []byte
+	completed := false
+	newWriter := func() *partWriter {
+		parts, put, completed = nil, nil, false
+		w := &partWriter{partSize: 4}
+		w.upload = func(n int, part []byte, last bool) error {
+			parts = append(parts, fmt.Sprintf("%d:%s:%t", n, part, last))
+			return nil
+		}
+		w.put = func(data []byte) error {
+			put = append([]byte{}, data...)
+			return nil
+		}
+		w.complete = func() error {
+			completed = true
+			return nil
+		}
+		return w
+	}
+
+	w := newWriter()
+	_, _ = io.WriteString(w, "abcdefgh")
+	_, _ = io.WriteString(w, "ij")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(parts, " ") != "1:abcd:false 2:efgh:false 3:ij:true" || !completed || put != nil {
+		t.Errorf("unexpected parts %v", parts)
+	}
+
+	w = newWriter()
+	_, _ = io.WriteString(w, "abcd")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if string(put) != "abcd" || parts != nil || completed {
+		t.Errorf("expected a single put got %q and parts %v", put, parts)
+	}
+}
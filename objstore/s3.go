@@ -0,0 +1,251 @@
+package objstore
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// s3MinPartSize is the smallest part S3 accepts other than the last.
+	s3MinPartSize = 5 << 20
+	// emptySHA256 is the hash of an empty payload.
+	emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// S3 is a bucket in Amazon S3 or an S3 compatible store such as MinIO or R2,
+// addressed path style and authenticated with AWS Signature Version 4.
+type S3 struct {
+	Options
+	// Endpoint defaults to https://s3.<Region>.amazonaws.com.
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is required with temporary credentials.
+	SessionToken string
+	// now is replaced in tests.
+	now func() time.Time
+}
+
+// Open returns the object at key, reading its size with a HEAD request.
+func (s *S3) Open(key string) (*Object, error) {
+	resp, err := s.do(http.MethodHead, key, nil, nil, nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	discard(resp)
+	return s.object(key, resp.ContentLength, func(off, n int64) (io.ReadCloser, error) {
+		h := http.Header{"Range": {fmt.Sprintf("bytes=%d-%d", off, off+n-1)}}
+		resp, err := s.do(http.MethodGet, key, nil, h, nil, http.StatusPartialContent)
+		if err != nil {
+			return nil, err
+		}
+		return resp.Body, nil
+	}), nil
+}
+
+// Create returns a writer uploading to key with a multipart upload, or a
+// single PUT when less than one part is written.
+func (s *S3) Create(key string) io.WriteCloser {
+	w := s.partWriter(s3MinPartSize)
+	var uploadID string
+	var etags []string
+	w.upload = func(n int, part []byte, _ bool) error {
+		if uploadID == "" {
+			resp, err := s.do(http.MethodPost, key, url.Values{"uploads": {""}}, nil, nil, http.StatusOK)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = resp.Body.Close() }()
+			var v struct {
+				UploadID string `xml:"UploadId"`
+			}
+			if err := xml.NewDecoder(resp.Body).Decode(&v); err != nil {
+				return fmt.Errorf("error creating multipart upload of %s: %w", key, err)
+			}
+			uploadID = v.UploadID
+		}
+		q := url.Values{"partNumber": {strconv.Itoa(n)}, "uploadId": {uploadID}}
+		resp, err := s.do(http.MethodPut, key, q, nil, part, http.StatusOK)
+		if err != nil {
+			return err
+		}
+		discard(resp)
+		etags = append(etags, resp.Header.Get("ETag"))
+		return nil
+	}
+	w.put = func(data []byte) error {
+		resp, err := s.do(http.MethodPut, key, nil, nil, data, http.StatusOK)
+		if err != nil {
+			return err
+		}
+		discard(resp)
+		return nil
+	}
+	w.complete = func() error {
+		var b bytes.Buffer
+		b.WriteString("<CompleteMultipartUpload>")
+		for i, etag := range etags {
+			fmt.Fprintf(&b, "<Part><PartNumber>%d</PartNumber><ETag>", i+1)
+			_ = xml.EscapeText(&b, []byte(etag))
+			b.WriteString("</ETag></Part>")
+		}
+		b.WriteString("</CompleteMultipartUpload>")
+		resp, err := s.do(http.MethodPost, key, url.Values{"uploadId": {uploadID}}, nil, b.Bytes(), http.StatusOK)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = resp.Body.Close() }()
+		// S3 can report a failure to complete with a 200 response
+		var v struct {
+			XMLName xml.Name
+			Message string
+		}
+		if err := xml.NewDecoder(resp.Body).Decode(&v); err == nil && v.XMLName.Local == "Error" {
+			return fmt.Errorf("error completing multipart upload of %s: %s", key, v.Message)
+		}
+		return nil
+	}
+	w.abort = func() {
+		if uploadID == "" {
+			return
+		}
+		if resp, err := s.do(http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil, nil, http.StatusNoContent); err == nil {
+			discard(resp)
+		}
+	}
+	return w
+}
+
+// PutObject stores data at key with a single request, so that an S3 can be
+// passed to chapointdat.NewObjectDeadLetterSink.
+func (s *S3) PutObject(key string, data []byte) error {
+	resp, err := s.do(http.MethodPut, key, nil, nil, data, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	discard(resp)
+	return nil
+}
+
+func (s *S3) do(method, key string, query url.Values, h http.Header, body []byte, want ...int) (*http.Response, error) {
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + s.Region + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.Bucket + "/" + key
+	u.RawPath = escapePath(u.Path)
+	u.RawQuery = canonicalQuery(query)
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	for k, v := range h {
+		req.Header[k] = v
+	}
+	hash := emptySHA256
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		hash = hex.EncodeToString(sum[:])
+	}
+	req.Header.Set("X-Amz-Content-Sha256", hash)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	signV4(req, hash, s.AccessKeyID, s.SecretAccessKey, s.Region, "s3", now())
+	return do(s.client(), req, want...)
+}
+
+// signV4 adds an AWS Signature Version 4 Authorization header to req, signing
+// the host, Content-Type and X-Amz-* headers.
+func signV4(req *http.Request, payloadHash, accessKeyID, secretAccessKey, region, service string, t time.Time) {
+	t = t.UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		k = strings.ToLower(k)
+		if k == "content-type" || strings.HasPrefix(k, "x-amz-") {
+			headers[k] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method, path, canonicalQuery(req.URL.Query()), canonicalHeaders.String(), signedHeaders, payloadHash,
+	}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	sum := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	for _, v := range []string{region, service, "aws4_request"} {
+		key = hmacSHA256(key, v)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// escapePath percent encodes everything in p other than unreserved characters
+// and slashes, as signing requires.
+func escapePath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-._~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// canonicalQuery encodes q sorted by key with spaces as %20, as both the
+// request and its signature require.
+func canonicalQuery(q url.Values) string {
+	return strings.ReplaceAll(q.Encode(), "+", "%20")
+}
@@ -0,0 +1,145 @@
+package objstore
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	ch "github.com/richardjennings/chapointdat"
+)
+
+var testModTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+const (
+	testHeaderLine  = "DDDDSNAP019520240101"
+	testCompanyLine = "000000841D                      00000019A. WEST & PARTNERS<"
+)
+
+func Test_SignV4(t *testing.T) {
+	// the example from the AWS Signature Version 4 documentation
+	req, _ := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	signV4(req, emptySHA256, "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "iam",
+		time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if a := req.Header.Get("Authorization"); a != expected {
+		t.Errorf("expected %s got %s", expected, a)
+	}
+}
+
+func Test_EscapePath(t *testing.T) {
+	if p := escapePath("/bucket/a b+c~/d!.zip"); p != "/bucket/a%20b%2Bc~/d%21.zip" {
+		t.Errorf("unexpected path %s", p)
+	}
+}
+
+// s3Uploads handles multipart uploads and single PUTs for a memStore.
+func s3Uploads(parts map[string][]byte) func(s *memStore, w http.ResponseWriter, r *http.Request) {
+	return func(s *memStore, w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=id/") || r.Header.Get("X-Amz-Content-Sha256") == "" {
+			http.Error(w, "unsigned", http.StatusForbidden)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		q := r.URL.Query()
+		switch {
+		case r.Method == http.MethodPost && q.Has("uploads"):
+			fmt.Fprint(w, "<InitiateMultipartUploadResult><UploadId>u1</UploadId></InitiateMultipartUploadResult>")
+		case r.Method == http.MethodPut && q.Get("uploadId") == "u1":
+			parts[q.Get("partNumber")] = body
+			w.Header().Set("ETag", `"etag`+q.Get("partNumber")+`"`)
+		case r.Method == http.MethodPost && q.Get("uploadId") == "u1":
+			var v struct {
+				Part []struct {
+					PartNumber string
+					ETag       string
+				}
+			}
+			if err := xml.Unmarshal(body, &v); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			var data []byte
+			for _, p := range v.Part {
+				if p.ETag != `"etag`+p.PartNumber+`"` {
+					http.Error(w, "bad etag", http.StatusBadRequest)
+					return
+				}
+				data = append(data, parts[p.PartNumber]...)
+			}
+			s.set(r.URL.Path, data)
+			fmt.Fprint(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+		case r.Method == http.MethodPut:
+			s.set(r.URL.Path, body)
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	}
+}
+
+func testS3(t *testing.T, parts map[string][]byte) (*S3, *memStore) {
+	store := &memStore{objects: map[string][]byte{}, put: s3Uploads(parts)}
+	srv := httptest.NewServer(store)
+	t.Cleanup(srv.Close)
+	return &S3{Endpoint: srv.URL, Region: "eu-west-2", Bucket: "b", AccessKeyID: "id", SecretAccessKey: "secret"}, store
+}
+
+func Test_S3_Extract(t *testing.T) {
+	s3, store := testS3(t, nil)
+	s3.BlockSize = 64
+	snap := testHeaderLine + "\n" + testCompanyLine + "\n9999999900000001\n"
+	store.set("/b/snapshots/Prod195.zip", testZip(t, snap, snap))
+	o, err := s3.Open("snapshots/Prod195.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	r := ch.NewReader(ch.WithCompanyHandler(func(c ch.Company) error {
+		names = append(names, c.CompanyName)
+		return nil
+	}))
+	s, err := r.ExtractReaderAt(o, o.Size(), o.Key(), 1, func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Companies != 2 || len(names) != 2 || names[0] != "A. WEST & PARTNERS" {
+		t.Errorf("unexpected summary %+v and names %v", s, names)
+	}
+}
+
+func Test_S3_Create(t *testing.T) {
+	parts := map[string][]byte{}
+	s3, store := testS3(t, parts)
+	s3.PartSize = 1 // rounded up to the 5 MiB minimum
+	data := bytes.Repeat([]byte("0123456789abcdef"), 3<<20/16*4)
+	w := s3.Create("exports/companies.jsonl")
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 3 || !bytes.Equal(store.objects["/b/exports/companies.jsonl"], data) {
+		t.Errorf("expected %d bytes uploaded in 3 parts got %d in %d", len(data), len(store.objects["/b/exports/companies.jsonl"]), len(parts))
+	}
+
+	if err := s3.PutObject("small", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	keys := make([]string, 0, len(store.objects))
+	for k := range store.objects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if strings.Join(keys, " ") != "/b/exports/companies.jsonl /b/small" {
+		t.Errorf("unexpected objects %v", keys)
+	}
+}
@@ -0,0 +1,113 @@
+package chapointdat
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// parsed is a line passing through the parsers of WithOrderedDelivery.
+type parsed struct {
+	i      int
+	seq    uint64
+	offset int64
+	line   []byte
+	// skip is set for a line skipped by WithCompanyNumbers.
+	skip bool
+	rec  Record
+	err  error
+}
+
+// WithOrderedDelivery parses the lines of each file on n goroutines, while
+// still passing records to handlers and sinks one at a time in the order they
+// appear in the file, so that for example a company is always handled before
+// its officers. A reorder buffer holds records parsed ahead of those still
+// being parsed. Record types registered with WithRecordType must be safe to
+// parse concurrently. WithWorkers is ignored, as handling records concurrently
+// would lose their order. Files read concurrently by Extract and ExtractAll are
+// each delivered in order but may interleave with each other.
+func WithOrderedDelivery(n int) Opt {
+	return func(r *Reader) {
+		r.parsers = n
+	}
+}
+
+func (r *Reader) extractLinesOrdered(scan lineScanner, st *state, path string, skip int, errH func(err error)) (Summary, error) {
+	jobs := make(chan *parsed, r.queueCapacity(r.parsers))
+	results := make(chan *parsed, r.queueCapacity(r.parsers))
+	jobMetrics, resultMetrics := &queueMetrics{capacity: cap(jobs)}, &queueMetrics{capacity: cap(results)}
+	var wg sync.WaitGroup
+	for range r.parsers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p, ok := receive(jobMetrics, jobs); ok; p, ok = receive(jobMetrics, jobs) {
+				if !p.skip {
+					p.rec, p.err = r.parse(p.line, st.first(p.i))
+				}
+				send(resultMetrics, results, p)
+			}
+		}()
+	}
+
+	// deliver records in order of i, once any parsed ahead of a record still
+	// being parsed have been held back
+	var failed atomic.Bool
+	delivered := make(chan error)
+	next := max(st.i, skip)
+	go func() {
+		var fatal error
+		pending := map[int]*parsed{}
+		for p, ok := receive(resultMetrics, results); ok; p, ok = receive(resultMetrics, results) {
+			pending[p.i] = p
+			for q, ok := pending[next]; ok; q, ok = pending[next] {
+				delete(pending, next)
+				next++
+				if fatal != nil {
+					continue
+				}
+				if q.skip {
+					st.summary.Skipped++
+					continue
+				}
+				st.line, st.seq, st.offset = q.i+1, q.seq, q.offset
+				if err := r.handleParsed(q.line, q.rec, q.err, st, errH); err != nil {
+					fatal = err
+					st.summary.Lines = q.i + 1
+					failed.Store(true)
+				}
+			}
+		}
+		delivered <- fatal
+	}()
+
+	stopped := false
+	for scan.Scan() {
+		if st.i < skip {
+			st.i++
+			continue
+		}
+		if r.stopped.Load() {
+			stopped = true
+			break
+		}
+		if failed.Load() {
+			break
+		}
+		// the scanner reuses its buffer once the next line is read
+		line := append([]byte(nil), scan.Bytes()...)
+		skip := r.companyFilter != nil && st.skips(r.companyFilter, line)
+		send(jobMetrics, jobs, &parsed{i: st.i, seq: r.seq.Add(1), offset: scan.Offset(), line: line, skip: skip})
+		st.i++
+	}
+	close(jobs)
+	wg.Wait()
+	close(results)
+	err := <-delivered
+	st.summary.Queues = addQueueStats(st.summary.Queues, "parse", jobMetrics.stats())
+	st.summary.Queues = addQueueStats(st.summary.Queues, "deliver", resultMetrics.stats())
+	if err != nil {
+		r.endStream(st, errH)
+		return st.summary, err
+	}
+	return r.endLines(scan, st, path, stopped, errH)
+}
@@ -0,0 +1,49 @@
+package chapointdat
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_OrderedDelivery(t *testing.T) {
+	var b strings.Builder
+	if err := GenerateSnapshot(&b, 500, 1); err != nil {
+		t.Fatal(err)
+	}
+	var sequential, ordered []string
+	var seqs []uint64
+	records := func(out *[]string) []Opt {
+		return []Opt{
+			WithCompanyHandler(func(c Company) error {
+				*out = append(*out, "c"+c.CompanyNumber)
+				return nil
+			}),
+			WithPersonHandler(func(p Person) error {
+				*out = append(*out, "p"+p.CompanyNumber+p.PersonNumber)
+				return nil
+			}),
+		}
+	}
+	if _, err := NewReader(records(&sequential)...).ExtractReader(strings.NewReader(b.String()), "-", func(err error) { t.Error(err) }); err != nil {
+		t.Fatal(err)
+	}
+	opts := append(records(&ordered), WithOrderedDelivery(8), WithRecordHandler(func(rec Record) error {
+		seqs = append(seqs, rec.Seq)
+		return nil
+	}))
+	s, err := NewReader(opts...).ExtractReader(strings.NewReader(b.String()), "-", func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(sequential, ",") != strings.Join(ordered, ",") {
+		t.Error("ordered delivery differs from sequential")
+	}
+	if len(seqs) != s.Lines {
+		t.Fatalf("expected %d records got %d", s.Lines, len(seqs))
+	}
+	for i := 1; i < len(seqs); i++ {
+		if seqs[i] <= seqs[i-1] {
+			t.Fatalf("sequence %d at %d does not follow %d", seqs[i], i, seqs[i-1])
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package chapointdat
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	AppDateOriginAppointment         = AppDateOrigin("1")
+	AppDateOriginAnnualReturn        = AppDateOrigin("2")
+	AppDateOriginIncorporation       = AppDateOrigin("3")
+	AppDateOriginLLPAppointment      = AppDateOrigin("4")
+	AppDateOriginLLPIncorporation    = AppDateOrigin("5")
+	AppDateOriginOverseasAppointment = AppDateOrigin("6")
+)
+
+// AppDateOrigin is the kind of document an appointment date was taken from.
+type AppDateOrigin string
+
+// documentForms are the document form codes of each AppDateOrigin given by the
+// specification. Northern Ireland forms are prefixed with NI.
+var documentForms = map[AppDateOrigin][]string{
+	// RR01 is the appointment of a secretary on re-registration from a
+	// private company to a PLC
+	AppDateOriginAppointment:         {"288a", "AP01", "AP02", "AP03", "AP04", "RR01", "NI 296", "SEAP01", "SEAP02"},
+	AppDateOriginAnnualReturn:        {"363"},
+	AppDateOriginIncorporation:       {"10", "IN01", "NI 21", "SEFM01", "SEFM02", "SEFM03", "SEFM04", "SEFM05", "SECV01", "SETR02"},
+	AppDateOriginLLPAppointment:      {"LLP288a", "LLAP01", "LLAP02", "NI LLP296a"},
+	AppDateOriginLLPIncorporation:    {"LLP2", "LLIN01"},
+	AppDateOriginOverseasAppointment: {"BR4", "OSAP01", "OSAP02", "OSAP03", "OSAP04"},
+}
+
+// AppDateOrigins returns every AppDateOrigin in the specification, in order.
+func AppDateOrigins() []AppDateOrigin {
+	return []AppDateOrigin{
+		AppDateOriginAppointment, AppDateOriginAnnualReturn, AppDateOriginIncorporation,
+		AppDateOriginLLPAppointment, AppDateOriginLLPIncorporation, AppDateOriginOverseasAppointment,
+	}
+}
+
+// ParseDocumentForm returns the AppDateOrigin of a document form code such as
+// AP01 or NI form 296, ignoring case.
+func ParseDocumentForm(code string) (AppDateOrigin, error) {
+	normalized := strings.Join(strings.Fields(strings.ToUpper(code)), " ")
+	normalized = strings.Replace(normalized, "NI FORM ", "NI ", 1)
+	normalized = strings.TrimPrefix(normalized, "FORM ")
+	for _, o := range AppDateOrigins() {
+		if slices.ContainsFunc(documentForms[o], func(f string) bool { return strings.ToUpper(f) == normalized }) {
+			return o, nil
+		}
+	}
+	return "", fmt.Errorf("unknown document form: %s", code)
+}
+
+// Forms returns the document form codes the appointment date may have been
+// taken from, or nil for an unknown origin.
+func (o AppDateOrigin) Forms() []string {
+	return slices.Clone(documentForms[o])
+}
+
+// Origin returns the AppDateOrigin of the appointment.
+func (p Person) Origin() AppDateOrigin {
+	return AppDateOrigin(p.AppDateOrigin)
+}
+
+func (o AppDateOrigin) String() string {
+	switch o {
+	case AppDateOriginAppointment:
+		return "Appointment document"
+	case AppDateOriginAnnualReturn:
+		return "Annual return"
+	case AppDateOriginIncorporation:
+		return "Incorporation document"
+	case AppDateOriginLLPAppointment:
+		return "LLP appointment document"
+	case AppDateOriginLLPIncorporation:
+		return "LLP incorporation document"
+	case AppDateOriginOverseasAppointment:
+		return "Overseas company appointment document"
+	default:
+		return "Unknown"
+	}
+}
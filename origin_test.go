@@ -0,0 +1,40 @@
+package chapointdat
+
+import (
+	"slices"
+	"testing"
+)
+
+func Test_ParseDocumentForm(t *testing.T) {
+	for code, expected := range map[string]AppDateOrigin{
+		"288a": AppDateOriginAppointment, "ap01": AppDateOriginAppointment, "NI form 296": AppDateOriginAppointment,
+		"363": AppDateOriginAnnualReturn, "form 10": AppDateOriginIncorporation, "IN01": AppDateOriginIncorporation,
+		"LLP288a": AppDateOriginLLPAppointment, "LLP2": AppDateOriginLLPIncorporation, "OSAP01": AppDateOriginOverseasAppointment,
+	} {
+		if o, err := ParseDocumentForm(code); err != nil || o != expected {
+			t.Errorf("expected %s for %s got %s %v", expected, code, o, err)
+		}
+	}
+	if _, err := ParseDocumentForm("CS01"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func Test_AppDateOriginForms(t *testing.T) {
+	for _, o := range AppDateOrigins() {
+		if len(o.Forms()) == 0 || o.String() == "Unknown" {
+			t.Errorf("expected forms and a description for %s", o)
+		}
+		for _, f := range o.Forms() {
+			if got, err := ParseDocumentForm(f); err != nil || got != o {
+				t.Errorf("expected %s for %s got %s %v", o, f, got, err)
+			}
+		}
+	}
+	if !slices.Contains(Person{AppDateOrigin: "5"}.Origin().Forms(), "LLIN01") {
+		t.Error("expected LLIN01 for an LLP incorporation")
+	}
+	if AppDateOrigin("9").Forms() != nil {
+		t.Error("expected no forms for an unknown origin")
+	}
+}
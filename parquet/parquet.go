@@ -0,0 +1,288 @@
+// Package parquet writes the companies and persons of a snapshot as Parquet
+// files, for loading into data lakes, DuckDB, Spark or pandas:
+//
+//	df = pandas.read_parquet("persons.parquet")
+//
+// Columns are the fields of chapointdat.CompanySchema and PersonSchema, named
+// as in CSV exports, with dates as DATE, integers as INT32 and blank dates and
+// integers null. Each row group has a single gzip compressed data page per
+// column, PLAIN encoded. It uses only the standard library, so no other
+// codecs, encodings or statistics are written.
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	ch "github.com/richardjennings/chapointdat"
+)
+
+const (
+	// DefaultRowGroupSize is the number of rows in each row group by default.
+	DefaultRowGroupSize = 100000
+
+	magic = "PAR1"
+
+	typeInt32     = 1
+	typeByteArray = 6
+
+	repetitionRequired = 0
+	repetitionOptional = 1
+
+	convertedUTF8  = 0
+	convertedDate  = 6
+	convertedInt32 = 17
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecGzip = 2
+
+	pageTypeData = 0
+)
+
+// Writer writes company or person records as a Parquet file. Pass its Write
+// method to WithRecordHandler and call Close once extraction has finished.
+type Writer struct {
+	mu           sync.Mutex
+	w            io.Writer
+	offset       int64
+	kind         ch.RecordKind
+	schema       ch.Schema
+	blanks       ch.Blanks
+	rowGroupSize int
+	// columns are the values of the rows of the current row group by field.
+	columns   [][]string
+	rows      int
+	numRows   int64
+	rowGroups []tValue
+	gz        *gzip.Writer
+	started   bool
+}
+
+// NewWriter returns a Writer of the records of kind, ch.RecordKindCompany or
+// ch.RecordKindPerson, to w, in row groups of rowGroupSize rows, or
+// DefaultRowGroupSize if it is 0 or less.
+func NewWriter(w io.Writer, kind ch.RecordKind, rowGroupSize int) (*Writer, error) {
+	var schema ch.Schema
+	switch kind {
+	case ch.RecordKindCompany:
+		schema = ch.CompanySchema()
+	case ch.RecordKindPerson:
+		schema = ch.PersonSchema()
+	default:
+		return nil, fmt.Errorf("no schema for records of kind %s", kind)
+	}
+	if rowGroupSize <= 0 {
+		rowGroupSize = DefaultRowGroupSize
+	}
+	return &Writer{w: w, kind: kind, schema: schema, rowGroupSize: rowGroupSize, columns: make([][]string, len(schema.Fields)), gz: gzip.NewWriter(nil)}, nil
+}
+
+// SetBlanks sets how blank values are written, by default as empty strings
+// other than blank dates and integers, which are null. It must be called
+// before the first Write.
+func (w *Writer) SetBlanks(b ch.Blanks) {
+	w.blanks = b
+}
+
+// Write adds a record of the Writer's kind to the current row group, writing
+// the row group once it is full. Other records are ignored.
+func (w *Writer) Write(rec ch.Record) error {
+	if rec.Kind != w.kind {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	fields := rec.Fields()
+	for i, f := range w.schema.Fields {
+		w.columns[i] = append(w.columns[i], fields[f.Name])
+	}
+	if w.rows++; w.rows == w.rowGroupSize {
+		return w.flush()
+	}
+	return nil
+}
+
+// Close writes any remaining rows and the footer. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.flush(); err != nil {
+		return err
+	}
+	if err := w.start(); err != nil {
+		return err
+	}
+	schema := []tValue{tStruct{{4, tBinary(w.kind.String())}, {5, tI32(len(w.schema.Fields))}}}
+	for _, f := range w.schema.Fields {
+		schema = append(schema, w.element(f))
+	}
+	footer := tStruct{
+		{1, tI32(1)},
+		{2, tList{tTypeStruct, schema}},
+		{3, tI64(w.numRows)},
+		{4, tList{tTypeStruct, w.rowGroups}},
+		{6, tBinary("chapointdat")},
+	}.write(nil)
+	footer = binary.LittleEndian.AppendUint32(footer, uint32(len(footer)))
+	return w.write(append(footer, magic...))
+}
+
+// element returns the SchemaElement of f.
+func (w *Writer) element(f ch.SchemaField) tStruct {
+	repetition := tI32(repetitionRequired)
+	if w.blanks.Nullable(f) {
+		repetition = repetitionOptional
+	}
+	switch f.Format {
+	case ch.FieldFormatDate:
+		// the logical type is a DateType
+		return tStruct{{1, tI32(typeInt32)}, {3, repetition}, {4, tBinary(f.Name)}, {6, tI32(convertedDate)}, {10, tStruct{{6, tStruct{}}}}}
+	case ch.FieldFormatInteger:
+		// the logical type is a signed 32 bit IntType
+		return tStruct{{1, tI32(typeInt32)}, {3, repetition}, {4, tBinary(f.Name)}, {6, tI32(convertedInt32)}, {10, tStruct{{10, tStruct{{1, tI8(32)}, {2, tBool(true)}}}}}}
+	default:
+		// the logical type is a StringType
+		return tStruct{{1, tI32(typeByteArray)}, {3, repetition}, {4, tBinary(f.Name)}, {6, tI32(convertedUTF8)}, {10, tStruct{{1, tStruct{}}}}}
+	}
+}
+
+// start writes the magic number starting the file if it has not been written.
+func (w *Writer) start() error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+	return w.write([]byte(magic))
+}
+
+func (w *Writer) write(b []byte) error {
+	n, err := w.w.Write(b)
+	w.offset += int64(n)
+	return err
+}
+
+// flush writes the rows of the current row group, each column as a single
+// data page.
+func (w *Writer) flush() error {
+	if w.rows == 0 {
+		return nil
+	}
+	if err := w.start(); err != nil {
+		return err
+	}
+	var columns []tValue
+	var size int64
+	for i, f := range w.schema.Fields {
+		page, err := w.page(f, w.columns[i])
+		if err != nil {
+			return err
+		}
+		compressed, err := w.compress(page)
+		if err != nil {
+			return err
+		}
+		header := tStruct{
+			{1, tI32(pageTypeData)},
+			{2, tI32(len(page))},
+			{3, tI32(len(compressed))},
+			{5, tStruct{{1, tI32(w.rows)}, {2, tI32(encodingPlain)}, {3, tI32(encodingRLE)}, {4, tI32(encodingRLE)}}},
+		}.write(nil)
+		offset := w.offset
+		if err := w.write(append(header, compressed...)); err != nil {
+			return err
+		}
+		typ := typeByteArray
+		if f.Format == ch.FieldFormatDate || f.Format == ch.FieldFormatInteger {
+			typ = typeInt32
+		}
+		size += int64(len(header) + len(page))
+		columns = append(columns, tStruct{
+			{2, tI64(offset)},
+			{3, tStruct{
+				{1, tI32(typ)},
+				{2, tList{tTypeI32, []tValue{tI32(encodingPlain), tI32(encodingRLE)}}},
+				{3, tList{tTypeBinary, []tValue{tBinary(f.Name)}}},
+				{4, tI32(codecGzip)},
+				{5, tI64(w.rows)},
+				{6, tI64(len(header) + len(page))},
+				{7, tI64(len(header) + len(compressed))},
+				{9, tI64(offset)},
+			}},
+		})
+	}
+	w.rowGroups = append(w.rowGroups, tStruct{{1, tList{tTypeStruct, columns}}, {2, tI64(size)}, {3, tI64(w.rows)}})
+	w.numRows += int64(w.rows)
+	for i := range w.columns {
+		w.columns[i] = w.columns[i][:0]
+	}
+	w.rows = 0
+	return nil
+}
+
+// page returns the body of a data page of values of f: the definition levels
+// of an optional field, as runs of the RLE hybrid encoding, and the PLAIN
+// encoding of the values which are not null.
+func (w *Writer) page(f ch.SchemaField, values []string) ([]byte, error) {
+	var levels, data []byte
+	run, level := 0, byte(0)
+	endRun := func() {
+		if run > 0 {
+			levels = binary.AppendUvarint(levels, uint64(run)<<1)
+			levels = append(levels, level)
+		}
+	}
+	nullable := w.blanks.Nullable(f)
+	for _, v := range values {
+		value := w.blanks.Value(f, v)
+		if nullable {
+			l := byte(0)
+			if value != nil {
+				l = 1
+			}
+			if l != level {
+				endRun()
+				run, level = 0, l
+			}
+			run++
+		}
+		switch value := value.(type) {
+		case time.Time:
+			data = binary.LittleEndian.AppendUint32(data, uint32(int32(value.Unix()/86400)))
+		case int:
+			data = binary.LittleEndian.AppendUint32(data, uint32(int32(value)))
+		case string:
+			data = binary.LittleEndian.AppendUint32(data, uint32(len(value)))
+			data = append(data, value...)
+		case nil:
+			if !nullable {
+				return nil, fmt.Errorf("null value of required field %s", f.Name)
+			}
+		}
+	}
+	if !nullable {
+		return data, nil
+	}
+	endRun()
+	page := binary.LittleEndian.AppendUint32(nil, uint32(len(levels)))
+	return append(append(page, levels...), data...), nil
+}
+
+func (w *Writer) compress(page []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w.gz.Reset(&buf)
+	if _, err := w.gz.Write(page); err != nil {
+		return nil, err
+	}
+	if err := w.gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
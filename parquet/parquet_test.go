@@ -0,0 +1,214 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	ch "github.com/richardjennings/chapointdat"
+)
+
+const (
+	testHeaderLine  = "DDDDSNAP019520240101"
+	testCompanyLine = "000000841D                      00010019A. WEST & PARTNERS<"
+	testPersonLine  = "004638192201024407940002        19910915        NP25 3DZ194509          0093MR<HANS<KJAERSGAARD<<<<1 AGINCOURT STREET<<MONMOUTH<<WALES<MARKETING DIRECTOR<DANISH<ENGLAND<"
+)
+
+// testOtherLine is testPersonLine of another person without an appointment
+// date.
+var testOtherLine = strings.NewReplacer("024407940002", "024407940003", "19910915", "        ", "KJAERSGAARD", "SMITHSONIAN").Replace(testPersonLine)
+
+func testSnapshot(lines ...string) string {
+	return testHeaderLine + "\n" + strings.Join(lines, "\n") + "\n" + fmt.Sprintf("99999999%08d\n", len(lines))
+}
+
+// tTest reads values of the Thrift compact protocol, structs as maps by field
+// id.
+type tTest struct {
+	t   *testing.T
+	buf []byte
+	pos int
+}
+
+func (d *tTest) uvarint() uint64 {
+	v, n := binary.Uvarint(d.buf[d.pos:])
+	if n <= 0 {
+		d.t.Fatalf("bad varint at %d", d.pos)
+	}
+	d.pos += n
+	return v
+}
+
+func (d *tTest) value(typ byte) any {
+	switch typ {
+	case tTypeTrue:
+		return true
+	case tTypeFalse:
+		return false
+	case tTypeByte:
+		d.pos++
+		return int64(int8(d.buf[d.pos-1]))
+	case tTypeI32, tTypeI64:
+		v := d.uvarint()
+		return int64(v>>1) ^ -int64(v&1)
+	case tTypeBinary:
+		n := int(d.uvarint())
+		d.pos += n
+		return string(d.buf[d.pos-n : d.pos])
+	case tTypeList:
+		h := d.buf[d.pos]
+		d.pos++
+		n := int(h >> 4)
+		if n == 15 {
+			n = int(d.uvarint())
+		}
+		var l []any
+		for range n {
+			l = append(l, d.value(h&0x0f))
+		}
+		return l
+	case tTypeStruct:
+		s := map[int64]any{}
+		id := int64(0)
+		for {
+			h := d.buf[d.pos]
+			d.pos++
+			if h == 0 {
+				return s
+			}
+			if delta := int64(h >> 4); delta != 0 {
+				id += delta
+			} else {
+				v := d.uvarint()
+				id = int64(v>>1) ^ -int64(v&1)
+			}
+			s[id] = d.value(h & 0x0f)
+		}
+	}
+	d.t.Fatalf("unexpected type %d at %d", typ, d.pos)
+	return nil
+}
+
+func (d *tTest) structAt(pos int) map[int64]any {
+	d.pos = pos
+	return d.value(tTypeStruct).(map[int64]any)
+}
+
+func Test_Writer(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, ch.RecordKindPerson, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := ch.NewReader(ch.WithRecordHandler(w.Write))
+	if _, err := r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine, testPersonLine, testOtherLine)), "-", func(err error) { t.Error(err) }); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	if string(b[:4]) != magic || string(b[len(b)-4:]) != magic {
+		t.Fatalf("expected the file to start and end with %s", magic)
+	}
+	n := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	d := &tTest{t: t, buf: b}
+	meta := d.structAt(len(b) - 8 - n)
+	if d.pos != len(b)-8 {
+		t.Fatalf("expected the footer to end at %d got %d", len(b)-8, d.pos)
+	}
+	if meta[3] != int64(2) {
+		t.Errorf("expected 2 rows got %v", meta[3])
+	}
+	schema := meta[2].([]any)
+	fields := ch.PersonSchema().Fields
+	if len(schema) != len(fields)+1 || schema[0].(map[int64]any)[5] != int64(len(fields)) {
+		t.Fatalf("expected a root and %d fields got %v", len(fields), schema)
+	}
+	col := map[string]int{}
+	for i, f := range fields {
+		col[f.Name] = i
+		e := schema[i+1].(map[int64]any)
+		if e[4] != f.Name {
+			t.Errorf("expected field %s got %v", f.Name, e[4])
+		}
+		if e[3] != map[bool]int64{false: repetitionRequired, true: repetitionOptional}[f.Nullable()] {
+			t.Errorf("unexpected repetition of %s: %v", f.Name, e[3])
+		}
+	}
+	rowGroups := meta[4].([]any)
+	if len(rowGroups) != 2 {
+		t.Fatalf("expected a row group per row got %d", len(rowGroups))
+	}
+
+	// column reads the values of a field in each row group
+	column := func(name string, optional bool) []any {
+		var values []any
+		for _, rg := range rowGroups {
+			cc := rg.(map[int64]any)[1].([]any)[col[name]].(map[int64]any)
+			cm := cc[3].(map[int64]any)
+			if cm[3].([]any)[0] != name || cm[4] != int64(codecGzip) {
+				t.Fatalf("unexpected column metadata %v", cm)
+			}
+			header := d.structAt(int(cm[9].(int64)))
+			compressed := b[d.pos : d.pos+int(header[3].(int64))]
+			if int64(d.pos-int(cm[9].(int64))+len(compressed)) != cm[7] {
+				t.Errorf("expected the compressed size of %s to be %v", name, cm[7])
+			}
+			zr, err := gzip.NewReader(bytes.NewReader(compressed))
+			if err != nil {
+				t.Fatal(err)
+			}
+			page, err := io.ReadAll(zr)
+			if err != nil || int64(len(page)) != header[2] {
+				t.Fatalf("expected a page of %v bytes got %d, %v", header[2], len(page), err)
+			}
+			if optional {
+				n := binary.LittleEndian.Uint32(page)
+				levels := page[4 : 4+n]
+				page = page[4+n:]
+				// a run of one row
+				if levels[0] != 1<<1 || levels[1] == 0 {
+					values = append(values, nil)
+					continue
+				}
+			}
+			if cm[1] == int64(typeInt32) {
+				values = append(values, int32(binary.LittleEndian.Uint32(page)))
+			} else {
+				values = append(values, string(page[4:4+binary.LittleEndian.Uint32(page)]))
+			}
+		}
+		return values
+	}
+	if v := column("Surname", false); v[0] != "KJAERSGAARD" || v[1] != "SMITHSONIAN" {
+		t.Errorf("unexpected surnames %v", v)
+	}
+	// 1991-09-15 is day 7927 of the Unix epoch
+	if v := column("AppointmentDate", true); v[0] != int32(7927) || v[1] != nil {
+		t.Errorf("unexpected appointment dates %v", v)
+	}
+}
+
+func Test_Writer_Blanks(t *testing.T) {
+	w, err := NewWriter(io.Discard, ch.RecordKindCompany, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetBlanks(ch.Blanks{Mode: ch.BlankNull})
+	page, err := w.page(ch.SchemaField{Name: "Name"}, []string{"A", "", "", "B"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{6, 0, 0, 0, 2, 1, 4, 0, 2, 1, 1, 0, 0, 0, 'A', 1, 0, 0, 0, 'B'}
+	if !bytes.Equal(page, expected) {
+		t.Errorf("expected levels of runs of 1, 2 and 1 and two values got %v", page)
+	}
+	if _, err := NewWriter(io.Discard, ch.RecordKindHeader, 0); err == nil {
+		t.Error("expected an error for records without a schema")
+	}
+}
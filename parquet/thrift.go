@@ -0,0 +1,107 @@
+package parquet
+
+import "encoding/binary"
+
+// The metadata of a Parquet file is encoded with the Thrift compact protocol.
+// Only what FileMetaData and PageHeader need is implemented: structs of
+// booleans, integers, strings, structs and lists.
+
+const (
+	tTypeTrue   = 1
+	tTypeFalse  = 2
+	tTypeByte   = 3
+	tTypeI32    = 5
+	tTypeI64    = 6
+	tTypeBinary = 8
+	tTypeList   = 9
+	tTypeStruct = 12
+)
+
+type (
+	// tValue is a value of a Thrift field or list.
+	tValue interface {
+		typ() byte
+		write(b []byte) []byte
+	}
+
+	// tStruct is a struct of fields in ascending order of id.
+	tStruct []tField
+
+	tField struct {
+		id int16
+		v  tValue
+	}
+
+	// tList is a list of values of type elem.
+	tList struct {
+		elem   byte
+		values []tValue
+	}
+
+	tBool   bool
+	tI8     int8
+	tI32    int32
+	tI64    int64
+	tBinary string
+)
+
+func (s tStruct) typ() byte { return tTypeStruct }
+
+func (s tStruct) write(b []byte) []byte {
+	last := int16(0)
+	for _, f := range s {
+		if delta := f.id - last; delta > 0 && delta <= 15 {
+			b = append(b, byte(delta)<<4|f.v.typ())
+		} else {
+			b = append(b, f.v.typ())
+			b = binary.AppendVarint(b, int64(f.id))
+		}
+		b = f.v.write(b)
+		last = f.id
+	}
+	return append(b, 0)
+}
+
+func (l tList) typ() byte { return tTypeList }
+
+func (l tList) write(b []byte) []byte {
+	if n := len(l.values); n < 15 {
+		b = append(b, byte(n)<<4|l.elem)
+	} else {
+		b = append(b, 0xf0|l.elem)
+		b = binary.AppendUvarint(b, uint64(n))
+	}
+	for _, v := range l.values {
+		b = v.write(b)
+	}
+	return b
+}
+
+// tBool is only written as a field, where its value is its type.
+func (v tBool) typ() byte {
+	if v {
+		return tTypeTrue
+	}
+	return tTypeFalse
+}
+
+func (v tBool) write(b []byte) []byte { return b }
+
+func (v tI8) typ() byte { return tTypeByte }
+
+func (v tI8) write(b []byte) []byte { return append(b, byte(v)) }
+
+func (v tI32) typ() byte { return tTypeI32 }
+
+func (v tI32) write(b []byte) []byte { return binary.AppendVarint(b, int64(v)) }
+
+func (v tI64) typ() byte { return tTypeI64 }
+
+func (v tI64) write(b []byte) []byte { return binary.AppendVarint(b, int64(v)) }
+
+func (v tBinary) typ() byte { return tTypeBinary }
+
+func (v tBinary) write(b []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
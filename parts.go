@@ -0,0 +1,174 @@
+package chapointdat
+
+import (
+	"archive/zip"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrMissingPart is wrapped by the error from CheckParts when a part of a split
+// snapshot is missing.
+var ErrMissingPart = errors.New("missing part")
+
+// partNumber matches the part number at the end of a file name such as
+// Prod195_3578_3.dat.
+var partNumber = regexp.MustCompile(`_(\d+)\.(?i:dat|zip|txt)$`)
+
+type Part struct {
+	Path, Entry string
+	// Number is the part number taken from the entry name, or the file name if
+	// the entry name does not have one.
+	Number int
+	// FirstCompanyNumber is the company number of the first record after the
+	// header.
+	FirstCompanyNumber string
+}
+
+// WithExpectedParts makes ExtractAll check, before reading any records, that
+// the files it is given contain parts 1 to n of a split snapshot.
+func WithExpectedParts(n int) Opt {
+	return func(r *Reader) {
+		r.expectedParts = n
+	}
+}
+
+// Parts lists the parts of a split snapshot found in paths, which may each be
+// a zip of one or more .dat files or a .dat file. Only the start of each part
+// is read.
+func Parts(paths []string) ([]Part, error) {
+	return listParts(paths, func(string) bool { return true })
+}
+
+// listParts lists the parts of paths in the zip entries chosen by readEntry.
+func listParts(paths []string, readEntry func(name string) bool) ([]Part, error) {
+	var parts []Part
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		p, err := fileParts(f, path, readEntry)
+		_ = f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading parts of %s: %w", path, err)
+		}
+		parts = append(parts, p...)
+	}
+	sort.SliceStable(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+	return parts, nil
+}
+
+func fileParts(f *os.File, path string, readEntry func(name string) bool) ([]Part, error) {
+	magic := make([]byte, len(zipMagic))
+	if _, err := io.ReadFull(f, magic); err != nil || string(magic) != zipMagic {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		p, err := readPart(f, path, filepath.Base(path))
+		return []Part{p}, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	z, err := zip.NewReader(f, fi.Size())
+	if err != nil {
+		return nil, err
+	}
+	var parts []Part
+	for _, zf := range z.File {
+		if !readEntry(zf.Name) {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, err
+		}
+		p, err := readPart(rc, path, zf.Name)
+		_ = rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, p)
+	}
+	return parts, nil
+}
+
+func readPart(rd io.Reader, path, entry string) (Part, error) {
+	p := Part{Path: path, Entry: entry}
+	m := partNumber.FindStringSubmatch(entry)
+	if m == nil {
+		m = partNumber.FindStringSubmatch(filepath.Base(path))
+	}
+	if m == nil {
+		return p, fmt.Errorf("no part number in %s", entry)
+	}
+	p.Number, _ = strconv.Atoi(m[1])
+	scan := bufio.NewScanner(rd)
+	for i := 0; scan.Scan() && i < 2; i++ {
+		line := scan.Bytes()
+		if i == 1 && len(line) >= 8 && string(line[0:8]) != trailerRecordIdentifier {
+			p.FirstCompanyNumber = strings.TrimSpace(string(line[0:8]))
+		}
+	}
+	return p, scan.Err()
+}
+
+// CheckParts checks that parts, as returned by Parts, are parts 1 to expected
+// of a split snapshot with each part starting at a higher company number than
+// the one before. The error for a missing part identifies the range of company
+// numbers it would have covered.
+func CheckParts(parts []Part, expected int) error {
+	var errs []error
+	byNumber := map[int]Part{}
+	for _, p := range parts {
+		if prev, ok := byNumber[p.Number]; ok {
+			errs = append(errs, fmt.Errorf("part %d found in both %s and %s", p.Number, prev.Entry, p.Entry))
+		}
+		byNumber[p.Number] = p
+		if p.Number < 1 || p.Number > expected {
+			errs = append(errs, fmt.Errorf("unexpected part %d of %d in %s", p.Number, expected, p.Entry))
+		}
+	}
+	var prev *Part
+	for n := 1; n <= expected; n++ {
+		p, ok := byNumber[n]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%w %d of %d: %s", ErrMissingPart, n, expected, missingRange(byNumber, n, expected)))
+			continue
+		}
+		if prev != nil && p.FirstCompanyNumber != "" && p.FirstCompanyNumber <= prev.FirstCompanyNumber {
+			errs = append(errs, fmt.Errorf("part %d starts at company %s which is not after part %d starting at %s",
+				p.Number, p.FirstCompanyNumber, prev.Number, prev.FirstCompanyNumber))
+		}
+		prev = &p
+	}
+	return errors.Join(errs...)
+}
+
+// missingRange describes the company numbers between the nearest parts present
+// either side of part n.
+func missingRange(byNumber map[int]Part, n, expected int) string {
+	from, to := "the start", "the end"
+	for i := n - 1; i >= 1; i-- {
+		if p, ok := byNumber[i]; ok {
+			from = "after part " + strconv.Itoa(i) + " starting at company " + p.FirstCompanyNumber
+			break
+		}
+	}
+	for i := n + 1; i <= expected; i++ {
+		if p, ok := byNumber[i]; ok {
+			to = "company " + p.FirstCompanyNumber
+			break
+		}
+	}
+	return "company numbers from " + from + " up to " + to
+}
@@ -0,0 +1,53 @@
+package chapointdat
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_CheckParts_Missing(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	for _, p := range []struct{ name, company string }{
+		{"Prod195_1_1.dat", "00000084"},
+		{"Prod195_1_2.dat", "01000000"},
+		{"Prod195_1_4.dat", "03000000"},
+	} {
+		path := filepath.Join(dir, p.name)
+		line := strings.Replace(testCompanyLine, "00000084", p.company, 1)
+		if err := os.WriteFile(path, []byte(testSnapshot(line)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	parts, err := Parts(paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckParts(parts, 3); err == nil || !strings.Contains(err.Error(), "unexpected part 4 of 3") {
+		t.Errorf("expected unexpected part 4 error got %v", err)
+	}
+	err = CheckParts(parts, 4)
+	if !errors.Is(err, ErrMissingPart) {
+		t.Fatalf("expected ErrMissingPart got %v", err)
+	}
+	expected := "missing part 3 of 4: company numbers from after part 2 starting at company 01000000 up to company 03000000"
+	if err.Error() != expected {
+		t.Errorf("expected %q got %q", expected, err.Error())
+	}
+
+	var companies int
+	r := NewReader(WithExpectedParts(4), WithCompanyHandler(func(c Company) error {
+		companies++
+		return nil
+	}))
+	if _, err := r.ExtractAll(paths, 1, func(err error) { t.Error(err) }); !errors.Is(err, ErrMissingPart) {
+		t.Errorf("expected ErrMissingPart got %v", err)
+	}
+	if companies != 0 {
+		t.Errorf("expected no records processed got %d", companies)
+	}
+}
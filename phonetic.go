@@ -0,0 +1,594 @@
+package chapointdat
+
+import (
+	"slices"
+	"strings"
+)
+
+const metaphoneLength = 4
+
+// PhoneticKeys are the keys of a name for fuzzy matching, with a key for each
+// word of a name of more than one word, separated by spaces.
+type PhoneticKeys struct {
+	Soundex string
+	// Metaphone and MetaphoneAlt are the primary and alternate Double
+	// Metaphone keys.
+	Metaphone, MetaphoneAlt string
+}
+
+// WithPhoneticKeys sets Person.Phonetic to the keys of each surname and
+// Company.Phonetic to the keys of each NormalizeCompanyName as they are read.
+func WithPhoneticKeys() Opt {
+	return func(r *Reader) {
+		r.phoneticKeys = true
+	}
+}
+
+// NamePhoneticKeys returns the PhoneticKeys of name.
+func NamePhoneticKeys(name string) PhoneticKeys {
+	var soundex, primary, alternate []string
+	for _, word := range CompanyNameTokens(name) {
+		if s := Soundex(word); s != "" {
+			soundex = append(soundex, s)
+		}
+		if p, a := DoubleMetaphone(word); p != "" || a != "" {
+			primary, alternate = append(primary, p), append(alternate, a)
+		}
+	}
+	return PhoneticKeys{
+		Soundex:      strings.Join(soundex, " "),
+		Metaphone:    strings.Join(primary, " "),
+		MetaphoneAlt: strings.Join(alternate, " "),
+	}
+}
+
+// PhoneticKeys returns the keys of the surname of p.
+func (p Person) PhoneticKeys() PhoneticKeys {
+	return NamePhoneticKeys(p.Surname)
+}
+
+// PhoneticKeys returns the keys of the NormalizeCompanyName of c, so that legal
+// suffixes do not take part in matching.
+func (c Company) PhoneticKeys() PhoneticKeys {
+	return NamePhoneticKeys(NormalizeCompanyName(c.CompanyName))
+}
+
+// Matches reports whether k and o share a Double Metaphone key, the usual test
+// for names which sound alike.
+func (k PhoneticKeys) Matches(o PhoneticKeys) bool {
+	if k.Metaphone == "" || o.Metaphone == "" {
+		return false
+	}
+	return k.Metaphone == o.Metaphone || k.Metaphone == o.MetaphoneAlt || k.MetaphoneAlt == o.Metaphone || k.MetaphoneAlt == o.MetaphoneAlt
+}
+
+// soundexCodes are the American Soundex digits of the letters A to Z, with 0
+// for vowels, which separate letters with the same digit, and - for H and W,
+// which do not.
+const soundexCodes = "0123012-02245501262301-202"
+
+// Soundex returns the American Soundex key of the letters A to Z in word, such
+// as R163 for ROBERT and RUPERT, or "" if there are none.
+func Soundex(word string) string {
+	key := make([]byte, 0, 4)
+	var last byte
+	for _, r := range strings.ToUpper(word) {
+		if r < 'A' || r > 'Z' {
+			continue
+		}
+		code := soundexCodes[r-'A']
+		switch {
+		case len(key) == 0:
+			key = append(key, byte(r))
+		case code == '-':
+			continue
+		case code != '0' && code != last:
+			key = append(key, code)
+		}
+		last = code
+		if len(key) == 4 {
+			break
+		}
+	}
+	if len(key) == 0 {
+		return ""
+	}
+	for len(key) < 4 {
+		key = append(key, '0')
+	}
+	return string(key)
+}
+
+type metaphone struct {
+	value              []rune
+	primary, alternate []rune
+	slavoGermanic      bool
+}
+
+// DoubleMetaphone returns the primary and alternate Double Metaphone keys of
+// word, of up to 4 characters, as described by Lawrence Philips. The keys are
+// the same for most words, and differ for those which may be pronounced
+// either way, such as SMITH, with SM0 and XMT, where 0 is TH.
+func DoubleMetaphone(word string) (primary, alternate string) {
+	m := &metaphone{value: []rune(strings.ToUpper(strings.TrimSpace(word)))}
+	if len(m.value) == 0 {
+		return "", ""
+	}
+	s := string(m.value)
+	m.slavoGermanic = strings.ContainsAny(s, "WK") || strings.Contains(s, "CZ") || strings.Contains(s, "WITZ")
+	i := 0
+	if m.contains(0, 2, "GN", "KN", "PN", "WR", "PS") {
+		i = 1
+	}
+	for i < len(m.value) && (len(m.primary) < metaphoneLength || len(m.alternate) < metaphoneLength) {
+		i = m.next(i)
+	}
+	return string(m.primary), string(m.alternate)
+}
+
+// next encodes the letter at i, returning the index of the next letter to
+// encode.
+func (m *metaphone) next(i int) int {
+	switch c := m.at(i); c {
+	case 'A', 'E', 'I', 'O', 'U', 'Y':
+		if i == 0 {
+			m.add("A")
+		}
+		return i + 1
+	case 'B':
+		m.add("P")
+		return m.skip(i, 'B')
+	case 'Ç':
+		m.add("S")
+		return i + 1
+	case 'C':
+		return m.c(i)
+	case 'D':
+		return m.d(i)
+	case 'F':
+		m.add("F")
+		return m.skip(i, 'F')
+	case 'G':
+		return m.g(i)
+	case 'H':
+		if (i == 0 || isMetaphoneVowel(m.at(i-1))) && isMetaphoneVowel(m.at(i+1)) {
+			m.add("H")
+			return i + 2
+		}
+		return i + 1
+	case 'J':
+		return m.j(i)
+	case 'K':
+		m.add("K")
+		return m.skip(i, 'K')
+	case 'L':
+		if m.at(i+1) == 'L' {
+			if m.spanishLL(i) {
+				m.addPrimary("L")
+			} else {
+				m.add("L")
+			}
+			return i + 2
+		}
+		m.add("L")
+		return i + 1
+	case 'M':
+		m.add("M")
+		if m.at(i+1) == 'M' || m.contains(i-1, 3, "UMB") && (i+1 == len(m.value)-1 || m.contains(i+2, 2, "ER")) {
+			return i + 2
+		}
+		return i + 1
+	case 'N':
+		m.add("N")
+		return m.skip(i, 'N')
+	case 'Ñ':
+		m.add("N")
+		return i + 1
+	case 'P':
+		if m.at(i+1) == 'H' {
+			m.add("F")
+			return i + 2
+		}
+		m.add("P")
+		if m.contains(i+1, 1, "P", "B") {
+			return i + 2
+		}
+		return i + 1
+	case 'Q':
+		m.add("K")
+		return m.skip(i, 'Q')
+	case 'R':
+		if i == len(m.value)-1 && !m.slavoGermanic && m.contains(i-2, 2, "IE") && !m.contains(i-4, 2, "ME", "MA") {
+			m.addAlternate("R")
+		} else {
+			m.add("R")
+		}
+		return m.skip(i, 'R')
+	case 'S':
+		return m.s(i)
+	case 'T':
+		return m.t(i)
+	case 'V':
+		m.add("F")
+		return m.skip(i, 'V')
+	case 'W':
+		return m.w(i)
+	case 'X':
+		if i == 0 {
+			m.add("S")
+			return i + 1
+		}
+		// French endings such as BREAUX are silent
+		if !(i == len(m.value)-1 && (m.contains(i-3, 3, "IAU", "EAU") || m.contains(i-2, 2, "AU", "OU"))) {
+			m.add("KS")
+		}
+		if m.contains(i+1, 1, "C", "X") {
+			return i + 2
+		}
+		return i + 1
+	case 'Z':
+		if m.at(i+1) == 'H' {
+			m.add("J")
+			return i + 2
+		}
+		if m.contains(i+1, 2, "ZO", "ZI", "ZA") || m.slavoGermanic && i > 0 && m.at(i-1) != 'T' {
+			m.addBoth("S", "TS")
+		} else {
+			m.add("S")
+		}
+		return m.skip(i, 'Z')
+	}
+	return i + 1
+}
+
+func (m *metaphone) c(i int) int {
+	switch {
+	case m.germanicC(i):
+		m.add("K")
+		return i + 2
+	case i == 0 && m.contains(i, 6, "CAESAR"):
+		m.add("S")
+		return i + 2
+	case m.contains(i, 2, "CH"):
+		return m.ch(i)
+	case m.contains(i, 2, "CZ") && !m.contains(i-2, 4, "WICZ"):
+		m.addBoth("S", "X")
+		return i + 2
+	case m.contains(i+1, 3, "CIA"):
+		m.add("X")
+		return i + 3
+	case m.contains(i, 2, "CC") && !(i == 1 && m.at(0) == 'M'):
+		if m.contains(i+2, 1, "I", "E", "H") && !m.contains(i+2, 2, "HU") {
+			if i == 1 && m.at(i-1) == 'A' || m.contains(i-1, 5, "UCCEE", "UCCES") {
+				m.add("KS")
+			} else {
+				m.add("X")
+			}
+			return i + 3
+		}
+		m.add("K")
+		return i + 2
+	case m.contains(i, 2, "CK", "CG", "CQ"):
+		m.add("K")
+		return i + 2
+	case m.contains(i, 2, "CI", "CE", "CY"):
+		if m.contains(i, 3, "CIO", "CIE", "CIA") {
+			m.addBoth("S", "X")
+		} else {
+			m.add("S")
+		}
+		return i + 2
+	}
+	m.add("K")
+	switch {
+	case m.contains(i+1, 2, " C", " Q", " G"):
+		return i + 3
+	case m.contains(i+1, 1, "C", "K", "Q") && !m.contains(i+1, 2, "CE", "CI"):
+		return i + 2
+	}
+	return i + 1
+}
+
+// germanicC reports whether the C at i is hard, as in BACHER.
+func (m *metaphone) germanicC(i int) bool {
+	switch {
+	case m.contains(i, 4, "CHIA"):
+		return true
+	case i <= 1 || isMetaphoneVowel(m.at(i-2)) || !m.contains(i-1, 3, "ACH"):
+		return false
+	}
+	c := m.at(i + 2)
+	return c != 'I' && c != 'E' || m.contains(i-2, 6, "BACHER", "MACHER")
+}
+
+func (m *metaphone) ch(i int) int {
+	switch {
+	case i > 0 && m.contains(i, 4, "CHAE"):
+		m.addBoth("K", "X")
+	case m.greekCH(i) || m.germanicCH(i):
+		m.add("K")
+	case i > 0 && m.contains(0, 2, "MC"):
+		m.add("K")
+	case i > 0:
+		m.addBoth("X", "K")
+	default:
+		m.add("X")
+	}
+	return i + 2
+}
+
+// greekCH reports whether the CH at the start of a word is hard, as in
+// CHARACTER and CHORUS.
+func (m *metaphone) greekCH(i int) bool {
+	return i == 0 && (m.contains(i+1, 5, "HARAC", "HARIS") || m.contains(i+1, 3, "HOR", "HYM", "HIA", "HEM")) &&
+		!m.contains(0, 5, "CHORE")
+}
+
+// germanicCH reports whether the CH at i is hard, as in ORCHESTRA and SCHOOL.
+func (m *metaphone) germanicCH(i int) bool {
+	return m.contains(0, 4, "VAN ", "VON ") || m.contains(0, 3, "SCH") ||
+		m.contains(i-2, 6, "ORCHES", "ARCHIT", "ORCHID") || m.contains(i+2, 1, "T", "S") ||
+		(m.contains(i-1, 1, "A", "O", "U", "E") || i == 0) &&
+			(m.contains(i+2, 1, "L", "R", "N", "M", "B", "H", "F", "V", "W", " ") || i+1 == len(m.value)-1)
+}
+
+func (m *metaphone) d(i int) int {
+	switch {
+	case m.contains(i, 2, "DG"):
+		if m.contains(i+2, 1, "I", "E", "Y") {
+			m.add("J")
+			return i + 3
+		}
+		m.add("TK")
+		return i + 2
+	case m.contains(i, 2, "DT", "DD"):
+		m.add("T")
+		return i + 2
+	}
+	m.add("T")
+	return i + 1
+}
+
+func (m *metaphone) g(i int) int {
+	switch {
+	case m.at(i+1) == 'H':
+		return m.gh(i)
+	case m.at(i+1) == 'N':
+		switch {
+		case i == 1 && isMetaphoneVowel(m.at(0)) && !m.slavoGermanic:
+			m.addBoth("KN", "N")
+		case !m.contains(i+2, 2, "EY") && m.at(i+1) != 'Y' && !m.slavoGermanic:
+			m.addBoth("N", "KN")
+		default:
+			m.add("KN")
+		}
+		return i + 2
+	case m.contains(i+1, 2, "LI") && !m.slavoGermanic:
+		m.addBoth("KL", "L")
+		return i + 2
+	case i == 0 && (m.at(i+1) == 'Y' || m.contains(i+1, 2, "ES", "EP", "EB", "EL", "EY", "IB", "IL", "IN", "IE", "EI", "ER")):
+		m.addBoth("K", "J")
+		return i + 2
+	case (m.contains(i+1, 2, "ER") || m.at(i+1) == 'Y') && !m.contains(0, 6, "DANGER", "RANGER", "MANGER") &&
+		!m.contains(i-1, 1, "E", "I") && !m.contains(i-1, 3, "RGY", "OGY"):
+		m.addBoth("K", "J")
+		return i + 2
+	case m.contains(i+1, 1, "E", "I", "Y") || m.contains(i-1, 4, "AGGI", "OGGI"):
+		switch {
+		case m.contains(0, 4, "VAN ", "VON ") || m.contains(0, 3, "SCH") || m.contains(i+1, 2, "ET"):
+			m.add("K")
+		case m.contains(i+1, 3, "IER"):
+			m.add("J")
+		default:
+			m.addBoth("J", "K")
+		}
+		return i + 2
+	}
+	m.add("K")
+	return m.skip(i, 'G')
+}
+
+func (m *metaphone) gh(i int) int {
+	switch {
+	case i > 0 && !isMetaphoneVowel(m.at(i-1)):
+		m.add("K")
+	case i == 0:
+		if m.at(i+2) == 'I' {
+			m.add("J")
+		} else {
+			m.add("K")
+		}
+	case i > 1 && m.contains(i-2, 1, "B", "H", "D") || i > 2 && m.contains(i-3, 1, "B", "H", "D") || i > 3 && m.contains(i-4, 1, "B", "H"):
+		// silent, as in BOUGH
+	case i > 2 && m.at(i-1) == 'U' && m.contains(i-3, 1, "C", "G", "L", "R", "T"):
+		// as in LAUGH
+		m.add("F")
+	case i > 0 && m.at(i-1) != 'I':
+		m.add("K")
+	}
+	return i + 2
+}
+
+func (m *metaphone) j(i int) int {
+	if m.contains(i, 4, "JOSE") || m.contains(0, 4, "SAN ") {
+		if i == 0 && m.at(i+4) == ' ' || len(m.value) == 4 || m.contains(0, 4, "SAN ") {
+			m.add("H")
+		} else {
+			m.addBoth("J", "H")
+		}
+		return i + 1
+	}
+	switch {
+	case i == 0:
+		m.addBoth("J", "A")
+	case isMetaphoneVowel(m.at(i-1)) && !m.slavoGermanic && (m.at(i+1) == 'A' || m.at(i+1) == 'O'):
+		m.addBoth("J", "H")
+	case i == len(m.value)-1:
+		m.addPrimary("J")
+	case !m.contains(i+1, 1, "L", "T", "K", "S", "N", "M", "B", "Z") && !m.contains(i-1, 1, "S", "K", "L"):
+		m.add("J")
+	}
+	return m.skip(i, 'J')
+}
+
+// spanishLL reports whether the LL at i is Spanish, as in CABRILLO, and so
+// has only an alternate key.
+func (m *metaphone) spanishLL(i int) bool {
+	n := len(m.value)
+	return i == n-3 && m.contains(i-1, 4, "ILLO", "ILLA", "ALLE") ||
+		(m.contains(n-2, 2, "AS", "OS") || m.contains(n-1, 1, "A", "O")) && m.contains(i-1, 4, "ALLE")
+}
+
+func (m *metaphone) s(i int) int {
+	switch {
+	case m.contains(i-1, 3, "ISL", "YSL"):
+		// silent, as in ISLAND
+		return i + 1
+	case i == 0 && m.contains(i, 5, "SUGAR"):
+		m.addBoth("X", "S")
+		return i + 1
+	case m.contains(i, 2, "SH"):
+		if m.contains(i+1, 4, "HEIM", "HOEK", "HOLM", "HOLZ") {
+			m.add("S")
+		} else {
+			m.add("X")
+		}
+		return i + 2
+	case m.contains(i, 3, "SIO", "SIA") || m.contains(i, 4, "SIAN"):
+		if m.slavoGermanic {
+			m.add("S")
+		} else {
+			m.addBoth("S", "X")
+		}
+		return i + 3
+	case i == 0 && m.contains(i+1, 1, "M", "N", "L", "W") || m.contains(i+1, 1, "Z"):
+		m.addBoth("S", "X")
+		if m.contains(i+1, 1, "Z") {
+			return i + 2
+		}
+		return i + 1
+	case m.contains(i, 2, "SC"):
+		switch {
+		case m.at(i+2) == 'H' && m.contains(i+3, 2, "ER", "EN"):
+			m.addBoth("X", "SK")
+		case m.at(i+2) == 'H' && m.contains(i+3, 2, "OO", "UY", "ED", "EM"):
+			m.add("SK")
+		case m.at(i+2) == 'H' && i == 0 && !isMetaphoneVowel(m.at(3)) && m.at(3) != 'W':
+			m.addBoth("X", "S")
+		case m.at(i+2) == 'H':
+			m.add("X")
+		case m.contains(i+2, 1, "I", "E", "Y"):
+			m.add("S")
+		default:
+			m.add("SK")
+		}
+		return i + 3
+	}
+	if i == len(m.value)-1 && m.contains(i-2, 2, "AI", "OI") {
+		// French, as in RESNAIS
+		m.addAlternate("S")
+	} else {
+		m.add("S")
+	}
+	if m.contains(i+1, 1, "S", "Z") {
+		return i + 2
+	}
+	return i + 1
+}
+
+func (m *metaphone) t(i int) int {
+	switch {
+	case m.contains(i, 4, "TION") || m.contains(i, 3, "TIA", "TCH"):
+		m.add("X")
+		return i + 3
+	case m.contains(i, 2, "TH") || m.contains(i, 3, "TTH"):
+		if m.contains(i+2, 2, "OM", "AM") || m.contains(0, 4, "VAN ", "VON ") || m.contains(0, 3, "SCH") {
+			m.add("T")
+		} else {
+			m.addBoth("0", "T")
+		}
+		return i + 2
+	}
+	m.add("T")
+	if m.contains(i+1, 1, "T", "D") {
+		return i + 2
+	}
+	return i + 1
+}
+
+func (m *metaphone) w(i int) int {
+	switch {
+	case m.contains(i, 2, "WR"):
+		m.add("R")
+		return i + 2
+	case i == 0 && (isMetaphoneVowel(m.at(i+1)) || m.contains(i, 2, "WH")):
+		if isMetaphoneVowel(m.at(i + 1)) {
+			m.addBoth("A", "F")
+		} else {
+			m.add("A")
+		}
+	case i == len(m.value)-1 && isMetaphoneVowel(m.at(i-1)) || m.contains(i-1, 5, "EWSKI", "EWSKY", "OWSKI", "OWSKY") || m.contains(0, 3, "SCH"):
+		m.addAlternate("F")
+	case m.contains(i, 4, "WICZ", "WITZ"):
+		m.addBoth("TS", "FX")
+		return i + 4
+	}
+	return i + 1
+}
+
+// at returns the letter at i, or 0 outside the word.
+func (m *metaphone) at(i int) rune {
+	if i < 0 || i >= len(m.value) {
+		return 0
+	}
+	return m.value[i]
+}
+
+// contains reports whether the n letters at i are one of options.
+func (m *metaphone) contains(i, n int, options ...string) bool {
+	if i < 0 || i+n > len(m.value) {
+		return false
+	}
+	s := string(m.value[i : i+n])
+	return slices.Contains(options, s)
+}
+
+// skip returns the index after the letter at i, and after the letter
+// following it if that is also c.
+func (m *metaphone) skip(i int, c rune) int {
+	if m.at(i+1) == c {
+		return i + 2
+	}
+	return i + 1
+}
+
+func (m *metaphone) add(s string) {
+	m.addBoth(s, s)
+}
+
+func (m *metaphone) addBoth(primary, alternate string) {
+	m.addPrimary(primary)
+	m.addAlternate(alternate)
+}
+
+func (m *metaphone) addPrimary(s string) {
+	m.primary = appendKey(m.primary, s)
+}
+
+func (m *metaphone) addAlternate(s string) {
+	m.alternate = appendKey(m.alternate, s)
+}
+
+func appendKey(key []rune, s string) []rune {
+	for _, r := range s {
+		if len(key) == metaphoneLength {
+			break
+		}
+		key = append(key, r)
+	}
+	return key
+}
+
+func isMetaphoneVowel(r rune) bool {
+	return strings.ContainsRune("AEIOUY", r)
+}
@@ -0,0 +1,65 @@
+package chapointdat
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_Soundex(t *testing.T) {
+	for word, expected := range map[string]string{
+		"Robert": "R163", "RUPERT": "R163", "Ashcraft": "A261", "Tymczak": "T522", "Pfister": "P236",
+		"Honeyman": "H555", "LEE": "L000", "O'NEILL": "O540", "": "", "123": "",
+	} {
+		if s := Soundex(word); s != expected {
+			t.Errorf("expected %q for %q got %q", expected, word, s)
+		}
+	}
+}
+
+func Test_DoubleMetaphone(t *testing.T) {
+	for word, expected := range map[string][2]string{
+		"SMITH": {"SM0", "XMT"}, "Schmidt": {"XMT", "SMT"}, "WILLIAMS": {"ALMS", "FLMS"}, "JOHNSON": {"JNSN", "ANSN"},
+		"XAVIER": {"SF", "SFR"}, "KNIGHT": {"NT", "NT"}, "CAESAR": {"SSR", "SSR"}, "JOSE": {"HS", "HS"},
+		"CHARACTER": {"KRKT", "KRKT"}, "GALLAGHER": {"KLKR", "KLKR"}, "LAUGH": {"LF", "LF"}, "ORCHESTRA": {"ARKS", "ARKS"},
+		"MCDONALD": {"MKTN", "MKTN"}, "DUMB": {"TM", "TM"}, "FOCACCIA": {"FKX", "FKX"}, "ARNOW": {"ARN", "ARNF"},
+		"SUGAR": {"XKR", "SKR"}, "ISLAND": {"ALNT", "ALNT"}, "GNOME": {"NM", "NM"}, "CABRILLO": {"KPRL", "KPR"},
+		"BREAUX": {"PR", "PR"}, "": {"", ""},
+	} {
+		if p, a := DoubleMetaphone(word); p != expected[0] || a != expected[1] {
+			t.Errorf("expected %v for %q got %s %s", expected, word, p, a)
+		}
+	}
+}
+
+func Test_PhoneticKeys(t *testing.T) {
+	k := NamePhoneticKeys("Smith Jones")
+	if k.Soundex != "S530 J520" || k.Metaphone != "SM0 JNS" || k.MetaphoneAlt != "XMT ANS" {
+		t.Errorf("unexpected keys %+v", k)
+	}
+	if !(Person{Surname: "SMYTH"}).PhoneticKeys().Matches(Person{Surname: "SMITH"}.PhoneticKeys()) {
+		t.Error("expected SMYTH to match SMITH")
+	}
+	if (Person{Surname: "JONES"}).PhoneticKeys().Matches(Person{Surname: "SMITH"}.PhoneticKeys()) {
+		t.Error("expected JONES not to match SMITH")
+	}
+
+	var people []Person
+	var companies []Company
+	r := NewReader(WithPhoneticKeys(), WithPersonHandler(func(p Person) error {
+		people = append(people, p)
+		return nil
+	}), WithCompanyHandler(func(c Company) error {
+		companies = append(companies, c)
+		return nil
+	}))
+	if _, err := r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine, testPersonLine)), "-", func(err error) { t.Error(err) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(people) != 1 || people[0].Phonetic == nil || people[0].Phonetic.Soundex != "K626" {
+		t.Errorf("unexpected people %+v", people)
+	}
+	// the keys of A WEST, without & PARTNERS
+	if len(companies) != 1 || companies[0].Phonetic == nil || companies[0].Phonetic.Metaphone != "A AST" {
+		t.Errorf("unexpected companies %+v", companies)
+	}
+}
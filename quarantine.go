@@ -0,0 +1,33 @@
+package chapointdat
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+type quarantine struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// WithQuarantine writes the original bytes of every line passed to the error
+// handler to w, one per line, so that rejected records can be inspected and
+// re-run once the cause is fixed. Nothing else is written, so the output has
+// no header or trailer of its own. It is the simplest DeadLetterSink; use
+// NewFileDeadLetterSink to keep the error and position of each line as well.
+func WithQuarantine(w io.Writer) Opt {
+	return WithDeadLetterSink(&quarantine{w: w})
+}
+
+func (q *quarantine) WriteDeadLetter(d DeadLetter) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, err := q.w.Write(d.Raw); err != nil {
+		return fmt.Errorf("error writing quarantine: %w", err)
+	}
+	if _, err := q.w.Write([]byte("\n")); err != nil {
+		return fmt.Errorf("error writing quarantine: %w", err)
+	}
+	return nil
+}
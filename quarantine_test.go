@@ -0,0 +1,57 @@
+package chapointdat
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Quarantine_RejectedLines(t *testing.T) {
+	bad := "101222052301207115400002 20160413 WA11 RLÆ197908 0098MR<DAVID<SEOW<<<<840 IBIS COURT CENTRE PARK<<WARRINGTON<CHESHIRE<ENGLAND<DIRECTOR<BRITISH<ENGLAND<"
+	path := writeTestZip(t, "a.zip", testSnapshot(testCompanyLine, bad, testPersonLine))
+	var q bytes.Buffer
+	r := NewReader(WithQuarantine(&q))
+	var errs int
+	if err := r.Extract(path, 1, func(err error) { errs++ }); err != nil {
+		t.Fatal(err)
+	}
+	// the bad line and the trailer, whose count then no longer matches
+	expected := bad + "\n" + trailerRecordIdentifier + "00000003\n"
+	if q.String() != expected {
+		t.Errorf("expected quarantine %q got %q", expected, q.String())
+	}
+	if errs != 2 {
+		t.Errorf("expected 2 errors got %d", errs)
+	}
+}
+
+func Test_Quarantine_Rerun(t *testing.T) {
+	path := writeTestZip(t, "a.zip", testSnapshot(testCompanyLine, testPersonLine))
+	q, err := os.Create(filepath.Join(t.TempDir(), "quarantine.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewReader(WithQuarantine(q), WithPersonHandler(func(p Person) error {
+		return errors.New("not yet")
+	}))
+	if err := r.Extract(path, 1, func(err error) {}); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var persons int
+	r = NewReader(WithRelaxedFraming(), WithPersonHandler(func(p Person) error {
+		persons++
+		return nil
+	}))
+	if err := r.Extract(q.Name(), 1, func(err error) { t.Error(err) }); err != nil {
+		t.Fatal(err)
+	}
+	if persons != 1 {
+		t.Errorf("expected 1 person got %d", persons)
+	}
+}
@@ -0,0 +1,81 @@
+package chapointdat
+
+import "sync/atomic"
+
+type (
+	// QueueStats measure one of the queues between goroutines used by
+	// WithWorkers and WithOrderedDelivery, for tuning WithQueueSize.
+	QueueStats struct {
+		Capacity int
+		// MaxDepth is the most items queued at once.
+		MaxDepth int
+		// Full is the number of items queued while the queue was full, each
+		// holding up the producer until a consumer caught up, as with a slow
+		// sink.
+		Full int
+		// Empty is the number of times a consumer found the queue empty and
+		// waited for the producer, as with a slow disk.
+		Empty int
+	}
+
+	queueMetrics struct {
+		capacity    int
+		maxDepth    atomic.Int64
+		full, empty atomic.Int64
+	}
+)
+
+// WithQueueSize sets the number of items each internal queue holds, which is
+// otherwise 10 for each goroutine consuming it. Larger queues smooth out
+// bursts from fast or slow sinks at the cost of memory. The QueueStats of each
+// queue are reported in Summary.Queues.
+func WithQueueSize(n int) Opt {
+	return func(r *Reader) {
+		r.queueSize = n
+	}
+}
+
+// queueCapacity returns the size of a queue consumed by n goroutines.
+func (r *Reader) queueCapacity(n int) int {
+	if r.queueSize > 0 {
+		return r.budgetQueue(r.queueSize)
+	}
+	return r.budgetQueue(n * 10)
+}
+
+// send queues v, recording whether the queue was full.
+func send[T any](m *queueMetrics, c chan<- T, v T) {
+	if len(c) == cap(c) {
+		m.full.Add(1)
+	}
+	c <- v
+	depth := int64(len(c))
+	for d := m.maxDepth.Load(); depth > d && !m.maxDepth.CompareAndSwap(d, depth); d = m.maxDepth.Load() {
+	}
+}
+
+// receive takes from c as a range over it would, recording whether the queue
+// was empty.
+func receive[T any](m *queueMetrics, c <-chan T) (T, bool) {
+	empty := len(c) == 0
+	v, ok := <-c
+	// a closed queue is not waiting for the producer
+	if empty && ok {
+		m.empty.Add(1)
+	}
+	return v, ok
+}
+
+func (m *queueMetrics) stats() QueueStats {
+	return QueueStats{Capacity: m.capacity, MaxDepth: int(m.maxDepth.Load()), Full: int(m.full.Load()), Empty: int(m.empty.Load())}
+}
+
+// addQueueStats combines the stats of the same queue in different files.
+func addQueueStats(m map[string]QueueStats, k string, s QueueStats) map[string]QueueStats {
+	if m == nil {
+		m = map[string]QueueStats{}
+	}
+	o := m[k]
+	m[k] = QueueStats{Capacity: max(s.Capacity, o.Capacity), MaxDepth: max(s.MaxDepth, o.MaxDepth), Full: s.Full + o.Full, Empty: s.Empty + o.Empty}
+	return m
+}
@@ -0,0 +1,35 @@
+package chapointdat
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_QueueSize(t *testing.T) {
+	var b strings.Builder
+	if err := GenerateSnapshot(&b, 20, 1); err != nil {
+		t.Fatal(err)
+	}
+	// a slow person handler fills the single slot of the queue
+	r := NewReader(WithQueueSize(1), WithWorkers(RecordKindPerson, 1), WithPersonHandler(func(p Person) error {
+		time.Sleep(time.Millisecond)
+		return nil
+	}))
+	s, err := r.ExtractReader(strings.NewReader(b.String()), "-", func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, ok := s.Queues["person workers"]
+	if !ok || q.Capacity != 1 || q.MaxDepth != 1 || q.Full == 0 {
+		t.Errorf("unexpected queue stats %+v", s.Queues)
+	}
+
+	r = NewReader(WithOrderedDelivery(2))
+	if s, err = r.ExtractReader(strings.NewReader(b.String()), "-", func(err error) { t.Error(err) }); err != nil {
+		t.Fatal(err)
+	}
+	if s.Queues["parse"].Capacity != 20 || s.Queues["deliver"].Capacity != 20 {
+		t.Errorf("expected the default capacity got %+v", s.Queues)
+	}
+}
@@ -0,0 +1,44 @@
+package chapointdat
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// WithRateLimit delivers at most recordsPerSecond records to the handlers and
+// sinks, so that loading a snapshot does not swamp a shared downstream system.
+// Records are spaced evenly rather than released in bursts, and the limit is
+// shared by all workers started with WithWorkers.
+func WithRateLimit(recordsPerSecond float64) Opt {
+	return func(r *Reader) {
+		if recordsPerSecond <= 0 {
+			r.rateLimit = nil
+			return
+		}
+		r.rateLimit = &rateLimiter{interval: time.Duration(float64(time.Second) / recordsPerSecond)}
+	}
+}
+
+// wait blocks until the next record may be delivered, returning early once the
+// Reader is stopped.
+func (l *rateLimiter) wait(stopped *atomic.Bool) {
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		// time spent idle is not saved up for a burst later
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+	for d := at.Sub(now); d > 0 && !stopped.Load(); d = time.Until(at) {
+		time.Sleep(min(d, 100*time.Millisecond))
+	}
+}
@@ -0,0 +1,41 @@
+package chapointdat
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_RateLimit(t *testing.T) {
+	calls := 0
+	r := NewReader(WithRateLimit(100), WithCompanyHandler(func(c Company) error {
+		calls++
+		return nil
+	}))
+	start := time.Now()
+	for range 11 {
+		if err := r.line([]byte(testCompanyLine), &state{i: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("expected 11 records at 100/s to take at least 100ms, took %s", elapsed)
+	}
+	if calls != 11 {
+		t.Errorf("expected 11 calls got %d", calls)
+	}
+}
+
+func Test_RateLimit_Stopped(t *testing.T) {
+	r := NewReader(WithRateLimit(0.1))
+	if err := r.line([]byte(testCompanyLine), &state{i: 1}); err != nil {
+		t.Fatal(err)
+	}
+	r.Stop()
+	start := time.Now()
+	if err := r.line([]byte(testCompanyLine), &state{i: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected a stopped reader not to wait, waited %s", elapsed)
+	}
+}
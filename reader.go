@@ -3,11 +3,18 @@ package chapointdat
 import (
 	"archive/zip"
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"golang.org/x/sync/errgroup"
+	"io"
+	"iter"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -15,7 +22,9 @@ const (
 	companyRecordType        = "1"
 	personRecordType         = "2"
 	snapshotHeaderIdentifier = "DDDDSNAP"
+	headerIdentifierPrefix   = "DDDD"
 	trailerRecordIdentifier  = "99999999"
+	zipMagic                 = "PK\x03\x04"
 
 	PrefixSC = Prefix("SC")
 	PrefixSZ = Prefix("SZ")
@@ -36,10 +45,64 @@ const (
 	StatusR = Status("R")
 )
 
+// ErrStaleSnapshot is wrapped by the error for a header older than allowed by
+// WithMaxHeaderAge.
+var ErrStaleSnapshot = errors.New("stale snapshot")
+
+// errVariableDataLength is wrapped by the error for a person row whose
+// variable data length is not a number.
+var errVariableDataLength = errors.New("error reading variable data length")
+
+// ErrCompanyName matches, with errors.Is, a CompanyNameError.
+var ErrCompanyName = errors.New("invalid company name")
+
+// CompanyNameError is returned for a company row whose name length does not
+// end at a "<" terminator, rather than guessing where the name ends.
+type CompanyNameError struct {
+	CompanyNumber string
+	// NameLength is the stated length of the name including its terminator.
+	NameLength int
+	// Raw is the bytes of the row from the start of the name.
+	Raw []byte
+}
+
+func (e *CompanyNameError) Error() string {
+	return fmt.Sprintf("company %s name length %d does not end at a \"<\" terminator in %q", e.CompanyNumber, e.NameLength, e.Raw)
+}
+
+func (e *CompanyNameError) Is(target error) bool {
+	return target == ErrCompanyName
+}
+
+// WithRecordHandler calls p with every parsed record, after the handler for
+// its type.
+func WithRecordHandler(p func(rec Record) error) Opt {
+	return func(r *Reader) {
+		r.sinks = append(r.sinks, p)
+	}
+}
+
 type (
 	Header struct {
+		/*
+		   Header identifier, “DDDDSNAP” for a snapshot.
+		*/
+		Identifier,
+
+		/*
+		   The run type from the last 4 characters of the header identifier,
+		   “SNAP” for a snapshot.
+		*/
+		RunType string
+
 		Run      int
 		ProdDate time.Time
+
+		/*
+		   Any characters following the production date, which pad the header to
+		   the record length.
+		*/
+		Filler string
 	}
 	Footer struct {
 		RecordCount int
@@ -138,9 +201,23 @@ type (
 		Title, Forenames, Surname,
 		Honours, CareOf, PoBox, AddressLine1, AddressLine2, PostTown,
 		County, Country, Occupation, Nationality, ResCountry string
+
+		// Truncated is set when the variable data may have been cut short by
+		// the product, as it or one of its fields reaches the maximum length
+		// of the specification, so that names and addresses may be
+		// incomplete. It is not part of the Schema.
+		Truncated bool `json:",omitempty" schema:"-"`
+		// Phonetic is the PhoneticKeys of Surname when reading
+		// WithPhoneticKeys.
+		Phonetic *PhoneticKeys `json:",omitempty" schema:"-"`
 	}
 	Company struct {
+		/*
+		   The majority of company numbers are 8 digit numeric;
+		   however, some consist of a prefix followed by digits.
+		*/
 		CompanyNumber,
+
 		/*
 		   “C”	  Converted/closed company
 		   “D”	  Dissolved company
@@ -149,8 +226,21 @@ type (
 		   Space  None of the above categories
 		*/
 		CompanyStatus,
+
+		/*
+		   The number of officers that follow the company record, as a 4 digit
+		   integer.
+		*/
 		NumberOfOfficers,
+
 		CompanyName string
+
+		// NormalizedName is CompanyName normalized by NormalizeCompanyName
+		// when reading WithNormalizedNames. It is not part of the Schema, so
+		// is left out of CSV and SQL exports.
+		NormalizedName string `json:",omitempty" schema:"-"`
+		// Phonetic is set when reading WithPhoneticKeys.
+		Phonetic *PhoneticKeys `json:",omitempty" schema:"-"`
 	}
 	Prefix string
 	Status string
@@ -159,6 +249,50 @@ type (
 		companyHandler func(company Company) error
 		headerHandler  func(header Header) error
 		footerHandler  func(footer Footer) error
+		warningHandler func(w Warning) error
+		companyStream  func(c Company, officers iter.Seq[Person]) error
+		charsetHandler func(v CharsetViolation) error
+		invalidBytes   InvalidBytesPolicy
+		selected       func(rec Record) bool
+		queueSize      int
+		normalizeNames bool
+		phoneticKeys   bool
+		companyNumbers CompanyNumberFormat
+		transforms     *Transforms
+		corrections    *correcting
+		now            func() time.Time
+		sink           *channelSink
+		sinks          []func(rec Record) error
+		recordTypes    map[string]recordType
+		deadLetters    []DeadLetterSink
+		workers        map[RecordKind]*workerPool
+		workerCounts   map[RecordKind]int
+		parsers        int
+		seq            atomic.Uint64
+		index          *indexWriter
+		disappeared    *disappeared
+		relaxedFraming bool
+		mmap           bool
+		maxHeaderAge   time.Duration
+		expectedParts  int
+		flushers       []func() error
+		retryAttempts  int
+		retryBackoff   time.Duration
+		rateLimit      *rateLimiter
+		escaping       ExportEscaping
+		memoryBudget   int64
+		cp1252Fallback bool
+		companyFilter  []string
+		inputHooks     []InputHook
+		entryFilter    *EntryFilter
+		// holdsRecords names an option holding every record in memory.
+		holdsRecords string
+
+		stopped           atomic.Bool
+		checkpointHandler func(checkpoint Checkpoint) error
+		resume            map[string]FileCheckpoint
+		mu                sync.Mutex
+		progress          map[string]FileCheckpoint
 	}
 	Opt func(r *Reader)
 )
@@ -187,12 +321,33 @@ func WithFooterHandler(p func(footer Footer) error) Opt {
 	}
 }
 
+// WithMaxHeaderAge rejects files whose header production date is more than d
+// before now, as given by WithClock. Reading of a stale file stops after its
+// header and the error, which wraps ErrStaleSnapshot, is returned as well as
+// passed to the error handler.
+func WithMaxHeaderAge(d time.Duration) Opt {
+	return func(r *Reader) {
+		r.maxHeaderAge = d
+	}
+}
+
+// WithRelaxedFraming reads files that need not start with a header or end in a
+// trailer, such as those written by WithQuarantine. Header and trailer lines
+// are still recognised wherever they occur, but trailer record counts are not
+// checked.
+func WithRelaxedFraming() Opt {
+	return func(r *Reader) {
+		r.relaxedFraming = true
+	}
+}
+
 func NewReader(opts ...Opt) *Reader {
 	r := &Reader{
 		personHandler:  func(p Person) error { return nil },
 		companyHandler: func(c Company) error { return nil },
 		headerHandler:  func(h Header) error { return nil },
 		footerHandler:  func(f Footer) error { return nil },
+		now:            time.Now,
 	}
 	for _, opt := range opts {
 		opt(r)
@@ -201,108 +356,616 @@ func NewReader(opts ...Opt) *Reader {
 }
 
 func (r *Reader) Extract(path string, concurrency int, errH func(err error)) error {
-	z, err := zip.OpenReader(path)
-	if err != nil {
+	if err := r.start(errH); err != nil {
 		return err
 	}
-	defer func() { _ = z.Close() }()
+	s, err := r.extractPath(path, concurrency, errH)
+	return errors.Join(err, r.finish(&s))
+}
 
-	for _, f := range z.File {
-		var i, companiesProcessed, personsProcessed int
-		zf, err := f.Open()
+// ExtractAll processes each snapshot file in paths, up to concurrency files at
+// a time, returning a Summary combined across all of them. Handlers are shared
+// between files so must be safe for concurrent use when concurrency > 1. Unless
+// framing is relaxed the combined Summary is validated once every file has been
+// read, so that totals which do not add up across parts are reported even when
+// each file's own trailer matched.
+func (r *Reader) ExtractAll(paths []string, concurrency int, errH func(err error)) (Summary, error) {
+	if err := r.start(errH); err != nil {
+		return Summary{}, err
+	}
+	if r.expectedParts > 0 {
+		err := r.rawInput("WithExpectedParts")
+		var parts []Part
+		if err == nil {
+			parts, err = listParts(paths, r.readEntry)
+		}
+		if err == nil {
+			err = CheckParts(parts, r.expectedParts)
+		}
 		if err != nil {
+			return Summary{}, errors.Join(err, r.finish(nil))
+		}
+	}
+	var mu sync.Mutex
+	var summary Summary
+	eg := errgroup.Group{}
+	eg.SetLimit(r.budgetConcurrency(concurrency))
+	for _, path := range paths {
+		eg.Go(func() error {
+			s, err := r.extractPath(path, 1, errH)
+			mu.Lock()
+			summary.Merge(s)
+			mu.Unlock()
+			if err != nil && !errors.Is(err, ErrStopped) {
+				return fmt.Errorf("error extracting %s: %w", path, err)
+			}
+			return err
+		})
+	}
+	err := eg.Wait()
+	if err == nil && !r.relaxedFraming {
+		err = summary.Validate()
+	}
+	return summary, errors.Join(err, r.finish(&summary))
+}
+
+// ExtractReader processes a single stream of lines from rd, such as os.Stdin
+// in a shell pipeline, using name to identify it in checkpoints. A zip archive
+// cannot be read as a stream, so if rd turns out to be one it is first copied
+// to a temporary file.
+func (r *Reader) ExtractReader(rd io.Reader, name string, errH func(err error)) (Summary, error) {
+	if err := r.start(errH); err != nil {
+		return Summary{}, err
+	}
+	s, err := r.extractStream(rd, name, name, 1, errH)
+	return s, errors.Join(err, r.finish(&s))
+}
+
+// ExtractReaderAt reads a zip or an uncompressed .dat file of size bytes from
+// ra, processing up to concurrency zip entries at once. Unlike ExtractReader
+// nothing is copied to a temporary file, so ra may read directly from remote
+// storage, as the objstore package does.
+func (r *Reader) ExtractReaderAt(ra io.ReaderAt, size int64, name string, concurrency int, errH func(err error)) (Summary, error) {
+	if err := r.start(errH); err != nil {
+		return Summary{}, err
+	}
+	s, err := r.extractReaderAt(ra, size, name, concurrency, errH)
+	return s, errors.Join(err, r.finish(&s))
+}
+
+func (r *Reader) extractReaderAt(ra io.ReaderAt, size int64, name string, concurrency int, errH func(err error)) (Summary, error) {
+	if len(r.inputHooks) > 0 {
+		return r.extractStream(io.NewSectionReader(ra, 0, size), name, name, concurrency, errH)
+	}
+	magic := make([]byte, len(zipMagic))
+	if _, err := ra.ReadAt(magic, 0); err != nil || string(magic) != zipMagic {
+		return r.extractEntry(io.NewSectionReader(ra, 0, size), name, name, errH)
+	}
+	z, err := zip.NewReader(ra, size)
+	if err != nil {
+		return Summary{}, err
+	}
+	return r.extractZip(z, name, concurrency, errH)
+}
+
+// extractStream reads rd, unwrapped by any input hooks, as a zip of .dat
+// files or as the entry of path if it is not a zip.
+func (r *Reader) extractStream(rd io.Reader, path, entry string, concurrency int, errH func(err error)) (s Summary, err error) {
+	rd, closeInput, err := r.unwrap(rd, path)
+	if err != nil {
+		return Summary{}, err
+	}
+	defer func() { err = errors.Join(err, closeInput()) }()
+	br := bufio.NewReader(rd)
+	magic, _ := br.Peek(len(zipMagic))
+	if string(magic) != zipMagic {
+		return r.extractEntry(br, path, entry, errH)
+	}
+	tmp, err := os.CreateTemp("", "chapointdat-*.zip")
+	if err != nil {
+		return Summary{}, err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	size, err := io.Copy(tmp, br)
+	if err != nil {
+		return Summary{}, fmt.Errorf("error copying zip to temporary file: %w", err)
+	}
+	z, err := zip.NewReader(tmp, size)
+	if err != nil {
+		return Summary{}, err
+	}
+	return r.extractZip(z, path, concurrency, errH)
+}
+
+// start is called before any lines are read, returning an error if the
+// options cannot be used together.
+func (r *Reader) start(errH func(err error)) error {
+	if r.entryFilter != nil {
+		if err := r.entryFilter.Validate(); err != nil {
 			return err
 		}
-		lineChan := make(chan []byte, concurrency*10)
-		doneChan := make(chan bool)
-		worker := func() error {
-			for {
-				select {
-				case <-doneChan:
-					for range concurrency - 1 {
-						doneChan <- true
-					}
-					return nil
-
-				case line := <-lineChan:
-					if err := r.line(line, i, &personsProcessed, &companiesProcessed); err != nil {
-						errH(fmt.Errorf("error: %w handling line: %s", err, string(line)))
-					}
-				}
+	}
+	if err := r.startBudget(); err != nil {
+		return err
+	}
+	r.progress = map[string]FileCheckpoint{}
+	if r.sink != nil {
+		r.sink.start(r.budgetQueue(r.sink.size))
+	}
+	r.startWorkers(errH)
+	return nil
+}
+
+// finish is called once all lines have been read, or reading has stopped. It
+// waits for workers, delivers the companies added by WithCorrections, flushes
+// sinks and flush handlers and then writes a checkpoint. Errors from workers
+// are added to s if it is not nil.
+func (r *Reader) finish(s *Summary) error {
+	defer r.finishBudget()
+	var errs []error
+	workerErrors, queues := r.stopWorkers()
+	if s != nil {
+		s.Errors += workerErrors
+		for k, q := range queues {
+			s.Queues = addQueueStats(s.Queues, k, q)
+		}
+	}
+	if r.corrections != nil && !r.stopped.Load() {
+		if err := r.deliverCorrections(s); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if r.sink != nil {
+		r.sink.close()
+	}
+	if r.disappeared != nil && !r.stopped.Load() {
+		if err := r.disappeared.flush(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, f := range r.flushers {
+		if err := f(); err != nil {
+			errs = append(errs, fmt.Errorf("error flushing: %w", err))
+		}
+	}
+	if r.checkpointHandler != nil {
+		if err := r.checkpointHandler(r.checkpoint()); err != nil {
+			errs = append(errs, fmt.Errorf("error processing checkpoint handler: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// extractPath reads path as a zip of .dat files, or as a single .dat file if
+// it is not a zip.
+func (r *Reader) extractPath(path string, concurrency int, errH func(err error)) (Summary, error) {
+	if r.stopped.Load() {
+		return Summary{}, ErrStopped
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return Summary{}, err
+	}
+	defer func() { _ = f.Close() }()
+	if len(r.inputHooks) > 0 {
+		return r.extractStream(f, path, filepath.Base(path), concurrency, errH)
+	}
+	magic := make([]byte, len(zipMagic))
+	if _, err := io.ReadFull(f, magic); err != nil || string(magic) != zipMagic {
+		if r.mmap {
+			return r.extractMmap(f, path, errH)
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return Summary{}, err
+		}
+		return r.extractEntry(f, path, filepath.Base(path), errH)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return Summary{}, err
+	}
+	z, err := zip.NewReader(f, fi.Size())
+	if err != nil {
+		return Summary{}, err
+	}
+	return r.extractZip(z, path, concurrency, errH)
+}
+
+func (r *Reader) extractZip(z *zip.Reader, path string, concurrency int, errH func(err error)) (Summary, error) {
+	var mu sync.Mutex
+	var summary Summary
+
+	eg := errgroup.Group{}
+	eg.SetLimit(r.budgetConcurrency(concurrency))
+	for _, f := range z.File {
+		if !r.readEntry(f.Name) {
+			continue
+		}
+		eg.Go(func() error {
+			if r.stopped.Load() {
+				return ErrStopped
+			}
+			zf, err := f.Open()
+			if err != nil {
+				return err
 			}
+			defer func() { _ = zf.Close() }()
+			s, err := r.extractEntry(zf, path, f.Name, errH)
+			mu.Lock()
+			summary.Merge(s)
+			mu.Unlock()
+			return err
+		})
+	}
+	err := eg.Wait()
+	return summary, err
+}
+
+func (r *Reader) extractEntry(rd io.Reader, path, entry string, errH func(err error)) (Summary, error) {
+	st, skip, done := r.entryState(path, entry)
+	if done {
+		return st.summary, nil
+	}
+	return r.extractLines(newScanner(rd, 0), st, path, skip, errH)
+}
+
+// lineScanner scans lines, reporting the byte offset of the start of the line
+// last scanned.
+type lineScanner interface {
+	Scan() bool
+	Bytes() []byte
+	Offset() int64
+	Err() error
+}
+
+func (r *Reader) extractLines(scan lineScanner, st *state, path string, skip int, errH func(err error)) (Summary, error) {
+	if r.cp1252Fallback {
+		scan = &decodingScanner{lineScanner: scan, st: st}
+	}
+	if r.corrections != nil {
+		scan = r.corrections.scanner(scan, st)
+	}
+	if r.parsers > 0 {
+		return r.extractLinesOrdered(scan, st, path, skip, errH)
+	}
+	stopped := false
+	for scan.Scan() {
+		if st.i < skip {
+			st.i++
+			continue
+		}
+		if r.stopped.Load() {
+			stopped = true
+			break
 		}
-		eg := errgroup.Group{}
-		for range concurrency {
-			eg.Go(worker)
+		st.line = st.i + 1
+		st.offset = scan.Offset()
+		st.seq = r.seq.Add(1)
+		if err := r.handleLine(scan.Bytes(), st, errH); err != nil {
+			r.endStream(st, errH)
+			st.summary.Lines = st.i + 1
+			return st.summary, err
+		}
+		st.i++
+	}
+	return r.endLines(scan, st, path, stopped, errH)
+}
+
+// endLines records progress once reading of a file has finished or stopped.
+func (r *Reader) endLines(scan lineScanner, st *state, path string, stopped bool, errH func(err error)) (Summary, error) {
+	r.endStream(st, errH)
+	st.summary.Lines = st.i
+	r.recordProgress(FileCheckpoint{Path: path, Entry: st.entry, Line: st.i, Complete: !stopped, Summary: st.summary})
+	if stopped {
+		return st.summary, ErrStopped
+	}
+	return st.summary, scan.Err()
+}
+
+// entryState returns the state to start reading entry with and the number of
+// lines to skip, or done if the entry was completed by a resumed checkpoint.
+func (r *Reader) entryState(path, entry string) (st *state, skip int, done bool) {
+	st = &state{path: path, entry: entry, summary: Summary{Files: 1}}
+	if fc, ok := r.resume[checkpointKey(path, entry)]; ok {
+		if fc.Complete {
+			r.recordProgress(fc)
+			st.summary = fc.Summary
+			return st, 0, true
 		}
-		scan := bufio.NewScanner(zf)
-		for scan.Scan() {
-			line := scan.Bytes()
-			if err := r.line(line, i, &personsProcessed, &companiesProcessed); err != nil {
-				errH(fmt.Errorf("error: %w handling line: %s", err, string(line)))
+		st.summary = fc.Summary
+		skip = fc.Line
+	}
+	return st, skip, false
+}
+
+// reportError writes line, read at the position in d, to the dead letter
+// sinks and passes err to errH.
+func (r *Reader) reportError(d DeadLetter, line []byte, err error, errH func(err error)) {
+	if len(r.deadLetters) > 0 {
+		d.Raw = append([]byte(nil), line...)
+		d.Err = err
+		d.Time = r.now()
+		for _, s := range r.deadLetters {
+			if werr := s.WriteDeadLetter(d); werr != nil {
+				err = errors.Join(err, fmt.Errorf("error writing dead letter: %w", werr))
 			}
-			i++
 		}
-		doneChan <- true
-		if err := eg.Wait(); err != nil {
-			return err
+	}
+	errH(fmt.Errorf("error: %w handling line: %s", err, string(line)))
+}
+
+// newScanner returns a lineScanner for rd which starts at offset.
+func newScanner(rd io.Reader, offset int64) *offsetScanner {
+	s := &offsetScanner{Scanner: bufio.NewScanner(rd)}
+	pos := offset
+	s.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		if token != nil {
+			s.offset = pos
 		}
+		pos += int64(advance)
+		return advance, token, err
+	})
+	return s
+}
+
+type offsetScanner struct {
+	*bufio.Scanner
+	offset int64
+}
+
+func (s *offsetScanner) Offset() int64 {
+	return s.offset
+}
+
+// handleLine processes a line, passing any error to errH. Only errors that
+// should stop reading the file are returned.
+func (r *Reader) handleLine(line []byte, st *state, errH func(err error)) error {
+	if r.skipLine(line, st) {
+		return nil
+	}
+	rec, err := r.parse(line, st.first(st.i))
+	return r.handleParsed(line, rec, err, st, errH)
+}
+
+// handleParsed records rec, or handles err from parsing it, in the same way as
+// handleLine.
+func (r *Reader) handleParsed(line []byte, rec Record, err error, st *state, errH func(err error)) error {
+	if r.charsetHandler != nil {
+		if cerr := r.checkCharset(line, st); cerr != nil {
+			err = cerr
+		}
+	}
+	if err == nil && r.invalidBytes != InvalidBytesKeep {
+		var n int
+		n, err = r.fixInvalidBytes(rec)
+		st.summary.InvalidBytes += n
+	}
+	if err == nil {
+		st.summary.repaired(rec)
+	}
+	if err == nil && rec.warning != nil {
+		err = r.warn(rec.warning, st)
+	}
+	if err == nil {
+		err = r.record(rec, line, st)
+	}
+	if err == nil {
+		if r.companyStream != nil {
+			r.stream(rec, line, st, errH)
+		}
+		return nil
+	}
+	st.summary.Errors++
+	r.reportError(st.deadLetter(), line, err, errH)
+	if errors.Is(err, ErrStaleSnapshot) {
+		return fmt.Errorf("error processing %s: %w", st.entry, err)
 	}
 	return nil
 }
 
-func (r *Reader) line(line []byte, i int, pt, ct *int) error {
-	if i == 0 {
+func (r *Reader) line(line []byte, st *state) error {
+	rec, err := r.parse(line, st.first(st.i))
+	if err != nil {
+		return err
+	}
+	return r.record(rec, line, st)
+}
+
+// parse parses a line without reference to the lines around it, other than
+// whether it is the first line of the file, so may be called concurrently.
+func (r *Reader) parse(line []byte, first bool) (Record, error) {
+	if first && !r.relaxedFraming || r.relaxedFraming && strings.HasPrefix(string(line), headerIdentifierPrefix) {
 		h, err := r.headerRow(line)
 		if err != nil {
-			return fmt.Errorf("error processing header row: %w", err)
-		}
-		if err := r.headerHandler(h); err != nil {
-			return fmt.Errorf("error processing header handler: %w", err)
+			return Record{}, fmt.Errorf("error processing header row: %w", err)
 		}
+		return Record{Kind: RecordKindHeader, Header: &h}, nil
+	} else if len(line) < 9 {
+		return Record{}, fmt.Errorf("line of %d bytes is too short for a record", len(line))
 	} else if trailerRecordIdentifier == string(line[0:8]) {
+		if len(line) < 16 {
+			return Record{}, fmt.Errorf("trailer record row of %d bytes is too short", len(line))
+		}
 		recordCount, err := strconv.Atoi(strings.TrimSpace(string(line[8:16])))
 		if err != nil {
-			return fmt.Errorf("error processing trailer record row: %w", err)
+			return Record{}, fmt.Errorf("error processing trailer record row: %w", err)
 		}
-		if err := r.footerHandler(Footer{RecordCount: recordCount}); err != nil {
-			return fmt.Errorf("error processing footer handler: %w", err)
-		}
-		if recordCount != *ct+*pt {
-			return fmt.Errorf("unexpected number of records: %d", recordCount)
+		return Record{Kind: RecordKindFooter, Footer: &Footer{RecordCount: recordCount}}, nil
+	} else if rt, ok := r.recordTypes[string(line[8])]; ok {
+		v, err := rt.parse(line)
+		if err != nil {
+			return Record{}, fmt.Errorf("error processing record type %s row: %w", string(line[8]), err)
 		}
+		return Record{Kind: RecordKindCustom, CustomType: string(line[8]), Custom: v}, nil
 	} else if string(line[8]) == companyRecordType {
 		company, err := r.companyRow(line)
 		if err != nil {
-			return fmt.Errorf("error processing Company row: %w", err)
+			return Record{}, fmt.Errorf("error processing Company row: %w", err)
 		}
-		*ct++
-		if err := r.companyHandler(company); err != nil {
-			return fmt.Errorf("error processing Company handler: %w", err)
+		if r.normalizeNames {
+			company.NormalizedName = NormalizeCompanyName(company.CompanyName)
+		}
+		if r.phoneticKeys {
+			k := company.PhoneticKeys()
+			company.Phonetic = &k
 		}
+		return Record{Kind: RecordKindCompany, Company: &company}, nil
 	} else if string(line[8]) == personRecordType {
-		person, err := r.personRow(line)
+		var repairs []RepairKind
+		person, warning, err := r.personRow(line)
+		if errors.Is(err, errVariableDataLength) && string(line[0]) == "0" && string(line[1]) != "0" {
+			// it seems like sometimes leading 0's are dropped, so lets add a 0
+			// and try again
+			line = append([]byte("0"), line...)
+			person, warning, err = r.personRow(line)
+			repairs = append(repairs, RepairVariableDataLength)
+		}
 		if err != nil {
-			return fmt.Errorf("error processing Person row: %w", err)
+			return Record{}, fmt.Errorf("error processing Person row: %w", err)
 		}
-		*pt++
-		if err := r.personHandler(person); err != nil {
-			return fmt.Errorf("error processing Person handler: %w", err)
+		if r.phoneticKeys {
+			k := person.PhoneticKeys()
+			person.Phonetic = &k
+		}
+		rec := Record{Kind: RecordKindPerson, Person: &person, repairs: repairs}
+		if warning != nil {
+			rec.warning = warning
 		}
+		return rec, nil
 	} else {
 		// sometimes it looks like leading 0's are missing
 		if string(line[0]) == "0" {
 			if string(line[1]) == "0" {
-				return fmt.Errorf("unhandled record: %s", string(line))
+				return Record{}, fmt.Errorf("unhandled record: %s", string(line))
 			}
 			line = append([]byte("0"), line...)
-			return r.line(line, i, pt, ct)
+			rec, err := r.parse(line, first)
+			rec.repairs = append(rec.repairs, RepairRecordType)
+			return rec, err
+		}
+	}
+	return Record{}, nil
+}
+
+// record accounts for a parsed record in the state of its file and dispatches
+// it, so must be called for each line in file order.
+func (r *Reader) record(rec Record, line []byte, st *state) error {
+	rec.Seq = st.seq
+	if rec.Kind == RecordKindHeader {
+		st.header = rec.Header
+	}
+	rec.header = st.header
+	switch rec.Kind {
+	case RecordKindHeader:
+		st.summary.Headers = append(st.summary.Headers, *rec.Header)
+	case RecordKindFooter:
+		st.summary.RecordCount += rec.Footer.RecordCount
+		st.summary.Trailers++
+		if err := r.dispatch(rec, line, st); err != nil {
+			return err
+		}
+		if !r.relaxedFraming && !st.partial() && rec.Footer.RecordCount != st.summary.Companies+st.summary.Persons+st.summary.Custom+st.summary.Skipped {
+			return fmt.Errorf("unexpected number of records: %d", rec.Footer.RecordCount)
+		}
+		return nil
+	case RecordKindCustom:
+		st.summary.Custom++
+	case RecordKindCompany:
+		st.summary.Companies++
+		st.summary.Statuses = addCount(st.summary.Statuses, Status(rec.Company.CompanyStatus), 1)
+		if r.disappeared != nil {
+			r.disappeared.add(rec.Company.CompanyNumber)
+		}
+		if r.index != nil {
+			if err := r.index.write(st.entry, rec.Company.CompanyNumber, st.offset); err != nil {
+				return err
+			}
+		}
+	case RecordKindPerson:
+		st.summary.Persons++
+		if rec.Person.Truncated {
+			st.summary.Truncated++
+		}
+	default:
+		return nil
+	}
+	if r.selected != nil && rec.Kind != RecordKindHeader && !r.selected(rec) {
+		return nil
+	}
+	return r.dispatch(rec, line, st)
+}
+
+// dispatch delivers a parsed record, or queues it with its line for a worker
+// when workers are configured for its kind.
+func (r *Reader) dispatch(rec Record, line []byte, st *state) error {
+	if w, ok := r.workers[rec.Kind]; ok && r.parsers == 0 {
+		w.queue(rec, line, st.deadLetter())
+		return nil
+	}
+	return r.deliver(rec)
+}
+
+// deliver passes a parsed record to its handler and then to the sinks.
+func (r *Reader) deliver(rec Record) error {
+	if r.rateLimit != nil {
+		r.rateLimit.wait(&r.stopped)
+	}
+	rec = r.output(rec)
+	switch rec.Kind {
+	case RecordKindHeader:
+		if err := r.retry(func() error { return r.headerHandler(*rec.Header) }); err != nil {
+			return fmt.Errorf("error processing header handler: %w", err)
+		}
+	case RecordKindFooter:
+		if err := r.retry(func() error { return r.footerHandler(*rec.Footer) }); err != nil {
+			return fmt.Errorf("error processing footer handler: %w", err)
+		}
+	case RecordKindCompany:
+		if err := r.retry(func() error { return r.companyHandler(*rec.Company) }); err != nil {
+			return fmt.Errorf("error processing Company handler: %w", err)
+		}
+	case RecordKindPerson:
+		if err := r.retry(func() error { return r.personHandler(*rec.Person) }); err != nil {
+			return fmt.Errorf("error processing Person handler: %w", err)
+		}
+	case RecordKindCustom:
+		rt := r.recordTypes[rec.CustomType]
+		if err := r.retry(func() error { return rt.handler(rec.Custom) }); err != nil {
+			return fmt.Errorf("error processing record type %s handler: %w", rec.CustomType, err)
+		}
+	}
+	for _, sink := range r.sinks {
+		if err := r.retry(func() error { return sink(rec) }); err != nil {
+			return fmt.Errorf("error processing sink: %w", err)
 		}
 	}
+	if r.sink != nil {
+		r.sink.send(rec)
+	}
 	return nil
 }
 
-func (r Reader) headerRow(line []byte) (h Header, err error) {
+// output returns rec as passed to handlers and sinks, with company numbers
+// formatted and fields transformed.
+func (r *Reader) output(rec Record) Record {
+	if r.companyNumbers != CompanyNumberPreserve {
+		rec = r.formatCompanyNumbers(rec)
+	}
+	if r.transforms != nil {
+		rec = r.transforms.Apply(rec)
+	}
+	return rec
+}
+
+func (r *Reader) headerRow(line []byte) (h Header, err error) {
+	if len(line) < 20 {
+		err = fmt.Errorf("header line of %d bytes is too short", len(line))
+		return
+	}
 	if string(line[0:8]) != snapshotHeaderIdentifier {
 		err = errors.New("header line does not start with DDDDSNAP")
 		return
@@ -312,102 +975,117 @@ func (r Reader) headerRow(line []byte) (h Header, err error) {
 		err = fmt.Errorf("error reading run: %w", err)
 		return
 	}
+	h.Identifier = string(line[0:8])
+	h.RunType = string(line[4:8])
 	h.Run = run
 	prodDate, err := time.Parse("20060102", string(line[12:20]))
+	if err != nil {
+		err = fmt.Errorf("error reading production date: %w", err)
+		return
+	}
 	h.ProdDate = prodDate
+	h.Filler = string(line[20:])
+	if r.maxHeaderAge > 0 && r.now().Sub(prodDate) > r.maxHeaderAge {
+		err = fmt.Errorf("%w: produced %s", ErrStaleSnapshot, prodDate.Format(time.DateOnly))
+	}
 	return
 }
 
-func (r Reader) personRow(line []byte) (p Person, err error) {
-	p.CompanyNumber = strings.TrimSpace(string(line[0:8]))
-	if strings.TrimSpace(string(line[8])) != personRecordType {
-		err = errors.New("person row does not include personRecordType")
-	}
-	p.AppDateOrigin = strings.TrimSpace(string(line[9]))
-	p.AppointmentType = strings.TrimSpace(string(line[10:12]))
-	p.PersonNumber = strings.TrimSpace(string(line[12:24]))
-	p.CorporateIndicator = strings.TrimSpace(string(line[24]))
-	p.AppointmentDate = strings.TrimSpace(string(line[32:40]))
-	p.ResignationDate = strings.TrimSpace(string(line[40:48]))
-	p.Postcode = strings.TrimSpace(string(line[48:56]))
-	p.PartialDateOfBirth = strings.TrimSpace(string(line[56:64]))
-	p.FullDateOfBirth = strings.TrimSpace(string(line[64:72]))
-	variableDataLength, err := strconv.Atoi(strings.TrimSpace(string(line[72:76])))
-	if err != nil {
-		// it seems like sometimes leading 0's are dropped, so lets add a 0 and
-		// try again
-		if string(line[0]) == "0" {
-			if string(line[01]) == "0" {
-				err = fmt.Errorf("error reading variable data length: %w", err)
-				return
-			}
-			line = append([]byte("0"), line...)
-			return r.personRow(line)
-		}
-	}
-	variableData := line[76 : 76+variableDataLength]
-	data := strings.Split(string(variableData), "<")
-	if len(data) > 0 {
-		p.Title = strings.TrimSpace(data[0])
-	}
-	if len(data) > 1 {
-		p.Forenames = strings.TrimSpace(data[1])
-	}
-	if len(data) > 2 {
-		p.Surname = strings.TrimSpace(data[2])
+func (r *Reader) personRow(line []byte) (p Person, warning *VariableDataWarning, err error) {
+	if len(line) < 76 {
+		return p, nil, fmt.Errorf("person row of %d bytes is shorter than its fixed fields", len(line))
 	}
-	if len(data) > 3 {
-		p.Honours = strings.TrimSpace(data[3])
+	// fields are sliced from a single conversion of the line so that trimming
+	// them does not allocate
+	l := string(line)
+	p.CompanyNumber = strings.TrimSpace(l[0:8])
+	if strings.TrimSpace(l[8:9]) != personRecordType {
+		return p, nil, errors.New("person row does not include personRecordType")
 	}
-	if len(data) > 4 {
-		p.CareOf = strings.TrimSpace(data[4])
-	}
-	if len(data) > 5 {
-		p.PoBox = strings.TrimSpace(data[5])
+	p.AppDateOrigin = strings.TrimSpace(l[9:10])
+	p.AppointmentType = strings.TrimSpace(l[10:12])
+	p.PersonNumber = strings.TrimSpace(l[12:24])
+	p.CorporateIndicator = strings.TrimSpace(l[24:25])
+	p.AppointmentDate = strings.TrimSpace(l[32:40])
+	p.ResignationDate = strings.TrimSpace(l[40:48])
+	p.Postcode = strings.TrimSpace(l[48:56])
+	p.PartialDateOfBirth = strings.TrimSpace(l[56:64])
+	p.FullDateOfBirth = strings.TrimSpace(l[64:72])
+	variableDataLength, err := strconv.Atoi(strings.TrimSpace(l[72:76]))
+	if err != nil {
+		return p, nil, fmt.Errorf("%w: %w", errVariableDataLength, err)
 	}
-	if len(data) > 6 {
-		p.AddressLine1 = strings.TrimSpace(data[6])
+	var issues []VariableDataIssue
+	end := 76 + variableDataLength
+	if end > len(l) {
+		issues = append(issues, VariableDataOverrun)
+		end = len(l)
 	}
-	if len(data) > 7 {
-		p.AddressLine2 = strings.TrimSpace(data[7])
+	variableData := l[76:end]
+	if !strings.HasSuffix(variableData, "<") {
+		issues = append(issues, VariableDataUnterminated)
 	}
-	if len(data) > 8 {
-		p.PostTown = strings.TrimSpace(data[8])
+	if strings.TrimSpace(l[end:]) != "" {
+		issues = append(issues, VariableDataTrailing)
 	}
-	if len(data) > 9 {
-		p.County = strings.TrimSpace(data[9])
+	fields := [...]*string{
+		&p.Title, &p.Forenames, &p.Surname, &p.Honours, &p.CareOf, &p.PoBox, &p.AddressLine1,
+		&p.AddressLine2, &p.PostTown, &p.County, &p.Country, &p.Occupation, &p.Nationality, &p.ResCountry,
 	}
-	if len(data) > 10 {
-		p.Country = strings.TrimSpace(data[10])
+	parts := 0
+	for {
+		part, rest, found := strings.Cut(variableData, "<")
+		if parts < len(fields) {
+			*fields[parts] = strings.TrimSpace(part)
+		}
+		parts++
+		if !found {
+			break
+		}
+		variableData = rest
 	}
-	if len(data) > 11 {
-		p.Occupation = strings.TrimSpace(data[11])
+	// a well formed line has a "<" after every field, leaving an empty part
+	// after the last; an unterminated ResCountry may have been cut short
+	if parts <= len(fields) {
+		p.ResCountry = ""
 	}
-	if len(data) > 12 {
-		p.Nationality = strings.TrimSpace(data[12])
+	if terminated := parts - 1; terminated != variableDataFields {
+		issues = append(issues, VariableDataFieldCount)
 	}
-	if len(data) == 14 {
-		p.ResCountry = strings.TrimSpace(data[13])
+	p.Truncated = truncated(fields[:], variableDataLength, issues)
+	if len(issues) > 0 {
+		warning = &VariableDataWarning{
+			CompanyNumber:  p.CompanyNumber,
+			PersonNumber:   p.PersonNumber,
+			Issues:         issues,
+			DeclaredLength: variableDataLength,
+			Fields:         parts - 1,
+			Data:           bytes.Clone(line[76:]),
+		}
 	}
 	return
 }
 
-func (r Reader) companyRow(line []byte) (c Company, err error) {
-	c.CompanyNumber = strings.TrimSpace(string(line[0:8]))
-	if string(line[8]) != companyRecordType {
-		err = fmt.Errorf("company row does not include companyRecordType")
+func (r *Reader) companyRow(line []byte) (c Company, err error) {
+	if len(line) < 40 {
+		return c, fmt.Errorf("company row of %d bytes is shorter than its fixed fields", len(line))
 	}
-	c.CompanyStatus = strings.TrimSpace(string(line[9]))
-	c.NumberOfOfficers = strings.TrimSpace(string(line[32:36]))
-	nameLength, err := strconv.Atoi(strings.TrimSpace(string(line[36:40])))
+	l := string(line)
+	c.CompanyNumber = strings.TrimSpace(l[0:8])
+	if l[8:9] != companyRecordType {
+		return c, fmt.Errorf("company row does not include companyRecordType")
+	}
+	c.CompanyStatus = strings.TrimSpace(l[9:10])
+	c.NumberOfOfficers = strings.TrimSpace(l[32:36])
+	nameLength, err := strconv.Atoi(strings.TrimSpace(l[36:40]))
 	if err != nil {
-		err = fmt.Errorf("error reading name length: %w", err)
+		return c, fmt.Errorf("error reading name length: %w", err)
 	}
-	if nameLength+40 > len(line) {
-		// hmmm
-		return
+	// the name length includes the "<" terminator
+	if nameLength < 1 || 40+nameLength > len(line) || line[40+nameLength-1] != '<' {
+		return c, &CompanyNameError{CompanyNumber: c.CompanyNumber, NameLength: nameLength, Raw: bytes.Clone(line[40:])}
 	}
-	c.CompanyName = strings.TrimSpace(string(line[40 : 40+nameLength-1]))
+	c.CompanyName = strings.TrimSpace(l[40 : 40+nameLength-1])
 	return
 }
 
@@ -426,6 +1104,16 @@ func (s Status) String() string {
 	}
 }
 
+// CompanyPrefix returns the Prefix of companyNumber, the letters it starts
+// with, which is "" for companies registered in England and Wales.
+func CompanyPrefix(companyNumber string) Prefix {
+	i := strings.IndexFunc(companyNumber, func(r rune) bool { return r < 'A' || r > 'Z' })
+	if i < 0 {
+		i = len(companyNumber)
+	}
+	return Prefix(companyNumber[:i])
+}
+
 func (p Prefix) String() string {
 	switch p {
 	case PrefixSC:
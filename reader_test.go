@@ -1,23 +1,30 @@
 package chapointdat
 
-import "testing"
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
 
 func Test_Line_Unhandled_missing_leading_0(t *testing.T) {
 	line := []byte("04638191C                      00140039INTERNATIONAL BEE RESEARCH ASSOCIATION<")
 	r := NewReader()
-	i := 1
-	pt, ct := 0, 0
-	err := r.line(line, i, &pt, &ct)
+	err := r.line(line, &state{i: 1})
 	if err != nil {
 		t.Error(err)
 	}
 }
 func Test_Line_Unhandled_variable_length_issue_missing_0(t *testing.T) {
 	r := NewReader()
-	i := 1
-	pt, ct := 0, 0
 	line := []byte("04638192201024407940002        19910915        NP25 3DZ194509          0093MR<HANS<KJAERSGAARD<<<<1 AGINCOURT STREET<<MONMOUTH<<WALES<MARKETING DIRECTOR<DANISH<ENGLAND<")
-	err := r.line(line, i, &pt, &ct)
+	err := r.line(line, &state{i: 1})
 	if err != nil {
 		t.Error(err)
 	}
@@ -25,10 +32,8 @@ func Test_Line_Unhandled_variable_length_issue_missing_0(t *testing.T) {
 
 func Test_Line_InvalidCharacter(t *testing.T) {
 	r := NewReader()
-	i := 1
-	pt, ct := 0, 0
 	line := []byte("101222052301207115400002 20160413 WA11 RLÆ197908 0098MR<DAVID<SEOW<<<<840 IBIS COURT CENTRE PARK<<WARRINGTON<CHESHIRE<ENGLAND<DIRECTOR<BRITISH<ENGLAND<")
-	err := r.line(line, i, &pt, &ct)
+	err := r.line(line, &state{i: 1})
 	if err == nil {
 		t.Error("expected error")
 	}
@@ -41,10 +46,8 @@ func Test_Company_Name(t *testing.T) {
 		return nil
 	}
 	r := NewReader(WithCompanyHandler(tf))
-	i := 1
-	pt, ct := 0, 0
 	line := []byte("000000841D                      00000019A. WEST & PARTNERS<")
-	err := r.line(line, i, &pt, &ct)
+	err := r.line(line, &state{i: 1})
 	if err != nil {
 		t.Error(err)
 	}
@@ -53,3 +56,139 @@ func Test_Company_Name(t *testing.T) {
 		t.Errorf("incorrect name expected %s got %s", expected, name)
 	}
 }
+
+func Test_Company_Name_Terminator(t *testing.T) {
+	r := NewReader()
+	for _, line := range []string{
+		"000000841D                      00000020A. WEST & PARTNERS<",
+		"000000841D                      00000018A. WEST & PARTNERS<",
+		"000000841D                      00000000A. WEST & PARTNERS<",
+		"000000841D                      00000019A. WEST & PARTNERS ",
+	} {
+		_, err := r.companyRow([]byte(line))
+		var ne *CompanyNameError
+		if !errors.As(err, &ne) || !errors.Is(err, ErrCompanyName) {
+			t.Errorf("expected a CompanyNameError for %q got %v", line, err)
+			continue
+		}
+		if ne.CompanyNumber != "00000084" || string(ne.Raw) != line[40:] {
+			t.Errorf("unexpected error %+v", ne)
+		}
+	}
+	if _, err := r.companyRow([]byte("000000841D")); err == nil {
+		t.Error("expected an error for a short row")
+	}
+}
+
+const (
+	testHeaderLine  = "DDDDSNAP019520240101"
+	testCompanyLine = "000000841D                      00000019A. WEST & PARTNERS<"
+	testPersonLine  = "004638192201024407940002        19910915        NP25 3DZ194509          0093MR<HANS<KJAERSGAARD<<<<1 AGINCOURT STREET<<MONMOUTH<<WALES<MARKETING DIRECTOR<DANISH<ENGLAND<"
+)
+
+func writeTestZip(t *testing.T, name string, entries ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for i, e := range entries {
+		w, err := zw.Create(fmt.Sprintf("part%d.dat", i+1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func testSnapshot(lines ...string) string {
+	s := testHeaderLine + "\n"
+	for _, l := range lines {
+		s += l + "\n"
+	}
+	return s + fmt.Sprintf("%s%08d\n", trailerRecordIdentifier, len(lines))
+}
+
+func Test_ExtractAll_Summary(t *testing.T) {
+	a := writeTestZip(t, "a.zip", testSnapshot(testCompanyLine, testPersonLine))
+	b := writeTestZip(t, "b.zip", testSnapshot(testCompanyLine), testSnapshot(testPersonLine))
+	var mu sync.Mutex
+	var persons int
+	r := NewReader(WithPersonHandler(func(p Person) error {
+		mu.Lock()
+		defer mu.Unlock()
+		persons++
+		return nil
+	}))
+	s, err := r.ExtractAll([]string{a, b}, 2, func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Files != 3 || s.Companies != 2 || s.Persons != 2 || s.RecordCount != 4 || len(s.Headers) != 3 {
+		t.Errorf("unexpected summary %+v", s)
+	}
+	if persons != 2 {
+		t.Errorf("expected 2 persons handled got %d", persons)
+	}
+}
+
+func Test_Header_Fields(t *testing.T) {
+	r := NewReader()
+	h, err := r.headerRow([]byte(testHeaderLine + "      "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Identifier != "DDDDSNAP" || h.RunType != "SNAP" || h.Run != 195 || h.Filler != "      " {
+		t.Errorf("unexpected header %+v", h)
+	}
+	if h.ProdDate != time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) {
+		t.Errorf("unexpected production date %s", h.ProdDate)
+	}
+}
+
+func Test_Header_Stale(t *testing.T) {
+	var persons int
+	r := NewReader(WithMaxHeaderAge(24*time.Hour), WithPersonHandler(func(p Person) error {
+		persons++
+		return nil
+	}))
+	_, err := r.ExtractReader(strings.NewReader(testSnapshot(testPersonLine)), "-", func(err error) {})
+	if !errors.Is(err, ErrStaleSnapshot) {
+		t.Errorf("expected ErrStaleSnapshot got %v", err)
+	}
+	if persons != 0 {
+		t.Errorf("expected no persons from stale snapshot got %d", persons)
+	}
+}
+
+func Test_ExtractReaderAt(t *testing.T) {
+	for _, data := range []string{testSnapshot(testCompanyLine), readTestFile(t, writeTestZip(t, "a.zip", testSnapshot(testCompanyLine)))} {
+		s, err := NewReader().ExtractReaderAt(strings.NewReader(data), int64(len(data)), "a", 1, func(err error) { t.Error(err) })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Files != 1 || s.Companies != 1 {
+			t.Errorf("unexpected summary %+v", s)
+		}
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
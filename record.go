@@ -0,0 +1,86 @@
+package chapointdat
+
+import "fmt"
+
+const (
+	RecordKindHeader = RecordKind(iota + 1)
+	RecordKindCompany
+	RecordKindPerson
+	RecordKindFooter
+	// RecordKindCustom is a record of a type registered with WithRecordType.
+	RecordKindCustom
+)
+
+type (
+	RecordKind int
+	// Record holds any one parsed line. Exactly one of Header, Company,
+	// Person or Footer is set, according to Kind.
+	Record struct {
+		// Seq increases with each line read by a Reader, so orders records read
+		// from the same file.
+		Seq     uint64
+		Kind    RecordKind
+		Header  *Header  `json:",omitempty"`
+		Company *Company `json:",omitempty"`
+		Person  *Person  `json:",omitempty"`
+		Footer  *Footer  `json:",omitempty"`
+		// CustomType is the record type of a RecordKindCustom record, and
+		// Custom the value its parse function returned.
+		CustomType string `json:",omitempty"`
+		Custom     any    `json:",omitempty"`
+		// warning is a problem found while parsing the line, and repairs the
+		// repairs made to it before it could be parsed.
+		warning error
+		repairs []RepairKind
+		// header is the header of the file the record was read from.
+		header *Header
+	}
+	recordType struct {
+		parse   func(line []byte) (any, error)
+		handler func(v any) error
+	}
+)
+
+func (k RecordKind) String() string {
+	switch k {
+	case RecordKindHeader:
+		return "header"
+	case RecordKindCompany:
+		return "company"
+	case RecordKindPerson:
+		return "person"
+	case RecordKindFooter:
+		return "footer"
+	case RecordKindCustom:
+		return "custom"
+	default:
+		return "unknown"
+	}
+}
+
+func (k RecordKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *RecordKind) UnmarshalText(b []byte) error {
+	for _, c := range []RecordKind{RecordKindHeader, RecordKindCompany, RecordKindPerson, RecordKindFooter, RecordKindCustom} {
+		if c.String() == string(b) {
+			*k = c
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown record kind: %s", string(b))
+}
+
+// WithRecordType registers parse and handler for lines whose record type, the
+// ninth byte, is t. Registered types take precedence over the built in
+// company ("1") and person ("2") types, and count towards the trailer record
+// count. Parsed values are also sent to sinks as RecordKindCustom records.
+func WithRecordType(t string, parse func(line []byte) (any, error), handler func(v any) error) Opt {
+	return func(r *Reader) {
+		if r.recordTypes == nil {
+			r.recordTypes = map[string]recordType{}
+		}
+		r.recordTypes[t] = recordType{parse: parse, handler: handler}
+	}
+}
@@ -0,0 +1,30 @@
+package chapointdat
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_RecordType_Custom(t *testing.T) {
+	var got []any
+	r := NewReader(WithRecordType("3",
+		func(line []byte) (any, error) {
+			return strings.TrimSpace(string(line[9:])), nil
+		},
+		func(v any) error {
+			got = append(got, v)
+			return nil
+		},
+	))
+	in := testSnapshot(testCompanyLine, "000000843 CUSTOM DATA", testPersonLine)
+	s, err := r.ExtractReader(strings.NewReader(in), "-", func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != "CUSTOM DATA" {
+		t.Errorf("unexpected custom records %v", got)
+	}
+	if s.Custom != 1 || s.Companies != 1 || s.Persons != 1 {
+		t.Errorf("unexpected summary %+v", s)
+	}
+}
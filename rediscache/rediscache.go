@@ -0,0 +1,217 @@
+// Package rediscache builds lookup tables of a snapshot in Redis, for services
+// that enrich requests with officer data. Keys are namespaced by the run
+// number of the snapshot:
+//
+//	<prefix>:<run>:company:<company number>       hash of company fields
+//	<prefix>:<run>:officers:<company number>      hash of person number to Person JSON
+//	<prefix>:<run>:appointments:<person number>   set of company numbers
+//	<prefix>:current                              the run number last loaded
+//
+// Readers look up <prefix>:current first, so that a new run is switched to
+// only once it has loaded completely, and each run expires after its TTL.
+package rediscache
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	ch "github.com/richardjennings/chapointdat"
+)
+
+const (
+	defaultPrefix = "chapointdat"
+	// pipelineSize is the number of commands sent before their replies are
+	// read.
+	pipelineSize = 1000
+)
+
+type (
+	Config struct {
+		// Prefix defaults to chapointdat.
+		Prefix string
+		// Password is sent with AUTH if set.
+		Password string
+		// TTL is set on every key of a run, so that old runs are removed once
+		// a newer one has replaced them. Zero keeps keys indefinitely.
+		TTL time.Duration
+	}
+
+	// Builder writes records to Redis. Pass its Write method to
+	// WithRecordHandler and call End once extraction has finished. Records
+	// must be handled in file order, as companies are keyed by the run of the
+	// header before them.
+	Builder struct {
+		mu      sync.Mutex
+		cfg     Config
+		w       *bufio.Writer
+		r       *bufio.Reader
+		run     int
+		pending int
+		authed  bool
+	}
+)
+
+// NewBuilder returns a Builder sending commands over conn, for example a
+// net.Conn to a Redis server.
+func NewBuilder(conn io.ReadWriter, cfg Config) *Builder {
+	if cfg.Prefix == "" {
+		cfg.Prefix = defaultPrefix
+	}
+	return &Builder{cfg: cfg, w: bufio.NewWriter(conn), r: bufio.NewReader(conn)}
+}
+
+// Write adds company and person records. Other records are ignored.
+func (b *Builder) Write(rec ch.Record) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch rec.Kind {
+	case ch.RecordKindHeader:
+		b.run = rec.Header.Run
+	case ch.RecordKindCompany:
+		c := rec.Company
+		key := b.key("company", c.CompanyNumber)
+		return b.send(key, "HSET", key,
+			"CompanyNumber", c.CompanyNumber,
+			"CompanyStatus", c.CompanyStatus,
+			"NumberOfOfficers", c.NumberOfOfficers,
+			"CompanyName", c.CompanyName)
+	case ch.RecordKindPerson:
+		p := rec.Person
+		v, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		key := b.key("officers", p.CompanyNumber)
+		if err := b.send(key, "HSET", key, p.PersonNumber, string(v)); err != nil {
+			return err
+		}
+		key = b.key("appointments", p.PersonNumber)
+		return b.send(key, "SADD", key, p.CompanyNumber)
+	}
+	return nil
+}
+
+// End sends any remaining commands and, if commit is true, sets
+// <prefix>:current to the run loaded.
+func (b *Builder) End(commit bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if commit {
+		if err := b.command("SET", b.cfg.Prefix+":current", strconv.Itoa(b.run)); err != nil {
+			return err
+		}
+	}
+	return b.sync()
+}
+
+func (b *Builder) key(kind, id string) string {
+	return b.cfg.Prefix + ":" + strconv.Itoa(b.run) + ":" + kind + ":" + id
+}
+
+// send pipelines a command and then PEXPIRE for key if there is a TTL, in
+// milliseconds rounded up so that a TTL under a second does not expire the key
+// at once.
+func (b *Builder) send(key string, args ...string) error {
+	if err := b.command(args...); err != nil {
+		return err
+	}
+	if b.cfg.TTL > 0 {
+		ms := (b.cfg.TTL + time.Millisecond - 1) / time.Millisecond
+		return b.command("PEXPIRE", key, strconv.FormatInt(int64(ms), 10))
+	}
+	return nil
+}
+
+func (b *Builder) command(args ...string) error {
+	if !b.authed {
+		b.authed = true
+		if b.cfg.Password != "" {
+			if err := b.command("AUTH", b.cfg.Password); err != nil {
+				return err
+			}
+		}
+	}
+	fmt.Fprintf(b.w, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(b.w, "$%d\r\n%s\r\n", len(a), a)
+	}
+	b.pending++
+	if b.pending >= pipelineSize {
+		return b.sync()
+	}
+	return nil
+}
+
+// sync flushes pipelined commands and reads their replies, returning the
+// first error reply.
+func (b *Builder) sync() error {
+	if err := b.w.Flush(); err != nil {
+		return fmt.Errorf("error writing to redis: %w", err)
+	}
+	var errs []error
+	for ; b.pending > 0; b.pending-- {
+		if err := readReply(b.r); err != nil {
+			var re replyError
+			if !errors.As(err, &re) {
+				return fmt.Errorf("error reading from redis: %w", err)
+			}
+			if len(errs) == 0 {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// replyError is an error reply from Redis.
+type replyError string
+
+func (e replyError) Error() string {
+	return "redis: " + string(e)
+}
+
+// readReply reads and discards a RESP reply, returning a replyError for an
+// error reply.
+func readReply(r *bufio.Reader) error {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if len(line) < 3 {
+		return fmt.Errorf("malformed reply %q", line)
+	}
+	body := line[1 : len(line)-2]
+	switch line[0] {
+	case '+', ':':
+		return nil
+	case '-':
+		return replyError(body)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return err
+		}
+		if n >= 0 {
+			_, err = r.Discard(n + 2)
+		}
+		return err
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return err
+		}
+		for range n {
+			if err := readReply(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unexpected reply %q", line)
+}
@@ -0,0 +1,182 @@
+package rediscache
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ch "github.com/richardjennings/chapointdat"
+)
+
+const (
+	testHeaderLine  = "DDDDSNAP019520240101"
+	testCompanyLine = "000000841D                      00010019A. WEST & PARTNERS<"
+	testPersonLine  = "000000842201024407940002        19910915        NP25 3DZ194509          0093MR<HANS<KJAERSGAARD<<<<1 AGINCOURT STREET<<MONMOUTH<<WALES<MARKETING DIRECTOR<DANISH<ENGLAND<"
+	testTrailerLine = "9999999900000002"
+)
+
+// fakeRedis records the commands it receives and stores the keys written.
+type fakeRedis struct {
+	mu       sync.Mutex
+	commands [][]string
+	hashes   map[string]map[string]string
+	sets     map[string]map[string]bool
+	strings  map[string]string
+	ttls     map[string]string
+}
+
+func newFakeRedis(t *testing.T) (*fakeRedis, net.Conn) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	f := &fakeRedis{hashes: map[string]map[string]string{}, sets: map[string]map[string]bool{}, strings: map[string]string{}, ttls: map[string]string{}}
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		f.serve(bufio.NewReader(c), c)
+	}()
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return f, c
+}
+
+func (f *fakeRedis) serve(r *bufio.Reader, w net.Conn) {
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, args)
+		reply := "+OK\r\n"
+		switch args[0] {
+		case "AUTH":
+			if args[1] != "secret" {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case "HSET":
+			if f.hashes[args[1]] == nil {
+				f.hashes[args[1]] = map[string]string{}
+			}
+			for i := 2; i+1 < len(args); i += 2 {
+				f.hashes[args[1]][args[i]] = args[i+1]
+			}
+			reply = ":1\r\n"
+		case "SADD":
+			if f.sets[args[1]] == nil {
+				f.sets[args[1]] = map[string]bool{}
+			}
+			f.sets[args[1]][args[2]] = true
+			reply = ":1\r\n"
+		case "PEXPIRE":
+			f.ttls[args[1]] = args[2]
+			reply = ":1\r\n"
+		case "SET":
+			f.strings[args[1]] = args[2]
+		}
+		f.mu.Unlock()
+		if _, err := w.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, n)
+	for i := range args {
+		if _, err := r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimSuffix(line, "\r\n")
+	}
+	return args, nil
+}
+
+func Test_Builder(t *testing.T) {
+	f, conn := newFakeRedis(t)
+	b := NewBuilder(conn, Config{Password: "secret", TTL: 48 * time.Hour})
+	r := ch.NewReader(ch.WithRecordHandler(b.Write))
+	snapshot := strings.Join([]string{testHeaderLine, testCompanyLine, testPersonLine, testTrailerLine}, "\n") + "\n"
+	if _, err := r.ExtractReader(strings.NewReader(snapshot), "-", func(err error) { t.Error(err) }); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.End(true); err != nil {
+		t.Fatal(err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.commands[0][0] != "AUTH" {
+		t.Errorf("expected AUTH first got %v", f.commands[0])
+	}
+	if v := f.hashes["chapointdat:195:company:00000084"]["CompanyName"]; v != "A. WEST & PARTNERS" {
+		t.Errorf("expected company name got %q", v)
+	}
+	if v := f.hashes["chapointdat:195:officers:00000084"]["024407940002"]; !strings.Contains(v, `"Surname":"KJAERSGAARD"`) {
+		t.Errorf("expected officer JSON got %q", v)
+	}
+	if !f.sets["chapointdat:195:appointments:024407940002"]["00000084"] {
+		t.Errorf("expected appointment got %v", f.sets)
+	}
+	if v := f.ttls["chapointdat:195:appointments:024407940002"]; v != "172800000" {
+		t.Errorf("expected TTL of 172800000ms got %q", v)
+	}
+	if v := f.strings["chapointdat:current"]; v != "195" {
+		t.Errorf("expected current run 195 got %q", v)
+	}
+}
+
+func Test_Builder_ShortTTL(t *testing.T) {
+	f, conn := newFakeRedis(t)
+	b := NewBuilder(conn, Config{TTL: 1500 * time.Microsecond})
+	if err := b.Write(ch.Record{Kind: ch.RecordKindCompany, Company: &ch.Company{CompanyNumber: "00000084"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.End(false); err != nil {
+		t.Fatal(err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for k, v := range f.ttls {
+		if v != "2" {
+			t.Errorf("expected the TTL of %s rounded up to 2ms got %q", k, v)
+		}
+	}
+	if len(f.ttls) == 0 {
+		t.Error("expected a TTL to be set")
+	}
+}
+
+func Test_Builder_ErrorReply(t *testing.T) {
+	_, conn := newFakeRedis(t)
+	b := NewBuilder(conn, Config{Prefix: "ch", Password: "wrong"})
+	c := ch.Company{CompanyNumber: "00000084"}
+	if err := b.Write(ch.Record{Kind: ch.RecordKindCompany, Company: &c}); err != nil {
+		t.Fatal(err)
+	}
+	err := b.End(false)
+	if err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("expected WRONGPASS error got %v", err)
+	}
+}
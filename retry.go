@@ -0,0 +1,59 @@
+package chapointdat
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrRetryable matches, with errors.Is, any error wrapped by Retryable or
+// wrapping ErrRetryable itself.
+var ErrRetryable = errors.New("retryable")
+
+type RetryableError struct {
+	Err error
+}
+
+// Retryable marks err, returned from a handler, as worth retrying.
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &RetryableError{Err: err}
+}
+
+func (e *RetryableError) Error() string {
+	return "retryable: " + e.Err.Error()
+}
+
+func (e *RetryableError) Unwrap() error {
+	return e.Err
+}
+
+func (e *RetryableError) Is(target error) bool {
+	return target == ErrRetryable
+}
+
+// WithRetry calls a handler up to attempts further times while it returns a
+// retryable error, waiting backoff before the first retry and doubling the
+// wait each time after. Non-retryable errors, and retryable errors remaining
+// once attempts are used up, are passed to the error handler as usual.
+func WithRetry(attempts int, backoff time.Duration) Opt {
+	return func(r *Reader) {
+		r.retryAttempts = attempts
+		r.retryBackoff = backoff
+	}
+}
+
+func (r *Reader) retry(f func() error) error {
+	err := f()
+	wait := r.retryBackoff
+	for attempt := 0; attempt < r.retryAttempts && errors.Is(err, ErrRetryable); attempt++ {
+		if r.stopped.Load() {
+			return err
+		}
+		time.Sleep(wait)
+		wait *= 2
+		err = f()
+	}
+	return err
+}
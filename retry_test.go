@@ -0,0 +1,43 @@
+package chapointdat
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_Retry_Retryable(t *testing.T) {
+	calls := 0
+	r := NewReader(WithRetry(2, 0), WithCompanyHandler(func(c Company) error {
+		calls++
+		if calls < 3 {
+			return Retryable(errors.New("unavailable"))
+		}
+		return nil
+	}))
+	if err := r.line([]byte(testCompanyLine), &state{i: 1}); err != nil {
+		t.Error(err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls got %d", calls)
+	}
+}
+
+func Test_Retry_Exhausted_And_Fatal(t *testing.T) {
+	calls := 0
+	r := NewReader(WithRetry(2, 0), WithCompanyHandler(func(c Company) error {
+		calls++
+		return Retryable(errors.New("unavailable"))
+	}))
+	err := r.line([]byte(testCompanyLine), &state{i: 1})
+	if !errors.Is(err, ErrRetryable) || calls != 3 {
+		t.Errorf("expected retryable error after 3 calls got %v after %d", err, calls)
+	}
+	calls = 0
+	r = NewReader(WithRetry(2, 0), WithCompanyHandler(func(c Company) error {
+		calls++
+		return errors.New("fatal")
+	}))
+	if err := r.line([]byte(testCompanyLine), &state{i: 1}); err == nil || calls != 1 {
+		t.Errorf("expected fatal error after 1 call got %v after %d", err, calls)
+	}
+}
@@ -0,0 +1,263 @@
+// Package roundtrip checks that records encoded by Writer are parsed back
+// unchanged by Reader, over random values covering everything the snapshot
+// format can represent. Use Check with Reader options, such as custom record
+// types or workers, to test them against the same property.
+package roundtrip
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"strings"
+	"time"
+
+	ch "github.com/richardjennings/chapointdat"
+)
+
+const (
+	// digits and letters make up fixed width identifiers.
+	digits  = "0123456789"
+	letters = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	// maxVariableField keeps the variable data of a person within the 4
+	// digits of its length.
+	maxVariableField = 60
+	maxCompanyName   = 160
+)
+
+var (
+	companyPrefixes = []string{"", "SC", "NI", "OC", "SO", "NC", "FC", "LP", "SL", "R0"}
+	// textRunes includes punctuation found in names, and multi byte UTF-8, as
+	// lengths are counted in bytes.
+	textRunes = []rune(letters + digits + " &'-.,()/@+!\"#$%*:;=>?[]_£ÉÖ")
+	// variableFieldLimits are the maximum lengths of the variable data fields
+	// of a person in the specification, which a field must stay below for the
+	// person not to be read as Truncated.
+	variableFieldLimits = []int{50, 50, 160, 50, 100, 10, 250, 50, 50, 50, 50, 100, 50, 50}
+)
+
+// Mismatch describes a field which was not parsed back as written.
+type Mismatch struct {
+	Kind     ch.RecordKind
+	Index    int
+	Field    string
+	Expected any
+	Got      any
+	Line     string
+}
+
+func (m *Mismatch) Error() string {
+	return fmt.Sprintf("%s %d: %s expected %q got %q in line %q", m.Kind, m.Index, m.Field, m.Expected, m.Got, m.Line)
+}
+
+// Header returns a random header.
+func Header(r *rand.Rand) ch.Header {
+	return ch.Header{
+		Identifier: "DDDDSNAP",
+		RunType:    "SNAP",
+		Run:        r.IntN(10000),
+		ProdDate:   time.Date(1990+r.IntN(50), time.Month(1+r.IntN(12)), 1+r.IntN(28), 0, 0, 0, 0, time.UTC),
+		Filler:     optional(r, func() string { return text(r, 20) }),
+	}
+}
+
+// Company returns a random company.
+func Company(r *rand.Rand) ch.Company {
+	return ch.Company{
+		CompanyNumber:    companyNumber(r),
+		CompanyStatus:    optional(r, func() string { return chars(r, letters, 1) }),
+		NumberOfOfficers: chars(r, digits, 4),
+		CompanyName:      optional(r, func() string { return text(r, maxCompanyName) }),
+	}
+}
+
+// Person returns a random person appointed to companyNumber.
+func Person(r *rand.Rand, companyNumber string) ch.Person {
+	p := ch.Person{
+		CompanyNumber:      companyNumber,
+		AppDateOrigin:      optional(r, func() string { return chars(r, digits, 1) }),
+		AppointmentType:    chars(r, digits, 2),
+		PersonNumber:       chars(r, digits, 12),
+		CorporateIndicator: optional(r, func() string { return "Y" }),
+		AppointmentDate:    optional(r, func() string { return date(r, 8) }),
+		ResignationDate:    optional(r, func() string { return date(r, 8) }),
+		Postcode:           optional(r, func() string { return postcode(r) }),
+		PartialDateOfBirth: optional(r, func() string { return date(r, 6) }),
+		FullDateOfBirth:    optional(r, func() string { return date(r, 8) }),
+	}
+	for i, f := range []*string{
+		&p.Title, &p.Forenames, &p.Surname, &p.Honours, &p.CareOf, &p.PoBox, &p.AddressLine1,
+		&p.AddressLine2, &p.PostTown, &p.County, &p.Country, &p.Occupation, &p.Nationality, &p.ResCountry,
+	} {
+		*f = optional(r, func() string { return text(r, min(maxVariableField, variableFieldLimits[i]-1)) })
+	}
+	return p
+}
+
+// Snapshot returns a random header and companies, each with up to
+// maxOfficers persons.
+func Snapshot(r *rand.Rand, companies, maxOfficers int) (ch.Header, []ch.Company, []ch.Person) {
+	h := Header(r)
+	var cs []ch.Company
+	var ps []ch.Person
+	for range companies {
+		c := Company(r)
+		cs = append(cs, c)
+		for range r.IntN(maxOfficers + 1) {
+			ps = append(ps, Person(r, c.CompanyNumber))
+		}
+	}
+	return h, cs, ps
+}
+
+// Check writes h, companies and persons as a snapshot, with the persons of
+// each company following it, and reads it back with a Reader for opts,
+// returning a *Mismatch for the first field which differs.
+func Check(h ch.Header, companies []ch.Company, persons []ch.Person, opts ...ch.Opt) error {
+	var buf bytes.Buffer
+	w := ch.NewWriter(&buf)
+	if err := w.WriteHeader(h); err != nil {
+		return err
+	}
+	byCompany := map[string][]ch.Person{}
+	for _, p := range persons {
+		byCompany[p.CompanyNumber] = append(byCompany[p.CompanyNumber], p)
+	}
+	var expected []ch.Record
+	for _, c := range companies {
+		if err := w.WriteCompany(c); err != nil {
+			return err
+		}
+		expected = append(expected, ch.Record{Kind: ch.RecordKindCompany, Company: &c})
+		for _, p := range byCompany[c.CompanyNumber] {
+			if err := w.WritePerson(p); err != nil {
+				return err
+			}
+			expected = append(expected, ch.Record{Kind: ch.RecordKindPerson, Person: &p})
+		}
+		delete(byCompany, c.CompanyNumber)
+	}
+	if err := w.WriteFooter(); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	lines := strings.Split(buf.String(), "\n")
+
+	var got []ch.Record
+	var errs []error
+	opts = append(opts, ch.WithRecordHandler(func(rec ch.Record) error {
+		got = append(got, rec)
+		return nil
+	}))
+	r := ch.NewReader(opts...)
+	if _, err := r.ExtractReader(bytes.NewReader(buf.Bytes()), "roundtrip", func(err error) { errs = append(errs, err) }); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	if len(got) != len(expected)+2 {
+		return fmt.Errorf("expected %d records got %d", len(expected)+2, len(got))
+	}
+	if err := compare(ch.RecordKindHeader, 0, h, *got[0].Header, lines[0]); err != nil {
+		return err
+	}
+	for i, e := range expected {
+		g := got[i+1]
+		if g.Kind != e.Kind {
+			return &Mismatch{Kind: e.Kind, Index: i, Field: "Kind", Expected: e.Kind, Got: g.Kind, Line: lines[i+1]}
+		}
+		var err error
+		switch e.Kind {
+		case ch.RecordKindCompany:
+			err = compare(e.Kind, i, *e.Company, *g.Company, lines[i+1])
+		case ch.RecordKindPerson:
+			err = compare(e.Kind, i, *e.Person, *g.Person, lines[i+1])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if n := got[len(got)-1].Footer.RecordCount; n != len(expected) {
+		return &Mismatch{Kind: ch.RecordKindFooter, Field: "RecordCount", Expected: len(expected), Got: n, Line: lines[len(expected)+1]}
+	}
+	return nil
+}
+
+// Run checks n random snapshots generated from seed.
+func Run(seed uint64, n int, opts ...ch.Opt) error {
+	r := rand.New(rand.NewPCG(seed, 0))
+	for i := range n {
+		h, cs, ps := Snapshot(r, 1+r.IntN(20), 5)
+		if err := Check(h, cs, ps, opts...); err != nil {
+			return fmt.Errorf("snapshot %d of seed %d: %w", i, seed, err)
+		}
+	}
+	return nil
+}
+
+func compare(kind ch.RecordKind, i int, expected, got any, line string) error {
+	ev, gv := reflect.ValueOf(expected), reflect.ValueOf(got)
+	for f := range ev.NumField() {
+		if !reflect.DeepEqual(ev.Field(f).Interface(), gv.Field(f).Interface()) {
+			return &Mismatch{Kind: kind, Index: i, Field: ev.Type().Field(f).Name, Expected: ev.Field(f).Interface(), Got: gv.Field(f).Interface(), Line: line}
+		}
+	}
+	return nil
+}
+
+// optional returns an empty string a quarter of the time, and otherwise f().
+func optional(r *rand.Rand, f func() string) string {
+	if r.IntN(4) == 0 {
+		return ""
+	}
+	return f()
+}
+
+func chars(r *rand.Rand, set string, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = set[r.IntN(len(set))]
+	}
+	return string(b)
+}
+
+// text returns up to maxLen bytes of textRunes, without leading or trailing
+// spaces, as those are trimmed when parsed.
+func text(r *rand.Rand, maxLen int) string {
+	var b strings.Builder
+	n := 1 + r.IntN(maxLen)
+	for b.Len() < n {
+		c := textRunes[r.IntN(len(textRunes))]
+		if c == ' ' && (b.Len() == 0 || b.Len()+1 >= n) {
+			continue
+		}
+		if b.Len()+len(string(c)) > maxLen {
+			break
+		}
+		b.WriteRune(c)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func companyNumber(r *rand.Rand) string {
+	for {
+		p := companyPrefixes[r.IntN(len(companyPrefixes))]
+		n := p + chars(r, digits, 8-len(p))
+		// a company number of 99999999 would be read as a trailer
+		if n != "99999999" {
+			return n
+		}
+	}
+}
+
+func date(r *rand.Rand, n int) string {
+	d := fmt.Sprintf("%04d%02d%02d", 1900+r.IntN(125), 1+r.IntN(12), 1+r.IntN(28))
+	return d[:n]
+}
+
+func postcode(r *rand.Rand) string {
+	return chars(r, letters, 2) + chars(r, digits, 1) + " " + chars(r, digits, 1) + chars(r, letters, 2)
+}
@@ -0,0 +1,37 @@
+package roundtrip
+
+import (
+	"errors"
+	"testing"
+
+	ch "github.com/richardjennings/chapointdat"
+)
+
+func Test_Run(t *testing.T) {
+	for seed := range uint64(20) {
+		if err := Run(seed, 25); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func Test_Run_OrderedWorkers(t *testing.T) {
+	if err := Run(1, 10, ch.WithOrderedDelivery(4)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_Check_Limits(t *testing.T) {
+	h := ch.Header{Identifier: "DDDDSNAP", RunType: "SNAP"}
+	c := ch.Company{CompanyNumber: "SC123456", NumberOfOfficers: "0001", CompanyName: "X"}
+	p := ch.Person{CompanyNumber: c.CompanyNumber, ResCountry: "ENGLAND"}
+	if err := Check(h, []ch.Company{c, {CompanyNumber: "00000001"}}, []ch.Person{p, {CompanyNumber: c.CompanyNumber}}); err != nil {
+		t.Fatal(err)
+	}
+	// values are trimmed, so leading spaces are not kept
+	c.CompanyName = " X"
+	var m *Mismatch
+	if err := Check(h, []ch.Company{c}, nil); !errors.As(err, &m) || m.Field != "CompanyName" {
+		t.Errorf("expected a CompanyName mismatch got %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+package chapointdat
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"sync"
+)
+
+var (
+	sampleWords = []string{"ACORN", "ALPHA", "BEACON", "BIRCH", "CASTLE", "CEDAR", "COASTAL", "CROWN", "DELTA",
+		"EAGLE", "FALCON", "GLOBAL", "HARBOUR", "HIGHLAND", "MAPLE", "MERIDIAN", "OAK", "ORCHARD", "PIONEER",
+		"QUAY", "RIVERSIDE", "SUMMIT", "THAMES", "UNITY", "VALLEY", "WILLOW"}
+	sampleForenames = []string{"ALEX", "ANNA", "BEN", "CLAIRE", "DANIEL", "EMMA", "FRANK", "GRACE", "HARRY",
+		"ISLA", "JACK", "KATE", "LIAM", "MEGAN", "NOAH", "OLIVIA", "PETER", "RUTH", "SAM", "TOM"}
+	sampleSurnames = []string{"ADAMS", "BAKER", "CLARKE", "DAVIES", "EVANS", "FOSTER", "GREEN", "HUGHES",
+		"JAMES", "KING", "LEWIS", "MORGAN", "PRICE", "REID", "SCOTT", "TURNER", "WALKER", "YOUNG"}
+	sampleStreets = []string{"HIGH STREET", "STATION ROAD", "CHURCH LANE", "MILL ROAD", "PARK AVENUE",
+		"VICTORIA ROAD", "THE GREEN", "KINGS WAY"}
+	// companyNameSuffixes are kept when company names are replaced, so that
+	// samples still have a realistic mix of company types.
+	companyNameSuffixes = []string{"LIMITED", "LTD", "PLC", "LLP", "LP", "CIC", "CYFYNGEDIG", "CYF"}
+)
+
+const postcodeLetters = "ABDEFGHJLNPQRSTUWXYZ"
+
+// Sampler writes a sample of the companies in a snapshot, each with all of its
+// officers, with the names, addresses, dates of birth and identifying numbers
+// of both replaced by random but structurally valid values. The output is a
+// snapshot in the same format which can be shared in bug reports and used in
+// tests. Pass its Write method to WithRecordHandler and call Close once
+// extraction has finished. Records must be handled in file order, so
+// WithWorkers must not be used.
+//
+// A company is chosen from a hash of its number, so that a sample is uniform
+// across the snapshot and the same companies are chosen for the same seed.
+// Replacement values are derived from a hash of the original, so that someone
+// holding several appointments has the same replacement in each of them.
+type Sampler struct {
+	mu     sync.Mutex
+	w      *Writer
+	rate   float64
+	seed   uint64
+	header bool
+}
+
+// NewSampler returns a Sampler choosing each company with probability rate,
+// for example 0.001 for 0.1% of companies.
+func NewSampler(w io.Writer, rate float64, seed uint64) *Sampler {
+	return &Sampler{w: NewWriter(w), rate: rate, seed: seed}
+}
+
+// Write writes the first header and the records of chosen companies. Other
+// records are ignored.
+func (s *Sampler) Write(rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch rec.Kind {
+	case RecordKindHeader:
+		if s.header {
+			return nil
+		}
+		s.header = true
+		return s.w.WriteHeader(*rec.Header)
+	case RecordKindCompany:
+		if !s.chosen(rec.Company.CompanyNumber) {
+			return nil
+		}
+		return s.w.WriteCompany(s.company(*rec.Company))
+	case RecordKindPerson:
+		if !s.chosen(rec.Person.CompanyNumber) {
+			return nil
+		}
+		return s.w.WritePerson(s.person(*rec.Person))
+	}
+	return nil
+}
+
+// Close writes the trailer and flushes the output.
+func (s *Sampler) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.w.WriteFooter(); err != nil {
+		return fmt.Errorf("error writing sample: %w", err)
+	}
+	return s.w.Flush()
+}
+
+func (s *Sampler) chosen(companyNumber string) bool {
+	return s.rand("company", companyNumber).Float64() < s.rate
+}
+
+// rand returns a source seeded from the sample seed and key.
+func (s *Sampler) rand(kind, key string) *rand.Rand {
+	h := fnv.New64a()
+	_ = binary.Write(h, binary.LittleEndian, s.seed)
+	_, _ = io.WriteString(h, kind+"\x00"+key)
+	return rand.New(rand.NewPCG(h.Sum64(), s.seed))
+}
+
+func (s *Sampler) company(c Company) Company {
+	rnd := s.rand("name", c.CompanyNumber)
+	c.CompanyNumber = s.companyNumber(c.CompanyNumber)
+	c.CompanyName = sampleCompanyName(rnd, c.CompanyName)
+	return c
+}
+
+func (s *Sampler) person(p Person) Person {
+	rnd := s.rand("person", p.PersonNumber)
+	p.CompanyNumber = s.companyNumber(p.CompanyNumber)
+	p.PersonNumber = sampleDigits(rnd, p.PersonNumber)
+	if p.IsCorporate() {
+		p.Forenames = ""
+		p.Surname = sampleCompanyName(rnd, p.Surname)
+	} else {
+		forenames := make([]string, len(strings.Fields(p.Forenames)))
+		for i := range forenames {
+			forenames[i] = sampleForenames[rnd.IntN(len(sampleForenames))]
+		}
+		p.Forenames = strings.Join(forenames, " ")
+		p.Surname = sampleSurnames[rnd.IntN(len(sampleSurnames))]
+	}
+	if p.CareOf != "" {
+		p.CareOf = sampleForenames[rnd.IntN(len(sampleForenames))] + " " + sampleSurnames[rnd.IntN(len(sampleSurnames))]
+	}
+	p.PoBox = sampleDigits(rnd, p.PoBox)
+	if p.AddressLine1 != "" {
+		p.AddressLine1 = fmt.Sprintf("%d %s", 1+rnd.IntN(200), sampleStreets[rnd.IntN(len(sampleStreets))])
+	}
+	if p.AddressLine2 != "" {
+		p.AddressLine2 = sampleStreets[rnd.IntN(len(sampleStreets))]
+	}
+	p.Postcode = samplePostcode(rnd, p.Postcode)
+	p.PartialDateOfBirth, p.FullDateOfBirth = sampleDateOfBirth(rnd, p.PartialDateOfBirth, p.FullDateOfBirth)
+	return p
+}
+
+// companyNumber replaces the digits of a company number, keeping any prefix
+// such as SC or OC.
+func (s *Sampler) companyNumber(n string) string {
+	rnd := s.rand("number", n)
+	b := []byte(n)
+	for i, c := range b {
+		if c >= '0' && c <= '9' {
+			b[i] = byte('0' + rnd.IntN(10))
+		}
+	}
+	return string(b)
+}
+
+// sampleCompanyName returns random words with the legal suffix of name.
+func sampleCompanyName(rnd *rand.Rand, name string) string {
+	words := make([]string, 1+rnd.IntN(3))
+	for i := range words {
+		words[i] = sampleWords[rnd.IntN(len(sampleWords))]
+	}
+	if f := strings.Fields(name); len(f) > 0 {
+		for _, suffix := range companyNameSuffixes {
+			if f[len(f)-1] == suffix {
+				words = append(words, suffix)
+				break
+			}
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// sampleDigits returns random digits of the same length as s, or "" if s is
+// empty.
+func sampleDigits(rnd *rand.Rand, s string) string {
+	if s == "" {
+		return ""
+	}
+	return fmt.Sprintf("%0*d", len(s), rnd.Int64N(int64(math.Pow10(min(len(s), 18)))))
+}
+
+// samplePostcode keeps the outward code of a postcode, such as NP25, and
+// replaces the inward code.
+func samplePostcode(rnd *rand.Rand, postcode string) string {
+	outward, _, found := strings.Cut(postcode, " ")
+	if !found {
+		return ""
+	}
+	return fmt.Sprintf("%s %d%c%c", outward, rnd.IntN(10), postcodeLetters[rnd.IntN(len(postcodeLetters))], postcodeLetters[rnd.IntN(len(postcodeLetters))])
+}
+
+// sampleDateOfBirth moves partial (YYYYMM) and full (YYYYMMDD) dates of birth
+// by up to five years, keeping them consistent with each other.
+func sampleDateOfBirth(rnd *rand.Rand, partial, full string) (string, string) {
+	year := 0
+	for _, d := range []string{partial, full} {
+		if len(d) >= 4 {
+			if _, err := fmt.Sscanf(d[:4], "%d", &year); err == nil {
+				break
+			}
+		}
+	}
+	if year == 0 {
+		return partial, full
+	}
+	year += rnd.IntN(11) - 5
+	month := 1 + rnd.IntN(12)
+	if len(partial) == 6 {
+		partial = fmt.Sprintf("%04d%02d", year, month)
+	}
+	if len(full) == 8 {
+		full = fmt.Sprintf("%04d%02d%02d", year, month, 1+rnd.IntN(28))
+	}
+	return partial, full
+}
@@ -0,0 +1,83 @@
+package chapointdat
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func sample(t *testing.T, in string, rate float64, seed uint64) string {
+	t.Helper()
+	var b bytes.Buffer
+	s := NewSampler(&b, rate, seed)
+	r := NewReader(WithRecordHandler(s.Write))
+	if _, err := r.ExtractReader(strings.NewReader(in), "-", func(err error) { t.Error(err) }); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func Test_Sampler(t *testing.T) {
+	var in strings.Builder
+	if err := GenerateSnapshot(&in, 2000, 1); err != nil {
+		t.Fatal(err)
+	}
+	out := sample(t, in.String(), 0.1, 7)
+	if out != sample(t, in.String(), 0.1, 7) {
+		t.Error("expected the same sample for the same seed")
+	}
+
+	var companies, persons int
+	var company Company
+	r := NewReader(
+		WithCompanyHandler(func(c Company) error {
+			companies++
+			company = c
+			if strings.HasPrefix(c.CompanyName, "ACME") || !strings.HasSuffix(c.CompanyName, " LIMITED") {
+				t.Errorf("unexpected company name %s", c.CompanyName)
+			}
+			return nil
+		}),
+		WithPersonHandler(func(p Person) error {
+			persons++
+			if p.CompanyNumber != company.CompanyNumber {
+				t.Errorf("person for %s follows company %s", p.CompanyNumber, company.CompanyNumber)
+			}
+			if slices.Contains(generatedSurnames, p.Surname) || len(p.PersonNumber) != 12 || len(p.PartialDateOfBirth) != 6 {
+				t.Errorf("unexpected person %+v", p)
+			}
+			return nil
+		}),
+	)
+	s, err := r.ExtractReader(strings.NewReader(out), "-", func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	// around 10% of companies, each with all of their officers
+	if companies < 150 || companies > 250 || s.Persons != persons || persons == 0 {
+		t.Errorf("unexpected sample of %d companies and %d persons", companies, persons)
+	}
+}
+
+func Test_Sampler_SamePerson(t *testing.T) {
+	a := testPersonLine
+	b := strings.Replace(testPersonLine, "00463819", "00463820", 1)
+	in := testSnapshot(strings.Replace(testCompanyLine, "00000084", "00463819", 1), a, strings.Replace(testCompanyLine, "00000084", "00463820", 1), b)
+	var persons []Person
+	r := NewReader(WithPersonHandler(func(p Person) error {
+		persons = append(persons, p)
+		return nil
+	}))
+	out := sample(t, in, 1, 1)
+	if _, err := r.ExtractReader(strings.NewReader(out), "-", func(err error) { t.Error(err) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(persons) != 2 || persons[0].PersonNumber != persons[1].PersonNumber || persons[0].Surname != persons[1].Surname ||
+		persons[0].CompanyNumber == persons[1].CompanyNumber || persons[0].Surname == "KJAERSGAARD" {
+		t.Errorf("expected the same replacement for the same person got %+v", persons)
+	}
+}
@@ -0,0 +1,178 @@
+package chapointdat
+
+//go:generate go run ./internal/schemagen -o schema_gen.go reader.go
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// FieldFormatDate is a CCYYMMDD date, or blank.
+	FieldFormatDate = FieldFormat("date")
+	// FieldFormatYearMonth is a CCYYMM partial date, or blank.
+	FieldFormatYearMonth = FieldFormat("year-month")
+	// FieldFormatInteger is a zero padded integer.
+	FieldFormatInteger = FieldFormat("integer")
+)
+
+type (
+	FieldFormat string
+
+	// Schema describes the fields of Company or Person as exported by
+	// WithCSVExport and WithJSONLExport and loaded by SQLScript. It is generated
+	// from the struct declarations and spec comments in reader.go, so run go
+	// generate after changing them.
+	Schema struct {
+		// Name is the table name used by SQLScript.
+		Name   string
+		Fields []SchemaField
+	}
+
+	SchemaField struct {
+		// Name is the Go field name, used as the CSV header and JSON key.
+		Name string
+		// Column is the SQL column name.
+		Column string
+		// Width is the fixed width of the field in the snapshot, or 0 for the
+		// variable length fields.
+		Width       int
+		Format      FieldFormat
+		Description string
+	}
+)
+
+func CompanySchema() Schema {
+	return companySchema
+}
+
+func PersonSchema() Schema {
+	return personSchema
+}
+
+// JSONSchema returns a JSON Schema (draft 2020-12) for the objects of s in a
+// JSONL export.
+func (s Schema) JSONSchema() ([]byte, error) {
+	type property struct {
+		Type        string `json:"type"`
+		Description string `json:"description,omitempty"`
+		MaxLength   int    `json:"maxLength,omitempty"`
+		Pattern     string `json:"pattern,omitempty"`
+	}
+	props := map[string]property{}
+	required := make([]string, len(s.Fields))
+	for i, f := range s.Fields {
+		p := property{Type: "string", Description: f.Description, MaxLength: f.Width}
+		switch f.Format {
+		case FieldFormatDate:
+			p.Pattern = `^(\d{8})?$`
+		case FieldFormatYearMonth:
+			p.Pattern = `^(\d{6})?$`
+		case FieldFormatInteger:
+			p.Pattern = `^\d*$`
+		}
+		props[f.Name] = p
+		required[i] = f.Name
+	}
+	return json.MarshalIndent(map[string]any{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"title":                s.Name,
+		"type":                 "object",
+		"properties":           props,
+		"required":             required,
+		"additionalProperties": false,
+	}, "", "  ")
+}
+
+// DDL returns a CREATE TABLE statement for s matching the rows written by
+// SQLScript. Dates are DATE columns, other than in SQLite which has no date
+// type, and identifiers such as company numbers are strings of their fixed
+// width so that leading zeros are kept. Dates, partial dates and integers are
+// nullable, as SQLScript writes blanks in them as NULL; other fields are NOT
+// NULL and blank as the empty string. For BigQuery the table name may need
+// qualifying with a dataset.
+func (s Schema) DDL(dialect SQLDialect) (string, error) {
+	return s.ddl(dialect, Blanks{})
+}
+
+// ddl returns the CREATE TABLE statement for s with the columns nullable for
+// blanks.
+func (s Schema) ddl(dialect SQLDialect, blanks Blanks) (string, error) {
+	switch dialect {
+	case SQLDialectPostgres, SQLDialectSQLite, SQLDialectMySQL, SQLDialectBigQuery:
+	default:
+		return "", fmt.Errorf("unsupported SQL dialect: %s", dialect)
+	}
+	cols := make([]string, len(s.Fields))
+	for i, f := range s.Fields {
+		cols[i] = f.Column + " " + f.SQLType(dialect)
+		if !blanks.Nullable(f) {
+			cols[i] += " NOT NULL"
+		}
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s);\n", s.Name, strings.Join(cols, ", ")), nil
+}
+
+// SQLType returns the column type of f in dialect.
+func (f SchemaField) SQLType(dialect SQLDialect) string {
+	switch {
+	case dialect == SQLDialectSQLite && f.Format == FieldFormatInteger:
+		return "INTEGER"
+	case dialect == SQLDialectSQLite:
+		return "TEXT"
+	case f.Format == FieldFormatDate:
+		return "DATE"
+	case f.Format == FieldFormatInteger && dialect == SQLDialectBigQuery:
+		return "INT64"
+	case f.Format == FieldFormatInteger:
+		return "INTEGER"
+	case dialect == SQLDialectBigQuery:
+		return "STRING"
+	case f.Format == FieldFormatYearMonth:
+		return "VARCHAR(6)"
+	case f.Width > 0:
+		return fmt.Sprintf("VARCHAR(%d)", f.Width)
+	}
+	return "TEXT"
+}
+
+// Value converts v, a value of f, to int for integers or time.Time for dates,
+// returning nil if v is blank or not valid for a nullable field.
+func (f SchemaField) Value(v string) any {
+	switch f.Format {
+	case FieldFormatDate:
+		t, err := time.Parse("20060102", v)
+		if err != nil {
+			return nil
+		}
+		return t
+	case FieldFormatYearMonth:
+		if _, err := time.Parse("200601", v); err != nil {
+			return nil
+		}
+	case FieldFormatInteger:
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return nil
+		}
+		return i
+	}
+	return v
+}
+
+// Nullable reports whether blank values of f are loaded as NULL.
+func (f SchemaField) Nullable() bool {
+	return f.Format != ""
+}
+
+// columns returns the column names of s.
+func (s Schema) columns() []string {
+	cols := make([]string, len(s.Fields))
+	for i, f := range s.Fields {
+		cols[i] = f.Column
+	}
+	return cols
+}
@@ -0,0 +1,43 @@
+// Code generated by schemagen from reader.go; DO NOT EDIT.
+
+package chapointdat
+
+var companySchema = Schema{
+	Name: "companies",
+	Fields: []SchemaField{
+		{Name: "CompanyNumber", Column: "company_number", Width: 8, Description: "The majority of company numbers are 8 digit numeric; however, some consist of a prefix followed by digits."},
+		{Name: "CompanyStatus", Column: "company_status", Width: 1, Description: "“C” Converted/closed company\n“D” Dissolved company\n“L” Company in liquidation\n“R” Company in receivership\nSpace None of the above categories"},
+		{Name: "NumberOfOfficers", Column: "number_of_officers", Width: 4, Format: FieldFormatInteger, Description: "The number of officers that follow the company record, as a 4 digit integer."},
+		{Name: "CompanyName", Column: "company_name"},
+	},
+}
+
+var personSchema = Schema{
+	Name: "persons",
+	Fields: []SchemaField{
+		{Name: "CompanyNumber", Column: "company_number", Width: 8, Description: "The majority of company numbers are 8 digit numeric; however, some consist of a prefix followed by digits."},
+		{Name: "AppDateOrigin", Column: "app_date_origin", Width: 1, Description: "This data item will contain one of the following values:\n1. Appointment date taken from appointment document (includes 288a, AP01, AP02, AP03, AP04, RR01**, NI form 296, SEAP01, and SEAP02)\n2 Appointment date taken from Annual Return (form 363)\n3 Appointment date taken from incorporation document (includes form 10, IN01, NI form 21, SEFM01, SEFM02, SEFM03, SEFM04, SEFM05, SECV01, and SETR02)\n4 Appointment date taken from LLP appointment document (includes LLP288a, LLAP01, LLAP02, and NI form LLP296a)\n5 Appointment date taken from LLP incorporation document (includes LLP2, and LLIN01)\n6 Appointment date taken from overseas company appointment document (includes BR4, OSAP01, OSAP02, OSAP03, and OSAP04)\n** Appointment of secretary on re-registration from private company to PLC."},
+		{Name: "AppointmentType", Column: "appointment_type", Width: 2, Description: "current secretary (00)\ncurrent director (01)\nresigned secretary (02)\nresigned director (03)\ncurrent non-designated LLP Member (04)\ncurrent designated LLP Member (05)\nresigned non-designated LLP Member (06)\nresigned designated LLP Member (07)\ncurrent judicial factor (11)\ncurrent receiver or manager appointed under the Charities Act (12)\ncurrent manager appointed under the CAICE Act (13)\nresigned judicial factor (14)\nresigned receiver or manager appointed under the Charities Act (15)\nresigned manager appointed under the CAICE Act (16)\ncurrent SE Member of Administrative Organ (17)\ncurrent SE Member of Supervisory Organ (18)\ncurrent SE Member of Management Organ (19)\nresigned SE Member of Administrative Organ (20)\nresigned SE Member of Supervisory Organ (21)\nresigned SE Member of Management Organ (22)\nerrored appointment (99)"},
+		{Name: "PersonNumber", Column: "person_number", Width: 12, Description: "12 character numeric unique person identifier (increased from 10 characters)."},
+		{Name: "CorporateIndicator", Column: "corporate_indicator", Width: 1, Description: "Will be set to “Y” if the officer is a corporate body, otherwise set to space."},
+		{Name: "AppointmentDate", Column: "appointment_date", Width: 8, Format: FieldFormatDate, Description: "Will contain either spaces or an actual date in the format CCYYMMDD. The value spaces will signify that Companies House does not have an actual date for that item. If an Appointment Date is provided for Appointment Type 11, 12, or 13 this refers to the date that the form was registered; the actual date of appointment is not captured for these appointment types."},
+		{Name: "ResignationDate", Column: "resignation_date", Width: 8, Format: FieldFormatDate, Description: "Will contain either spaces or an actual date in the format CCYYMMDD. The value spaces will signify that Companies House does not have an actual date for that item. Resigned appointments are not normally included in a snapshot so this field will usually be blank."},
+		{Name: "Postcode", Column: "postcode", Width: 8, Description: "Current postcode for officer Service Address."},
+		{Name: "PartialDateOfBirth", Column: "partial_date_of_birth", Width: 8, Format: FieldFormatYearMonth, Description: "Partial Date of Birth field will contain either all spaces, or a partial date of birth (century, year, month) followed by 2 space characters in the format ‘CCYYMM ‘. If Full Date of Birth is provided then Partial Date of Birth will also be provided. However, Partial Date of Birth may be provided without Full Date of Birth."},
+		{Name: "FullDateOfBirth", Column: "full_date_of_birth", Width: 8, Format: FieldFormatDate, Description: "Will contain either spaces or an actual date in the format CCYYMMDD. The value spaces will signify that Companies House does not have an actual date for that item."},
+		{Name: "Title", Column: "title"},
+		{Name: "Forenames", Column: "forenames"},
+		{Name: "Surname", Column: "surname"},
+		{Name: "Honours", Column: "honours"},
+		{Name: "CareOf", Column: "care_of"},
+		{Name: "PoBox", Column: "po_box"},
+		{Name: "AddressLine1", Column: "address_line1"},
+		{Name: "AddressLine2", Column: "address_line2"},
+		{Name: "PostTown", Column: "post_town"},
+		{Name: "County", Column: "county"},
+		{Name: "Country", Column: "country"},
+		{Name: "Occupation", Column: "occupation"},
+		{Name: "Nationality", Column: "nationality"},
+		{Name: "ResCountry", Column: "res_country"},
+	},
+}
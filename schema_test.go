@@ -0,0 +1,78 @@
+package chapointdat
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func Test_Schema_Fields(t *testing.T) {
+	// fails when reader.go has changed without running go generate
+	for _, tc := range []struct {
+		s      Schema
+		fields []string
+	}{
+		{CompanySchema(), fieldNames(Company{})},
+		{PersonSchema(), fieldNames(Person{})},
+	} {
+		var names []string
+		for _, f := range tc.s.Fields {
+			names = append(names, f.Name)
+		}
+		if !slices.Equal(names, tc.fields) {
+			t.Errorf("expected %s fields %v got %v", tc.s.Name, tc.fields, names)
+		}
+	}
+	f := PersonSchema().Fields[3]
+	if f.Name != "PersonNumber" || f.Width != 12 || !strings.Contains(f.Description, "unique person identifier") {
+		t.Errorf("unexpected field %+v", f)
+	}
+}
+
+func Test_Schema_JSONSchema(t *testing.T) {
+	b, err := PersonSchema().JSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v struct {
+		Properties map[string]struct {
+			MaxLength int
+			Pattern   string
+		}
+		Required []string
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Required) != 24 || v.Properties["AppointmentDate"].Pattern != `^(\d{8})?$` || v.Properties["Postcode"].MaxLength != 8 {
+		t.Errorf("unexpected schema %s", b)
+	}
+}
+
+func Test_Schema_DDL(t *testing.T) {
+	ddl, err := CompanySchema().DDL(SQLDialectSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "CREATE TABLE IF NOT EXISTS companies (company_number TEXT NOT NULL, company_status TEXT NOT NULL, number_of_officers INTEGER, company_name TEXT NOT NULL);\n"
+	if ddl != expected {
+		t.Errorf("expected %s got %s", expected, ddl)
+	}
+	for dialect, expected := range map[SQLDialect]string{
+		SQLDialectPostgres: "company_number VARCHAR(8) NOT NULL, app_date_origin VARCHAR(1) NOT NULL, appointment_type VARCHAR(2) NOT NULL, person_number VARCHAR(12) NOT NULL, corporate_indicator VARCHAR(1) NOT NULL, appointment_date DATE, resignation_date DATE, postcode VARCHAR(8) NOT NULL, partial_date_of_birth VARCHAR(6), full_date_of_birth DATE, title TEXT NOT NULL,",
+		SQLDialectMySQL:    "person_number VARCHAR(12) NOT NULL, corporate_indicator VARCHAR(1) NOT NULL, appointment_date DATE,",
+		SQLDialectBigQuery: "person_number STRING NOT NULL, corporate_indicator STRING NOT NULL, appointment_date DATE, resignation_date DATE, postcode STRING NOT NULL, partial_date_of_birth STRING,",
+	} {
+		ddl, err := PersonSchema().DDL(dialect)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(ddl, expected) {
+			t.Errorf("expected %s DDL to contain %s got %s", dialect, expected, ddl)
+		}
+	}
+	if _, err := CompanySchema().DDL("oracle"); err == nil {
+		t.Error("expected an error for an unsupported dialect")
+	}
+}
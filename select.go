@@ -0,0 +1,295 @@
+package chapointdat
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type (
+	// selectExpr is a parsed selection expression, evaluated against a
+	// record of one of the kinds it refers to.
+	selectExpr interface {
+		eval(v reflect.Value, kind RecordKind) bool
+	}
+	selectAnd   struct{ a, b selectExpr }
+	selectOr    struct{ a, b selectExpr }
+	selectNot   struct{ e selectExpr }
+	selectField struct {
+		kind  RecordKind
+		index int
+	}
+	selectCompare struct {
+		field selectField
+		op    string
+		value string
+	}
+
+	selectParser struct {
+		tokens []string
+		pos    int
+		kinds  map[RecordKind]bool
+	}
+)
+
+var selectKinds = map[string]struct {
+	kind RecordKind
+	typ  reflect.Type
+}{
+	"header":  {RecordKindHeader, reflect.TypeFor[Header]()},
+	"company": {RecordKindCompany, reflect.TypeFor[Company]()},
+	"person":  {RecordKindPerson, reflect.TypeFor[Person]()},
+	"footer":  {RecordKindFooter, reflect.TypeFor[Footer]()},
+}
+
+// WithSelect delivers only the companies, persons and custom records for which
+// f returns true, such as a function returned by ParseSelect. Records not
+// selected are still counted in the Summary. Headers and footers are always
+// delivered.
+func WithSelect(f func(rec Record) bool) Opt {
+	return func(r *Reader) {
+		r.selected = f
+	}
+}
+
+// ParseSelect parses a selection expression such as
+//
+//	person.Postcode startswith "WA" and not person.Country == "WALES"
+//
+// returning a function reporting whether a record is selected. A field is the
+// kind of record, one of header, company, person or footer, and a field name
+// as in Fields, ignoring case. A field is compared with a quoted string or
+// number using ==, !=, <, <=, >, >=, startswith, endswith or contains, or on
+// its own is true unless blank. Values which are both numbers are compared as
+// numbers, and otherwise as strings. Comparisons are combined with and, or,
+// not and parentheses. Records of kinds the expression does not refer to are
+// selected; otherwise comparisons of fields of another kind are false.
+func ParseSelect(expr string) (func(rec Record) bool, error) {
+	tokens, err := selectTokens(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &selectParser{tokens: tokens, kinds: map[RecordKind]bool{}}
+	e, err := p.or()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %s", p.tokens[p.pos])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing select expression %q: %w", expr, err)
+	}
+	return func(rec Record) bool {
+		if !p.kinds[rec.Kind] {
+			return true
+		}
+		return e.eval(rec.value(), rec.Kind)
+	}, nil
+}
+
+// selectTokens splits expr into words, quoted strings and operators.
+func selectTokens(expr string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '"':
+			j := i + 1
+			for ; j < len(expr) && expr[j] != '"'; j++ {
+				if expr[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(expr) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			tokens = append(tokens, expr[i:j+1])
+			i = j + 1
+		case strings.ContainsRune("()", rune(c)):
+			tokens = append(tokens, expr[i:i+1])
+			i++
+		case strings.ContainsRune("=!<>", rune(c)):
+			j := i + 1
+			if j < len(expr) && expr[j] == '=' {
+				j++
+			}
+			tokens = append(tokens, expr[i:j])
+			i = j
+		default:
+			j := i
+			for j < len(expr) && (expr[j] == '.' || expr[j] == '_' || expr[j] == '-' || unicode.IsLetter(rune(expr[j])) || unicode.IsDigit(rune(expr[j]))) {
+				j++
+			}
+			if j == i {
+				return nil, fmt.Errorf("unexpected %q at %d", c, i)
+			}
+			tokens = append(tokens, expr[i:j])
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+func (p *selectParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *selectParser) next() (string, error) {
+	t := p.peek()
+	if t == "" {
+		return "", fmt.Errorf("unexpected end of expression")
+	}
+	p.pos++
+	return t, nil
+}
+
+func (p *selectParser) or() (selectExpr, error) {
+	e, err := p.and()
+	for err == nil && strings.EqualFold(p.peek(), "or") {
+		p.pos++
+		var b selectExpr
+		if b, err = p.and(); err == nil {
+			e = selectOr{e, b}
+		}
+	}
+	return e, err
+}
+
+func (p *selectParser) and() (selectExpr, error) {
+	e, err := p.not()
+	for err == nil && strings.EqualFold(p.peek(), "and") {
+		p.pos++
+		var b selectExpr
+		if b, err = p.not(); err == nil {
+			e = selectAnd{e, b}
+		}
+	}
+	return e, err
+}
+
+func (p *selectParser) not() (selectExpr, error) {
+	if strings.EqualFold(p.peek(), "not") {
+		p.pos++
+		e, err := p.not()
+		return selectNot{e}, err
+	}
+	return p.primary()
+}
+
+func (p *selectParser) primary() (selectExpr, error) {
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if t == "(" {
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if t, err := p.next(); err != nil || t != ")" {
+			return nil, fmt.Errorf("expected )")
+		}
+		return e, nil
+	}
+	f, err := p.field(t)
+	if err != nil {
+		return nil, err
+	}
+	op := strings.ToLower(p.peek())
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=", "startswith", "endswith", "contains":
+	default:
+		return selectCompare{field: f, op: "!=", value: ""}, nil
+	}
+	p.pos++
+	v, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(v, `"`) {
+		if v, err = strconv.Unquote(v); err != nil {
+			return nil, fmt.Errorf("invalid string: %w", err)
+		}
+	} else if _, err := strconv.ParseFloat(v, 64); err != nil {
+		return nil, fmt.Errorf("expected a string or number after %s, got %s", op, v)
+	}
+	return selectCompare{field: f, op: op, value: v}, nil
+}
+
+// field resolves a path such as person.Postcode.
+func (p *selectParser) field(path string) (selectField, error) {
+	f, err := lookupField(path)
+	if err == nil {
+		p.kinds[f.kind] = true
+	}
+	return f, err
+}
+
+// lookupField resolves a path such as person.Postcode, of the kind of record
+// and a field name ignoring case.
+func lookupField(path string) (selectField, error) {
+	kind, name, ok := strings.Cut(path, ".")
+	k, known := selectKinds[strings.ToLower(kind)]
+	if !ok || !known {
+		return selectField{}, fmt.Errorf("expected a field such as person.Postcode, got %s", path)
+	}
+	for i := range k.typ.NumField() {
+		if strings.EqualFold(k.typ.Field(i).Name, name) {
+			return selectField{kind: k.kind, index: i}, nil
+		}
+	}
+	return selectField{}, fmt.Errorf("unknown field %s", path)
+}
+
+func (e selectAnd) eval(v reflect.Value, kind RecordKind) bool {
+	return e.a.eval(v, kind) && e.b.eval(v, kind)
+}
+
+func (e selectOr) eval(v reflect.Value, kind RecordKind) bool {
+	return e.a.eval(v, kind) || e.b.eval(v, kind)
+}
+
+func (e selectNot) eval(v reflect.Value, kind RecordKind) bool {
+	return !e.e.eval(v, kind)
+}
+
+func (e selectCompare) eval(v reflect.Value, kind RecordKind) bool {
+	if kind != e.field.kind {
+		return false
+	}
+	s := fieldString(v.Field(e.field.index))
+	switch e.op {
+	case "startswith":
+		return strings.HasPrefix(s, e.value)
+	case "endswith":
+		return strings.HasSuffix(s, e.value)
+	case "contains":
+		return strings.Contains(s, e.value)
+	}
+	c := cmp.Compare(s, e.value)
+	if a, err := strconv.ParseFloat(s, 64); err == nil {
+		if b, err := strconv.ParseFloat(e.value, 64); err == nil {
+			c = cmp.Compare(a, b)
+		}
+	}
+	switch e.op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
@@ -0,0 +1,68 @@
+package chapointdat
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_ParseSelect(t *testing.T) {
+	company, person := testRecords(t)
+	for expr, expected := range map[string][2]bool{
+		`person.Postcode startswith "NP"`:                                                  {true, true},
+		`person.Postcode startswith "WA"`:                                                  {true, false},
+		`person.postcode endswith "3DZ" and person.Country == "WALES"`:                     {true, true},
+		`not person.Country == "WALES" or person.Surname contains "JAER"`:                  {true, true},
+		`company.CompanyStatus == "D"`:                                                     {true, true},
+		`company.NumberOfOfficers > 0`:                                                     {false, true},
+		`company.CompanyName contains "WEST" or (person.Forenames and not person.Honours)`: {true, true},
+		`person.Honours`:                    {true, false},
+		`person.AppointmentDate < 20000101`: {true, true},
+	} {
+		f, err := ParseSelect(expr)
+		if err != nil {
+			t.Errorf("%s: %v", expr, err)
+			continue
+		}
+		if got := [2]bool{f(company), f(person)}; got != expected {
+			t.Errorf("%s: expected %v got %v", expr, expected, got)
+		}
+	}
+	for _, expr := range []string{`person.Unknown == "x"`, `person.Postcode ==`, `Postcode == "x"`, `(person.Postcode`, `person.Postcode == WA`, `person.Postcode == "x`} {
+		if _, err := ParseSelect(expr); err == nil {
+			t.Errorf("expected an error for %s", expr)
+		}
+	}
+}
+
+func Test_WithSelect(t *testing.T) {
+	f, err := ParseSelect(`person.Postcode startswith "WA"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []RecordKind
+	r := NewReader(WithSelect(f), WithRecordHandler(func(rec Record) error {
+		kinds = append(kinds, rec.Kind)
+		return nil
+	}))
+	s, err := r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine, testPersonLine)), "-", func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kinds) != 3 || kinds[2] != RecordKindFooter || s.Persons != 1 {
+		t.Errorf("expected the person not to be delivered got %v", kinds)
+	}
+}
+
+// testRecords parses testCompanyLine and testPersonLine.
+func testRecords(t *testing.T) (company, person Record) {
+	t.Helper()
+	r := NewReader()
+	company, err := r.parse([]byte(testCompanyLine), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if person, err = r.parse([]byte(testPersonLine), false); err != nil {
+		t.Fatal(err)
+	}
+	return company, person
+}
@@ -0,0 +1,343 @@
+package chapointdat
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrShardMismatch is returned when a Shard does not match the snapshot file it
+// describes, which has changed since it was split.
+var ErrShardMismatch = errors.New("shard does not match snapshot")
+
+// Shard describes a byte range of a zip entry or .dat file of a snapshot which
+// can be extracted independently of the rest, by ExtractShard, in another
+// process or on another machine with a copy of the file. Shards are JSON
+// encoded to be handed out, and the Summary of each is merged with Merge and
+// checked with Validate as for any split snapshot.
+type Shard struct {
+	// Path is the snapshot file and Entry the zip entry, or the base name of a
+	// .dat file.
+	Path, Entry string
+	// Start and End are the byte offsets of the range of the decompressed
+	// entry, each the start of a line. Only the shard starting at 0 reads the
+	// header and counts the file. The trailer count is of the whole entry, so
+	// is only checked by a shard of all of it; Validate checks it against the
+	// merged Summary of the shards.
+	Start, End int64
+	// Size is the decompressed size of the entry, Header its first line and
+	// First the first 9 bytes of the company line at Start, if it is not 0.
+	// They are checked before the shard is extracted.
+	Size   int64
+	Header string
+	First  string `json:",omitempty"`
+}
+
+// SplitShards splits the entries of paths into shards of about an nth of their
+// total size. .dat files and stored zip entries are split at company lines;
+// deflated entries cannot be read from an offset without decompressing the
+// bytes before it, so each is a single shard.
+func SplitShards(paths []string, n int) ([]Shard, error) {
+	return SplitEntryShards(paths, n, EntryFilter{})
+}
+
+// SplitEntryShards splits as SplitShards does, but only the zip entries
+// chosen by filter, so that README and other files alongside the .dat parts
+// are neither sized nor handed out as shards. It returns an error if a pattern
+// of filter is malformed.
+func SplitEntryShards(paths []string, n int, filter EntryFilter) ([]Shard, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	type unit struct {
+		shard Shard
+		ra    io.ReaderAt
+	}
+	var units []unit
+	var total int64
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+		fi, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		magic := make([]byte, len(zipMagic))
+		if _, err := f.ReadAt(magic, 0); err != nil || string(magic) != zipMagic {
+			units = append(units, unit{Shard{Path: path, Entry: filepath.Base(path), Size: fi.Size()}, f})
+			total += fi.Size()
+			continue
+		}
+		z, err := zip.NewReader(f, fi.Size())
+		if err != nil {
+			return nil, err
+		}
+		for _, zf := range z.File {
+			if !filter.Match(zf.Name) {
+				continue
+			}
+			u := unit{shard: Shard{Path: path, Entry: zf.Name, Size: int64(zf.UncompressedSize64)}}
+			if zf.Method == zip.Store {
+				offset, err := zf.DataOffset()
+				if err != nil {
+					return nil, err
+				}
+				u.ra = io.NewSectionReader(f, offset, u.shard.Size)
+			}
+			units = append(units, u)
+			total += u.shard.Size
+		}
+	}
+
+	size := max(total/int64(max(n, 1)), 1)
+	var shards []Shard
+	for _, u := range units {
+		header, err := shardHeader(u.ra, u.shard)
+		if err != nil {
+			return nil, err
+		}
+		u.shard.Header = header
+		start := int64(0)
+		for u.ra != nil && u.shard.Size-start > size {
+			offset, first, ok, err := nextCompany(u.ra, start+size, u.shard.Size)
+			if err != nil {
+				return nil, fmt.Errorf("error splitting %s: %w", u.shard.Entry, err)
+			}
+			if !ok {
+				break
+			}
+			s := u.shard
+			s.Start, s.End = start, offset
+			shards = append(shards, s)
+			start, u.shard.First = offset, first
+		}
+		u.shard.Start, u.shard.End = start, u.shard.Size
+		shards = append(shards, u.shard)
+	}
+	return shards, nil
+}
+
+// shardHeader returns the first line of the entry of s, read from ra or, for a
+// deflated entry, by opening it.
+func shardHeader(ra io.ReaderAt, s Shard) (string, error) {
+	var rd io.Reader
+	if ra != nil {
+		rd = io.NewSectionReader(ra, 0, s.Size)
+	} else {
+		zf, err := openShardEntry(s)
+		if err != nil {
+			return "", err
+		}
+		defer func() { _ = zf.Close() }()
+		rd = zf
+	}
+	line, err := bufio.NewReader(rd).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("error reading header of %s: %w", s.Entry, err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// openShardEntry opens the zip entry of s.
+func openShardEntry(s Shard) (io.ReadCloser, error) {
+	z, err := zip.OpenReader(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	for _, zf := range z.File {
+		if zf.Name == s.Entry {
+			rc, err := zf.Open()
+			if err != nil {
+				_ = z.Close()
+				return nil, err
+			}
+			return struct {
+				io.Reader
+				io.Closer
+			}{rc, closers{rc, z}}, nil
+		}
+	}
+	_ = z.Close()
+	return nil, fmt.Errorf("%w: no entry %s in %s", ErrShardMismatch, s.Entry, s.Path)
+}
+
+type closers []io.Closer
+
+func (c closers) Close() error {
+	var errs []error
+	for _, cl := range c {
+		errs = append(errs, cl.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// nextCompany returns the offset and first 9 bytes of the first company line
+// starting at or after from, or not ok if the trailer or end of the entry is
+// reached first.
+func nextCompany(ra io.ReaderAt, from, size int64) (offset int64, first string, ok bool, err error) {
+	// start from the byte before from, so that a line starting at from is found
+	br := bufio.NewReader(io.NewSectionReader(ra, from-1, size-from+1))
+	skipped, err := br.ReadBytes('\n')
+	if err != nil {
+		return 0, "", false, nilEOF(err)
+	}
+	offset = from - 1 + int64(len(skipped))
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) == 0 || bytes.HasPrefix(line, []byte(trailerRecordIdentifier)) {
+			return 0, "", false, nilEOF(err)
+		}
+		if isRecordType(line, companyRecordType) {
+			return offset, string(line[:min(9, len(line))]), true, nil
+		}
+		offset += int64(len(line))
+		if err != nil {
+			return 0, "", false, nilEOF(err)
+		}
+	}
+}
+
+func nilEOF(err error) error {
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// ExtractShard processes the lines of s. A shard not starting at 0 takes its
+// header from s.Header. Checkpoints are recorded per shard but not resumed, and
+// WithCorrections and WithDisappeared see only the companies of the shard.
+func (r *Reader) ExtractShard(s Shard, errH func(err error)) (Summary, error) {
+	if err := r.start(errH); err != nil {
+		return Summary{}, err
+	}
+	sum, err := r.extractShard(s, errH)
+	return sum, errors.Join(err, r.finish(&sum))
+}
+
+func (r *Reader) extractShard(s Shard, errH func(err error)) (Summary, error) {
+	if err := r.rawInput("ExtractShard"); err != nil {
+		return Summary{}, err
+	}
+	if r.stopped.Load() {
+		return Summary{}, ErrStopped
+	}
+	rd, err := openShard(s)
+	if err != nil {
+		return Summary{}, err
+	}
+	defer func() { _ = rd.Close() }()
+	br := bufio.NewReader(rd)
+	st := &state{path: s.Path, entry: s.Entry, shard: &s}
+	if s.Start == 0 {
+		st.summary.Files = 1
+		line, _ := br.Peek(len(s.Header))
+		if string(line) != s.Header {
+			return Summary{}, fmt.Errorf("%w: %s header is not %s", ErrShardMismatch, s.Entry, s.Header)
+		}
+	} else {
+		h, err := r.headerRow([]byte(s.Header))
+		if err != nil {
+			return Summary{}, fmt.Errorf("error parsing shard header: %w", err)
+		}
+		st.header = &h
+		if line, _ := br.Peek(len(s.First)); string(line) != s.First {
+			return Summary{}, fmt.Errorf("%w: %s at %d is not %s", ErrShardMismatch, s.Entry, s.Start, s.First)
+		}
+	}
+	return r.extractLines(newScanner(br, s.Start), st, s.Path, 0, errH)
+}
+
+// openShard returns a reader of the byte range of s, checking the size of its
+// entry and that the entry starts with its header.
+func openShard(s Shard) (io.ReadCloser, error) {
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	section := func(ra io.ReaderAt, size int64) (io.ReadCloser, error) {
+		if size != s.Size {
+			_ = f.Close()
+			return nil, fmt.Errorf("%w: %s is %d bytes not %d", ErrShardMismatch, s.Entry, size, s.Size)
+		}
+		if s.Start > 0 {
+			if header, err := shardHeader(ra, s); err != nil || header != s.Header {
+				_ = f.Close()
+				return nil, errors.Join(err, fmt.Errorf("%w: %s header is not %s", ErrShardMismatch, s.Entry, s.Header))
+			}
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{io.NewSectionReader(ra, s.Start, s.End-s.Start), f}, nil
+	}
+	magic := make([]byte, len(zipMagic))
+	if _, err := f.ReadAt(magic, 0); err != nil || string(magic) != zipMagic {
+		return section(f, fi.Size())
+	}
+	z, err := zip.NewReader(f, fi.Size())
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	for _, zf := range z.File {
+		if zf.Name != s.Entry {
+			continue
+		}
+		if zf.Method == zip.Store {
+			offset, err := zf.DataOffset()
+			if err != nil {
+				_ = f.Close()
+				return nil, err
+			}
+			return section(io.NewSectionReader(f, offset, int64(zf.UncompressedSize64)), int64(zf.UncompressedSize64))
+		}
+		if int64(zf.UncompressedSize64) != s.Size || s.Start != 0 || s.End != s.Size {
+			_ = f.Close()
+			return nil, fmt.Errorf("%w: %s is deflated so cannot be read from %d to %d", ErrShardMismatch, s.Entry, s.Start, s.End)
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{rc, closers{rc, f}}, nil
+	}
+	_ = f.Close()
+	return nil, fmt.Errorf("%w: no entry %s in %s", ErrShardMismatch, s.Entry, s.Path)
+}
+
+// first reports whether line i is the first of its file, which is parsed as
+// the header.
+func (st *state) first(i int) bool {
+	return i == 0 && (st.shard == nil || st.shard.Start == 0)
+}
+
+// partial reports whether only part of the file is read, so the trailer count
+// cannot be checked.
+func (st *state) partial() bool {
+	return st.shard != nil && (st.shard.Start != 0 || st.shard.End != st.shard.Size)
+}
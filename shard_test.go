@@ -0,0 +1,98 @@
+package chapointdat
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func Test_SplitShards(t *testing.T) {
+	var lines []string
+	for i := range 6 {
+		lines = append(lines, strings.Replace(testCompanyLine, "00000084", fmt.Sprintf("%08d", i+1), 1), testPersonLine)
+	}
+	path := filepath.Join(t.TempDir(), "Prod195.dat")
+	if err := os.WriteFile(path, []byte(testSnapshot(lines...)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	shards, err := SplitShards([]string{path}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shards) != 3 {
+		t.Fatalf("expected 3 shards got %d", len(shards))
+	}
+
+	var summary Summary
+	var companies []string
+	for i, s := range shards {
+		if i > 0 && (s.Start != shards[i-1].End || s.First == "") {
+			t.Errorf("expected shard %d to start at a company where %d ends got %+v", i, i-1, s)
+		}
+		r := NewReader(WithCompanyHandler(func(c Company) error {
+			companies = append(companies, c.CompanyNumber)
+			return nil
+		}))
+		sum, err := r.ExtractShard(s, func(err error) { t.Error(err) })
+		if err != nil {
+			t.Fatal(err)
+		}
+		summary.Merge(sum)
+	}
+	if err := summary.Validate(); err != nil {
+		t.Error(err)
+	}
+	if summary.Files != 1 || summary.Companies != 6 || summary.Persons != 6 || summary.Lines != 14 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+	if !slices.Equal(companies, []string{"00000001", "00000002", "00000003", "00000004", "00000005", "00000006"}) {
+		t.Errorf("unexpected companies %v", companies)
+	}
+
+	if err := os.WriteFile(path, []byte(testSnapshot(lines[2:]...)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewReader().ExtractShard(shards[1], func(err error) {}); !errors.Is(err, ErrShardMismatch) {
+		t.Errorf("expected ErrShardMismatch got %v", err)
+	}
+}
+
+func Test_SplitShards_Deflated(t *testing.T) {
+	path := writeTestZip(t, "Prod195.zip", testSnapshot(testCompanyLine, testPersonLine), testSnapshot(testCompanyLine))
+	shards, err := SplitShards([]string{path}, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shards) != 2 || shards[0].Entry != "part1.dat" || shards[0].Start != 0 || shards[0].End != shards[0].Size || shards[0].Header != testHeaderLine {
+		t.Fatalf("expected a shard of each entry got %+v", shards)
+	}
+	var summary Summary
+	for _, s := range shards {
+		sum, err := NewReader().ExtractShard(s, func(err error) { t.Error(err) })
+		if err != nil {
+			t.Fatal(err)
+		}
+		summary.Merge(sum)
+	}
+	if err := summary.Validate(); err != nil || summary.Files != 2 || summary.Persons != 1 {
+		t.Errorf("unexpected summary %+v: %v", summary, err)
+	}
+}
+
+func Test_SplitEntryShards(t *testing.T) {
+	path := writeTestZip(t, "Prod195.zip", testSnapshot(testCompanyLine, testPersonLine), "Appointments data\n")
+	shards, err := SplitEntryShards([]string{path}, 2, EntryFilter{Exclude: []string{"part2.dat"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shards) != 1 || shards[0].Entry != "part1.dat" {
+		t.Errorf("expected a shard of the entry not excluded got %+v", shards)
+	}
+	if _, err := SplitEntryShards([]string{path}, 2, EntryFilter{Include: []string{"["}}); err == nil {
+		t.Error("expected an error for a malformed pattern")
+	}
+}
@@ -0,0 +1,42 @@
+package chapointdat
+
+type channelSink struct {
+	out  chan<- Record
+	size int
+	buf  chan Record
+	done chan struct{}
+}
+
+// WithChannelSink sends every parsed record to c, in addition to calling any
+// handlers. Up to bufferSize records are held while the consumer of c catches
+// up; once that buffer is full extraction blocks until the consumer receives
+// again. c is closed when Extract or ExtractAll returns, so a Reader with a
+// channel sink should only be used for a single extraction.
+func WithChannelSink(c chan<- Record, bufferSize int) Opt {
+	return func(r *Reader) {
+		r.sink = &channelSink{out: c, size: max(bufferSize, 0)}
+	}
+}
+
+// start starts sending to out, holding up to size records.
+func (s *channelSink) start(size int) {
+	s.buf = make(chan Record, size)
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		for rec := range s.buf {
+			s.out <- rec
+		}
+	}()
+}
+
+func (s *channelSink) send(rec Record) {
+	s.buf <- rec
+}
+
+// close waits for buffered records to be received and then closes out.
+func (s *channelSink) close() {
+	close(s.buf)
+	<-s.done
+	close(s.out)
+}
@@ -0,0 +1,38 @@
+package chapointdat
+
+import (
+	"testing"
+)
+
+func Test_ChannelSink_Backpressure(t *testing.T) {
+	path := writeTestZip(t, "a.zip", testSnapshot(testCompanyLine, testPersonLine, testPersonLine))
+	records := make(chan Record)
+	r := NewReader(WithChannelSink(records, 1))
+	done := make(chan error)
+	go func() {
+		done <- r.Extract(path, 1, func(err error) { t.Error(err) })
+	}()
+	// with an unbuffered channel and a buffer of 1 the extraction cannot have
+	// finished before anything has been received
+	select {
+	case err := <-done:
+		t.Fatalf("extraction finished without a consumer: %v", err)
+	default:
+	}
+	var kinds []RecordKind
+	for rec := range records {
+		kinds = append(kinds, rec.Kind)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	expected := []RecordKind{RecordKindHeader, RecordKindCompany, RecordKindPerson, RecordKindPerson, RecordKindFooter}
+	if len(kinds) != len(expected) {
+		t.Fatalf("expected %v got %v", expected, kinds)
+	}
+	for i := range expected {
+		if kinds[i] != expected[i] {
+			t.Errorf("expected %v got %v", expected, kinds)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package chapointdat
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SQLDialectPostgres = SQLDialect("postgres")
+	SQLDialectSQLite   = SQLDialect("sqlite")
+	SQLDialectMySQL    = SQLDialect("mysql")
+	// SQLDialectBigQuery is supported by Schema.DDL but not SQLScript.
+	SQLDialectBigQuery = SQLDialect("bigquery")
+
+	// sqlBatchSize is the number of rows per INSERT statement, kept below the
+	// 500 allowed by older versions of SQLite.
+	sqlBatchSize = 100
+)
+
+type (
+	SQLDialect string
+	// SQLScript writes a SQL script which creates companies and persons tables
+	// and loads records into them in a single transaction, for piping to psql,
+	// mysql or sqlite3. Pass its Write method to WithRecordHandler and call End
+	// once extraction has finished. Tables are created with Schema.DDL; for
+	// MySQL this is done before the transaction starts, as DDL statements
+	// commit implicitly.
+	SQLScript struct {
+		mu       sync.Mutex
+		w        *bufio.Writer
+		dialect  SQLDialect
+		replace  bool
+		started  bool
+		batches  map[string][]string
+		schemas  map[string]Schema
+		blanks   Blanks
+		startErr error
+	}
+)
+
+// NewSQLScript returns a SQLScript writing to w. If replace is true existing
+// rows are deleted in the same transaction, so that a snapshot replaces the
+// previous one atomically.
+func NewSQLScript(w io.Writer, dialect SQLDialect, replace bool) (*SQLScript, error) {
+	switch dialect {
+	case SQLDialectPostgres, SQLDialectSQLite, SQLDialectMySQL:
+	default:
+		return nil, fmt.Errorf("unsupported SQL dialect: %s", dialect)
+	}
+	return &SQLScript{
+		w:       bufio.NewWriter(w),
+		dialect: dialect,
+		replace: replace,
+		batches: map[string][]string{},
+		schemas: map[string]Schema{
+			companySchema.Name: companySchema,
+			personSchema.Name:  personSchema,
+		},
+	}, nil
+}
+
+// SetBlanks sets how blank values are written, before the first Write. Tables
+// are created with nullable columns for BlankNull.
+func (s *SQLScript) SetBlanks(b Blanks) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blanks = b
+}
+
+// Write adds company and person records to the script. Other records are
+// ignored.
+func (s *SQLScript) Write(rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch rec.Kind {
+	case RecordKindCompany:
+		return s.add(companySchema, fieldValues(*rec.Company))
+	case RecordKindPerson:
+		return s.add(personSchema, fieldValues(*rec.Person))
+	}
+	return nil
+}
+
+// End writes any remaining rows and then COMMIT if commit is true, or
+// ROLLBACK so that nothing is loaded if extraction failed.
+func (s *SQLScript) End(commit bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.begin(); err != nil {
+		return err
+	}
+	for _, table := range []string{companySchema.Name, personSchema.Name} {
+		if err := s.flush(table); err != nil {
+			return err
+		}
+	}
+	end := "COMMIT;\n"
+	if !commit {
+		end = "ROLLBACK;\n"
+	}
+	if _, err := s.w.WriteString(end); err != nil {
+		return err
+	}
+	return s.w.Flush()
+}
+
+func (s *SQLScript) add(schema Schema, values []string) error {
+	if err := s.begin(); err != nil {
+		return err
+	}
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = s.value(schema.Fields[i], v)
+	}
+	table := schema.Name
+	s.batches[table] = append(s.batches[table], "("+strings.Join(quoted, ", ")+")")
+	if len(s.batches[table]) >= sqlBatchSize {
+		return s.flush(table)
+	}
+	return nil
+}
+
+// value returns v as a literal for the column of f, with dates in ISO 8601
+// format. Dates, partial dates and integers which are blank, or not valid, are
+// NULL, as are other blank values for BlankNull.
+func (s *SQLScript) value(f SchemaField, v string) string {
+	value := s.blanks.Value(f, v)
+	switch value := value.(type) {
+	case nil:
+		return "NULL"
+	case int:
+		return strconv.Itoa(value)
+	case time.Time:
+		return sqlQuote(value.Format(time.DateOnly))
+	}
+	v = value.(string)
+	if s.dialect == SQLDialectMySQL {
+		// MySQL treats backslashes in strings as escapes by default
+		v = strings.ReplaceAll(v, `\`, `\\`)
+	}
+	return sqlQuote(v)
+}
+
+// begin creates the tables and starts the transaction, once.
+func (s *SQLScript) begin() error {
+	if s.started {
+		return s.startErr
+	}
+	s.started = true
+	var ddl, deletes strings.Builder
+	for _, table := range []string{companySchema.Name, personSchema.Name} {
+		d, err := s.schemas[table].ddl(s.dialect, s.blanks)
+		if err != nil {
+			s.startErr = err
+			return err
+		}
+		ddl.WriteString(d)
+		if s.replace {
+			fmt.Fprintf(&deletes, "DELETE FROM %s;\n", table)
+		}
+	}
+	script := "BEGIN;\n" + ddl.String() + deletes.String()
+	if s.dialect == SQLDialectMySQL {
+		script = ddl.String() + "START TRANSACTION;\n" + deletes.String()
+	}
+	_, s.startErr = s.w.WriteString(script)
+	return s.startErr
+}
+
+func (s *SQLScript) flush(table string) error {
+	rows := s.batches[table]
+	if len(rows) == 0 {
+		return nil
+	}
+	s.batches[table] = rows[:0]
+	_, err := fmt.Fprintf(s.w, "INSERT INTO %s (%s) VALUES\n%s;\n", table, strings.Join(s.schemas[table].columns(), ", "), strings.Join(rows, ",\n"))
+	return err
+}
+
+// sqlQuote quotes v as a SQL string literal.
+func sqlQuote(v string) string {
+	return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+}
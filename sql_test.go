@@ -0,0 +1,64 @@
+package chapointdat
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_SQLScript(t *testing.T) {
+	var b strings.Builder
+	script, err := NewSQLScript(&b, SQLDialectSQLite, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := strings.Replace(testCompanyLine, "A. WEST & PARTNERS<", "O'NEILL & PARTNERS<", 1)
+	r := NewReader(WithRecordHandler(script.Write))
+	if _, err := r.ExtractReader(strings.NewReader(testSnapshot(line)), "-", func(err error) { t.Error(err) }); err != nil {
+		t.Fatal(err)
+	}
+	if err := script.End(true); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"BEGIN;\nCREATE TABLE IF NOT EXISTS companies (company_number TEXT NOT NULL, company_status TEXT NOT NULL, number_of_officers INTEGER, company_name TEXT NOT NULL);\n",
+		"DELETE FROM companies;\n",
+		"INSERT INTO companies (company_number, company_status, number_of_officers, company_name) VALUES\n('00000084', 'D', 0, 'O''NEILL & PARTNERS');\n",
+		"COMMIT;\n",
+	} {
+		if !strings.Contains(b.String(), expected) {
+			t.Errorf("expected script to contain %q got %q", expected, b.String())
+		}
+	}
+	if strings.Contains(b.String(), "INSERT INTO persons") {
+		t.Error("unexpected persons insert")
+	}
+}
+
+func Test_SQLScript_MySQL(t *testing.T) {
+	var b strings.Builder
+	script, err := NewSQLScript(&b, SQLDialectMySQL, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := Person{CompanyNumber: "00463819", AppointmentDate: "19910915", PartialDateOfBirth: "194509", Surname: `A\B`}
+	if err := script.Write(Record{Kind: RecordKindPerson, Person: &p}); err != nil {
+		t.Fatal(err)
+	}
+	if err := script.End(false); err != nil {
+		t.Fatal(err)
+	}
+	// the tables are created outside the transaction, and blank dates are NULL
+	for _, expected := range []string{
+		"CREATE TABLE IF NOT EXISTS persons (company_number VARCHAR(8) NOT NULL,",
+		");\nSTART TRANSACTION;\nINSERT INTO persons",
+		"('00463819', '', '', '', '', '1991-09-15', NULL, '', '194509', NULL, '', '', 'A\\\\B',",
+		"ROLLBACK;\n",
+	} {
+		if !strings.Contains(b.String(), expected) {
+			t.Errorf("expected script to contain %q got %q", expected, b.String())
+		}
+	}
+	if _, err := NewSQLScript(&b, SQLDialectBigQuery, false); err == nil {
+		t.Error("expected an error for BigQuery")
+	}
+}
@@ -0,0 +1,187 @@
+package chapointdat
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// RepairRecordType is a leading 0 added to a line whose record type was
+	// not recognised, as the company number appears to have lost one.
+	RepairRecordType = RepairKind("record type")
+	// RepairVariableDataLength is a leading 0 added to a person line whose
+	// variable data length was not a number.
+	RepairVariableDataLength = RepairKind("variable data length")
+)
+
+// ErrInconsistentSummary is wrapped by the errors returned from
+// Summary.Validate.
+var ErrInconsistentSummary = errors.New("inconsistent summary")
+
+type (
+	RepairKind string
+
+	Summary struct {
+		// Files is the number of .dat files (zip entries) processed.
+		Files int
+		// Lines is the number of lines read, including header and trailer.
+		Lines     int
+		Companies int
+		Persons   int
+		// Custom is the number of records of types registered with
+		// WithRecordType.
+		Custom int
+		// RecordCount is the total of the record counts stated by trailers.
+		RecordCount int
+		// Trailers is the number of trailer records read.
+		Trailers int
+		// Errors is the number of lines passed to the error handler.
+		Errors int
+		// Warnings is the number of lines read despite a Warning.
+		Warnings int
+		// CharsetViolations is the number of characters passed to the
+		// WithCharsetHandler handler.
+		CharsetViolations int
+		// InvalidBytes is the number of bytes replaced or dropped under the
+		// WithInvalidBytes policy.
+		InvalidBytes int
+		// Truncated is the number of persons with Person.Truncated set.
+		Truncated int
+		// Corrections is the number of records replaced or added by
+		// WithCorrections.
+		Corrections int
+		// CP1252Lines is the number of lines decoded from Windows-1252 by
+		// WithCP1252Fallback.
+		CP1252Lines int
+		// Skipped is the number of companies and persons skipped by
+		// WithCompanyNumbers.
+		Skipped int
+		// NotIndexed is the number of company numbers given to
+		// ExtractCompanies which were not in its index.
+		NotIndexed int
+		// Statuses is the number of companies read by Company.CompanyStatus.
+		Statuses map[Status]int `json:",omitempty"`
+		// Queues are the QueueStats of the queues of WithWorkers, by kind of
+		// record, and of WithOrderedDelivery.
+		Queues map[string]QueueStats `json:",omitempty"`
+		// Repaired is the number of lines read only once repaired, by kind of
+		// repair, and RepairedByPrefix the same lines by the Prefix of their
+		// company number, with "" for companies registered in England and
+		// Wales.
+		Repaired         map[RepairKind]int `json:",omitempty"`
+		RepairedByPrefix map[Prefix]int     `json:",omitempty"`
+		Headers          []Header
+	}
+
+	// state is tracked per file while reading lines.
+	state struct {
+		i     int
+		path  string
+		entry string
+		// line is the 1 based number of the current line, offset the byte
+		// offset of its start and seq its sequence number.
+		line    int
+		offset  int64
+		seq     uint64
+		summary Summary
+		// stream is the company stream handler for the current company.
+		stream *companyStream
+		// header is the last header read.
+		header *Header
+		// shard is the Shard read, if only part of the file is.
+		shard *Shard
+		// encoding is the encoding detected by WithCP1252Fallback.
+		encoding lineEncoding
+		// filter tracks the companies of WithCompanyNumbers.
+		filter *companyFilter
+	}
+)
+
+// Merge adds the counts from o into s.
+func (s *Summary) Merge(o Summary) {
+	s.Files += o.Files
+	s.Lines += o.Lines
+	s.Companies += o.Companies
+	s.Persons += o.Persons
+	s.Custom += o.Custom
+	s.RecordCount += o.RecordCount
+	s.Trailers += o.Trailers
+	s.Errors += o.Errors
+	s.Warnings += o.Warnings
+	s.CharsetViolations += o.CharsetViolations
+	s.InvalidBytes += o.InvalidBytes
+	s.Truncated += o.Truncated
+	s.Corrections += o.Corrections
+	s.CP1252Lines += o.CP1252Lines
+	s.Skipped += o.Skipped
+	s.NotIndexed += o.NotIndexed
+	for k, n := range o.Statuses {
+		s.Statuses = addCount(s.Statuses, k, n)
+	}
+	for k, q := range o.Queues {
+		s.Queues = addQueueStats(s.Queues, k, q)
+	}
+	for k, n := range o.Repaired {
+		s.Repaired = addCount(s.Repaired, k, n)
+	}
+	for p, n := range o.RepairedByPrefix {
+		s.RepairedByPrefix = addCount(s.RepairedByPrefix, p, n)
+	}
+	s.Headers = append(s.Headers, o.Headers...)
+}
+
+// Validate checks the totals of a Summary combined across the parts of a split
+// snapshot: that every file had a header and a trailer, that the headers agree
+// on run and production date, and that the trailer record counts add up to the
+// number of records read.
+func (s Summary) Validate() error {
+	var errs []error
+	if len(s.Headers) != s.Files {
+		errs = append(errs, fmt.Errorf("%w: %d headers in %d files", ErrInconsistentSummary, len(s.Headers), s.Files))
+	}
+	if s.Trailers != s.Files {
+		errs = append(errs, fmt.Errorf("%w: %d trailers in %d files", ErrInconsistentSummary, s.Trailers, s.Files))
+	}
+	for _, h := range s.Headers {
+		if h.Run != s.Headers[0].Run || !h.ProdDate.Equal(s.Headers[0].ProdDate) {
+			errs = append(errs, fmt.Errorf("%w: headers for run %d produced %s and run %d produced %s", ErrInconsistentSummary,
+				s.Headers[0].Run, s.Headers[0].ProdDate.Format("20060102"), h.Run, h.ProdDate.Format("20060102")))
+			break
+		}
+	}
+	if records := s.Companies + s.Persons + s.Custom + s.Skipped; s.RecordCount != records {
+		errs = append(errs, fmt.Errorf("%w: trailers count %d records but %d were read", ErrInconsistentSummary, s.RecordCount, records))
+	}
+	return errors.Join(errs...)
+}
+
+// deadLetter returns a DeadLetter for the current line.
+func (st *state) deadLetter() DeadLetter {
+	return DeadLetter{Path: st.path, Entry: st.entry, Line: st.line, Offset: st.offset}
+}
+
+// repaired counts the repairs made to rec.
+func (s *Summary) repaired(rec Record) {
+	if len(rec.repairs) == 0 {
+		return
+	}
+	for _, k := range rec.repairs {
+		s.Repaired = addCount(s.Repaired, k, 1)
+	}
+	var companyNumber string
+	switch rec.Kind {
+	case RecordKindCompany:
+		companyNumber = rec.Company.CompanyNumber
+	case RecordKindPerson:
+		companyNumber = rec.Person.CompanyNumber
+	}
+	s.RepairedByPrefix = addCount(s.RepairedByPrefix, CompanyPrefix(companyNumber), 1)
+}
+
+func addCount[K comparable](m map[K]int, k K, n int) map[K]int {
+	if m == nil {
+		m = map[K]int{}
+	}
+	m[k] += n
+	return m
+}
@@ -0,0 +1,48 @@
+package chapointdat
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func Test_ExtractAll_MissingTrailer(t *testing.T) {
+	truncated := strings.TrimSuffix(testSnapshot(testPersonLine), trailerRecordIdentifier+"00000001\n")
+	a := writeTestZip(t, "a.zip", testSnapshot(testCompanyLine), truncated)
+	r := NewReader()
+	s, err := r.ExtractAll([]string{a}, 1, func(err error) { t.Error(err) })
+	if !errors.Is(err, ErrInconsistentSummary) {
+		t.Errorf("expected ErrInconsistentSummary got %v", err)
+	}
+	if s.Trailers != 1 || s.Files != 2 {
+		t.Errorf("unexpected summary %+v", s)
+	}
+}
+
+func Test_Summary_Validate_Runs(t *testing.T) {
+	a := writeTestZip(t, "a.zip", testSnapshot(testCompanyLine))
+	b := writeTestZip(t, "b.zip", strings.Replace(testSnapshot(testPersonLine), "0195", "0196", 1))
+	r := NewReader()
+	_, err := r.ExtractAll([]string{a, b}, 2, func(err error) { t.Error(err) })
+	if !errors.Is(err, ErrInconsistentSummary) || !strings.Contains(err.Error(), "run 196") {
+		t.Errorf("expected ErrInconsistentSummary for run 196 got %v", err)
+	}
+}
+
+func Test_Summary_Repaired(t *testing.T) {
+	company := "04638191C                      00140039INTERNATIONAL BEE RESEARCH ASSOCIATION<"
+	person := "04638192201024407940002        19910915        NP25 3DZ194509          0093MR<HANS<KJAERSGAARD<<<<1 AGINCOURT STREET<<MONMOUTH<<WALES<MARKETING DIRECTOR<DANISH<ENGLAND<"
+	a := writeTestZip(t, "a.zip", testSnapshot(company, person, testPersonLine))
+	b := writeTestZip(t, "b.zip", testSnapshot(company))
+	r := NewReader()
+	s, err := r.ExtractAll([]string{a, b}, 2, func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Repaired[RepairRecordType] != 2 || s.Repaired[RepairVariableDataLength] != 1 || s.RepairedByPrefix[""] != 3 {
+		t.Errorf("unexpected repairs %v %v", s.Repaired, s.RepairedByPrefix)
+	}
+	if p := CompanyPrefix("SC123456"); p != PrefixSC {
+		t.Errorf("expected SC got %q", p)
+	}
+}
@@ -0,0 +1,141 @@
+package chapointdat
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+type (
+	// Transform returns the value to pass on in place of v.
+	Transform func(v string) string
+
+	// Transforms is a registry of transforms of fields, such as hashing
+	// dates of birth or mapping nationalities, applied to every record before
+	// it is passed to handlers, sinks, exporters and loaders, so that a change
+	// of policy, such as a new redaction rule, is made in one place. It is
+	// safe for concurrent use.
+	Transforms struct {
+		mu     sync.RWMutex
+		fields map[RecordKind][]fieldTransform
+	}
+	fieldTransform struct {
+		index     int
+		transform Transform
+	}
+)
+
+// WithTransforms applies t to each record before it is passed to handlers
+// and sinks. Transforms registered after extraction has started apply to the
+// records which follow.
+func WithTransforms(t *Transforms) Opt {
+	return func(r *Reader) {
+		r.transforms = t
+	}
+}
+
+func NewTransforms() *Transforms {
+	return &Transforms{fields: map[RecordKind][]fieldTransform{}}
+}
+
+// Register adds f to the transforms of field, such as person.Postcode, named
+// as in ParseSelect. Transforms of a field are applied in the order they were
+// registered.
+func (t *Transforms) Register(field string, f Transform) error {
+	sf, err := lookupField(field)
+	if err != nil {
+		return fmt.Errorf("error registering transform: %w", err)
+	}
+	kind, _, _ := strings.Cut(field, ".")
+	if selectKinds[strings.ToLower(kind)].typ.Field(sf.index).Type.Kind() != reflect.String {
+		return fmt.Errorf("error registering transform: %s is not a string field", field)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.fields[sf.kind] = append(t.fields[sf.kind], fieldTransform{index: sf.index, transform: f})
+	return nil
+}
+
+// Apply returns rec with its fields transformed. The header, company, person
+// or footer is copied, so that the record as read is unchanged.
+func (t *Transforms) Apply(rec Record) Record {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	fields := t.fields[rec.Kind]
+	if len(fields) == 0 {
+		return rec
+	}
+	rec = rec.clone()
+	v := rec.value()
+	for _, ft := range fields {
+		f := v.Field(ft.index)
+		f.SetString(ft.transform(f.String()))
+	}
+	return rec
+}
+
+// TransformUpper returns values in upper case.
+func TransformUpper(v string) string {
+	return strings.ToUpper(v)
+}
+
+// TransformRedact returns a Transform replacing values which are not blank
+// with replacement.
+func TransformRedact(replacement string) Transform {
+	return func(v string) string {
+		if isBlank(v) {
+			return v
+		}
+		return replacement
+	}
+}
+
+// TransformHash returns a Transform replacing values which are not blank with
+// the hex encoded HMAC-SHA256 of the value with key, so that equal values can
+// still be joined without revealing them. The key must be kept secret, as
+// values such as dates of birth are few enough to be found by hashing them
+// all. Hashes are longer than the fixed widths of the Schema.
+func TransformHash(key []byte) Transform {
+	return func(v string) string {
+		if isBlank(v) {
+			return v
+		}
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(v))
+		return hex.EncodeToString(h.Sum(nil))
+	}
+}
+
+// TransformMap returns a Transform replacing values found in m with their
+// mapping. Other values are unchanged.
+func TransformMap(m map[string]string) Transform {
+	return func(v string) string {
+		if mapped, ok := m[v]; ok {
+			return mapped
+		}
+		return v
+	}
+}
+
+// clone returns rec with a copy of its header, company, person or footer.
+func (rec Record) clone() Record {
+	switch rec.Kind {
+	case RecordKindHeader:
+		h := *rec.Header
+		rec.Header = &h
+	case RecordKindCompany:
+		c := *rec.Company
+		rec.Company = &c
+	case RecordKindPerson:
+		p := *rec.Person
+		rec.Person = &p
+	case RecordKindFooter:
+		f := *rec.Footer
+		rec.Footer = &f
+	}
+	return rec
+}
@@ -0,0 +1,74 @@
+package chapointdat
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func Test_WithTransforms(t *testing.T) {
+	tr := NewTransforms()
+	for field, f := range map[string]Transform{
+		"person.PartialDateOfBirth": TransformHash([]byte("key")),
+		"person.Postcode":           TransformRedact("REDACTED"),
+		"person.AddressLine2":       TransformRedact("REDACTED"),
+		"person.nationality":        TransformMap(map[string]string{"DANISH": "DK"}),
+		"company.CompanyName":       strings.ToLower,
+	} {
+		if err := tr.Register(field, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tr.Register("company.CompanyName", TransformUpper); err != nil {
+		t.Fatal(err)
+	}
+
+	var persons, companies bytes.Buffer
+	var handled []Person
+	r := NewReader(WithTransforms(tr), WithCSVExport(&companies, &persons), WithPersonHandler(func(p Person) error {
+		handled = append(handled, p)
+		return nil
+	}))
+	if _, err := r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine, testPersonLine)), "-", func(err error) { t.Error(err) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(handled) != 1 {
+		t.Fatalf("expected 1 person got %d", len(handled))
+	}
+	p := handled[0]
+	if len(p.PartialDateOfBirth) != 64 || p.PartialDateOfBirth != TransformHash([]byte("key"))("194509") || p.PartialDateOfBirth == TransformHash([]byte("other"))("194509") {
+		t.Errorf("unexpected hash %s", p.PartialDateOfBirth)
+	}
+	// blank values are not redacted
+	if p.Postcode != "REDACTED" || p.AddressLine2 != "" || p.Nationality != "DK" || p.Surname != "KJAERSGAARD" {
+		t.Errorf("unexpected person %+v", p)
+	}
+	if !strings.Contains(persons.String(), ",REDACTED,") || strings.Contains(persons.String(), "194509") {
+		t.Errorf("expected transformed values in %s", persons.String())
+	}
+	// transforms of a field are applied in order
+	if !strings.Contains(companies.String(), "A. WEST & PARTNERS") {
+		t.Errorf("expected the company name in upper case in %s", companies.String())
+	}
+}
+
+func Test_Transforms_Apply(t *testing.T) {
+	tr := NewTransforms()
+	if err := tr.Register("person.Surname", TransformRedact("X")); err != nil {
+		t.Fatal(err)
+	}
+	p := Person{Surname: "SMITH"}
+	rec := tr.Apply(Record{Kind: RecordKindPerson, Person: &p})
+	if rec.Person.Surname != "X" || p.Surname != "SMITH" {
+		t.Errorf("expected a transformed copy got %+v and %+v", rec.Person, p)
+	}
+	c := Company{CompanyName: "ACME"}
+	if rec := tr.Apply(Record{Kind: RecordKindCompany, Company: &c}); rec.Company != &c {
+		t.Error("expected a record without transforms to be unchanged")
+	}
+	for _, field := range []string{"person.Unknown", "Surname", "header.ProdDate"} {
+		if err := tr.Register(field, TransformUpper); err == nil {
+			t.Errorf("%s: expected an error", field)
+		}
+	}
+}
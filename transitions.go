@@ -0,0 +1,97 @@
+package chapointdat
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+type (
+	// StatusTransition is a change in the status of a company between runs,
+	// such as from "" (active) to StatusL on going into liquidation.
+	StatusTransition struct {
+		CompanyNumber string
+		From, To      Status
+		// Removed is set for a company missing from the run, with To "".
+		Removed bool
+		// Run and ProdDate are of the first run with To, and PreviousRun and
+		// PreviousProdDate of the last run with From.
+		Run              int
+		ProdDate         time.Time
+		PreviousRun      int
+		PreviousProdDate time.Time
+	}
+
+	// StatusTracker follows the status of each company across runs, read in
+	// run order, calling a handler with each StatusTransition. Pass its Write
+	// method to WithRecordHandler and call EndRun once each run has been read.
+	StatusTracker struct {
+		mu       sync.Mutex
+		handler  func(t StatusTransition) error
+		previous map[string]Status
+		current  map[string]Status
+		runPair
+	}
+)
+
+// NewStatusTracker returns a StatusTracker calling h with each transition.
+func NewStatusTracker(h func(t StatusTransition) error) *StatusTracker {
+	return &StatusTracker{handler: h, current: map[string]Status{}}
+}
+
+// TrackStatusTransitions reads every run of the snapshots in dir, as
+// WalkArchive, calling h with the transitions between them. The first run read
+// is the baseline and has no transitions, and companies first appearing in a
+// later run are not transitions. opts must not include WithRecordHandler.
+func TrackStatusTransitions(dir string, h func(t StatusTransition) error, errH func(err error), opts ...Opt) error {
+	t := NewStatusTracker(h)
+	return walkRuns(dir, t.Write, t.EndRun, errH, opts)
+}
+
+// Write records the run of headers and the status of companies. Other records
+// are ignored.
+func (t *StatusTracker) Write(rec Record) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch rec.Kind {
+	case RecordKindHeader:
+		t.header(rec.Header)
+	case RecordKindCompany:
+		t.current[rec.Company.CompanyNumber] = Status(rec.Company.CompanyStatus)
+	}
+	return nil
+}
+
+// EndRun calls the handler, in company number order, with the transitions
+// from the previous run to the run written since, which becomes the previous
+// run.
+func (t *StatusTracker) EndRun() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.previous != nil {
+		var transitions []StatusTransition
+		for n, from := range t.previous {
+			to, ok := t.current[n]
+			if ok && to == from {
+				continue
+			}
+			transitions = append(transitions, StatusTransition{
+				CompanyNumber: n, From: from, To: to, Removed: !ok,
+				Run: t.run, ProdDate: t.prodDate, PreviousRun: t.previousRun, PreviousProdDate: t.previousProdDate,
+			})
+		}
+		slices.SortFunc(transitions, func(a, b StatusTransition) int {
+			return cmp.Compare(a.CompanyNumber, b.CompanyNumber)
+		})
+		for _, tr := range transitions {
+			if err := t.handler(tr); err != nil {
+				return fmt.Errorf("error processing status transition handler: %w", err)
+			}
+		}
+	}
+	t.previous, t.current = t.current, map[string]Status{}
+	t.endRun()
+	return nil
+}
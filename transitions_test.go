@@ -0,0 +1,41 @@
+package chapointdat
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_TrackStatusTransitions(t *testing.T) {
+	dir := t.TempDir()
+	other := strings.Replace(testCompanyLine, "00000084", "00000085", 1)
+	for name, companies := range map[string][]string{
+		"Prod195_0001.dat": {testCompanyLine, other},
+		"Prod195_0002.dat": {strings.Replace(testCompanyLine, "1D", "1L", 1), other},
+		"Prod195_0003.dat": {strings.Replace(testCompanyLine, "1D", "1L", 1)},
+	} {
+		run := strings.TrimSuffix(strings.TrimPrefix(name, "Prod195_"), ".dat")
+		content := strings.Replace(testSnapshot(companies...), "0195", run, 1)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var transitions []StatusTransition
+	err := TrackStatusTransitions(dir, func(tr StatusTransition) error {
+		transitions = append(transitions, tr)
+		return nil
+	}, func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transitions) != 2 {
+		t.Fatalf("unexpected transitions %+v", transitions)
+	}
+	if tr := transitions[0]; tr.CompanyNumber != "00000084" || tr.From != StatusD || tr.To != StatusL || tr.Run != 2 || tr.PreviousRun != 1 || tr.Removed {
+		t.Errorf("unexpected transition %+v", tr)
+	}
+	if tr := transitions[1]; tr.CompanyNumber != "00000085" || !tr.Removed || tr.To != "" || tr.Run != 3 || tr.PreviousRun != 2 {
+		t.Errorf("unexpected transition %+v", tr)
+	}
+}
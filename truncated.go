@@ -0,0 +1,47 @@
+package chapointdat
+
+import "unicode/utf8"
+
+// variableDataLimit is the maximum length of the variable data of a person row
+// in the specification, and personFieldLimits the maximum lengths of its
+// fields, in order. Data reaching a limit is likely to have been cut short by
+// the product rather than to end there.
+const variableDataLimit = 1125
+
+var personFieldLimits = [variableDataFields]int{
+	50,  // Title
+	50,  // Forenames
+	160, // Surname
+	50,  // Honours
+	100, // CareOf
+	10,  // PoBox
+	250, // AddressLine1
+	50,  // AddressLine2
+	50,  // PostTown
+	50,  // County
+	50,  // Country
+	100, // Occupation
+	50,  // Nationality
+	50,  // ResCountry
+}
+
+// truncated reports whether the variable data of a person row, of declared
+// length and read into fields with issues, may be incomplete: the declared
+// length or a field reaches its limit, or the data ends before its last
+// terminator.
+func truncated(fields []*string, declared int, issues []VariableDataIssue) bool {
+	if declared >= variableDataLimit {
+		return true
+	}
+	for _, issue := range issues {
+		if issue == VariableDataOverrun || issue == VariableDataUnterminated {
+			return true
+		}
+	}
+	for i, f := range fields {
+		if utf8.RuneCountInString(*f) >= personFieldLimits[i] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package chapointdat
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func Test_Person_Truncated(t *testing.T) {
+	// a person line with its variable data replaced
+	line := func(data string) string {
+		return fmt.Sprintf("%s%04d%s", testPersonLine[:72], len(data), data)
+	}
+	long := line("MR<HANS<KJAERSGAARD<<<<" + strings.Repeat("A", 250) + "<<MONMOUTH<<WALES<MARKETING DIRECTOR<DANISH<ENGLAND<")
+	unterminated := line("MR<HANS<KJAERSGAARD<<<<1 AGINCOURT STREET<<MONMOUTH<<WALES<MARKETING DIRECTOR<DANISH<ENG")
+
+	var persons []Person
+	sel, err := ParseSelect("person.Truncated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var selected int
+	r := NewReader(WithPersonHandler(func(p Person) error {
+		persons = append(persons, p)
+		if sel(Record{Kind: RecordKindPerson, Person: &p}) {
+			selected++
+		}
+		return nil
+	}))
+	s, err := r.ExtractReader(strings.NewReader(testSnapshot(testPersonLine, long, unterminated)), "-", func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(persons) != 3 {
+		t.Fatalf("expected 3 persons got %d", len(persons))
+	}
+	if persons[0].Truncated || !persons[1].Truncated || !persons[2].Truncated {
+		t.Errorf("unexpected truncation %v %v %v", persons[0].Truncated, persons[1].Truncated, persons[2].Truncated)
+	}
+	if s.Truncated != 2 || selected != 2 {
+		t.Errorf("expected 2 truncated persons got %d and %d selected", s.Truncated, selected)
+	}
+	if f := (Record{Kind: RecordKindPerson, Person: &persons[1]}).Fields(); f["Truncated"] != "true" {
+		t.Errorf("expected a truncated field got %q", f["Truncated"])
+	}
+}
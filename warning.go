@@ -0,0 +1,98 @@
+package chapointdat
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// VariableDataOverrun is a declared length running past the end of the
+	// line. The fields are read from the data there is.
+	VariableDataOverrun = VariableDataIssue("overrun")
+	// VariableDataUnterminated is declared data not ending with "<", so that
+	// the last field read may have been cut short.
+	VariableDataUnterminated = VariableDataIssue("unterminated")
+	// VariableDataFieldCount is declared data with more or fewer than the 14
+	// fields of the specification.
+	VariableDataFieldCount = VariableDataIssue("field count")
+	// VariableDataTrailing is data after the declared length, such as part of
+	// another record run on to the line.
+	VariableDataTrailing = VariableDataIssue("trailing data")
+
+	variableDataFields = 14
+)
+
+// ErrVariableData matches, with errors.Is, a VariableDataWarning.
+var ErrVariableData = errors.New("invalid variable data")
+
+type (
+	// Warning is a problem with a line which was still read, passed to the
+	// WithWarningHandler handler before the record is delivered.
+	Warning struct {
+		Path  string
+		Entry string
+		Line  int
+		// Offset is the byte offset of the start of the line within Entry.
+		Offset int64
+		// Err is the warning, such as a *VariableDataWarning.
+		Err error
+	}
+
+	VariableDataIssue string
+
+	// VariableDataWarning describes a person row whose variable data does not
+	// match its declared length.
+	VariableDataWarning struct {
+		CompanyNumber  string
+		PersonNumber   string
+		Issues         []VariableDataIssue
+		DeclaredLength int
+		// Fields is the number of "<" terminated fields in the declared data.
+		Fields int
+		// Data is the bytes of the row from the start of the variable data.
+		Data []byte
+	}
+)
+
+// WithWarningHandler calls h with every Warning. Warnings are counted in
+// Summary.Warnings whether or not there is a handler. An error returned by h
+// is handled as for any other handler, and the record is not delivered.
+func WithWarningHandler(h func(w Warning) error) Opt {
+	return func(r *Reader) {
+		r.warningHandler = h
+	}
+}
+
+func (r *Reader) warn(err error, st *state) error {
+	st.summary.Warnings++
+	if r.warningHandler == nil {
+		return nil
+	}
+	w := Warning{Path: st.path, Entry: st.entry, Line: st.line, Offset: st.offset, Err: err}
+	if err := r.retry(func() error { return r.warningHandler(w) }); err != nil {
+		return fmt.Errorf("error processing warning handler: %w", err)
+	}
+	return nil
+}
+
+func (w Warning) Error() string {
+	return fmt.Sprintf("warning: %s line %d: %s", w.Entry, w.Line, w.Err)
+}
+
+func (w Warning) Unwrap() error {
+	return w.Err
+}
+
+func (e *VariableDataWarning) Error() string {
+	issues := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		issues[i] = string(issue)
+	}
+	return fmt.Sprintf("person %s of company %s variable data length %d with %d fields: %s",
+		e.PersonNumber, e.CompanyNumber, e.DeclaredLength, e.Fields, strings.Join(issues, ", "))
+}
+
+func (e *VariableDataWarning) Is(target error) bool {
+	return target == ErrVariableData
+}
@@ -0,0 +1,70 @@
+package chapointdat
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func Test_Person_VariableDataWarnings(t *testing.T) {
+	prefix, data, _ := strings.Cut(testPersonLine, "0093")
+	for _, tc := range []struct {
+		line     string
+		issues   []VariableDataIssue
+		surname  string
+		resCount string
+	}{
+		{testPersonLine, nil, "KJAERSGAARD", "ENGLAND"},
+		{prefix + "0120" + data, []VariableDataIssue{VariableDataOverrun}, "KJAERSGAARD", "ENGLAND"},
+		{prefix + "0090" + data, []VariableDataIssue{VariableDataUnterminated, VariableDataTrailing, VariableDataFieldCount}, "KJAERSGAARD", ""},
+		{prefix + "0093" + data + "00463819", []VariableDataIssue{VariableDataTrailing}, "KJAERSGAARD", "ENGLAND"},
+		{prefix + "0094" + "<" + data, []VariableDataIssue{VariableDataFieldCount}, "HANS", "DANISH"},
+	} {
+		var warnings []Warning
+		r := NewReader(WithWarningHandler(func(w Warning) error {
+			warnings = append(warnings, w)
+			return nil
+		}))
+		var persons []Person
+		r.personHandler = func(p Person) error {
+			persons = append(persons, p)
+			return nil
+		}
+		s, err := r.ExtractReader(strings.NewReader(testSnapshot(tc.line)), "-", func(err error) { t.Error(err) })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(persons) != 1 {
+			t.Fatalf("expected the person to be read from %q", tc.line)
+		}
+		if persons[0].Surname != tc.surname || persons[0].ResCountry != tc.resCount {
+			t.Errorf("unexpected person %+v from %q", persons[0], tc.line)
+		}
+		if s.Warnings != len(warnings) || len(warnings) != min(len(tc.issues), 1) {
+			t.Fatalf("expected %d warnings got %v with summary %d", min(len(tc.issues), 1), warnings, s.Warnings)
+		}
+		if len(tc.issues) == 0 {
+			continue
+		}
+		var w *VariableDataWarning
+		if !errors.As(warnings[0], &w) || !errors.Is(warnings[0], ErrVariableData) || warnings[0].Line != 2 {
+			t.Fatalf("unexpected warning %v", warnings[0])
+		}
+		if !slices.Equal(w.Issues, tc.issues) || w.PersonNumber != "024407940002" {
+			t.Errorf("expected issues %v got %v", tc.issues, w)
+		}
+	}
+}
+
+func Test_Parse_ShortLines(t *testing.T) {
+	r := NewReader()
+	for _, line := range []string{"", "0", "99999999", "999999990", "00000084", "000000842", "000000841D"} {
+		if _, err := r.parse([]byte(line), false); err == nil {
+			t.Errorf("expected an error for %q", line)
+		}
+	}
+	if _, err := r.parse([]byte("DDDDSNAP"), true); err == nil {
+		t.Error("expected an error for a short header")
+	}
+}
@@ -0,0 +1,237 @@
+package chapointdat
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// WebhookSignatureHeader carries SignWebhook of the timestamp and body,
+	// prefixed with sha256=.
+	WebhookSignatureHeader = "X-Chapointdat-Signature-256"
+	// WebhookTimestampHeader carries the Unix time at which a batch was first
+	// sent, so that receivers can reject replayed requests.
+	WebhookTimestampHeader = "X-Chapointdat-Timestamp"
+	// WebhookDeliveryHeader carries an ID which is the same for every attempt
+	// at a batch, so that receivers can ignore duplicates: a random prefix for
+	// the sink followed by a sequence number, so that IDs are unique across
+	// sinks and runs.
+	WebhookDeliveryHeader = "X-Chapointdat-Delivery"
+
+	defaultWebhookBatchSize = 100
+)
+
+type (
+	WebhookConfig struct {
+		URL string
+		// Secret is the key of the HMAC-SHA256 signature of each request. No
+		// signature is sent if it is empty.
+		Secret []byte
+		// Client defaults to http.DefaultClient.
+		Client *http.Client
+		// Header is added to every request, for example for authorization.
+		Header http.Header
+		// BatchSize is the number of records per request, 100 by default.
+		BatchSize int
+		// Filter selects the records sent. By default companies and persons
+		// are sent.
+		Filter func(rec Record) bool
+		// Attempts is the number of further attempts made at a batch after a
+		// network error, 429 or 5xx response, waiting Backoff before the first
+		// and doubling the wait each time after. A Retry-After header in
+		// seconds is used instead when longer.
+		Attempts int
+		Backoff  time.Duration
+		// Now returns the time sent in the timestamp header, by default the
+		// clock of the Reader for WithWebhook, or else time.Now.
+		Now func() time.Time
+	}
+
+	// WebhookSink POSTs batches of records to a webhook as a JSON object with
+	// a records array.
+	WebhookSink struct {
+		mu    sync.Mutex
+		cfg   WebhookConfig
+		batch []Record
+		// pending is a batch which has not been accepted, sent again before
+		// any other.
+		pending    *webhookDelivery
+		run        string
+		deliveries int
+		now        func() time.Time
+		sleep      func(time.Duration)
+	}
+
+	webhookDelivery struct {
+		id, timestamp string
+		body          []byte
+		records       int
+	}
+
+	// WebhookError is returned for a batch which was not accepted.
+	WebhookError struct {
+		StatusCode int
+		Body       string
+	}
+)
+
+// WithWebhook sends records to a WebhookSink for cfg, sending any remaining
+// records when extraction finishes.
+func WithWebhook(cfg WebhookConfig) Opt {
+	return func(r *Reader) {
+		cfg := cfg
+		if cfg.Now == nil {
+			// r.now is read when sending, so that WithClock may come later
+			cfg.Now = func() time.Time { return r.now() }
+		}
+		s := NewWebhookSink(cfg)
+		r.sinks = append(r.sinks, s.Write)
+		r.flushers = append(r.flushers, s.Flush)
+	}
+}
+
+// NewWebhookSink returns a WebhookSink for cfg. Pass its Write method to
+// WithRecordHandler and call Flush when done, or use WithWebhook.
+func NewWebhookSink(cfg WebhookConfig) *WebhookSink {
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultWebhookBatchSize
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	if cfg.Filter == nil {
+		cfg.Filter = func(rec Record) bool {
+			return rec.Kind == RecordKindCompany || rec.Kind == RecordKindPerson
+		}
+	}
+	run := make([]byte, 8)
+	_, _ = rand.Read(run)
+	return &WebhookSink{cfg: cfg, run: hex.EncodeToString(run), now: cfg.Now, sleep: time.Sleep}
+}
+
+// SignWebhook returns the hex encoded HMAC-SHA256 of timestamp, a full stop
+// and body, for receivers to compare with the signature header using
+// hmac.Equal.
+func SignWebhook(secret []byte, timestamp string, body []byte) string {
+	m := hmac.New(sha256.New, secret)
+	m.Write([]byte(timestamp))
+	m.Write([]byte{'.'})
+	m.Write(body)
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// Write adds rec to the batch if it is selected by the filter, sending the
+// batch once it is full.
+func (s *WebhookSink) Write(rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.cfg.Filter(rec) {
+		return nil
+	}
+	s.batch = append(s.batch, rec)
+	if len(s.batch) >= s.cfg.BatchSize {
+		return s.flush()
+	}
+	return nil
+}
+
+// Flush sends any records in the current batch. A batch which is not
+// accepted is kept, and sent again with the same delivery ID before any other
+// by the next Write or Flush.
+func (s *WebhookSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush()
+}
+
+func (s *WebhookSink) flush() error {
+	for s.pending != nil || len(s.batch) > 0 {
+		if s.pending == nil {
+			body, err := json.Marshal(struct {
+				Records []Record `json:"records"`
+			}{s.batch})
+			if err != nil {
+				return err
+			}
+			s.deliveries++
+			s.pending = &webhookDelivery{
+				id:        s.run + "-" + strconv.Itoa(s.deliveries),
+				timestamp: strconv.FormatInt(s.now().Unix(), 10),
+				body:      body,
+				records:   len(s.batch),
+			}
+			s.batch = s.batch[:0]
+		}
+		if err := s.send(s.pending); err != nil {
+			return err
+		}
+		s.pending = nil
+	}
+	return nil
+}
+
+// send posts d, retrying as configured.
+func (s *WebhookSink) send(d *webhookDelivery) error {
+	wait := s.cfg.Backoff
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := s.post(d.body, d.id, d.timestamp)
+		if err == nil {
+			return nil
+		}
+		if attempt >= s.cfg.Attempts || retryAfter < 0 {
+			return fmt.Errorf("error sending webhook delivery %s of %d records: %w", d.id, d.records, err)
+		}
+		s.sleep(max(wait, retryAfter))
+		wait *= 2
+	}
+}
+
+// post sends body once, returning the Retry-After wait, or -1 if the error is
+// not worth retrying.
+func (s *WebhookSink) post(body []byte, delivery, timestamp string) (time.Duration, error) {
+	req, err := http.NewRequest(http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return -1, err
+	}
+	for k, v := range s.cfg.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookDeliveryHeader, delivery)
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	if len(s.cfg.Secret) > 0 {
+		req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhook(s.cfg.Secret, timestamp, body))
+	}
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return 0, nil
+	}
+	err = &WebhookError{StatusCode: resp.StatusCode, Body: string(b)}
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+		return -1, err
+	}
+	seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+	return time.Duration(max(seconds, 0)) * time.Second, err
+}
+
+func (e *WebhookError) Error() string {
+	return fmt.Sprintf("webhook returned %d: %s", e.StatusCode, e.Body)
+}
@@ -0,0 +1,157 @@
+package chapointdat
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_WebhookSink(t *testing.T) {
+	var mu sync.Mutex
+	var deliveries []string
+	var batches [][]Record
+	failures := 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ := io.ReadAll(r.Body)
+		expected := "sha256=" + SignWebhook([]byte("secret"), r.Header.Get(WebhookTimestampHeader), body)
+		if !hmac.Equal([]byte(r.Header.Get(WebhookSignatureHeader)), []byte(expected)) {
+			t.Errorf("expected signature %q got %q", expected, r.Header.Get(WebhookSignatureHeader))
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("expected configured header got %v", r.Header)
+		}
+		deliveries = append(deliveries, r.Header.Get(WebhookDeliveryHeader))
+		if failures > 0 {
+			failures--
+			w.Header().Set("Retry-After", "2")
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		var v struct {
+			Records []Record `json:"records"`
+		}
+		if err := json.Unmarshal(body, &v); err != nil {
+			t.Error(err)
+		}
+		batches = append(batches, v.Records)
+	}))
+	defer srv.Close()
+
+	s := NewWebhookSink(WebhookConfig{
+		URL:       srv.URL,
+		Secret:    []byte("secret"),
+		Header:    http.Header{"Authorization": {"Bearer token"}},
+		BatchSize: 2,
+		Attempts:  2,
+		Backoff:   time.Second,
+	})
+	var slept []time.Duration
+	s.sleep = func(d time.Duration) { slept = append(slept, d) }
+	r := NewReader(WithRecordHandler(s.Write))
+	if _, err := r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine, testPersonLine, testPersonLine)), "-", func(err error) { t.Error(err) }); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if expected := s.run + "-1," + s.run + "-1," + s.run + "-2"; strings.Join(deliveries, ",") != expected {
+		t.Errorf("expected deliveries %s got %v", expected, deliveries)
+	}
+	if len(s.run) != 16 || NewWebhookSink(WebhookConfig{}).run == s.run {
+		t.Errorf("expected a random delivery prefix per sink got %q", s.run)
+	}
+	if len(slept) != 1 || slept[0] != 2*time.Second {
+		t.Errorf("expected to wait for Retry-After got %v", slept)
+	}
+	if len(batches) != 2 || len(batches[0]) != 2 || len(batches[1]) != 1 {
+		t.Fatalf("expected batches of 2 and 1 got %v", batches)
+	}
+	if batches[0][0].Kind != RecordKindCompany || batches[1][0].Person.Surname != "KJAERSGAARD" {
+		t.Errorf("unexpected records %v", batches)
+	}
+}
+
+func Test_WebhookSink_Rejected(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, "bad request", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+	s := NewWebhookSink(WebhookConfig{URL: srv.URL, Attempts: 3})
+	if err := s.Write(Record{Kind: RecordKindCompany, Company: &Company{CompanyNumber: "00000084"}}); err != nil {
+		t.Fatal(err)
+	}
+	err := s.Flush()
+	var we *WebhookError
+	if !errors.As(err, &we) || we.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected a WebhookError got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected a 400 not to be retried, got %d calls", calls)
+	}
+}
+
+func Test_WebhookSink_KeepsBatch(t *testing.T) {
+	var deliveries []string
+	var batches [][]Record
+	fail := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deliveries = append(deliveries, r.Header.Get(WebhookDeliveryHeader))
+		if fail {
+			http.Error(w, "down", http.StatusBadGateway)
+			return
+		}
+		var v struct {
+			Records []Record `json:"records"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+			t.Error(err)
+		}
+		batches = append(batches, v.Records)
+	}))
+	defer srv.Close()
+	s := NewWebhookSink(WebhookConfig{URL: srv.URL, BatchSize: 2})
+	for _, n := range []string{"00000001", "00000002", "00000003"} {
+		if err := s.Write(Record{Kind: RecordKindCompany, Company: &Company{CompanyNumber: n}}); err != nil && n != "00000002" {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Flush(); err == nil || !strings.Contains(err.Error(), "of 2 records") {
+		t.Fatalf("expected the pending batch of 2 records to fail got %v", err)
+	}
+	fail = false
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if expected := s.run + "-1," + s.run + "-1," + s.run + "-1," + s.run + "-2"; strings.Join(deliveries, ",") != expected {
+		t.Errorf("expected deliveries %s got %v", expected, deliveries)
+	}
+	if len(batches) != 2 || len(batches[0]) != 2 || len(batches[1]) != 1 || batches[1][0].Company.CompanyNumber != "00000003" {
+		t.Errorf("expected the kept batch and then the last got %v", batches)
+	}
+}
+
+func Test_WithWebhook_Clock(t *testing.T) {
+	var timestamps []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timestamps = append(timestamps, r.Header.Get(WebhookTimestampHeader))
+	}))
+	defer srv.Close()
+	r := NewReader(WithWebhook(WebhookConfig{URL: srv.URL}), WithClock(FixedClock(time.Unix(1700000000, 0))))
+	if _, err := r.ExtractReader(strings.NewReader(testSnapshot(testCompanyLine)), "-", func(err error) { t.Error(err) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(timestamps) != 1 || timestamps[0] != "1700000000" {
+		t.Errorf("expected the timestamp of the Reader's clock got %v", timestamps)
+	}
+}
@@ -0,0 +1,80 @@
+package chapointdat
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+type (
+	workerPool struct {
+		jobs    chan job
+		wg      sync.WaitGroup
+		errors  atomic.Int64
+		metrics queueMetrics
+	}
+	job struct {
+		rec  Record
+		line []byte
+		at   DeadLetter
+	}
+)
+
+// WithWorkers hands records of kind to a pool of n goroutines which call the
+// handler and sinks, instead of calling them from the goroutine reading the
+// file. Person records far outnumber companies, so WithWorkers(RecordKindPerson,
+// 8) alongside WithWorkers(RecordKindCompany, 1) for example keeps heavier
+// company handlers from holding up reading. Handlers for a kind with more than
+// one worker must be safe for concurrent use, and records of that kind are no
+// longer handled in file order.
+func WithWorkers(kind RecordKind, n int) Opt {
+	return func(r *Reader) {
+		if r.workerCounts == nil {
+			r.workerCounts = map[RecordKind]int{}
+		}
+		r.workerCounts[kind] = n
+	}
+}
+
+func (r *Reader) startWorkers(errH func(err error)) {
+	r.workers = map[RecordKind]*workerPool{}
+	for kind, n := range r.workerCounts {
+		if n < 1 {
+			continue
+		}
+		w := &workerPool{jobs: make(chan job, r.queueCapacity(n))}
+		w.metrics.capacity = cap(w.jobs)
+		for range n {
+			w.wg.Add(1)
+			go func() {
+				defer w.wg.Done()
+				for j, ok := receive(&w.metrics, w.jobs); ok; j, ok = receive(&w.metrics, w.jobs) {
+					if err := r.deliver(j.rec); err != nil {
+						w.errors.Add(1)
+						r.reportError(j.at, j.line, err, errH)
+					}
+				}
+			}()
+		}
+		r.workers[kind] = w
+	}
+}
+
+// stopWorkers waits for queued records to be handled, returning the number
+// that failed and the QueueStats of each pool.
+func (r *Reader) stopWorkers() (int, map[string]QueueStats) {
+	var errors int
+	var stats map[string]QueueStats
+	for kind, w := range r.workers {
+		close(w.jobs)
+		w.wg.Wait()
+		errors += int(w.errors.Load())
+		stats = addQueueStats(stats, kind.String()+" workers", w.metrics.stats())
+	}
+	r.workers = nil
+	return errors, stats
+}
+
+func (w *workerPool) queue(rec Record, line []byte, at DeadLetter) {
+	// the scanner reuses line once the next one is read
+	send(&w.metrics, w.jobs, job{rec: rec, line: append([]byte(nil), line...), at: at})
+}
@@ -0,0 +1,41 @@
+package chapointdat
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func Test_Workers_PerKind(t *testing.T) {
+	var b strings.Builder
+	if err := GenerateSnapshot(&b, 200, 1); err != nil {
+		t.Fatal(err)
+	}
+	var companies, persons atomic.Int64
+	var errs atomic.Int64
+	r := NewReader(
+		WithWorkers(RecordKindCompany, 1),
+		WithWorkers(RecordKindPerson, 4),
+		WithCompanyHandler(func(c Company) error {
+			companies.Add(1)
+			return nil
+		}),
+		WithPersonHandler(func(p Person) error {
+			if persons.Add(1)%10 == 0 {
+				return errors.New("every tenth person fails")
+			}
+			return nil
+		}),
+	)
+	s, err := r.ExtractReader(strings.NewReader(b.String()), "-", func(err error) { errs.Add(1) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int(companies.Load()) != s.Companies || int(persons.Load()) != s.Persons {
+		t.Errorf("handled %d companies and %d persons for summary %+v", companies.Load(), persons.Load(), s)
+	}
+	if s.Errors != s.Persons/10 || int(errs.Load()) != s.Errors {
+		t.Errorf("expected %d errors got %d in summary and %d handled", s.Persons/10, s.Errors, errs.Load())
+	}
+}